@@ -1,61 +1,68 @@
 package logger
 
 import (
-	"encoding/json"
-	"os"
-	"path/filepath"
-	"sync"
+	"GradGuard/internal/analyzer"
+	"GradGuard/internal/attack"
+	"GradGuard/internal/events"
 	"time"
 )
 
-var mu sync.Mutex
-
 type CommandEvent struct {
-	Timestamp      string `json:"timestamp"`
-	SessionID      string `json:"session"`
-	RemoteAddr     string `json:"remote_addr"`
-	Command        string `json:"command"`
-	DelayMs        int64  `json:"delay_ms"`
-	CommandIndex   int    `json:"command_index"`
-	Category       string `json:"category"`
-	SuspicionScore int    `json:"suspicion_score"`
-	Reason         string `json:"reason"`
+	Timestamp      string       `json:"timestamp"`
+	SessionID      string       `json:"session"`
+	RemoteAddr     string       `json:"remote_addr"`
+	Command        string       `json:"command"`
+	DelayMs        int64        `json:"delay_ms"`
+	CommandIndex   int          `json:"command_index"`
+	Category       string       `json:"category"`
+	SuspicionScore int          `json:"suspicion_score"`
+	Reason         string       `json:"reason"`
+	Techniques     []attack.Tag `json:"techniques,omitempty"`
+	// Rules are the IDs of the rules the command matched.
+	Rules []string `json:"rules,omitempty"`
+	// Decoded holds each layer of encoding peeled off the command.
+	Decoded []analyzer.DecodedPayload `json:"decoded,omitempty"`
 }
 
-func LogCommand(
-	sessionID string,
-	remoteAddr string,
-	cmd string,
-	delayMs int64,
-	index int,
-	category string,
-	suspicionScore int,
-	reason string,
-) {
-	mu.Lock()
-	defer mu.Unlock()
-
-	dir := "logs/sessions"
-	_ = os.MkdirAll(dir, 0755)
-
-	path := filepath.Join(dir, sessionID+".json")
-	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
-		return
-	}
-	defer file.Close()
+// CommandEntry is one line of a session's command log, or a bracketed
+// marker of something the honeypot did; LogCommand stamps it with the
+// time.
+type CommandEntry struct {
+	SessionID      string
+	RemoteAddr     string
+	Command        string
+	DelayMs        int64
+	Index          int
+	Category       string
+	SuspicionScore int
+	Reason         string
+	Techniques     []attack.Tag
+	Rules          []string
+	Decoded        []analyzer.DecodedPayload
+}
 
+func LogCommand(e CommandEntry) {
+	now := time.Now().UTC()
 	event := CommandEvent{
-		Timestamp:      time.Now().UTC().Format(time.RFC3339),
-		SessionID:      sessionID,
-		RemoteAddr:     remoteAddr,
-		Command:        cmd,
-		DelayMs:        delayMs,
-		CommandIndex:   index,
-		Category:       category,
-		SuspicionScore: suspicionScore,
-		Reason:         reason,
+		Timestamp:      now.Format(time.RFC3339),
+		SessionID:      e.SessionID,
+		RemoteAddr:     e.RemoteAddr,
+		Command:        e.Command,
+		DelayMs:        e.DelayMs,
+		CommandIndex:   e.Index,
+		Category:       e.Category,
+		SuspicionScore: e.SuspicionScore,
+		Reason:         e.Reason,
+		Techniques:     e.Techniques,
+		Rules:          e.Rules,
+		Decoded:        e.Decoded,
 	}
 
-	json.NewEncoder(file).Encode(event)
+	events.Publish(events.Event{
+		Kind:       events.KindCommand,
+		Time:       now,
+		SessionID:  e.SessionID,
+		RemoteAddr: e.RemoteAddr,
+		Data:       event,
+	})
 }
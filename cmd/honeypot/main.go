@@ -5,6 +5,8 @@ import (
 	sshserver "GradGuard/internal/sshserver"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 )
 
 func main() {
@@ -16,23 +18,219 @@ func main() {
 	switch os.Args[1] {
 	case "analyze":
 		sessionID := ""
+		matrix := false
 		for i, arg := range os.Args[2:] {
 			if arg == "--session" && i+1 < len(os.Args[2:]) {
 				sessionID = os.Args[i+3]
 			}
+			if arg == "--attack" {
+				matrix = true
+			}
 		}
-		if sessionID != "" {
+		if matrix {
+			cli.ShowAttackMatrix()
+		} else if sessionID != "" {
 			cli.ShowSession(sessionID)
 		} else {
 			cli.ShowSummary()
 		}
 
+	case "rules":
+		ok := false
+		switch {
+		case len(os.Args) == 3 && os.Args[2] == "corpus":
+			ok = cli.CheckCorpus()
+		case len(os.Args) >= 4 && os.Args[2] == "test":
+			ok = cli.TestRules(strings.Join(os.Args[3:], " "))
+		default:
+			usage()
+		}
+		if !ok {
+			os.Exit(1)
+		}
+
+	case "response":
+		if len(os.Args) != 4 || os.Args[2] != "plan" {
+			usage()
+		}
+		if !cli.PlanResponse(os.Args[3]) {
+			os.Exit(1)
+		}
+
+	case "logs":
+		if len(os.Args) < 3 {
+			usage()
+		}
+		flags := map[string]int{"--older-than-hours": -1, "--max-age-days": -1, "--max-total-mb": -1}
+		args := os.Args[3:]
+		for i := 0; i < len(args); i++ {
+			if _, ok := flags[args[i]]; !ok || i+1 == len(args) {
+				usage()
+			}
+			n, err := strconv.Atoi(args[i+1])
+			if err != nil || n < 0 {
+				usage()
+			}
+			flags[args[i]] = n
+			i++
+		}
+		ok := false
+		switch os.Args[2] {
+		case "archive":
+			ok = cli.ArchiveLogs(flags["--older-than-hours"])
+		case "prune":
+			ok = cli.PruneLogs(flags["--max-age-days"], flags["--max-total-mb"])
+		default:
+			usage()
+		}
+		if !ok {
+			os.Exit(1)
+		}
+
+	case "verify":
+		if len(os.Args) != 4 || os.Args[2] != "--session" {
+			usage()
+		}
+		if !cli.VerifySession(os.Args[3]) {
+			os.Exit(1)
+		}
+
+	case "export":
+		if len(os.Args) >= 3 && os.Args[2] == "intel" {
+			if !cli.ExportIntel(os.Args[3:]) {
+				os.Exit(1)
+			}
+			return
+		}
+		if len(os.Args) < 5 || os.Args[2] != "evidence" || os.Args[3] != "--session" {
+			usage()
+		}
+		out := ""
+		switch {
+		case len(os.Args) == 7 && os.Args[5] == "--out":
+			out = os.Args[6]
+		case len(os.Args) != 5:
+			usage()
+		}
+		if !cli.ExportEvidence(os.Args[4], out) {
+			os.Exit(1)
+		}
+
+	case "siem":
+		ok := false
+		switch {
+		case len(os.Args) == 5 && os.Args[2] == "receive":
+			ok = cli.ReceiveSIEM(os.Args[3], os.Args[4], "")
+		case len(os.Args) == 7 && os.Args[2] == "receive" && os.Args[5] == "--cert":
+			ok = cli.ReceiveSIEM(os.Args[3], os.Args[4], os.Args[6])
+		case len(os.Args) >= 3 && os.Args[2] == "replay":
+			ok = cli.ReplaySIEM(os.Args[3:])
+		default:
+			usage()
+		}
+		if !ok {
+			os.Exit(1)
+		}
+
+	case "taxii":
+		addr := ""
+		switch {
+		case len(os.Args) == 3 && os.Args[2] == "serve":
+		case len(os.Args) == 5 && os.Args[2] == "serve" && os.Args[3] == "--listen":
+			addr = os.Args[4]
+		default:
+			usage()
+		}
+		if !cli.ServeTAXII(addr) {
+			os.Exit(1)
+		}
+
+	case "watch":
+		ok := false
+		switch {
+		case len(os.Args) == 3 && os.Args[2] == "sessions":
+			ok = cli.WatchSessions()
+		case len(os.Args) == 3 && os.Args[2] == "attach":
+			ok = cli.WatchAttach("")
+		case len(os.Args) == 4 && os.Args[2] == "attach":
+			ok = cli.WatchAttach(os.Args[3])
+		default:
+			ok = cli.Watch(os.Args[2:])
+		}
+		if !ok {
+			os.Exit(1)
+		}
+
+	case "alerts":
+		ok := false
+		switch {
+		case len(os.Args) >= 3 && os.Args[2] == "test":
+			ok = cli.TestAlerts(os.Args[3:])
+		case len(os.Args) == 5 && os.Args[2] == "receive":
+			ok = cli.ReceiveAlerts(os.Args[3], os.Args[4])
+		default:
+			usage()
+		}
+		if !ok {
+			os.Exit(1)
+		}
+
+	case "store":
+		ok := false
+		switch {
+		case len(os.Args) == 3 && os.Args[2] == "import":
+			ok = cli.ImportStore()
+		case len(os.Args) >= 3 && os.Args[2] == "query":
+			ok = cli.QueryStore(os.Args[3:])
+		default:
+			usage()
+		}
+		if !ok {
+			os.Exit(1)
+		}
+
 	default:
 		fmt.Fprintf(os.Stderr, "unknown command: %s\n", os.Args[1])
-		fmt.Fprintf(os.Stderr, "usage:\n")
-		fmt.Fprintf(os.Stderr, "  honeypot                          start the honeypot\n")
-		fmt.Fprintf(os.Stderr, "  honeypot analyze                  show all sessions summary\n")
-		fmt.Fprintf(os.Stderr, "  honeypot analyze --session ID     show full session detail\n")
-		os.Exit(1)
+		usage()
 	}
 }
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage:\n")
+	fmt.Fprintf(os.Stderr, "  honeypot                          start the honeypot\n")
+	fmt.Fprintf(os.Stderr, "  honeypot analyze                  show all sessions summary\n")
+	fmt.Fprintf(os.Stderr, "  honeypot analyze --session ID     show full session detail\n")
+	fmt.Fprintf(os.Stderr, "  honeypot analyze --attack         show the ATT&CK heatmap across sessions\n")
+	fmt.Fprintf(os.Stderr, "  honeypot rules test \"<command>\"   show which rules fire for a command\n")
+	fmt.Fprintf(os.Stderr, "  honeypot rules corpus             check rules against the regression corpus\n")
+	fmt.Fprintf(os.Stderr, "  honeypot response plan LEVEL      dry-run the response playbooks up to LEVEL\n")
+	fmt.Fprintf(os.Stderr, "  honeypot verify --session ID      check a session's logs against their signed manifest\n")
+	fmt.Fprintf(os.Stderr, "  honeypot export evidence --session ID [--out FILE]\n")
+	fmt.Fprintf(os.Stderr, "                                    package everything kept about a session\n")
+	fmt.Fprintf(os.Stderr, "  honeypot export intel [--format stix|misp] [--since T] [--until T]\n")
+	fmt.Fprintf(os.Stderr, "        [--verdict V[,V]] [--tlp LEVEL] [--out FILE]\n")
+	fmt.Fprintf(os.Stderr, "                                    share attackers and sessions as STIX 2.1 or MISP\n")
+	fmt.Fprintf(os.Stderr, "  honeypot taxii serve [--listen ADDR]\n")
+	fmt.Fprintf(os.Stderr, "                                    serve the intel as a TAXII 2.1 feed\n")
+	fmt.Fprintf(os.Stderr, "  honeypot logs archive [--older-than-hours N]\n")
+	fmt.Fprintf(os.Stderr, "                                    gzip finished sessions into logs/archive\n")
+	fmt.Fprintf(os.Stderr, "  honeypot logs prune [--max-age-days N] [--max-total-mb N]\n")
+	fmt.Fprintf(os.Stderr, "                                    remove sessions past the retention limits\n")
+	fmt.Fprintf(os.Stderr, "  honeypot store import             backfill the event store from logs/\n")
+	fmt.Fprintf(os.Stderr, "  honeypot store query [--session ID] [--ip IP] [--kind K] [--category C]\n")
+	fmt.Fprintf(os.Stderr, "        [--rule R] [--signal S] [--verdict V] [--since T] [--until T] [--limit N]\n")
+	fmt.Fprintf(os.Stderr, "                                    list stored events matching every filter\n")
+	fmt.Fprintf(os.Stderr, "  honeypot siem replay [--session ID] [--since T] [--until T]\n")
+	fmt.Fprintf(os.Stderr, "                                    send stored events through the siem sinks\n")
+	fmt.Fprintf(os.Stderr, "  honeypot siem receive udp|tcp|tls|http|https ADDR [--cert FILE]\n")
+	fmt.Fprintf(os.Stderr, "                                    run a stand-in SIEM that prints what it gets\n")
+	fmt.Fprintf(os.Stderr, "  honeypot alerts test [--kind K] [--severity S] [--signal S] [--verdict V] [--ip IP]\n")
+	fmt.Fprintf(os.Stderr, "                                    route made-up alerts through the alert rules\n")
+	fmt.Fprintf(os.Stderr, "  honeypot alerts receive http|smtp ADDR\n")
+	fmt.Fprintf(os.Stderr, "                                    run a stand-in alert output that prints what it gets\n")
+	fmt.Fprintf(os.Stderr, "  honeypot watch [--verdict V[,V]] [--ip IP|CIDR[,...]]\n")
+	fmt.Fprintf(os.Stderr, "                                    follow live sessions on the running sensor\n")
+	fmt.Fprintf(os.Stderr, "  honeypot watch sessions           list the live sessions\n")
+	fmt.Fprintf(os.Stderr, "  honeypot watch attach [ID|N]      view a live session's terminal, read-only\n")
+	os.Exit(1)
+}
@@ -0,0 +1,137 @@
+package Session
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// pasteGap is how close two reads must be to belong to one paste
+	// that the transport split up.
+	pasteGap = 5 * time.Millisecond
+	// typingPause ends a run of typing; longer gaps are thinking time
+	// and stay out of the typing speed.
+	typingPause = 2 * time.Second
+)
+
+// Keystrokes records when and how input arrived on a session's stdin.
+// The shell records from the channel reader while the logger and the
+// detector read it, so it is locked.
+type Keystrokes struct {
+	mu      sync.Mutex
+	last    time.Time
+	lastKey bool
+	stats   KeystrokeStats
+	n       float64
+	sum     float64
+	sumSq   float64
+	inBurst bool
+}
+
+// KeystrokeStats summarises a session's input. Keys are keystrokes that
+// arrived on their own; a burst is several characters arriving at once,
+// which is what a paste or a scripted client looks like.
+type KeystrokeStats struct {
+	Keys           int     `json:"keys"`
+	Bursts         int     `json:"paste_bursts"`
+	BurstChars     int     `json:"pasted_chars"`
+	Backspaces     int     `json:"backspaces"`
+	Lines          int     `json:"lines"`
+	BurstLines     int     `json:"burst_lines"`
+	MeanIntervalMs float64 `json:"mean_interval_ms"`
+	IntervalCV     float64 `json:"interval_cv"`
+}
+
+// Record notes one read of stdin made at the given time.
+func (k *Keystrokes) Record(p []byte, at time.Time) {
+	if k == nil || len(p) == 0 {
+		return
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	gap := at.Sub(k.last)
+	single := len(p) == 1 || isEscapeSequence(p)
+	switch {
+	case !single || (k.inBurst && gap < pasteGap):
+		if !k.inBurst || gap >= pasteGap {
+			k.stats.Bursts++
+		}
+		k.inBurst = true
+		k.stats.BurstChars += len(p)
+		k.lastKey = false
+	default:
+		if k.lastKey && gap < typingPause {
+			ms := float64(gap) / float64(time.Millisecond)
+			k.n++
+			k.sum += ms
+			k.sumSq += ms * ms
+		}
+		k.inBurst = false
+		k.stats.Keys++
+		k.lastKey = true
+	}
+	k.last = at
+
+	for _, b := range p {
+		switch b {
+		case 0x7f, 0x08:
+			k.stats.Backspaces++
+		case '\r', '\n':
+			k.stats.Lines++
+			if k.inBurst {
+				k.stats.BurstLines++
+			}
+		}
+	}
+}
+
+// Stats returns the summary so far; a nil recorder has seen nothing.
+func (k *Keystrokes) Stats() KeystrokeStats {
+	if k == nil {
+		return KeystrokeStats{}
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	s := k.stats
+	if k.n > 0 {
+		mean := k.sum / k.n
+		s.MeanIntervalMs = math.Round(mean*10) / 10
+		if mean > 0 {
+			variance := math.Max(k.sumSq/k.n-mean*mean, 0)
+			s.IntervalCV = math.Round(math.Sqrt(variance)/mean*100) / 100
+		}
+	}
+	return s
+}
+
+// BackspaceRate is backspaces per typed or pasted character.
+func (s KeystrokeStats) BackspaceRate() float64 {
+	if total := s.Keys + s.BurstChars; total > 0 {
+		return float64(s.Backspaces) / float64(total)
+	}
+	return 0
+}
+
+// PasteRatio is the share of input that arrived in bursts.
+func (s KeystrokeStats) PasteRatio() float64 {
+	if total := s.Keys + s.BurstChars; total > 0 {
+		return float64(s.BurstChars) / float64(total)
+	}
+	return 0
+}
+
+// isEscapeSequence reports whether p is one terminal key such as an arrow
+// or function key, which arrives as several bytes.
+func isEscapeSequence(p []byte) bool {
+	if len(p) < 2 || len(p) > 8 || p[0] != 0x1b {
+		return false
+	}
+	for _, b := range p[1:] {
+		if b == 0x1b || b < 0x20 {
+			return false
+		}
+	}
+	return true
+}
@@ -1,28 +1,80 @@
 package Session
 
-import "time"
+import (
+	"GradGuard/internal/attack"
+	"time"
+)
 
 type SessionState struct {
 	ID              string
 	RemoteAddr      string
+	User            string
 	StartTime       time.Time
 	LastCommandTime time.Time
 	CommandCount    int
 	SuspicionScore  int
+	// Score is the exact, decaying score SuspicionScore is rounded from;
+	// ScoredAt is when it was last brought up to date.
+	Score           float64
+	ScoredAt        time.Time
+	CategoryScore   map[string]float64
+	CommandRepeats  map[string]int
+	ScoreChanges    []ScoreChange
 	FlaggedCommands []string
 	CategoryCounts  map[string]int
+	Techniques      map[attack.Tag]int
+	ShellVars       map[string]string
+	ShellAliases    map[string]string
+	// Chains holds partly matched kill-chain patterns by pattern id.
+	Chains          map[string][]ChainProgress
+	ChainsCompleted map[string]bool
+	Keystrokes      *Keystrokes
 }
 
 func NewSession(id, remoteAddr string) *SessionState {
 	return &SessionState{
-		ID:         id,
-		RemoteAddr: remoteAddr,
-		StartTime:  time.Now(),
-		CategoryCounts: map[string]int{
-			"fingerprint": 0,
-			"recon":       0,
-			"exploit":     0,
-			"unknown":     0,
-		},
+		ID:             id,
+		RemoteAddr:     remoteAddr,
+		StartTime:      time.Now(),
+		CategoryCounts: map[string]int{},
+		Techniques:     map[attack.Tag]int{},
+		CategoryScore:  map[string]float64{},
+		CommandRepeats: map[string]int{},
+		Keystrokes:     &Keystrokes{},
 	}
 }
+
+// ScoreChange explains one update of the suspicion score: how much had
+// decayed since the previous command and what each rule added.
+type ScoreChange struct {
+	CommandIndex  int                 `json:"command_index"`
+	Command       string              `json:"command"`
+	Time          time.Time           `json:"time"`
+	Decay         float64             `json:"decay"`
+	Contributions []ScoreContribution `json:"contributions"`
+	Score         float64             `json:"score"`
+}
+
+type ScoreContribution struct {
+	RuleID   string  `json:"rule_id,omitempty"`
+	Category string  `json:"category"`
+	Weight   int     `json:"weight"`
+	Points   float64 `json:"points"`
+	Note     string  `json:"note,omitempty"`
+}
+
+// ChainProgress is one partial match of a kill-chain pattern: how many
+// steps are done, the commands that did them and the values captured.
+type ChainProgress struct {
+	Step      int
+	Started   time.Time
+	LastIndex int
+	Vars      map[string]string
+	Steps     []ChainStepMatch
+}
+
+type ChainStepMatch struct {
+	Step         string `json:"step"`
+	Command      string `json:"command"`
+	CommandIndex int    `json:"command_index"`
+}
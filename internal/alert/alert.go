@@ -0,0 +1,161 @@
+// Package alert tells people about attacks while they happen. Detections,
+// session verdicts and logins from networks not seen before become
+// alerts, which rules route to webhooks, Slack, Teams or mail, holding
+// back repeats and gathering low-priority ones into digests.
+package alert
+
+import (
+	"GradGuard/internal/analyzer"
+	"GradGuard/internal/detector"
+	"GradGuard/internal/events"
+	"encoding/json"
+	"fmt"
+	"net"
+	"time"
+)
+
+// Kind is what an alert is about.
+type Kind string
+
+const (
+	// KindDetection is a signal the detector raised in a live session.
+	KindDetection Kind = "detection"
+	// KindVerdict is the verdict a session ended with.
+	KindVerdict Kind = "verdict"
+	// KindNewASN is a session from an autonomous system not seen before.
+	KindNewASN Kind = "new_asn"
+)
+
+// Kinds lists the kinds by the names the config uses.
+func Kinds() []Kind { return []Kind{KindDetection, KindVerdict, KindNewASN} }
+
+// Severities are the levels an alert can have, lowest first.
+var Severities = []string{"info", "warning", "high", "critical"}
+
+// rank is a severity's place in Severities, or -1.
+func rank(severity string) int {
+	for i, s := range Severities {
+		if s == severity {
+			return i
+		}
+	}
+	return -1
+}
+
+// Alert is one thing worth telling someone about.
+type Alert struct {
+	Kind      Kind      `json:"kind"`
+	Time      time.Time `json:"time"`
+	Severity  string    `json:"severity"`
+	SessionID string    `json:"session_id,omitempty"`
+	IP        string    `json:"ip"`
+	// ASN, Org and Country are where IP is, when it was looked up.
+	ASN        string   `json:"asn,omitempty"`
+	Org        string   `json:"org,omitempty"`
+	Country    string   `json:"country,omitempty"`
+	User       string   `json:"user,omitempty"`
+	Signal     string   `json:"signal,omitempty"`
+	Verdict    string   `json:"verdict,omitempty"`
+	Details    string   `json:"details,omitempty"`
+	Command    string   `json:"command,omitempty"`
+	Response   string   `json:"response,omitempty"`
+	Commands   int      `json:"commands,omitempty"`
+	Techniques []string `json:"techniques,omitempty"`
+	// Rule is the rule that routed the alert. Suppressed is how many
+	// alerts the rule held back for the same attacker since it last let
+	// one through.
+	Rule       string `json:"rule,omitempty"`
+	Suppressed int    `json:"suppressed,omitempty"`
+}
+
+// session is what the sink remembers of a live session, to fill in the
+// alerts raised in it.
+type session struct {
+	ip, user          string
+	asn, org, country string
+	// highest is the most severe detection so far.
+	highest string
+}
+
+// detectionAlert is the alert for a detection event.
+func detectionAlert(e events.Event, s *session) (Alert, bool) {
+	var d detector.DetectionEvent
+	if !decode(e, &d) {
+		return Alert{}, false
+	}
+	a := s.alert(KindDetection, e.Time)
+	a.SessionID = e.SessionID
+	a.Severity = string(d.Confidence)
+	if rank(a.Severity) < 0 {
+		a.Severity = "warning"
+	}
+	a.Signal = string(d.Signal)
+	a.Details = d.Details
+	a.Command = d.TriggerCommand
+	a.Response = d.ResponseTaken
+	for _, t := range d.Techniques {
+		a.Techniques = append(a.Techniques, t.Technique)
+	}
+	if rank(a.Severity) > rank(s.highest) {
+		s.highest = a.Severity
+	}
+	return a, true
+}
+
+// verdictSeverity weighs a verdict on its own; a session that raised
+// detections is at least as severe as the worst of them.
+func verdictSeverity(verdict string) string {
+	switch verdict {
+	case analyzer.VerdictClean:
+		return "info"
+	case analyzer.VerdictSuspicious, analyzer.VerdictLikelyFingerprinting:
+		return "warning"
+	}
+	return "high"
+}
+
+// verdictAlert is the alert for a session's end.
+func verdictAlert(e events.Event, s *session) (Alert, bool) {
+	var r analyzer.SessionReport
+	if !decode(e, &r) {
+		return Alert{}, false
+	}
+	a := s.alert(KindVerdict, e.Time)
+	a.SessionID = e.SessionID
+	a.Verdict = r.Verdict
+	a.Severity = verdictSeverity(r.Verdict)
+	if rank(s.highest) > rank(a.Severity) {
+		a.Severity = s.highest
+	}
+	a.Commands = r.TotalCommands
+	a.Details = fmt.Sprintf("Peak suspicion %d/100 over %d commands", r.PeakSuspicionScore, r.TotalCommands)
+	if len(r.FlaggedCommands) > 0 {
+		a.Command = r.FlaggedCommands[0]
+	}
+	for _, t := range r.Techniques {
+		a.Techniques = append(a.Techniques, t.Technique)
+	}
+	return a, true
+}
+
+func (s *session) alert(k Kind, at time.Time) Alert {
+	return Alert{
+		Kind: k, Time: at.UTC(),
+		IP: s.ip, User: s.user,
+		ASN: s.asn, Org: s.org, Country: s.country,
+	}
+}
+
+// decode reads an event's record back into its producer's type, which
+// works the same for live events and ones replayed from the store.
+func decode(e events.Event, v any) bool {
+	data, err := json.Marshal(e.Data)
+	return err == nil && json.Unmarshal(data, v) == nil
+}
+
+func hostOf(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
@@ -0,0 +1,165 @@
+package alert
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os"
+	"strings"
+	"time"
+)
+
+const defaultTimeout = 10 * time.Second
+
+// Message is what an output sends: one alert, or a digest of several.
+type Message struct {
+	Rule     string  `json:"rule"`
+	Severity string  `json:"severity"`
+	Title    string  `json:"title"`
+	Body     string  `json:"text"`
+	Alerts   []Alert `json:"alerts"`
+}
+
+// OutputOptions is one place alerts go. Type picks which fields apply:
+// webhook, slack and teams post to URL with Headers; smtp mails From to
+// To through the server at Address, logging in as User when set.
+type OutputOptions struct {
+	Name     string
+	Type     string
+	URL      string
+	Headers  map[string]string
+	Address  string
+	From     string
+	To       []string
+	User     string
+	Password string
+	Timeout  time.Duration
+}
+
+// OutputTypes lists the output types by the names the config uses.
+func OutputTypes() []string { return []string{"webhook", "slack", "teams", "smtp"} }
+
+type output interface {
+	send(m Message) error
+}
+
+func newOutput(o OutputOptions) (output, error) {
+	if o.Timeout <= 0 {
+		o.Timeout = defaultTimeout
+	}
+	switch o.Type {
+	case "webhook", "slack", "teams":
+		if o.URL == "" {
+			return nil, fmt.Errorf("output %s: %s needs a url", o.Name, o.Type)
+		}
+		return &poster{o: o, client: &http.Client{Timeout: o.Timeout}}, nil
+	case "smtp":
+		if o.Address == "" || o.From == "" || len(o.To) == 0 {
+			return nil, fmt.Errorf("output %s: smtp needs an address, from and to", o.Name)
+		}
+		return &mailer{o: o}, nil
+	}
+	return nil, fmt.Errorf("output %s: unknown type %q (want one of %s)", o.Name, o.Type, strings.Join(OutputTypes(), ", "))
+}
+
+// poster posts a message as JSON: the message itself for a webhook, or
+// the payload of a Slack or Teams incoming webhook.
+type poster struct {
+	o      OutputOptions
+	client *http.Client
+}
+
+// colors are what Slack and Teams tint an alert by severity.
+var colors = map[string]string{"info": "439FE0", "warning": "ECB22E", "high": "E8912D", "critical": "D00000"}
+
+func (p *poster) payload(m Message) any {
+	switch p.o.Type {
+	case "slack":
+		return map[string]any{
+			"text": m.Title,
+			"attachments": []map[string]any{{
+				"color": "#" + colors[m.Severity],
+				"title": m.Title,
+				"text":  m.Body,
+			}},
+		}
+	case "teams":
+		return map[string]any{
+			"@type":      "MessageCard",
+			"@context":   "https://schema.org/extensions",
+			"summary":    m.Title,
+			"title":      m.Title,
+			"themeColor": colors[m.Severity],
+			// Teams reads the text as markdown; two trailing spaces keep
+			// the lines apart.
+			"text": strings.ReplaceAll(m.Body, "\n", "  \n"),
+		}
+	}
+	return m
+}
+
+func (p *poster) send(m Message) error {
+	body, err := json.Marshal(p.payload(m))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, p.o.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range p.o.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s: %s", p.o.URL, resp.Status)
+	}
+	return nil
+}
+
+// mailer sends a message as a plain-text mail. The server is asked for
+// STARTTLS when it offers it.
+type mailer struct {
+	o OutputOptions
+}
+
+func (ml *mailer) send(m Message) error {
+	host, _, err := net.SplitHostPort(ml.o.Address)
+	if err != nil {
+		return err
+	}
+	var msg bytes.Buffer
+	name, _ := os.Hostname()
+	fmt.Fprintf(&msg, "From: %s\r\n", ml.o.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(ml.o.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", strings.NewReplacer("\r", " ", "\n", " ").Replace(m.Title)))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&msg, "Message-ID: <%d.gradguard@%s>\r\n", time.Now().UnixNano(), name)
+	fmt.Fprintf(&msg, "X-GradGuard-Severity: %s\r\n", m.Severity)
+	msg.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(m.Body, "\n", "\r\n") + "\r\n")
+
+	var auth smtp.Auth
+	if ml.o.User != "" {
+		auth = smtp.PlainAuth("", ml.o.User, ml.o.Password, host)
+	}
+	done := make(chan error, 1)
+	go func() { done <- smtp.SendMail(ml.o.Address, auth, ml.o.From, ml.o.To, msg.Bytes()) }()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(ml.o.Timeout):
+		return fmt.Errorf("smtp %s: timed out", ml.o.Address)
+	}
+}
@@ -0,0 +1,89 @@
+package alert
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Receive stands in for where alerts go: it listens on addr and calls fn
+// with every alert that arrives, until the listener fails. Network is
+// http for webhook, Slack and Teams outputs, which get the posted body,
+// or smtp for mail, which gets the message as sent.
+func Receive(network, addr string, fn func(from string, msg []byte)) error {
+	switch network {
+	case "http":
+		return http.ListenAndServe(addr, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			fn(r.RemoteAddr, body)
+			// What Slack's incoming webhooks answer.
+			w.Write([]byte("ok"))
+		}))
+	case "smtp":
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			return err
+		}
+		defer ln.Close()
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return err
+			}
+			go serveSMTP(conn, fn)
+		}
+	}
+	return fmt.Errorf("unknown network %q (want http or smtp)", network)
+}
+
+// serveSMTP takes mail over one connection, accepting any sender and
+// recipient. It offers neither STARTTLS nor AUTH.
+func serveSMTP(conn net.Conn, fn func(from string, msg []byte)) {
+	defer conn.Close()
+	from := conn.RemoteAddr().String()
+	r := bufio.NewReader(conn)
+	reply := func(line string) { fmt.Fprintf(conn, "%s\r\n", line) }
+	reply("220 gradguard stand-in ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		verb, _, _ := strings.Cut(strings.TrimSpace(line), " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			reply("250-gradguard stand-in")
+			reply("250 8BITMIME")
+		case "HELO", "MAIL", "RCPT", "RSET", "NOOP":
+			reply("250 OK")
+		case "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var msg bytes.Buffer
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if strings.TrimRight(line, "\r\n") == "." {
+					break
+				}
+				msg.WriteString(strings.TrimPrefix(line, "."))
+			}
+			fn(from, msg.Bytes())
+			reply("250 OK: queued")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package alert
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Rule picks alerts and says where they go. An alert matches when its
+// kind is one of Kinds, it is at least MinSeverity and, when set, its
+// signal is one of Signals and its verdict one of Verdicts; empty lists
+// match anything. Rules are tried in order and the first match routes
+// the alert, unless it is marked Continue.
+type Rule struct {
+	Name        string
+	Kinds       []Kind
+	MinSeverity string
+	Signals     []string
+	Verdicts    []string
+	Outputs     []string
+	Continue    bool
+
+	// Per is what repeats and rates are counted against: "ip",
+	// "session", or empty for all alerts the rule matches.
+	Per string
+	// Dedupe drops an alert the same as one the rule let through for the
+	// same Per within that long: same kind, signal and verdict.
+	Dedupe time.Duration
+	// ThrottleCount lets at most that many alerts through for the same
+	// Per within Throttle.
+	ThrottleCount int
+	Throttle      time.Duration
+	// Digest gathers the alerts the rule lets through and sends them as
+	// one message that long after the first of them.
+	Digest time.Duration
+
+	// Title and Body are text/template templates of an Alert; empty ones
+	// use the defaults for its kind.
+	Title string
+	Body  string
+}
+
+func (r *Rule) matches(a Alert) bool {
+	if len(r.Kinds) > 0 && !slices.Contains(r.Kinds, a.Kind) {
+		return false
+	}
+	if r.MinSeverity != "" && rank(a.Severity) < rank(r.MinSeverity) {
+		return false
+	}
+	if len(r.Signals) > 0 && !slices.Contains(r.Signals, a.Signal) {
+		return false
+	}
+	if len(r.Verdicts) > 0 && !slices.Contains(r.Verdicts, a.Verdict) {
+		return false
+	}
+	return true
+}
+
+// route is a rule ready to use: its templates parsed and what it has let
+// through remembered.
+type route struct {
+	Rule
+	title, body *template.Template
+
+	// sent holds, per Per key, when alerts were last let through;
+	// similar, per Per key and alert class, when the last one of each
+	// was. held counts what was held back per Per key since.
+	sent    map[string][]time.Time
+	similar map[string]time.Time
+	held    map[string]int
+
+	pending      []Alert
+	pendingSince time.Time
+}
+
+func newRoute(r Rule) (*route, error) {
+	if r.Name == "" {
+		return nil, fmt.Errorf("an alert rule needs a name")
+	}
+	if r.MinSeverity != "" && rank(r.MinSeverity) < 0 {
+		return nil, fmt.Errorf("rule %s: unknown severity %q (want one of %s)", r.Name, r.MinSeverity, strings.Join(Severities, ", "))
+	}
+	for _, k := range r.Kinds {
+		if !slices.Contains(Kinds(), k) {
+			return nil, fmt.Errorf("rule %s: unknown kind %q", r.Name, k)
+		}
+	}
+	switch r.Per {
+	case "", "ip", "session":
+	default:
+		return nil, fmt.Errorf("rule %s: per must be ip or session, not %q", r.Name, r.Per)
+	}
+	rt := &route{Rule: r, sent: map[string][]time.Time{}, similar: map[string]time.Time{}, held: map[string]int{}}
+	var err error
+	if rt.title, err = parse(r.Name+" title", r.Title); err != nil {
+		return nil, fmt.Errorf("rule %s: %w", r.Name, err)
+	}
+	if rt.body, err = parse(r.Name+" body", r.Body); err != nil {
+		return nil, fmt.Errorf("rule %s: %w", r.Name, err)
+	}
+	return rt, nil
+}
+
+func (rt *route) key(a Alert) string {
+	switch rt.Per {
+	case "ip":
+		return a.IP
+	case "session":
+		return a.SessionID
+	}
+	return ""
+}
+
+// admit says whether the rule lets an alert through now, counting it as
+// held back if not. One let through carries how many were held before it.
+func (rt *route) admit(a *Alert, now time.Time) bool {
+	key := rt.key(*a)
+	class := key + "|" + string(a.Kind) + "|" + a.Signal + "|" + a.Verdict
+	if rt.Dedupe > 0 {
+		if last, ok := rt.similar[class]; ok && now.Sub(last) < rt.Dedupe {
+			rt.held[key]++
+			return false
+		}
+	}
+	if rt.ThrottleCount > 0 && rt.Throttle > 0 {
+		recent := rt.sent[key][:0]
+		for _, t := range rt.sent[key] {
+			if now.Sub(t) < rt.Throttle {
+				recent = append(recent, t)
+			}
+		}
+		rt.sent[key] = recent
+		if len(recent) >= rt.ThrottleCount {
+			rt.held[key]++
+			return false
+		}
+		rt.sent[key] = append(recent, now)
+	}
+	if rt.Dedupe > 0 {
+		rt.similar[class] = now
+	}
+	a.Suppressed = rt.held[key]
+	delete(rt.held, key)
+	return true
+}
+
+// forget drops what the rule remembers that no longer holds anything
+// back, so long-running honeypots do not collect every attacker seen.
+func (rt *route) forget(now time.Time) {
+	for class, t := range rt.similar {
+		if now.Sub(t) >= rt.Dedupe {
+			delete(rt.similar, class)
+		}
+	}
+	for key, times := range rt.sent {
+		if len(times) == 0 || now.Sub(times[len(times)-1]) >= rt.Throttle {
+			delete(rt.sent, key)
+		}
+	}
+}
+
+// message renders the alerts the rule sends at once: one, or a digest.
+func (rt *route) message(alerts []Alert) (Message, error) {
+	m := Message{Rule: rt.Name, Alerts: alerts}
+	for _, a := range alerts {
+		if rank(a.Severity) > rank(m.Severity) {
+			m.Severity = a.Severity
+		}
+	}
+	if len(alerts) == 1 {
+		var err error
+		if m.Title, err = render(rt.title, alerts[0], titles[alerts[0].Kind]); err != nil {
+			return m, err
+		}
+		m.Body, err = render(rt.body, alerts[0], defaultBody)
+		return m, err
+	}
+	m.Title = fmt.Sprintf("%d alerts from %s since %s", len(alerts), rt.Name, alerts[0].Time.Format("15:04 MST"))
+	var b strings.Builder
+	for _, a := range alerts {
+		title, err := render(rt.title, a, titles[a.Kind])
+		if err != nil {
+			return m, err
+		}
+		fmt.Fprintf(&b, "%s  %s\n", a.Time.Format("15:04:05"), title)
+	}
+	m.Body = strings.TrimSuffix(b.String(), "\n")
+	return m, nil
+}
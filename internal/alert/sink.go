@@ -0,0 +1,411 @@
+package alert
+
+import (
+	"GradGuard/internal/events"
+	"GradGuard/internal/ipintel"
+	"GradGuard/internal/logstore"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// digestTick is how often digests are checked for being due.
+	digestTick = 15 * time.Second
+	// retryDelay is how long a failed send waits before its one retry.
+	retryDelay = 2 * time.Second
+	// lookupEvery spaces out address lookups to within ip-api.com's free
+	// tier of 45 a minute; lookups already kept are not held back.
+	lookupEvery = 1500 * time.Millisecond
+	// lookupQueue is how many sessions may wait for their lookup; past
+	// it their addresses are not looked up.
+	lookupQueue = 256
+)
+
+// Options is the alerting setup: where alerts can go, and the rules that
+// send them there.
+type Options struct {
+	Outputs []OutputOptions
+	Rules   []Rule
+	// ASNState keeps the autonomous systems sessions have come from, so
+	// a restart does not make them new again. It defaults to
+	// logs/alerts/asns.json.
+	ASNState string
+}
+
+// Sink is an event sink that turns events into alerts and routes them.
+type Sink struct {
+	outputs map[string]output
+	routes  []*route
+	// lookup is set when a rule names new_asn; only then are addresses
+	// looked up, off the write path, as the sessions they start wait on.
+	lookup   bool
+	lookups  chan events.Event
+	asnState string
+
+	mu       sync.Mutex
+	sessions map[string]*session
+	asns     map[string]bool
+	stop     chan struct{}
+	done     chan struct{}
+	looked   chan struct{}
+}
+
+// Delivery is what became of an alert under one rule.
+type Delivery struct {
+	Rule   string
+	Output string
+	// Held is set when the rule's dedupe or throttle held the alert back
+	// and Digest when it waits for the rule's digest.
+	Held   bool
+	Digest bool
+	Err    error
+}
+
+func New(o Options) (*Sink, error) {
+	s := &Sink{
+		outputs:  map[string]output{},
+		asnState: o.ASNState,
+		sessions: map[string]*session{},
+		asns:     map[string]bool{},
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+		looked:   make(chan struct{}),
+	}
+	if s.asnState == "" {
+		s.asnState = filepath.Join(logstore.Root, "alerts", "asns.json")
+	}
+	for _, oo := range o.Outputs {
+		if _, dup := s.outputs[oo.Name]; dup || oo.Name == "" {
+			return nil, fmt.Errorf("alert outputs need distinct names, not %q", oo.Name)
+		}
+		out, err := newOutput(oo)
+		if err != nil {
+			return nil, err
+		}
+		s.outputs[oo.Name] = out
+	}
+	for _, r := range o.Rules {
+		rt, err := newRoute(r)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range r.Outputs {
+			if _, ok := s.outputs[name]; !ok {
+				return nil, fmt.Errorf("rule %s: no output named %q", r.Name, name)
+			}
+		}
+		// Looking addresses up sends them to ip-api.com, so only a rule
+		// that asks for new_asn by name turns it on.
+		if slices.Contains(r.Kinds, KindNewASN) {
+			s.lookup = true
+		}
+		s.routes = append(s.routes, rt)
+	}
+	go s.digests()
+	if s.lookup {
+		s.loadASNs()
+		s.lookups = make(chan events.Event, lookupQueue)
+		go s.lookUp()
+	} else {
+		close(s.looked)
+	}
+	return s, nil
+}
+
+func (s *Sink) Name() string { return "alerts" }
+
+// Write raises the alerts the events call for and sends them where the
+// rules say.
+func (s *Sink) Write(batch []events.Event) error {
+	var errs []error
+	for _, e := range batch {
+		for _, a := range s.alerts(e) {
+			for _, d := range s.Route(a) {
+				if d.Err != nil {
+					errs = append(errs, fmt.Errorf("%s to %s: %w", d.Rule, d.Output, d.Err))
+				}
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// alerts are the alerts an event raises, keeping track of the session
+// it belongs to.
+func (s *Sink) alerts(e events.Event) []Alert {
+	switch e.Kind {
+	case events.KindSessionStart:
+		sess := &session{ip: hostOf(e.RemoteAddr)}
+		var start events.SessionStart
+		if decode(e, &start) {
+			sess.user = start.User
+		}
+		s.mu.Lock()
+		s.sessions[e.SessionID] = sess
+		s.mu.Unlock()
+		if s.lookup {
+			select {
+			case s.lookups <- e:
+			default:
+				log.Printf("alerts: too many lookups waiting; %s not looked up", sess.ip)
+			}
+		}
+
+	case events.KindDetection:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if a, ok := detectionAlert(e, s.session(e)); ok {
+			return []Alert{a}
+		}
+
+	case events.KindSessionEnd:
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		sess := s.session(e)
+		delete(s.sessions, e.SessionID)
+		if a, ok := verdictAlert(e, sess); ok {
+			return []Alert{a}
+		}
+	}
+	return nil
+}
+
+// lookUp looks up the address of each session started, spacing out the
+// lookups it has to make, and raises new_asn for a new AS.
+func (s *Sink) lookUp() {
+	defer close(s.looked)
+	tick := time.NewTicker(lookupEvery)
+	defer tick.Stop()
+	for {
+		var e events.Event
+		select {
+		case <-s.stop:
+			return
+		case e = <-s.lookups:
+		}
+		info := ipintel.Kept(e.RemoteAddr)
+		if info == nil {
+			select {
+			case <-s.stop:
+				return
+			case <-tick.C:
+			}
+			info = ipintel.Cached(e.RemoteAddr)
+		}
+		a, ok := s.newASN(e, info)
+		if !ok {
+			continue
+		}
+		for _, d := range s.Route(a) {
+			if d.Err != nil {
+				log.Printf("alerts: %s to %s: %v", d.Rule, d.Output, d.Err)
+			}
+		}
+	}
+}
+
+// newASN notes where a session comes from and raises new_asn if no
+// session has come from its AS before.
+func (s *Sink) newASN(e events.Event, info *ipintel.Info) (Alert, bool) {
+	if info == nil || info.AS == "" {
+		return Alert{}, false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[e.SessionID]
+	if !ok {
+		// The session ended before its lookup came back.
+		sess = &session{ip: hostOf(e.RemoteAddr)}
+	}
+	sess.asn, sess.org, sess.country = info.AS, info.Org, info.Country
+	if s.asns[info.AS] {
+		return Alert{}, false
+	}
+	s.asns[info.AS] = true
+	s.saveASNs()
+	a := sess.alert(KindNewASN, e.Time)
+	a.SessionID = e.SessionID
+	a.Severity = "info"
+	a.Details = fmt.Sprintf("No session has come from %s before", info.AS)
+	return a, true
+}
+
+// session is what is known of an event's session; one whose start was
+// not seen, such as one live across a restart, has only its address.
+func (s *Sink) session(e events.Event) *session {
+	sess, ok := s.sessions[e.SessionID]
+	if !ok {
+		sess = &session{ip: hostOf(e.RemoteAddr)}
+		s.sessions[e.SessionID] = sess
+	}
+	return sess
+}
+
+// Route sends an alert by the first rule it matches, and by every rule
+// before that marked Continue.
+func (s *Sink) Route(a Alert) []Delivery {
+	type send struct {
+		rule    string
+		message Message
+		outputs []string
+	}
+	var (
+		deliveries []Delivery
+		sends      []send
+	)
+	now := time.Now()
+	s.mu.Lock()
+	for _, rt := range s.routes {
+		if !rt.matches(a) {
+			continue
+		}
+		routed := a
+		routed.Rule = rt.Name
+		switch {
+		case !rt.admit(&routed, now):
+			deliveries = append(deliveries, Delivery{Rule: rt.Name, Held: true})
+		case rt.Digest > 0:
+			if len(rt.pending) == 0 {
+				rt.pendingSince = now
+			}
+			rt.pending = append(rt.pending, routed)
+			deliveries = append(deliveries, Delivery{Rule: rt.Name, Digest: true})
+		default:
+			m, err := rt.message([]Alert{routed})
+			if err != nil {
+				deliveries = append(deliveries, Delivery{Rule: rt.Name, Err: err})
+			} else {
+				sends = append(sends, send{rt.Name, m, rt.Outputs})
+			}
+		}
+		if !rt.Continue {
+			break
+		}
+	}
+	s.mu.Unlock()
+
+	for _, sd := range sends {
+		deliveries = append(deliveries, s.deliver(sd.rule, sd.message, sd.outputs)...)
+	}
+	return deliveries
+}
+
+// deliver sends a message to each output, retrying once.
+func (s *Sink) deliver(rule string, m Message, outputs []string) []Delivery {
+	var deliveries []Delivery
+	for _, name := range outputs {
+		err := s.outputs[name].send(m)
+		if err != nil {
+			time.Sleep(retryDelay)
+			err = s.outputs[name].send(m)
+		}
+		deliveries = append(deliveries, Delivery{Rule: rule, Output: name, Err: err})
+	}
+	return deliveries
+}
+
+// digests sends each rule's gathered alerts once its digest is due, and
+// clears out what throttles no longer need.
+func (s *Sink) digests() {
+	defer close(s.done)
+	tick := time.NewTicker(digestTick)
+	defer tick.Stop()
+	for {
+		closing := false
+		select {
+		case <-s.stop:
+			// The last digest takes in any alert a lookup raises as it
+			// stops.
+			<-s.looked
+			closing = true
+		case <-tick.C:
+		}
+		for _, d := range s.flush(closing) {
+			if d.Err != nil {
+				log.Printf("alerts: digest %s to %s: %v", d.Rule, d.Output, d.Err)
+			}
+		}
+		if closing {
+			return
+		}
+	}
+}
+
+// Flush sends every digest now, due or not.
+func (s *Sink) Flush() []Delivery { return s.flush(true) }
+
+func (s *Sink) flush(all bool) []Delivery {
+	var deliveries []Delivery
+	now := time.Now()
+	s.mu.Lock()
+	var due []*route
+	for _, rt := range s.routes {
+		rt.forget(now)
+		if len(rt.pending) > 0 && (all || now.Sub(rt.pendingSince) >= rt.Digest) {
+			due = append(due, rt)
+		}
+	}
+	messages := make([]Message, len(due))
+	var errs []error
+	for i, rt := range due {
+		m, err := rt.message(rt.pending)
+		messages[i] = m
+		errs = append(errs, err)
+		rt.pending = nil
+	}
+	s.mu.Unlock()
+
+	for i, rt := range due {
+		if errs[i] != nil {
+			deliveries = append(deliveries, Delivery{Rule: rt.Name, Err: errs[i]})
+			continue
+		}
+		deliveries = append(deliveries, s.deliver(rt.Name, messages[i], rt.Outputs)...)
+	}
+	return deliveries
+}
+
+func (s *Sink) loadASNs() {
+	data, err := os.ReadFile(s.asnState)
+	if err != nil {
+		return
+	}
+	var asns []string
+	if json.Unmarshal(data, &asns) == nil {
+		for _, asn := range asns {
+			s.asns[asn] = true
+		}
+	}
+}
+
+func (s *Sink) saveASNs() {
+	asns := make([]string, 0, len(s.asns))
+	for asn := range s.asns {
+		asns = append(asns, asn)
+	}
+	sort.Strings(asns)
+	data, _ := json.MarshalIndent(asns, "", "  ")
+	if err := os.MkdirAll(filepath.Dir(s.asnState), 0755); err != nil {
+		return
+	}
+	tmp := s.asnState + ".tmp"
+	if os.WriteFile(tmp, append(data, '\n'), 0644) == nil {
+		os.Rename(tmp, s.asnState)
+	}
+}
+
+// Close sends the digests still gathering; lookups still waiting are
+// given up.
+func (s *Sink) Close() error {
+	close(s.stop)
+	<-s.done
+	return nil
+}
@@ -0,0 +1,52 @@
+package alert
+
+import (
+	"strings"
+	"text/template"
+)
+
+var funcs = template.FuncMap{
+	"upper": strings.ToUpper,
+	"words": func(s string) string { return strings.ReplaceAll(s, "_", " ") },
+	"join":  strings.Join,
+}
+
+// titles are the default one-line summaries of each kind.
+var titles = map[Kind]*template.Template{
+	KindDetection: mustParse(`[{{upper .Severity}}] {{words .Signal}} from {{.IP}}`),
+	KindVerdict:   mustParse(`[{{upper .Severity}}] Session from {{.IP}} ended: {{words .Verdict}}`),
+	KindNewASN:    mustParse(`[{{upper .Severity}}] First session from {{.ASN}}`),
+}
+
+var defaultBody = mustParse(`{{with .Details}}{{.}}
+{{end}}{{with .Command}}Command: {{.}}
+{{end}}{{with .Response}}Response: {{.}}
+{{end}}{{with .SessionID}}Session: {{.}}
+{{end}}Address: {{.IP}}{{with .ASN}} ({{.}}{{with $.Org}}, {{.}}{{end}}{{with $.Country}}, {{.}}{{end}}){{end}}
+{{with .User}}User: {{.}}
+{{end}}{{with .Techniques}}ATT&CK: {{join . ", "}}
+{{end}}{{with .Suppressed}}{{.}} more held back since the last alert
+{{end}}`)
+
+func mustParse(text string) *template.Template {
+	return template.Must(template.New("").Funcs(funcs).Parse(text))
+}
+
+// parse is a rule's own template, or nil when it has none.
+func parse(name, text string) (*template.Template, error) {
+	if text == "" {
+		return nil, nil
+	}
+	return template.New(name).Funcs(funcs).Parse(text)
+}
+
+func render(t *template.Template, a Alert, fallback *template.Template) (string, error) {
+	if t == nil {
+		t = fallback
+	}
+	var b strings.Builder
+	if err := t.Execute(&b, a); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(b.String()), nil
+}
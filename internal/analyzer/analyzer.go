@@ -2,6 +2,7 @@ package analyzer
 
 import (
 	Session "GradGuard/internal/Session"
+	"time"
 )
 
 const (
@@ -9,34 +10,36 @@ const (
 	VerdictSuspicious           = "suspicious"
 	VerdictLikelyFingerprinting = "likely_fingerprinting"
 	VerdictExploitAttempt       = "exploit_attempt"
+	VerdictPersistence          = "persistence_attempt"
+	VerdictCredentialTheft      = "credential_theft"
+	VerdictLateralMovement      = "lateral_movement"
+	VerdictCryptomining         = "cryptomining"
 )
 
 func Analyze(session *Session.SessionState, cmd string) ClassificationResult {
-	result := Classify(cmd)
+	if session.ShellVars == nil {
+		env := NewShellEnv()
+		session.ShellVars = env.Vars
+		session.ShellAliases = env.Aliases
+	}
+	env := &ShellEnv{Vars: session.ShellVars, Aliases: session.ShellAliases}
+	result := ClassifyIn(cmd, env)
+	session.ShellAliases = env.Aliases
 
-	session.SuspicionScore += result.SuspicionWeight
-	if session.SuspicionScore > 100 {
-		session.SuspicionScore = 100
+	at := session.LastCommandTime
+	if at.IsZero() {
+		at = time.Now()
 	}
+	ActiveScoring().Apply(session, cmd, result, at)
 
 	session.CategoryCounts[string(result.Category)]++
+	for _, t := range result.Techniques {
+		session.Techniques[t]++
+	}
 
-	if result.Category == CategoryFingerprint || result.Category == CategoryExploit {
+	if info, ok := LookupCategory(result.Category); ok && info.Flagged {
 		session.FlaggedCommands = append(session.FlaggedCommands, cmd)
 	}
 
 	return result
 }
-
-func Verdict(session *Session.SessionState) string {
-	if session.CategoryCounts[string(CategoryExploit)] > 0 {
-		return VerdictExploitAttempt
-	}
-	if session.SuspicionScore >= 70 {
-		return VerdictLikelyFingerprinting
-	}
-	if session.SuspicionScore >= 30 {
-		return VerdictSuspicious
-	}
-	return VerdictClean
-}
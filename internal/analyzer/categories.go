@@ -0,0 +1,54 @@
+package analyzer
+
+import "sort"
+
+// CategoryInfo describes a behaviour category. Rank decides which category
+// a command is filed under when rules from several categories fire, and
+// which verdict wins when a session touched several. Rule files can add
+// categories of their own in a "categories" section. Behaviour is the
+// session label the category is evidence for.
+type CategoryInfo struct {
+	Name      Category `json:"name"`
+	Label     string   `json:"label"`
+	Rank      int      `json:"rank"`
+	Flagged   bool     `json:"flagged"`
+	Verdict   string   `json:"verdict,omitempty"`
+	Behaviour string   `json:"behaviour,omitempty"`
+}
+
+var builtinCategories = []CategoryInfo{
+	{CategoryUnknown, "unknown", 0, false, "", ""},
+	{CategoryRecon, "recon", 10, false, "", ""},
+	{CategoryDownload, "download/staging", 20, true, "", ""},
+	{CategoryFingerprint, "fingerprint", 30, true, VerdictLikelyFingerprinting, BehaviourFingerprinting},
+	{CategoryExploit, "exploit", 40, true, VerdictExploitAttempt, BehaviourExploitation},
+	{CategoryDefenseEvasion, "defense evasion", 50, true, "", BehaviourDefenseEvasion},
+	{CategoryCredentialAccess, "credential access", 60, true, VerdictCredentialTheft, BehaviourCredentialTheft},
+	{CategoryLateralMovement, "lateral movement", 70, true, VerdictLateralMovement, BehaviourLateralMovement},
+	{CategoryPersistence, "persistence", 80, true, VerdictPersistence, BehaviourPersistence},
+	{CategoryCryptomining, "cryptomining", 90, true, VerdictCryptomining, BehaviourCryptomining},
+}
+
+// Categories returns every category the active rule set knows, highest
+// rank first.
+func Categories() []CategoryInfo {
+	return ActiveRules().Categories
+}
+
+func LookupCategory(name Category) (CategoryInfo, bool) {
+	for _, c := range ActiveRules().Categories {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return CategoryInfo{}, false
+}
+
+func categoryRank(c Category) int {
+	info, _ := LookupCategory(c)
+	return info.Rank
+}
+
+func sortCategories(cats []CategoryInfo) {
+	sort.SliceStable(cats, func(i, j int) bool { return cats[i].Rank > cats[j].Rank })
+}
@@ -1,6 +1,7 @@
 package analyzer
 
 import (
+	"GradGuard/internal/attack"
 	"regexp"
 	"strings"
 )
@@ -8,10 +9,16 @@ import (
 type Category string
 
 const (
-	CategoryFingerprint Category = "fingerprint"
-	CategoryRecon       Category = "recon"
-	CategoryExploit     Category = "exploit"
-	CategoryUnknown     Category = "unknown"
+	CategoryFingerprint      Category = "fingerprint"
+	CategoryRecon            Category = "recon"
+	CategoryExploit          Category = "exploit"
+	CategoryPersistence      Category = "persistence"
+	CategoryCredentialAccess Category = "credential_access"
+	CategoryDefenseEvasion   Category = "defense_evasion"
+	CategoryLateralMovement  Category = "lateral_movement"
+	CategoryCryptomining     Category = "cryptomining"
+	CategoryDownload         Category = "download"
+	CategoryUnknown          Category = "unknown"
 )
 
 var ansiEscape = regexp.MustCompile(`(\x9B|\x1B\[)[0-?]*[ -\/]*[@-~]|\x1B\][^\x07]*\x07|\x1B[()][AB]|\r`)
@@ -20,113 +27,134 @@ type ClassificationResult struct {
 	Category        Category
 	SuspicionWeight int
 	Reason          string
+	Matches         []RuleMatch
+	// Weighted is the rule behind each part of SuspicionWeight: the
+	// heaviest match of every simple command and of every decoded layer.
+	Weighted   []RuleMatch
+	Techniques []attack.Tag
+	Decoded    []DecodedPayload
 }
 
-var fingerprintPatterns = []struct {
-	pattern string
-	weight  int
-	reason  string
-}{
-	{"/.dockerenv", 40, "checking for docker environment file"},
-	{"/proc/1/cgroup", 35, "reading cgroup to detect containerization"},
-	{"/proc/self/cgroup", 35, "reading cgroup to detect containerization"},
-	{"/proc/self/status", 20, "reading process status for VM detection"},
-	{"systemd-detect-virt", 40, "explicit virtualization detection tool"},
-	{"virt-what", 40, "explicit virtualization detection tool"},
-	{"dmidecode", 30, "reading DMI data for hardware fingerprinting"},
-	{"dmesg", 25, "reading kernel messages for environment clues"},
-	{"/proc/version", 20, "checking kernel version for fingerprinting"},
-	{"/proc/cpuinfo", 20, "reading CPU info for VM detection"},
-	{"container=", 30, "checking container environment variable"},
+type RuleMatch struct {
+	RuleID    string
+	Category  Category
+	Weight    int
+	Reason    string
+	Tactic    string
+	Technique string
+	Command   string
 }
 
-var reconPatterns = []struct {
-	pattern string
-	weight  int
-	reason  string
-}{
-	{"whoami", 5, "checking current user"},
-	{"id", 5, "checking user identity"},
-	{"uname", 5, "checking OS info"},
-	{"hostname", 5, "checking hostname"},
-	{"ifconfig", 8, "network interface reconnaissance"},
-	{"ip a", 8, "network interface reconnaissance"},
-	{"ip addr", 8, "network interface reconnaissance"},
-	{"netstat", 10, "checking network connections"},
-	{"ps ", 8, "listing running processes"},
-	{"ps\n", 8, "listing running processes"},
-	{"w ", 5, "checking logged in users"},
-	{"who", 5, "checking logged in users"},
-	{"uptime", 3, "checking system uptime"},
-	{"env", 5, "dumping environment variables"},
-	{"printenv", 5, "dumping environment variables"},
-	{"/etc/passwd", 15, "reading password file"},
-	{"/etc/shadow", 20, "attempting to read shadow file"},
-	{"cat /etc/", 10, "reading system config files"},
-	{"ls -la", 5, "detailed directory listing"},
-	{"find /", 12, "filesystem search"},
+func Classify(cmd string) ClassificationResult {
+	return ClassifyIn(cmd, nil)
 }
 
-var exploitPatterns = []struct {
-	pattern string
-	weight  int
-	reason  string
-}{
-	{"chmod +s", 50, "setuid bit — privilege escalation attempt"},
-	{"chmod 777", 30, "world-writable permission change"},
-	{"/bin/bash -p", 50, "privileged bash shell attempt"},
-	{"python3 -c", 30, "python code execution"},
-	{"python -c", 30, "python code execution"},
-	{"pty.spawn", 40, "PTY shell spawning via python"},
-	{"nc -e", 50, "netcat reverse shell"},
-	{"ncat -e", 50, "netcat reverse shell"},
-	{"bash -i", 40, "interactive bash — likely reverse shell"},
-	{"bash -c", 25, "bash command execution"},
-	{"curl | bash", 60, "remote code execution via curl pipe"},
-	{"wget | sh", 60, "remote code execution via wget pipe"},
-	{"curl|bash", 60, "remote code execution via curl pipe"},
-	{"wget|sh", 60, "remote code execution via wget pipe"},
-	{"> /etc/", 40, "writing to system config files"},
-	{"dd if=", 35, "disk read/write operation"},
-	{"/dev/tcp/", 45, "bash TCP redirect — reverse shell attempt"},
-}
+// ClassifyIn classifies every simple command on the line against the
+// rule set, resolving aliases and variables through env. Encoded payloads
+// are decoded and their commands classified like any other; each layer of
+// encoding adds the weight of the rules matching it on top.
+func ClassifyIn(cmd string, env *ShellEnv) ClassificationResult {
+	clean := ansiEscape.ReplaceAllString(strings.TrimSpace(cmd), "")
+	parsed := ParseLine(clean, env)
 
-func Classify(cmd string) ClassificationResult {
-	lower := strings.ToLower(strings.TrimSpace(cmd))
-	lower = ansiEscape.ReplaceAllString(lower, "")
-	for _, p := range exploitPatterns {
-		if strings.Contains(lower, p.pattern) {
-			return ClassificationResult{
-				Category:        CategoryExploit,
-				SuspicionWeight: p.weight,
-				Reason:          p.reason,
+	var matches, weighted []RuleMatch
+	weight := 0
+	add := func(best RuleMatch, ok bool) {
+		if ok {
+			weight += best.Weight
+			weighted = append(weighted, best)
+		}
+	}
+	for _, pl := range parsed.Pipelines {
+		for i, c := range pl.Commands {
+			var next *SimpleCommand
+			if i+1 < len(pl.Commands) {
+				next = &pl.Commands[i+1]
 			}
+			add(matchRules(&matches, c.String(), factsFor(c, next)))
 		}
 	}
+	// The category comes from what the commands do; obfuscation only
+	// decides it when nothing else matched.
+	commandMatches := len(matches)
+	for _, d := range parsed.Decoded {
+		add(matchRules(&matches, d.Encoding+": "+d.Text, factsForPayload(d)))
+	}
 
-	for _, p := range fingerprintPatterns {
-		if strings.Contains(lower, p.pattern) {
-			return ClassificationResult{
-				Category:        CategoryFingerprint,
-				SuspicionWeight: p.weight,
-				Reason:          p.reason,
-			}
+	if len(matches) == 0 {
+		return ClassificationResult{
+			Category:        CategoryUnknown,
+			SuspicionWeight: 1,
+			Reason:          "no pattern matched",
+			Weighted: []RuleMatch{{
+				Category: CategoryUnknown,
+				Weight:   1,
+				Reason:   "no pattern matched",
+				Command:  clean,
+			}},
+			Decoded: parsed.Decoded,
 		}
 	}
 
-	for _, p := range reconPatterns {
-		if strings.Contains(lower, p.pattern) {
-			return ClassificationResult{
-				Category:        CategoryRecon,
-				SuspicionWeight: p.weight,
-				Reason:          p.reason,
-			}
+	result := ClassificationResult{
+		Category:        matches[0].Category,
+		SuspicionWeight: weight,
+		Matches:         matches,
+		Weighted:        weighted,
+		Decoded:         parsed.Decoded,
+	}
+	if commandMatches == 0 {
+		commandMatches = len(matches)
+	}
+	var reasons []string
+	seen := map[string]bool{}
+	for i, m := range matches {
+		if i < commandMatches && categoryRank(m.Category) > categoryRank(result.Category) {
+			result.Category = m.Category
+		}
+		if !seen[m.Reason] {
+			seen[m.Reason] = true
+			reasons = append(reasons, m.Reason)
 		}
+		result.Techniques = attack.Merge(result.Techniques, attack.Tag{Tactic: m.Tactic, Technique: m.Technique})
 	}
+	result.Reason = strings.Join(reasons, "; ")
+	return result
+}
 
-	return ClassificationResult{
-		Category:        CategoryUnknown,
-		SuspicionWeight: 1,
-		Reason:          "no pattern matched",
+// matchRules appends every rule that fires on a command or decoded layer
+// and returns the heaviest, which is the weight it contributes.
+func matchRules(matches *[]RuleMatch, command string, f commandFacts) (RuleMatch, bool) {
+	var best RuleMatch
+	found := false
+	rules := ActiveRules().Rules
+	for i := range rules {
+		r := &rules[i]
+		if !r.Match.matches(f) {
+			continue
+		}
+		m := RuleMatch{
+			RuleID:    r.ID,
+			Category:  r.Category,
+			Weight:    r.Weight,
+			Reason:    r.Reason,
+			Tactic:    r.Tactic,
+			Technique: r.Technique,
+			Command:   command,
+		}
+		dup := false
+		for _, have := range *matches {
+			if have.RuleID == r.ID {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			*matches = append(*matches, m)
+		}
+		if !found || r.Weight > best.Weight {
+			best, found = m, true
+		}
 	}
+	return best, found
 }
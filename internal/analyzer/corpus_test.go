@@ -0,0 +1,17 @@
+package analyzer
+
+import "testing"
+
+// TestRegressionCorpus runs the corpus "honeypot rules corpus" checks
+// against the built-in rules, so a rule change that breaks it fails the
+// build.
+func TestRegressionCorpus(t *testing.T) {
+	set, err := LoadRules("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetActiveRules(set)
+	for _, f := range CheckCorpus(RegressionCorpus) {
+		t.Error(f)
+	}
+}
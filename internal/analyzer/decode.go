@@ -0,0 +1,380 @@
+package analyzer
+
+import (
+	"compress/gzip"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// maxDecodeDepth bounds how many encodings deep a payload is unwrapped,
+	// counting both layers in one pipeline and payloads that decode to
+	// further encoded commands.
+	maxDecodeDepth = 6
+	maxDecodedSize = 64 << 10
+)
+
+// encodings are the names rules can match decoded layers on.
+var encodings = []string{"base64", "base32", "hex", "octal", "rev", "gzip", "rot13", "pack"}
+
+// DecodedPayload is one layer of obfuscation peeled off a command line.
+type DecodedPayload struct {
+	Encoding string `json:"encoding"`
+	Text     string `json:"text"`
+	Level    int    `json:"level"`
+}
+
+var errNotEncoded = errors.New("input is not encoded")
+
+// layer records a decoded layer and reports whether the depth limit
+// still allows looking inside it.
+func (x *expander) layer(encoding, text string) bool {
+	if x.level >= maxDecodeDepth {
+		return false
+	}
+	x.decoded = append(x.decoded, DecodedPayload{
+		Encoding: encoding,
+		Text:     printable(text),
+		Level:    x.level + 1,
+	})
+	return true
+}
+
+// run parses a decoded payload as shell one level further down.
+func (x *expander) run(text string, depth int, via string) {
+	x.level++
+	x.script(text, depth+1, via)
+	x.level--
+}
+
+// decodePipeline follows literal text through the decoding stages of a
+// pipeline such as `echo ... | base64 -d | gunzip | sh`. Every stage that
+// decodes adds a layer. The final text is parsed as commands whenever the
+// pipeline feeds it to a shell, or when it was decoded on a line of its
+// own; inside $(...) the consuming command decides. It returns what the
+// pipeline prints when that is knowable.
+func (x *expander) decodePipeline(cmds []SimpleCommand, depth int, via string) string {
+	before := len(x.decoded)
+	text, start, ok := x.staticInput(cmds[0])
+	if !ok || len(cmds[0].Argv) == 0 {
+		return ""
+	}
+	layers := len(x.decoded) - before
+	for _, c := range cmds[start:] {
+		if len(c.Argv) == 0 {
+			return ""
+		}
+		encoding, decode := decoderFor(c)
+		if decode == nil {
+			if isShell(c.Name()) {
+				if _, inline := dashCPayload(c.Argv[1:]); !inline {
+					x.run(text, depth, "decode | "+c.Name())
+				}
+			}
+			return ""
+		}
+		out, err := decode(text)
+		if err != nil || !x.layer(encoding, out) {
+			return ""
+		}
+		text = out
+		layers++
+	}
+	if layers > 0 && via != "substitution" && isText(text) {
+		x.run(text, depth, "decode")
+	}
+	return text
+}
+
+// staticInput returns the text the first command of a pipeline writes,
+// and 1 when that command was a producer (echo, printf) rather than a
+// decoder reading a here-string.
+func (x *expander) staticInput(c SimpleCommand) (string, int, bool) {
+	switch c.Name() {
+	case "echo":
+		args := c.Argv[1:]
+		escapes := false
+		for len(args) > 0 && (args[0] == "-n" || args[0] == "-e" || args[0] == "-E" || args[0] == "-ne" || args[0] == "-en") {
+			escapes = escapes || strings.Contains(args[0], "e")
+			args = args[1:]
+		}
+		text := strings.Join(args, " ")
+		if escapes {
+			text = x.unescape(text)
+		}
+		return text, 1, true
+	case "printf":
+		args := c.Argv[1:]
+		if len(args) > 1 && args[0] == "-v" {
+			return "", 0, false
+		}
+		if len(args) > 0 && args[0] == "--" {
+			args = args[1:]
+		}
+		if len(args) == 0 {
+			return "", 0, false
+		}
+		return x.printf(args[0], args[1:]), 1, true
+	}
+	for _, r := range c.Redirects {
+		if r.Op == "<<<" {
+			return r.Target, 0, true
+		}
+	}
+	return "", 0, false
+}
+
+// printf renders the %s, %b and %d verbs of a printf format, enough to
+// recover payloads assembled from escapes and arguments.
+func (x *expander) printf(format string, args []string) string {
+	format = x.unescape(format)
+	var sb strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i+1 >= len(format) {
+			sb.WriteByte(format[i])
+			continue
+		}
+		i++
+		verb := format[i]
+		if verb == '%' {
+			sb.WriteByte('%')
+			continue
+		}
+		arg := ""
+		if len(args) > 0 {
+			arg, args = args[0], args[1:]
+		}
+		if verb == 'b' {
+			arg = x.unescape(arg)
+		}
+		sb.WriteString(arg)
+	}
+	return sb.String()
+}
+
+// unescape interprets echo -e / printf backslash escapes, recording a hex
+// or octal layer when the text was spelled with numeric escapes.
+func (x *expander) unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	l := &lexer{src: s + "'"}
+	text, encoding := l.readANSIC()
+	if encoding != "" {
+		x.layer(encoding, text)
+	}
+	return text
+}
+
+func isShell(name string) bool {
+	switch name {
+	case "sh", "bash", "dash", "zsh", "ksh", "ash", "busybox":
+		return true
+	}
+	return false
+}
+
+// decoderFor returns the decoding a pipeline stage applies to its input,
+// or nil when the stage is not a decoder.
+func decoderFor(c SimpleCommand) (string, func(string) (string, error)) {
+	args := c.Argv[1:]
+	switch c.Name() {
+	case "base64":
+		if hasShortFlag(args, 'd') || hasShortFlag(args, 'D') || contains(args, "--decode") {
+			return "base64", decodeBase64
+		}
+	case "base32":
+		if hasShortFlag(args, 'd') || contains(args, "--decode") {
+			return "base32", decodeBase32
+		}
+	case "openssl":
+		if (contains(args, "base64") || contains(args, "-base64") || contains(args, "-a")) && contains(args, "-d") {
+			return "base64", decodeBase64
+		}
+	case "xxd":
+		if hasShortFlag(args, 'r') && hasShortFlag(args, 'p') {
+			return "hex", decodeHex
+		}
+	case "rev":
+		return "rev", reverseLines
+	case "gunzip", "zcat":
+		return "gzip", gunzip
+	case "gzip":
+		if hasShortFlag(args, 'd') || contains(args, "--decompress") {
+			return "gzip", gunzip
+		}
+	case "tr":
+		if len(args) == 2 && (strings.Contains(args[1], "N-ZA-M") || strings.Contains(args[1], "n-za-m")) {
+			return "rot13", rot13
+		}
+	}
+	return "", nil
+}
+
+func hasShortFlag(args []string, flag byte) bool {
+	for _, a := range args {
+		if len(a) > 1 && a[0] == '-' && a[1] != '-' && strings.IndexByte(a[1:], flag) >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func decodeBase64(s string) (string, error) {
+	s = strings.Join(strings.Fields(s), "")
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if b, err := enc.DecodeString(s); err == nil {
+			return capped(b)
+		}
+	}
+	return "", errNotEncoded
+}
+
+func decodeBase32(s string) (string, error) {
+	b, err := base32.StdEncoding.DecodeString(strings.Join(strings.Fields(s), ""))
+	if err != nil {
+		return "", errNotEncoded
+	}
+	return capped(b)
+}
+
+func decodeHex(s string) (string, error) {
+	b, err := hex.DecodeString(strings.Join(strings.Fields(s), ""))
+	if err != nil {
+		return "", errNotEncoded
+	}
+	return capped(b)
+}
+
+func gunzip(s string) (string, error) {
+	r, err := gzip.NewReader(strings.NewReader(s))
+	if err != nil {
+		return "", errNotEncoded
+	}
+	b, err := io.ReadAll(io.LimitReader(r, maxDecodedSize+1))
+	if err != nil {
+		return "", errNotEncoded
+	}
+	return capped(b)
+}
+
+func reverseLines(s string) (string, error) {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		r := []rune(line)
+		for a, b := 0, len(r)-1; a < b; a, b = a+1, b-1 {
+			r[a], r[b] = r[b], r[a]
+		}
+		lines[i] = string(r)
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func rot13(s string) (string, error) {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return 'a' + (r-'a'+13)%26
+		case r >= 'A' && r <= 'Z':
+			return 'A' + (r-'A'+13)%26
+		}
+		return r
+	}, s), nil
+}
+
+func capped(b []byte) (string, error) {
+	if len(b) > maxDecodedSize {
+		return "", fmt.Errorf("decoded payload larger than %d bytes", maxDecodedSize)
+	}
+	return string(b), nil
+}
+
+// isText reports whether a decoded payload looks like something a shell
+// would run rather than binary data.
+func isText(s string) bool {
+	if !utf8.ValidString(s) {
+		return false
+	}
+	for _, r := range s {
+		if r < ' ' && r != '\n' && r != '\t' && r != '\r' {
+			return false
+		}
+	}
+	return true
+}
+
+func printable(s string) string {
+	if isText(s) {
+		return s
+	}
+	return fmt.Sprintf("<%d bytes binary>", len(s))
+}
+
+// embeddedDecoders find encoded blobs inside perl -e and python -c code.
+var embeddedDecoders = []struct {
+	encoding string
+	pattern  *regexp.Regexp
+	decode   func(string) (string, error)
+}{
+	{"pack", regexp.MustCompile(`pack\s*\(?\s*["']H\*["']\s*,\s*["']([0-9a-fA-F]+)["']`), decodeHex},
+	{"base64", regexp.MustCompile(`(?:decode_base64|b64decode)\s*\(\s*b?["']([A-Za-z0-9+/=\s]+)["']`), decodeBase64},
+	{"base64", regexp.MustCompile(`["']([A-Za-z0-9+/=]+)["']\.decode\(\s*["']base64["']`), decodeBase64},
+	{"hex", regexp.MustCompile(`(?:fromhex|unhexlify)\s*\(\s*b?["']([0-9a-fA-F]+)["']`), decodeHex},
+	{"hex", regexp.MustCompile(`["']([0-9a-fA-F]+)["']\.decode\(\s*["']hex["']`), decodeHex},
+}
+
+// shellCall finds the shell command strings interpreter code hands to
+// system(), popen(), subprocess and friends.
+var shellCall = regexp.MustCompile(`\b(?:system|popen|Popen|check_output|check_call|call|run|getoutput|getstatusoutput|exec|qx)\s*\(?\s*\[?\s*["']([^"']+)["']`)
+
+// shellExec spots code that hands a computed string to the shell, as in
+// system(pack(...)).
+var shellExec = regexp.MustCompile(`\b(?:system|popen|Popen|check_output|check_call|getoutput|getstatusoutput|exec|qx)\b`)
+
+// interpreter decodes blobs embedded in perl or python code and parses
+// the shell commands the code runs.
+func (x *expander) interpreter(code string, depth int, via string) {
+	if depth > maxExpandDepth {
+		return
+	}
+	for _, d := range embeddedDecoders {
+		for _, m := range d.pattern.FindAllStringSubmatch(code, -1) {
+			out, err := d.decode(m[1])
+			if err != nil || !x.layer(d.encoding, out) {
+				continue
+			}
+			x.level++
+			if shellExec.MatchString(code) && !shellCall.MatchString(out) && !shellExec.MatchString(out) && isText(out) {
+				x.script(out, depth+1, via)
+			} else {
+				x.interpreter(out, depth+1, via)
+			}
+			x.level--
+		}
+	}
+	for _, m := range shellCall.FindAllStringSubmatch(code, -1) {
+		x.script(m[1], depth+1, via)
+	}
+}
+
+// interpreterPayload returns the index of the code argument of perl -e
+// or python -c.
+func interpreterPayload(name string, args []string) (int, bool) {
+	flag := "-c"
+	if name == "perl" {
+		flag = "-e"
+	}
+	for i, a := range args {
+		if a == flag || (name == "perl" && a == "-E") {
+			return i + 1, i+1 < len(args)
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,72 @@
+package analyzer
+
+import (
+	Session "GradGuard/internal/Session"
+	"fmt"
+)
+
+const (
+	OperatorBot     = "bot"
+	OperatorPasting = "human_pasting"
+	OperatorTyping  = "human_typing"
+)
+
+const (
+	// minTypedLines is how many lines of input a session needs before
+	// its keystrokes say anything.
+	minTypedLines = 3
+	// fastestTypistMs is a key interval no person sustains.
+	fastestTypistMs = 25.0
+)
+
+// OperatorClass is who keystroke dynamics say is at the keyboard.
+type OperatorClass struct {
+	Class      string  `json:"class"`
+	Confidence float64 `json:"confidence"`
+	Evidence   string  `json:"evidence"`
+}
+
+// ClassifyOperator tells a scripted client from a person pasting and a
+// person typing. Scripts send each line whole with its Enter and never
+// correct themselves; pasting people send bursts but press Enter and fix
+// mistakes by hand; typists send single keys at uneven human speed. It
+// reports false until there is enough input to go on.
+func ClassifyOperator(s Session.KeystrokeStats) (OperatorClass, bool) {
+	if s.Lines < minTypedLines {
+		return OperatorClass{}, false
+	}
+	paste := s.PasteRatio()
+	whole := float64(s.BurstLines) / float64(s.Lines)
+
+	switch {
+	case paste >= 0.95 && whole >= 0.8 && s.Backspaces == 0:
+		return OperatorClass{OperatorBot, 0.85, fmt.Sprintf(
+			"%d of %d lines arrived whole with their Enter, nothing typed or corrected", s.BurstLines, s.Lines)}, true
+	case s.Keys >= 10 && s.MeanIntervalMs > 0 && s.MeanIntervalMs < fastestTypistMs:
+		return OperatorClass{OperatorBot, 0.8, fmt.Sprintf(
+			"keys %.0fms apart on average, faster than anyone types", s.MeanIntervalMs)}, true
+	case s.Keys >= 10 && s.MeanIntervalMs > 0 && s.IntervalCV < 0.1:
+		return OperatorClass{OperatorBot, 0.7, fmt.Sprintf(
+			"key intervals vary by only %.0f%%", s.IntervalCV*100)}, true
+	case paste >= 0.5:
+		confidence := 0.6
+		if whole < 0.5 || s.Backspaces > 0 {
+			confidence = 0.75
+		}
+		return OperatorClass{OperatorPasting, confidence, fmt.Sprintf(
+			"%.0f%% of input pasted in %d burst(s), %d of %d lines entered by hand, %d backspace(s)",
+			paste*100, s.Bursts, s.Lines-s.BurstLines, s.Lines, s.Backspaces)}, true
+	case s.Keys >= 20:
+		confidence := 0.55
+		if s.IntervalCV >= 0.3 {
+			confidence += 0.15
+		}
+		if s.Backspaces > 0 {
+			confidence += 0.1
+		}
+		return OperatorClass{OperatorTyping, confidence, fmt.Sprintf(
+			"%d keys typed %.0fms apart on average, varying by %.0f%%, %.1f%% backspaces",
+			s.Keys, s.MeanIntervalMs, s.IntervalCV*100, s.BackspaceRate()*100)}, true
+	}
+	return OperatorClass{}, false
+}
@@ -2,15 +2,13 @@ package analyzer
 
 import (
 	Session "GradGuard/internal/Session"
-	"encoding/json"
-	"os"
-	"path/filepath"
-	"sync"
+	"GradGuard/internal/attack"
+	"GradGuard/internal/events"
+	"math"
+	"sort"
 	"time"
 )
 
-var reportMu sync.Mutex
-
 type SessionReport struct {
 	SessionID           string         `json:"session_id"`
 	RemoteAddr          string         `json:"remote_addr"`
@@ -19,19 +17,68 @@ type SessionReport struct {
 	DurationSeconds     float64        `json:"duration_seconds"`
 	TotalCommands       int            `json:"total_commands"`
 	FinalSuspicionScore int            `json:"final_suspicion_score"`
+	PeakSuspicionScore  int            `json:"peak_suspicion_score"`
 	Verdict             string         `json:"verdict"`
 	CategoryBreakdown   map[string]int `json:"category_breakdown"`
 	FlaggedCommands     []string       `json:"flagged_commands"`
+	Techniques          []TechniqueHit `json:"techniques"`
+
+	ScoreChanges []Session.ScoreChange  `json:"score_changes"`
+	Assessment   Assessment             `json:"assessment"`
+	Keystrokes   Session.KeystrokeStats `json:"keystrokes"`
+	Operator     *OperatorClass         `json:"operator,omitempty"`
+	// ML is what the model said about the session, kept as it was when
+	// the verdict was reached.
+	ML *MLOpinion `json:"ml,omitempty"`
+}
+
+type TechniqueHit struct {
+	Tactic    string `json:"tactic"`
+	Technique string `json:"technique"`
+	Name      string `json:"name"`
+	Count     int    `json:"count"`
 }
 
-func WriteReport(session *Session.SessionState) {
-	reportMu.Lock()
-	defer reportMu.Unlock()
+func techniqueHits(counts map[attack.Tag]int) []TechniqueHit {
+	hits := []TechniqueHit{}
+	for tag, n := range counts {
+		hits = append(hits, TechniqueHit{
+			Tactic:    tag.Tactic,
+			Technique: tag.Technique,
+			Name:      attack.TechniqueName(tag.Technique),
+			Count:     n,
+		})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		a, b := attack.TacticIndex(hits[i].Tactic), attack.TacticIndex(hits[j].Tactic)
+		if a != b {
+			return a < b
+		}
+		return hits[i].Technique < hits[j].Technique
+	})
+	return hits
+}
 
-	dir := "logs/reports"
-	_ = os.MkdirAll(dir, 0755)
+func peakScore(changes []Session.ScoreChange) int {
+	peak := 0.0
+	for _, c := range changes {
+		peak = math.Max(peak, c.Score)
+	}
+	return int(math.Round(peak))
+}
 
+// WriteReport writes the end-of-session report. opinion is the model's
+// view of the session and may be nil.
+func WriteReport(session *Session.SessionState, opinion *MLOpinion) {
 	endTime := time.Now()
+	breakdown := map[string]int{}
+	for _, c := range Categories() {
+		breakdown[string(c.Name)] = 0
+	}
+	for name, n := range session.CategoryCounts {
+		breakdown[name] = n
+	}
+	assessment := Assess(session, opinion)
 	report := SessionReport{
 		SessionID:           session.ID,
 		RemoteAddr:          session.RemoteAddr,
@@ -40,19 +87,28 @@ func WriteReport(session *Session.SessionState) {
 		DurationSeconds:     endTime.Sub(session.StartTime).Seconds(),
 		TotalCommands:       session.CommandCount,
 		FinalSuspicionScore: session.SuspicionScore,
-		Verdict:             Verdict(session),
-		CategoryBreakdown:   session.CategoryCounts,
+		PeakSuspicionScore:  peakScore(session.ScoreChanges),
+		Verdict:             assessment.Verdict,
+		CategoryBreakdown:   breakdown,
 		FlaggedCommands:     session.FlaggedCommands,
+		Techniques:          techniqueHits(session.Techniques),
+		ScoreChanges:        session.ScoreChanges,
+		Assessment:          assessment,
+		Keystrokes:          session.Keystrokes.Stats(),
+		ML:                  opinion,
 	}
-
-	path := filepath.Join(dir, session.ID+"-report.json")
-	file, err := os.Create(path)
-	if err != nil {
-		return
+	if op, ok := ClassifyOperator(report.Keystrokes); ok {
+		report.Operator = &op
+	}
+	if report.ScoreChanges == nil {
+		report.ScoreChanges = []Session.ScoreChange{}
 	}
-	defer file.Close()
 
-	enc := json.NewEncoder(file)
-	enc.SetIndent("", "  ")
-	enc.Encode(report)
+	events.Publish(events.Event{
+		Kind:       events.KindSessionEnd,
+		Time:       endTime.UTC(),
+		SessionID:  session.ID,
+		RemoteAddr: session.RemoteAddr,
+		Data:       report,
+	})
 }
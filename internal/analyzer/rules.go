@@ -0,0 +1,219 @@
+package analyzer
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Matcher describes which simple commands a rule fires on. Every field
+// that is set must match. Commands, Args, Paths, Writes and PipeTo match
+// if any listed value matches; Flags requires all listed flags.
+//
+// A matcher with Encodings fires on decoded layers instead of commands:
+// once for every layer in one of those encodings whose decoded text
+// matches Regex, if set.
+type Matcher struct {
+	Commands []string `json:"commands,omitempty"`
+	Flags    []string `json:"flags,omitempty"`
+	Args     []string `json:"args,omitempty"`
+	ArgRegex string   `json:"arg_regex,omitempty"`
+	Paths    []string `json:"paths,omitempty"`
+	Writes   []string `json:"writes,omitempty"`
+	PipeTo   []string `json:"pipe_to,omitempty"`
+	Regex    string   `json:"regex,omitempty"`
+	Bare     bool     `json:"bare,omitempty"`
+
+	Encodings []string `json:"encodings,omitempty"`
+
+	argRegex *regexp.Regexp
+	regex    *regexp.Regexp
+}
+
+type Rule struct {
+	ID        string   `json:"id"`
+	Category  Category `json:"category"`
+	Weight    int      `json:"weight"`
+	Reason    string   `json:"reason"`
+	Tactic    string   `json:"tactic,omitempty"`
+	Technique string   `json:"technique,omitempty"`
+	Match     Matcher  `json:"match"`
+	Disabled  bool     `json:"disabled,omitempty"`
+}
+
+func (r *Rule) compile() error {
+	m := &r.Match
+	// Bare only narrows the others; on its own it would match nearly
+	// every command.
+	if len(m.Commands) == 0 && len(m.Flags) == 0 && len(m.Args) == 0 && m.ArgRegex == "" &&
+		len(m.Paths) == 0 && len(m.Writes) == 0 && len(m.PipeTo) == 0 && m.Regex == "" &&
+		len(m.Encodings) == 0 {
+		return fmt.Errorf("rule %s: match has no conditions", r.ID)
+	}
+	for _, e := range m.Encodings {
+		if !contains(encodings, e) {
+			return fmt.Errorf("rule %s: unknown encoding %q", r.ID, e)
+		}
+	}
+	var err error
+	if m.ArgRegex != "" {
+		if m.argRegex, err = regexp.Compile(m.ArgRegex); err != nil {
+			return fmt.Errorf("rule %s: arg_regex: %w", r.ID, err)
+		}
+	}
+	if m.Regex != "" {
+		if m.regex, err = regexp.Compile(m.Regex); err != nil {
+			return fmt.Errorf("rule %s: regex: %w", r.ID, err)
+		}
+	}
+	return nil
+}
+
+// commandFacts is the token view of one simple command that rules are
+// evaluated against.
+type commandFacts struct {
+	name     string
+	flags    map[string]bool
+	operands []string
+	paths    []string
+	writes   []string
+	pipeTo   string
+	text     string
+	encoding string
+}
+
+func factsFor(c SimpleCommand, next *SimpleCommand) commandFacts {
+	c = c.Own()
+	f := commandFacts{
+		name:  c.Name(),
+		flags: map[string]bool{},
+		text:  c.String(),
+	}
+	if next != nil {
+		f.pipeTo = next.Name()
+	}
+
+	var args []string
+	if len(c.Argv) > 0 {
+		if strings.Contains(c.Argv[0], "/") {
+			f.paths = append(f.paths, c.Argv[0])
+		}
+		args = c.Argv[1:]
+	}
+	for _, a := range args {
+		switch {
+		case strings.HasPrefix(a, "--"):
+			f.flags[a] = true
+			if eq := strings.IndexByte(a, '='); eq > 0 {
+				f.flags[a[:eq]] = true
+				f.addPath(a[eq+1:])
+			}
+		case strings.HasPrefix(a, "-") && len(a) > 1:
+			f.flags[a] = true
+			for _, ch := range a[1:] {
+				f.flags["-"+string(ch)] = true
+			}
+		default:
+			f.operands = append(f.operands, a)
+			f.addPath(a)
+			if eq := strings.IndexByte(a, '='); eq > 0 {
+				f.addPath(a[eq+1:])
+			}
+		}
+	}
+
+	for _, r := range c.Redirects {
+		f.addPath(r.Target)
+		if strings.HasPrefix(r.Op, ">") || r.Op == "&>" || r.Op == "&>>" || r.Op == "<>" {
+			f.writes = append(f.writes, r.Target)
+		}
+	}
+	if f.name == "tee" {
+		f.writes = append(f.writes, f.operands...)
+	}
+	return f
+}
+
+func factsForPayload(d DecodedPayload) commandFacts {
+	return commandFacts{encoding: d.Encoding, text: d.Text}
+}
+
+func (f *commandFacts) addPath(p string) {
+	if strings.HasPrefix(p, "/") || strings.HasPrefix(p, "./") || strings.HasPrefix(p, "~") {
+		f.paths = append(f.paths, p)
+	}
+}
+
+func (m *Matcher) matches(f commandFacts) bool {
+	if len(m.Encodings) > 0 || f.encoding != "" {
+		return contains(m.Encodings, f.encoding) && (m.regex == nil || m.regex.MatchString(f.text))
+	}
+	if len(m.Commands) > 0 && !contains(m.Commands, f.name) {
+		return false
+	}
+	for _, flag := range m.Flags {
+		if !f.flags[flag] {
+			return false
+		}
+	}
+	if m.Bare && len(f.operands) > 0 {
+		return false
+	}
+	if len(m.Args) > 0 && !anyOf(f.operands, func(a string) bool { return contains(m.Args, a) }) {
+		return false
+	}
+	if m.argRegex != nil && !anyOf(f.operands, m.argRegex.MatchString) {
+		return false
+	}
+	if len(m.Paths) > 0 && !anyOf(f.paths, func(p string) bool { return matchPath(m.Paths, p) }) {
+		return false
+	}
+	if len(m.Writes) > 0 && !anyOf(f.writes, func(p string) bool { return matchPath(m.Writes, p) }) {
+		return false
+	}
+	if len(m.PipeTo) > 0 && !contains(m.PipeTo, f.pipeTo) {
+		return false
+	}
+	if m.regex != nil && !m.regex.MatchString(f.text) {
+		return false
+	}
+	return true
+}
+
+// matchPath reports whether p matches one of the patterns. A pattern
+// ending in "/" matches everything below that directory; anything else
+// is a path.Match glob.
+func matchPath(patterns []string, p string) bool {
+	clean := path.Clean(p)
+	for _, pat := range patterns {
+		if strings.HasSuffix(pat, "/") {
+			if strings.HasPrefix(clean+"/", pat) {
+				return true
+			}
+			continue
+		}
+		if ok, _ := path.Match(pat, clean); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func anyOf(list []string, fn func(string) bool) bool {
+	for _, v := range list {
+		if fn(v) {
+			return true
+		}
+	}
+	return false
+}
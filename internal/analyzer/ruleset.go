@@ -0,0 +1,244 @@
+package analyzer
+
+import (
+	"GradGuard/internal/attack"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+//go:embed rules/*.json
+var builtinRules embed.FS
+
+type ruleFile struct {
+	Version    int            `json:"version"`
+	Categories []CategoryInfo `json:"categories"`
+	Rules      []Rule         `json:"rules"`
+}
+
+type RuleSet struct {
+	Rules      []Rule
+	Categories []CategoryInfo
+	Sources    []string
+	LoadedAt   time.Time
+}
+
+func (s *RuleSet) hasCategory(name Category) bool {
+	for _, c := range s.Categories {
+		if c.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+var active atomic.Pointer[RuleSet]
+
+func init() {
+	set, err := LoadRules("")
+	if err != nil {
+		panic(err)
+	}
+	active.Store(set)
+}
+
+func ActiveRules() *RuleSet {
+	return active.Load()
+}
+
+func SetActiveRules(set *RuleSet) {
+	active.Store(set)
+}
+
+// LoadRules reads the built-in rules and then every *.json file in dir in
+// name order. Rules are written in JSON only; a YAML file in dir fails
+// the load rather than being passed over unnoticed. A rule whose id is already defined replaces the earlier
+// one, and "disabled": true removes it. Any invalid rule fails the whole
+// load so a half-edited file never replaces a working set.
+func LoadRules(dir string) (*RuleSet, error) {
+	set := &RuleSet{LoadedAt: time.Now()}
+	set.Categories = append(set.Categories, builtinCategories...)
+	byID := map[string]int{}
+
+	add := func(source string, data []byte) error {
+		var f ruleFile
+		if err := json.Unmarshal(data, &f); err != nil {
+			return fmt.Errorf("%s: %w", source, err)
+		}
+		if f.Version != 1 {
+			return fmt.Errorf("%s: unsupported rule file version %d", source, f.Version)
+		}
+		for _, c := range f.Categories {
+			if c.Name == "" {
+				return fmt.Errorf("%s: category without a name", source)
+			}
+			if set.hasCategory(c.Name) {
+				return fmt.Errorf("%s: category %q already defined", source, c.Name)
+			}
+			if c.Label == "" {
+				c.Label = string(c.Name)
+			}
+			set.Categories = append(set.Categories, c)
+		}
+		seen := map[string]bool{}
+		for i := range f.Rules {
+			r := &f.Rules[i]
+			if seen[r.ID] {
+				return fmt.Errorf("%s: duplicate rule id %q", source, r.ID)
+			}
+			seen[r.ID] = true
+			if err := r.validate(set); err != nil {
+				return fmt.Errorf("%s: rule #%d: %w", source, i+1, err)
+			}
+			if idx, ok := byID[r.ID]; ok {
+				set.Rules[idx] = *r
+			} else {
+				byID[r.ID] = len(set.Rules)
+				set.Rules = append(set.Rules, *r)
+			}
+		}
+		set.Sources = append(set.Sources, source)
+		return nil
+	}
+
+	builtin, _ := builtinRules.ReadDir("rules")
+	for _, e := range builtin {
+		data, err := builtinRules.ReadFile("rules/" + e.Name())
+		if err != nil {
+			return nil, err
+		}
+		if err := add("builtin:"+e.Name(), data); err != nil {
+			return nil, err
+		}
+	}
+
+	files, err := ruleFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := add(path, data); err != nil {
+			return nil, err
+		}
+	}
+
+	enabled := set.Rules[:0]
+	for _, r := range set.Rules {
+		if !r.Disabled {
+			enabled = append(enabled, r)
+		}
+	}
+	set.Rules = enabled
+	sortCategories(set.Categories)
+	return set, nil
+}
+
+func ruleFiles(dir string) ([]string, error) {
+	if dir == "" {
+		return nil, nil
+	}
+	for _, pattern := range []string{"*.yaml", "*.yml"} {
+		if yaml, _ := filepath.Glob(filepath.Join(dir, pattern)); len(yaml) > 0 {
+			return nil, fmt.Errorf("%s: rule files are JSON, YAML is not read", yaml[0])
+		}
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func (r *Rule) validate(set *RuleSet) error {
+	if r.ID == "" {
+		return fmt.Errorf("missing id")
+	}
+	if r.Disabled {
+		return nil
+	}
+	if r.Category == CategoryUnknown || !set.hasCategory(r.Category) {
+		return fmt.Errorf("rule %s: unknown category %q", r.ID, r.Category)
+	}
+	if r.Weight < 0 || r.Weight > 100 {
+		return fmt.Errorf("rule %s: weight %d out of range 0-100", r.ID, r.Weight)
+	}
+	if strings.TrimSpace(r.Reason) == "" {
+		return fmt.Errorf("rule %s: missing reason", r.ID)
+	}
+	if r.Technique != "" {
+		if !attack.ValidTechniqueID(r.Technique) {
+			return fmt.Errorf("rule %s: malformed technique %q", r.ID, r.Technique)
+		}
+		if r.Tactic == "" {
+			r.Tactic = attack.PrimaryTactic(r.Technique)
+		}
+		if r.Tactic == "" {
+			return fmt.Errorf("rule %s: technique %s is not in the catalogue, set tactic explicitly", r.ID, r.Technique)
+		}
+	}
+	if r.Tactic != "" && !attack.ValidTacticID(r.Tactic) {
+		return fmt.Errorf("rule %s: unknown tactic %q", r.ID, r.Tactic)
+	}
+	return r.compile()
+}
+
+// WatchRules polls dir and swaps in a freshly loaded rule set whenever a
+// rule file is added, removed or modified. Classification reads the
+// active set on every call, so live sessions pick up the new rules on
+// their next command. A set that fails validation is logged and the
+// previous one stays active. An interval of 0 loads the rules once and
+// does not watch for changes.
+func WatchRules(dir string, interval time.Duration) {
+	if set, err := LoadRules(dir); err != nil {
+		log.Printf("rules: %v — keeping built-in rules", err)
+	} else {
+		active.Store(set)
+		log.Printf("rules: loaded %d rules from %s", len(set.Rules), strings.Join(set.Sources, ", "))
+	}
+
+	if interval <= 0 {
+		log.Printf("rules: reloading is off")
+		return
+	}
+	last := dirStamp(dir)
+	for range time.Tick(interval) {
+		stamp := dirStamp(dir)
+		if stamp == last {
+			continue
+		}
+		last = stamp
+
+		set, err := LoadRules(dir)
+		if err != nil {
+			log.Printf("rules: reload failed: %v — keeping previous rules", err)
+			continue
+		}
+		active.Store(set)
+		log.Printf("rules: reloaded %d rules", len(set.Rules))
+	}
+}
+
+func dirStamp(dir string) string {
+	files, _ := ruleFiles(dir)
+	var sb strings.Builder
+	for _, f := range files {
+		info, err := os.Stat(f)
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(&sb, "%s:%d:%d;", f, info.Size(), info.ModTime().UnixNano())
+	}
+	return sb.String()
+}
@@ -0,0 +1,127 @@
+package analyzer
+
+import (
+	Session "GradGuard/internal/Session"
+	"fmt"
+	"math"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// ScoringModel turns classified commands into the session suspicion
+// score. The score halves every HalfLife of inactivity, a command typed
+// again counts RepeatFactor times what it counted the previous time, and
+// no category can add more than its cap over a whole session.
+type ScoringModel struct {
+	HalfLife     time.Duration
+	RepeatFactor float64
+	CategoryCaps map[Category]float64
+	Max          float64
+}
+
+func DefaultScoring() ScoringModel {
+	return ScoringModel{
+		HalfLife:     15 * time.Minute,
+		RepeatFactor: 0.5,
+		CategoryCaps: map[Category]float64{
+			CategoryUnknown:  10,
+			CategoryRecon:    30,
+			CategoryDownload: 40,
+		},
+		Max: 100,
+	}
+}
+
+var scoring atomic.Pointer[ScoringModel]
+
+func init() {
+	m := DefaultScoring()
+	scoring.Store(&m)
+}
+
+func ActiveScoring() *ScoringModel {
+	return scoring.Load()
+}
+
+func SetScoring(m ScoringModel) {
+	if m.Max <= 0 {
+		m.Max = 100
+	}
+	scoring.Store(&m)
+}
+
+// decay brings the session score forward to at and returns how much it
+// lost on the way.
+func (m *ScoringModel) decay(session *Session.SessionState, at time.Time) float64 {
+	before := session.Score
+	if m.HalfLife > 0 && !session.ScoredAt.IsZero() && at.After(session.ScoredAt) {
+		elapsed := at.Sub(session.ScoredAt).Seconds()
+		session.Score *= math.Pow(0.5, elapsed/m.HalfLife.Seconds())
+	}
+	session.ScoredAt = at
+	return session.Score - before
+}
+
+// Apply adds the weight of a classified command to the session score and
+// records the change, rule by rule.
+func (m *ScoringModel) Apply(session *Session.SessionState, cmd string, result ClassificationResult, at time.Time) Session.ScoreChange {
+	if session.CategoryScore == nil {
+		session.CategoryScore = map[string]float64{}
+	}
+	if session.CommandRepeats == nil {
+		session.CommandRepeats = map[string]int{}
+	}
+
+	change := Session.ScoreChange{
+		CommandIndex: session.CommandCount,
+		Command:      cmd,
+		Time:         at.UTC(),
+		Decay:        round2(m.decay(session, at)),
+	}
+
+	key := strings.Join(strings.Fields(cmd), " ")
+	session.CommandRepeats[key]++
+	repeats := session.CommandRepeats[key]
+	factor := 1.0
+	if repeats > 1 && m.RepeatFactor < 1 {
+		factor = math.Pow(m.RepeatFactor, float64(repeats-1))
+	}
+
+	for _, w := range result.Weighted {
+		points := float64(w.Weight) * factor
+		var notes []string
+		if factor < 1 {
+			notes = append(notes, fmt.Sprintf("repeat ×%d", repeats))
+		}
+		if limit, ok := m.CategoryCaps[w.Category]; ok {
+			left := math.Max(0, limit-session.CategoryScore[string(w.Category)])
+			if points > left {
+				points = left
+				notes = append(notes, fmt.Sprintf("%s cap %.0f", w.Category, limit))
+			}
+		}
+		if room := m.Max - session.Score; points > room {
+			points = math.Max(0, room)
+			notes = append(notes, fmt.Sprintf("score max %.0f", m.Max))
+		}
+		session.CategoryScore[string(w.Category)] += points
+		session.Score += points
+		change.Contributions = append(change.Contributions, Session.ScoreContribution{
+			RuleID:   w.RuleID,
+			Category: string(w.Category),
+			Weight:   w.Weight,
+			Points:   round2(points),
+			Note:     strings.Join(notes, ", "),
+		})
+	}
+
+	session.SuspicionScore = int(math.Round(session.Score))
+	change.Score = round2(session.Score)
+	session.ScoreChanges = append(session.ScoreChanges, change)
+	return change
+}
+
+func round2(f float64) float64 {
+	return math.Round(f*100) / 100
+}
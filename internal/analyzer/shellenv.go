@@ -0,0 +1,447 @@
+package analyzer
+
+import (
+	"path"
+	"strings"
+)
+
+const (
+	maxExpandDepth = 8
+	// maxExpandWork bounds how many pipelines and maxExpandSource how
+	// many bytes of script one line may expand into. Depth alone does not
+	// bound the work: an alias or eval that runs itself several times
+	// grows exponentially within it.
+	maxExpandWork   = 512
+	maxExpandSource = 64 << 10
+)
+
+type ShellEnv struct {
+	Vars    map[string]string
+	Aliases map[string]string
+}
+
+func NewShellEnv() *ShellEnv {
+	return &ShellEnv{
+		Vars: map[string]string{
+			"HOME":  "/root",
+			"USER":  "root",
+			"SHELL": "/bin/bash",
+			"PATH":  "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin",
+		},
+		Aliases: map[string]string{},
+	}
+}
+
+type Redirect struct {
+	Op     string
+	Target string
+}
+
+type SimpleCommand struct {
+	Assigns   []string
+	Argv      []string
+	Redirects []Redirect
+	Via       string
+	// Delegated is the index in Argv where arguments handed to another
+	// command (eval, sh -c, sudo ...) begin; those are classified on
+	// their own, so Own leaves them out.
+	Delegated int
+}
+
+func (c SimpleCommand) Own() SimpleCommand {
+	if c.Delegated <= 0 || c.Delegated > len(c.Argv) {
+		return c
+	}
+	own := c
+	own.Argv = c.Argv[:c.Delegated]
+	return own
+}
+
+func (c SimpleCommand) Name() string {
+	if len(c.Argv) == 0 {
+		return ""
+	}
+	return path.Base(c.Argv[0])
+}
+
+func (c SimpleCommand) String() string {
+	var sb strings.Builder
+	sb.WriteString(strings.Join(append(append([]string{}, c.Assigns...), c.Argv...), " "))
+	for _, r := range c.Redirects {
+		if sb.Len() > 0 {
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(r.Op)
+		sb.WriteByte(' ')
+		sb.WriteString(r.Target)
+	}
+	return sb.String()
+}
+
+type Pipeline struct {
+	Commands []SimpleCommand
+	Depth    int
+
+	output string
+}
+
+func (p Pipeline) String() string {
+	parts := make([]string, len(p.Commands))
+	for i, c := range p.Commands {
+		parts[i] = c.String()
+	}
+	return strings.Join(parts, " | ")
+}
+
+// ParsedLine is a command line broken into the simple commands it runs
+// and the layers of encoding that had to be peeled off to find them.
+type ParsedLine struct {
+	Pipelines []Pipeline
+	Decoded   []DecodedPayload
+}
+
+// ParseLine splits a raw shell line into its simple commands, expanding
+// aliases and variables from env and descending into subshells, command
+// substitutions, eval and `sh -c` payloads and encoded payloads.
+// Assignments, alias and unset builtins seen on the line are applied to
+// env so later lines in the same session resolve them.
+func ParseLine(line string, env *ShellEnv) ParsedLine {
+	if env == nil {
+		env = NewShellEnv()
+	}
+	x := &expander{env: env}
+	x.script(line, 0, "")
+	return ParsedLine{Pipelines: x.out, Decoded: x.decoded}
+}
+
+func ParseCommandLine(line string, env *ShellEnv) []Pipeline {
+	return ParseLine(line, env).Pipelines
+}
+
+type expander struct {
+	env     *ShellEnv
+	out     []Pipeline
+	decoded []DecodedPayload
+	// level is how many decoded layers deep the expander currently is.
+	level int
+	// work and source count what the line has cost so far; see
+	// maxExpandWork.
+	work, source int
+	// expanding holds the aliases being expanded, which, as in bash, are
+	// not expanded again inside their own expansion.
+	expanding map[string]bool
+}
+
+func (x *expander) spent() bool {
+	return x.work >= maxExpandWork || x.source >= maxExpandSource
+}
+
+func (x *expander) script(src string, depth int, via string) {
+	if depth > maxExpandDepth || x.spent() || strings.TrimSpace(src) == "" {
+		return
+	}
+	x.source += len(src)
+	for _, pl := range parseScript(src) {
+		x.pipeline(pl, depth, via)
+	}
+}
+
+func (x *expander) pipeline(pl *astPipeline, depth int, via string) {
+	if x.spent() {
+		return
+	}
+	x.work++
+	idx := len(x.out)
+	x.out = append(x.out, Pipeline{Depth: depth})
+
+	var cmds []SimpleCommand
+	for _, c := range pl.commands {
+		for _, b := range c.body {
+			x.pipeline(b, depth+1, "subshell")
+		}
+		if len(c.words) == 0 && len(c.redirects) == 0 {
+			continue
+		}
+		if sc, ok := x.command(c, depth, via); ok {
+			cmds = append(cmds, sc)
+		}
+	}
+
+	if len(cmds) == 0 {
+		x.out = append(x.out[:idx], x.out[idx+1:]...)
+		return
+	}
+	x.out[idx].Commands = cmds
+	x.out[idx].output = x.decodePipeline(cmds, depth, via)
+}
+
+func (x *expander) command(c *astCommand, depth int, via string) (SimpleCommand, bool) {
+	words := c.words
+
+	if len(words) > 0 && words[0].isLiteral() {
+		name := words[0].literal()
+		if alias, ok := x.env.Aliases[name]; ok && !x.expanding[name] && depth < maxExpandDepth {
+			rest := make([]string, 0, len(words)-1)
+			for _, w := range words[1:] {
+				rest = append(rest, w.raw)
+			}
+			if x.expanding == nil {
+				x.expanding = map[string]bool{}
+			}
+			x.expanding[name] = true
+			x.script(strings.TrimSpace(alias+" "+strings.Join(rest, " ")), depth+1, "alias")
+			delete(x.expanding, name)
+			return SimpleCommand{}, false
+		}
+	}
+
+	sc := SimpleCommand{Via: via}
+	var assigns [][2]string
+	for len(words) > 0 {
+		name, value, ok := x.assignment(words[0], depth)
+		if !ok {
+			break
+		}
+		assigns = append(assigns, [2]string{name, value})
+		sc.Assigns = append(sc.Assigns, name+"="+value)
+		words = words[1:]
+	}
+
+	for _, w := range words {
+		sc.Argv = append(sc.Argv, x.expand(w, depth)...)
+	}
+	for _, r := range c.redirects {
+		sc.Redirects = append(sc.Redirects, Redirect{
+			Op:     r.op,
+			Target: strings.Join(x.expand(r.target, depth), " "),
+		})
+	}
+
+	if len(sc.Argv) == 0 {
+		for _, a := range assigns {
+			x.env.Vars[a[0]] = a[1]
+		}
+		return sc, true
+	}
+
+	sc.Delegated = x.builtin(sc, depth)
+	return sc, true
+}
+
+func (x *expander) assignment(w word, depth int) (string, string, bool) {
+	if len(w.parts) == 0 || w.parts[0].kind != partLiteral || w.parts[0].quoted {
+		return "", "", false
+	}
+	head := w.parts[0].text
+	eq := strings.IndexByte(head, '=')
+	if eq <= 0 {
+		return "", "", false
+	}
+	name := strings.TrimSuffix(head[:eq], "+")
+	for i := 0; i < len(name); i++ {
+		if !isNameChar(name[i]) || (i == 0 && !isNameStart(name[i])) {
+			return "", "", false
+		}
+	}
+	rest := word{parts: append([]wordPart{{kind: partLiteral, text: head[eq+1:]}}, w.parts[1:]...)}
+	return name, strings.Join(x.expandQuoted(rest, depth), ""), true
+}
+
+// builtin applies the side effects of commands that change how later
+// commands are interpreted and recurses into commands that run other
+// commands from their arguments. It returns the index in sc.Argv where
+// delegated arguments start, or 0.
+func (x *expander) builtin(sc SimpleCommand, depth int) int {
+	args := sc.Argv[1:]
+	switch sc.Name() {
+	case "alias":
+		for _, a := range args {
+			if eq := strings.IndexByte(a, '='); eq > 0 {
+				x.env.Aliases[a[:eq]] = a[eq+1:]
+			}
+		}
+		return 1
+	case "unalias":
+		for _, a := range args {
+			if a == "-a" {
+				x.env.Aliases = map[string]string{}
+			}
+			delete(x.env.Aliases, a)
+		}
+	case "export", "declare", "typeset", "local", "readonly":
+		for _, a := range args {
+			if eq := strings.IndexByte(a, '='); eq > 0 && !strings.HasPrefix(a, "-") {
+				x.env.Vars[a[:eq]] = a[eq+1:]
+			}
+		}
+	case "unset":
+		for _, a := range args {
+			delete(x.env.Vars, a)
+		}
+	case "eval":
+		x.script(strings.Join(args, " "), depth+1, "eval")
+		return 1
+	case "sh", "bash", "dash", "zsh", "ksh", "ash", "busybox", "su":
+		if i, ok := dashCPayload(args); ok {
+			x.script(args[i], depth+1, sc.Name()+" -c")
+			return i + 1
+		}
+		for _, r := range sc.Redirects {
+			if r.Op == "<<<" {
+				x.script(r.Target, depth+1, sc.Name()+" <<<")
+			}
+		}
+	case "perl", "python", "python2", "python3":
+		if i, ok := interpreterPayload(sc.Name(), args); ok {
+			x.interpreter(args[i], depth, sc.Name())
+		}
+	default:
+		if inner := wrappedCommand(sc.Name(), args); len(inner) > 0 {
+			wrapped := SimpleCommand{Argv: inner, Via: sc.Name()}
+			idx := len(x.out)
+			x.out = append(x.out, Pipeline{Depth: depth + 1})
+			wrapped.Delegated = x.builtin(wrapped, depth+1)
+			x.out[idx].Commands = []SimpleCommand{wrapped}
+			return len(sc.Argv) - len(inner)
+		}
+	}
+	return 0
+}
+
+func dashCPayload(args []string) (int, bool) {
+	for i, a := range args {
+		if strings.HasPrefix(a, "-") && !strings.HasPrefix(a, "--") && strings.Contains(a, "c") {
+			return i + 1, i+1 < len(args)
+		}
+	}
+	return 0, false
+}
+
+// wrappedCommand returns the command line run by wrappers like sudo or
+// nohup, skipping the wrapper's own options.
+func wrappedCommand(name string, args []string) []string {
+	switch name {
+	case "sudo", "doas", "nohup", "exec", "command", "builtin", "time", "setsid", "stdbuf", "nice", "ionice", "chroot", "watch", "strace", "xargs":
+	case "env":
+		for len(args) > 0 && (strings.Contains(args[0], "=") || strings.HasPrefix(args[0], "-")) {
+			args = args[1:]
+		}
+		return args
+	case "timeout":
+		for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+			args = args[1:]
+		}
+		if len(args) > 0 {
+			args = args[1:]
+		}
+		return args
+	default:
+		return nil
+	}
+
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+		opt := args[0]
+		args = args[1:]
+		if len(args) > 0 && (opt == "-u" || opt == "-g" || opt == "-n" || opt == "-c") && name != "exec" {
+			args = args[1:]
+		}
+	}
+	if name == "chroot" && len(args) > 0 {
+		args = args[1:]
+	}
+	return args
+}
+
+func (x *expander) expand(w word, depth int) []string {
+	var fields []string
+	var cur strings.Builder
+	have := false
+
+	for i, p := range w.parts {
+		var value string
+		switch p.kind {
+		case partLiteral:
+			value = p.text
+			if p.encoding != "" {
+				x.layer(p.encoding, value)
+			}
+			if i == 0 && !p.quoted && (value == "~" || strings.HasPrefix(value, "~/")) {
+				value = x.env.Vars["HOME"] + value[1:]
+			}
+		case partParam:
+			value = x.param(p.text, depth)
+		case partCommand:
+			value = x.substitute(p.text, depth)
+		}
+
+		if p.quoted || p.kind == partLiteral {
+			cur.WriteString(value)
+			have = true
+			continue
+		}
+
+		split := strings.Fields(value)
+		if len(split) == 0 {
+			continue
+		}
+		if value[0] == ' ' || value[0] == '\t' {
+			if have {
+				fields = append(fields, cur.String())
+			}
+			cur.Reset()
+			have = false
+		}
+		for j, f := range split {
+			if j > 0 {
+				fields = append(fields, cur.String())
+				cur.Reset()
+			}
+			cur.WriteString(f)
+			have = true
+		}
+	}
+	if have {
+		fields = append(fields, cur.String())
+	}
+	return fields
+}
+
+func (x *expander) expandQuoted(w word, depth int) []string {
+	for i := range w.parts {
+		w.parts[i].quoted = true
+	}
+	return x.expand(w, depth)
+}
+
+func (x *expander) param(expr string, depth int) string {
+	name, fallback := expr, ""
+	for _, op := range []string{":-", ":=", "-", "="} {
+		if i := strings.Index(expr, op); i > 0 {
+			name, fallback = expr[:i], expr[i+len(op):]
+			break
+		}
+	}
+	if v, ok := x.env.Vars[name]; ok && v != "" {
+		return v
+	}
+	if fallback != "" {
+		return strings.Join(x.expandQuoted((&lexer{src: fallback}).readWord(), depth), "")
+	}
+	return ""
+}
+
+// substitute records the commands inside $(...) or backticks and returns
+// the text they would print when that is knowable without running them.
+func (x *expander) substitute(src string, depth int) string {
+	start := len(x.out)
+	x.script(src, depth+1, "substitution")
+	if start == len(x.out) {
+		return ""
+	}
+	for _, pl := range x.out[start+1:] {
+		if pl.Depth <= depth+1 {
+			return ""
+		}
+	}
+	return x.out[start].output
+}
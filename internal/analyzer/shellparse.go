@@ -0,0 +1,624 @@
+package analyzer
+
+import (
+	"strconv"
+	"strings"
+)
+
+type partKind int
+
+const (
+	partLiteral partKind = iota
+	partParam
+	partCommand
+)
+
+type wordPart struct {
+	kind   partKind
+	text   string
+	quoted bool
+	// encoding is set for $'...' strings spelled with \x or octal
+	// escapes, which hide the real text from a casual reader.
+	encoding string
+}
+
+type word struct {
+	parts []wordPart
+	raw   string
+}
+
+func (w word) isLiteral() bool {
+	for _, p := range w.parts {
+		if p.kind != partLiteral || p.quoted {
+			return false
+		}
+	}
+	return true
+}
+
+func (w word) literal() string {
+	var sb strings.Builder
+	for _, p := range w.parts {
+		if p.kind == partLiteral {
+			sb.WriteString(p.text)
+		}
+	}
+	return sb.String()
+}
+
+type tokenKind int
+
+const (
+	tokWord tokenKind = iota
+	tokOp
+	tokEOF
+)
+
+type token struct {
+	kind tokenKind
+	op   string
+	word word
+}
+
+type lexer struct {
+	src string
+	pos int
+}
+
+func isOpChar(c byte) bool {
+	switch c {
+	case ';', '&', '|', '(', ')', '<', '>', '\n':
+		return true
+	}
+	return false
+}
+
+func isBlank(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r'
+}
+
+func (l *lexer) peek(off int) byte {
+	if l.pos+off < len(l.src) {
+		return l.src[l.pos+off]
+	}
+	return 0
+}
+
+func (l *lexer) tokens() []token {
+	var toks []token
+	for {
+		t := l.next()
+		toks = append(toks, t)
+		if t.kind == tokEOF {
+			return toks
+		}
+	}
+}
+
+func (l *lexer) next() token {
+	for l.pos < len(l.src) && isBlank(l.src[l.pos]) {
+		l.pos++
+	}
+	if l.pos >= len(l.src) {
+		return token{kind: tokEOF}
+	}
+
+	c := l.src[l.pos]
+	if c == '#' {
+		for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+			l.pos++
+		}
+		return l.next()
+	}
+
+	if op := l.redirectOp(); op != "" {
+		return token{kind: tokOp, op: op}
+	}
+
+	if isOpChar(c) && !((c == '<' || c == '>') && l.peek(1) == '(') {
+		for _, op := range []string{"&&", "||", "|&", ";;", "&>>", "&>"} {
+			if strings.HasPrefix(l.src[l.pos:], op) {
+				l.pos += len(op)
+				return token{kind: tokOp, op: op}
+			}
+		}
+		l.pos++
+		return token{kind: tokOp, op: string(c)}
+	}
+
+	return token{kind: tokWord, word: l.readWord()}
+}
+
+var redirectOps = []string{"<<<", "<<-", "<<", ">>", ">&", "<&", ">|", "<>", ">", "<"}
+
+func (l *lexer) redirectOp() string {
+	i := l.pos
+	for i < len(l.src) && l.src[i] >= '0' && l.src[i] <= '9' {
+		i++
+	}
+	rest := l.src[i:]
+	if strings.HasPrefix(rest, "<(") || strings.HasPrefix(rest, ">(") {
+		return ""
+	}
+	for _, op := range redirectOps {
+		if strings.HasPrefix(rest, op) {
+			l.pos = i + len(op)
+			return op
+		}
+	}
+	return ""
+}
+
+func (l *lexer) readWord() word {
+	start := l.pos
+	var parts []wordPart
+	var lit strings.Builder
+	quotedLit := false
+
+	flush := func() {
+		if lit.Len() > 0 {
+			parts = append(parts, wordPart{kind: partLiteral, text: lit.String(), quoted: quotedLit})
+			lit.Reset()
+		}
+	}
+	add := func(p wordPart) {
+		flush()
+		parts = append(parts, p)
+	}
+
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		if isBlank(c) {
+			break
+		}
+		if (c == '<' || c == '>') && l.peek(1) == '(' {
+			l.pos++
+			add(wordPart{kind: partCommand, text: l.readBalanced('(', ')')})
+			continue
+		}
+		if isOpChar(c) {
+			break
+		}
+
+		switch c {
+		case '\\':
+			l.pos++
+			if l.pos < len(l.src) {
+				if l.src[l.pos] != '\n' {
+					if quotedLit {
+						flush()
+					}
+					quotedLit = false
+					lit.WriteByte(l.src[l.pos])
+				}
+				l.pos++
+			}
+		case '\'':
+			flush()
+			l.pos++
+			end := strings.IndexByte(l.src[l.pos:], '\'')
+			if end < 0 {
+				end = len(l.src) - l.pos
+			}
+			parts = append(parts, wordPart{kind: partLiteral, text: l.src[l.pos : l.pos+end], quoted: true})
+			l.pos += end + 1
+		case '"':
+			flush()
+			l.pos++
+			parts = append(parts, l.readDoubleQuoted()...)
+		case '`':
+			l.pos++
+			add(wordPart{kind: partCommand, text: l.readBackquoted()})
+		case '$':
+			if p, ok := l.readDollar(false); ok {
+				add(p)
+			} else {
+				if quotedLit {
+					flush()
+				}
+				quotedLit = false
+				lit.WriteByte('$')
+				l.pos++
+			}
+		default:
+			if quotedLit {
+				flush()
+			}
+			quotedLit = false
+			lit.WriteByte(c)
+			l.pos++
+		}
+	}
+	flush()
+
+	end := l.pos
+	if end > len(l.src) {
+		end = len(l.src)
+	}
+	return word{parts: parts, raw: l.src[start:end]}
+}
+
+func (l *lexer) readDoubleQuoted() []wordPart {
+	var parts []wordPart
+	var lit strings.Builder
+	flush := func() {
+		parts = append(parts, wordPart{kind: partLiteral, text: lit.String(), quoted: true})
+		lit.Reset()
+	}
+
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch c {
+		case '"':
+			l.pos++
+			flush()
+			return parts
+		case '\\':
+			next := l.peek(1)
+			if next == '"' || next == '\\' || next == '$' || next == '`' {
+				lit.WriteByte(next)
+				l.pos += 2
+			} else if next == '\n' {
+				l.pos += 2
+			} else {
+				lit.WriteByte(c)
+				l.pos++
+			}
+		case '`':
+			flush()
+			l.pos++
+			parts = append(parts, wordPart{kind: partCommand, text: l.readBackquoted(), quoted: true})
+		case '$':
+			if p, ok := l.readDollar(true); ok {
+				flush()
+				p.quoted = true
+				parts = append(parts, p)
+			} else {
+				lit.WriteByte('$')
+				l.pos++
+			}
+		default:
+			lit.WriteByte(c)
+			l.pos++
+		}
+	}
+	flush()
+	return parts
+}
+
+func (l *lexer) readBackquoted() string {
+	var sb strings.Builder
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		if c == '`' {
+			l.pos++
+			return sb.String()
+		}
+		if c == '\\' && (l.peek(1) == '`' || l.peek(1) == '\\' || l.peek(1) == '$') {
+			sb.WriteByte(l.peek(1))
+			l.pos += 2
+			continue
+		}
+		sb.WriteByte(c)
+		l.pos++
+	}
+	return sb.String()
+}
+
+// readBalanced expects l.pos at the opening delimiter and returns the
+// text between it and its matching close, honouring nested quotes.
+func (l *lexer) readBalanced(open, close byte) string {
+	l.pos++
+	start := l.pos
+	depth := 1
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch c {
+		case '\\':
+			l.pos++
+		case '\'':
+			end := strings.IndexByte(l.src[l.pos+1:], '\'')
+			if end < 0 {
+				l.pos = len(l.src)
+				return l.src[start:]
+			}
+			l.pos += end + 1
+		case '"':
+			l.pos++
+			for l.pos < len(l.src) && l.src[l.pos] != '"' {
+				if l.src[l.pos] == '\\' {
+					l.pos++
+				}
+				l.pos++
+			}
+		case open:
+			depth++
+		case close:
+			depth--
+			if depth == 0 {
+				inner := l.src[start:l.pos]
+				l.pos++
+				return inner
+			}
+		}
+		l.pos++
+	}
+	return l.src[start:]
+}
+
+func (l *lexer) readDollar(inQuotes bool) (wordPart, bool) {
+	next := l.peek(1)
+	switch {
+	case next == '(' && l.peek(2) == '(':
+		l.pos++
+		inner := l.readBalanced('(', ')')
+		return wordPart{kind: partLiteral, text: strings.Trim(inner, "()")}, true
+	case next == '(':
+		l.pos++
+		return wordPart{kind: partCommand, text: l.readBalanced('(', ')')}, true
+	case next == '{':
+		l.pos++
+		return wordPart{kind: partParam, text: l.readBalanced('{', '}')}, true
+	case next == '\'' && !inQuotes:
+		l.pos += 2
+		text, encoding := l.readANSIC()
+		return wordPart{kind: partLiteral, text: text, quoted: true, encoding: encoding}, true
+	case isNameStart(next):
+		l.pos++
+		start := l.pos
+		for l.pos < len(l.src) && isNameChar(l.src[l.pos]) {
+			l.pos++
+		}
+		return wordPart{kind: partParam, text: l.src[start:l.pos]}, true
+	case next != 0 && strings.IndexByte("0123456789?#@*$!-", next) >= 0:
+		l.pos += 2
+		return wordPart{kind: partParam, text: string(next)}, true
+	}
+	return wordPart{}, false
+}
+
+// readANSIC reads the body of a $'...' string and reports "hex" or
+// "octal" when the text was spelled with numeric escapes.
+func (l *lexer) readANSIC() (string, string) {
+	var sb strings.Builder
+	encoding := ""
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		if c == '\'' {
+			l.pos++
+			return sb.String(), encoding
+		}
+		if c != '\\' || l.pos+1 >= len(l.src) {
+			sb.WriteByte(c)
+			l.pos++
+			continue
+		}
+		l.pos++
+		e := l.src[l.pos]
+		l.pos++
+		switch e {
+		case 'n':
+			sb.WriteByte('\n')
+		case 't':
+			sb.WriteByte('\t')
+		case 'r':
+			sb.WriteByte('\r')
+		case 'a':
+			sb.WriteByte('\a')
+		case 'e', 'E':
+			sb.WriteByte(0x1b)
+		case 'x':
+			sb.WriteByte(l.readNumber(16, 2))
+			encoding = "hex"
+		case '0', '1', '2', '3', '4', '5', '6', '7':
+			l.pos--
+			sb.WriteByte(l.readNumber(8, 3))
+			if encoding == "" {
+				encoding = "octal"
+			}
+		default:
+			sb.WriteByte(e)
+		}
+	}
+	return sb.String(), encoding
+}
+
+func (l *lexer) readNumber(base, max int) byte {
+	start := l.pos
+	for l.pos < len(l.src) && l.pos-start < max && isDigit(l.src[l.pos], base) {
+		l.pos++
+	}
+	n, _ := strconv.ParseUint(l.src[start:l.pos], base, 8)
+	return byte(n)
+}
+
+func isDigit(c byte, base int) bool {
+	switch {
+	case c >= '0' && c <= '7':
+		return true
+	case c == '8' || c == '9':
+		return base >= 10
+	case (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F'):
+		return base == 16
+	}
+	return false
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isNameChar(c byte) bool {
+	return isNameStart(c) || (c >= '0' && c <= '9')
+}
+
+type redirect struct {
+	op     string
+	target word
+}
+
+type astCommand struct {
+	words     []word
+	redirects []redirect
+	body      []*astPipeline
+}
+
+type astPipeline struct {
+	commands []*astCommand
+}
+
+type parser struct {
+	toks []token
+	pos  int
+}
+
+func parseScript(src string) []*astPipeline {
+	l := &lexer{src: src}
+	p := &parser{toks: l.tokens()}
+	var out []*astPipeline
+	for p.cur().kind != tokEOF {
+		out = append(out, p.parseList("")...)
+		if p.cur().kind != tokEOF {
+			// stray closing token — skip it and keep going
+			p.pos++
+		}
+	}
+	return out
+}
+
+func (p *parser) cur() token {
+	return p.toks[p.pos]
+}
+
+func (p *parser) isOp(ops ...string) bool {
+	t := p.cur()
+	if t.kind != tokOp {
+		return false
+	}
+	for _, op := range ops {
+		if t.op == op {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *parser) isReserved(w string) bool {
+	t := p.cur()
+	return t.kind == tokWord && t.word.isLiteral() && t.word.literal() == w
+}
+
+func (p *parser) parseList(terminator string) []*astPipeline {
+	var out []*astPipeline
+	for {
+		for p.isOp(";", "&", "\n", "&&", "||", ";;") {
+			p.pos++
+		}
+		if p.cur().kind == tokEOF || p.isOp(")") {
+			return out
+		}
+		if terminator != "" && p.isReserved(terminator) {
+			return out
+		}
+		if pl := p.parsePipeline(terminator); pl != nil {
+			out = append(out, pl)
+		}
+	}
+}
+
+func (p *parser) parsePipeline(terminator string) *astPipeline {
+	pl := &astPipeline{}
+	for {
+		cmd := p.parseCommand(terminator)
+		if cmd != nil {
+			pl.commands = append(pl.commands, cmd)
+		}
+		if !p.isOp("|", "|&") {
+			break
+		}
+		p.pos++
+		for p.isOp("\n") {
+			p.pos++
+		}
+	}
+	if len(pl.commands) == 0 {
+		return nil
+	}
+	return pl
+}
+
+func (p *parser) parseCommand(terminator string) *astCommand {
+	cmd := &astCommand{}
+
+	switch {
+	case p.isOp("("):
+		p.pos++
+		cmd.body = p.parseList("")
+		if p.isOp(")") {
+			p.pos++
+		}
+	case p.isReserved("{"):
+		p.pos++
+		cmd.body = p.parseList("}")
+		if p.isReserved("}") {
+			p.pos++
+		}
+	}
+
+	for {
+		t := p.cur()
+		switch {
+		case t.kind == tokEOF:
+			return cmd.orNil()
+		case t.kind == tokOp && isRedirectOp(t.op):
+			p.pos++
+			r := redirect{op: t.op}
+			if p.cur().kind == tokWord {
+				r.target = p.cur().word
+				p.pos++
+			}
+			cmd.redirects = append(cmd.redirects, r)
+		case t.kind == tokOp && t.op == "(" && len(cmd.words) == 1:
+			// function definition: name() { body; }
+			p.pos++
+			if p.isOp(")") {
+				p.pos++
+			}
+			for p.isOp("\n") {
+				p.pos++
+			}
+			body := p.parseCommand(terminator)
+			if body != nil {
+				cmd.body = append(cmd.body, &astPipeline{commands: []*astCommand{body}})
+			}
+			cmd.words = nil
+			return cmd.orNil()
+		case t.kind == tokOp:
+			return cmd.orNil()
+		case terminator != "" && len(cmd.words) == 0 && p.isReserved(terminator):
+			return cmd.orNil()
+		default:
+			cmd.words = append(cmd.words, t.word)
+			p.pos++
+		}
+	}
+}
+
+func (c *astCommand) orNil() *astCommand {
+	if len(c.words) == 0 && len(c.redirects) == 0 && len(c.body) == 0 {
+		return nil
+	}
+	return c
+}
+
+func isRedirectOp(op string) bool {
+	if op == "&>" || op == "&>>" {
+		return true
+	}
+	for _, r := range redirectOps {
+		if op == r {
+			return true
+		}
+	}
+	return false
+}
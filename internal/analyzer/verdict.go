@@ -0,0 +1,258 @@
+package analyzer
+
+import (
+	Session "GradGuard/internal/Session"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// AssessmentSchemaVersion is bumped whenever the shape or meaning of
+// Assessment changes, so readers of old reports can tell them apart.
+const AssessmentSchemaVersion = 1
+
+const (
+	BehaviourScanner         = "scanner"
+	BehaviourFingerprinting  = "fingerprinting"
+	BehaviourExploitation    = "exploitation"
+	BehaviourDefenseEvasion  = "defense_evasion"
+	BehaviourCredentialTheft = "credential_theft"
+	BehaviourLateralMovement = "lateral_movement"
+	BehaviourPersistence     = "persistence"
+	BehaviourCryptomining    = "cryptomining"
+	BehaviourHuman           = "human_operator"
+	BehaviourBot             = "bot"
+)
+
+const (
+	// minConfidence is the lowest confidence a behaviour is reported at.
+	minConfidence = 0.25
+	// primaryConfidence is what a behaviour needs to decide the verdict.
+	primaryConfidence = 0.5
+	// evidenceScale is how many score points of one category make its
+	// behaviour about 63% certain.
+	evidenceScale = 30.0
+	// mlWeight discounts the model relative to matched rules, which say
+	// exactly what was run.
+	mlWeight = 0.6
+)
+
+// MLOpinion is what the session model predicted, handed in by callers
+// that have a trained model.
+type MLOpinion struct {
+	FingerprintingProb float64 `json:"fingerprinting_prob"`
+	Intent             string  `json:"intent"`
+	Anomaly            bool    `json:"anomaly"`
+	AnomalyScore       float64 `json:"anomaly_score"`
+}
+
+type Behaviour struct {
+	Label      string   `json:"label"`
+	Confidence float64  `json:"confidence"`
+	Sources    []string `json:"sources"`
+	Evidence   []string `json:"evidence"`
+
+	verdict string
+}
+
+// Assessment lists every behaviour a session showed with how sure we are
+// of each. Verdict is the single legacy verdict derived from it.
+type Assessment struct {
+	SchemaVersion int         `json:"schema_version"`
+	Verdict       string      `json:"verdict"`
+	Behaviours    []Behaviour `json:"behaviours"`
+}
+
+type assessor struct {
+	byLabel map[string]*Behaviour
+	order   []string
+}
+
+// add folds one independent piece of evidence into a behaviour; several
+// agreeing sources raise confidence as a noisy-OR.
+func (a *assessor) add(label, source string, confidence float64, evidence string) *Behaviour {
+	b, ok := a.byLabel[label]
+	if !ok {
+		b = &Behaviour{Label: label}
+		a.byLabel[label] = b
+		a.order = append(a.order, label)
+	}
+	if confidence <= 0 {
+		return b
+	}
+	b.Confidence = 1 - (1-b.Confidence)*(1-confidence)
+	if !contains(b.Sources, source) {
+		b.Sources = append(b.Sources, source)
+	}
+	b.Evidence = append(b.Evidence, evidence)
+	return b
+}
+
+// Assess combines the session's rule evidence, its command timing and,
+// when given, the model's opinion into labelled behaviours.
+func Assess(session *Session.SessionState, opinion *MLOpinion) Assessment {
+	a := &assessor{byLabel: map[string]*Behaviour{}}
+
+	for _, c := range Categories() {
+		n := session.CategoryCounts[string(c.Name)]
+		if c.Behaviour == "" || n == 0 {
+			continue
+		}
+		points := session.CategoryScore[string(c.Name)]
+		b := a.add(c.Behaviour, "rules", 1-math.Exp(-points/evidenceScale),
+			fmt.Sprintf("%d %s command(s) worth %.0f points", n, c.Label, points))
+		if b.verdict == "" {
+			b.verdict = c.Verdict
+		}
+	}
+
+	assessScanner(a, session)
+	assessOperator(a, session)
+
+	if opinion != nil {
+		fp := a.add(BehaviourFingerprinting, "ml", mlWeight*opinion.FingerprintingProb,
+			fmt.Sprintf("model fingerprinting probability %.2f", opinion.FingerprintingProb))
+		if fp.verdict == "" {
+			fp.verdict = VerdictLikelyFingerprinting
+		}
+		switch opinion.Intent {
+		case "exploit":
+			b := a.add(BehaviourExploitation, "ml", mlWeight*0.5, "model intent: exploit")
+			if b.verdict == "" {
+				b.verdict = VerdictExploitAttempt
+			}
+		case "brute_force":
+			a.add(BehaviourScanner, "ml", mlWeight*0.5, "model intent: brute force")
+		}
+		if opinion.Anomaly {
+			a.add(BehaviourHuman, "ml", mlWeight*0.3,
+				fmt.Sprintf("session is unlike the training data (anomaly %.2f)", opinion.AnomalyScore))
+		}
+	}
+
+	human, bot := a.byLabel[BehaviourHuman], a.byLabel[BehaviourBot]
+	if human != nil && bot != nil {
+		if human.Confidence >= bot.Confidence {
+			bot.Confidence = 0
+		} else {
+			human.Confidence = 0
+		}
+	}
+
+	result := Assessment{SchemaVersion: AssessmentSchemaVersion, Behaviours: []Behaviour{}}
+	var best *Behaviour
+	for _, label := range a.order {
+		b := a.byLabel[label]
+		if b.Confidence < minConfidence {
+			continue
+		}
+		b.Confidence = math.Round(b.Confidence*100) / 100
+		result.Behaviours = append(result.Behaviours, *b)
+		// Behaviours from categories arrive in rank order, so on a tie the
+		// higher-ranked one keeps the verdict.
+		if b.verdict != "" && b.Confidence >= primaryConfidence && (best == nil || b.Confidence > best.Confidence) {
+			best = b
+		}
+	}
+	sort.SliceStable(result.Behaviours, func(i, j int) bool {
+		return result.Behaviours[i].Confidence > result.Behaviours[j].Confidence
+	})
+
+	switch {
+	case best != nil:
+		result.Verdict = best.verdict
+	case session.SuspicionScore >= 30:
+		result.Verdict = VerdictSuspicious
+	default:
+		result.Verdict = VerdictClean
+	}
+	return result
+}
+
+// Verdict is the single-label verdict of Assess without a model opinion.
+func Verdict(session *Session.SessionState) string {
+	return Assess(session, nil).Verdict
+}
+
+// assessScanner looks for the in-and-out sessions of mass scanners: a
+// handful of discovery commands, or none, and gone within a minute.
+func assessScanner(a *assessor, session *Session.SessionState) {
+	duration := sessionEnd(session).Sub(session.StartTime)
+	if session.CommandCount > 5 || duration > time.Minute {
+		return
+	}
+	for _, c := range Categories() {
+		if c.Behaviour != "" && session.CategoryCounts[string(c.Name)] > 0 {
+			return
+		}
+	}
+	if session.CommandCount == 0 {
+		a.add(BehaviourScanner, "rules", 0.6, "logged in and ran nothing")
+		return
+	}
+	recon := session.CategoryCounts[string(CategoryRecon)]
+	a.add(BehaviourScanner, "rules", 0.3+0.4*float64(recon)/float64(session.CommandCount),
+		fmt.Sprintf("%d command(s), %d of them discovery, in %s", session.CommandCount, recon, duration.Round(time.Second)))
+}
+
+// assessOperator tells scripted sessions from people by how their input
+// arrived and by the gaps between commands: bots are fast and regular,
+// people slow and uneven.
+func assessOperator(a *assessor, session *Session.SessionState) {
+	if op, ok := ClassifyOperator(session.Keystrokes.Stats()); ok {
+		label := BehaviourHuman
+		if op.Class == OperatorBot {
+			label = BehaviourBot
+		}
+		a.add(label, "keystrokes", op.Confidence, op.Class+": "+op.Evidence)
+	}
+
+	gaps := commandGaps(session.ScoreChanges)
+	if len(gaps) < 2 {
+		return
+	}
+	mean, cv := meanAndCV(gaps)
+	switch {
+	case mean < 1:
+		a.add(BehaviourBot, "timing", 0.8, fmt.Sprintf("commands %.2fs apart on average", mean))
+	case cv < 0.2:
+		a.add(BehaviourBot, "timing", 0.6, fmt.Sprintf("command gaps vary by only %.0f%%", cv*100))
+	case mean >= 2 && cv >= 0.5:
+		a.add(BehaviourHuman, "timing", 0.7,
+			fmt.Sprintf("commands %.1fs apart on average, varying by %.0f%%", mean, cv*100))
+	case mean >= 2:
+		a.add(BehaviourHuman, "timing", 0.4, fmt.Sprintf("commands %.1fs apart on average", mean))
+	}
+}
+
+func commandGaps(changes []Session.ScoreChange) []float64 {
+	var gaps []float64
+	for i := 1; i < len(changes); i++ {
+		gaps = append(gaps, changes[i].Time.Sub(changes[i-1].Time).Seconds())
+	}
+	return gaps
+}
+
+func meanAndCV(xs []float64) (float64, float64) {
+	sum := 0.0
+	for _, x := range xs {
+		sum += x
+	}
+	mean := sum / float64(len(xs))
+	if mean == 0 {
+		return 0, 0
+	}
+	variance := 0.0
+	for _, x := range xs {
+		variance += (x - mean) * (x - mean)
+	}
+	return mean, math.Sqrt(variance/float64(len(xs))) / mean
+}
+
+func sessionEnd(session *Session.SessionState) time.Time {
+	if !session.LastCommandTime.IsZero() {
+		return session.LastCommandTime
+	}
+	return time.Now()
+}
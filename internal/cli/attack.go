@@ -0,0 +1,130 @@
+package cli
+
+import (
+	"GradGuard/internal/attack"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+)
+
+const heatCellWidth = 18
+
+// ShowAttackMatrix renders technique counts across every session as an
+// ATT&CK matrix: one column per tactic that was observed, techniques
+// stacked under it by frequency and coloured by how often they occur.
+func ShowAttackMatrix() {
+	reports := loadAllReports()
+	if len(reports) == 0 {
+		yellow.Println("No sessions found in logs/reports/")
+		return
+	}
+
+	type cell struct {
+		technique string
+		count     int
+		sessions  int
+	}
+	columns := map[string][]*cell{}
+	index := map[attack.Tag]*cell{}
+	max := 0
+
+	for _, r := range reports {
+		for _, t := range r.Techniques {
+			tag := attack.Tag{Tactic: t.Tactic, Technique: t.Technique}
+			c, ok := index[tag]
+			if !ok {
+				c = &cell{technique: t.Technique}
+				index[tag] = c
+				columns[t.Tactic] = append(columns[t.Tactic], c)
+			}
+			c.count += t.Count
+			c.sessions++
+			if c.count > max {
+				max = c.count
+			}
+		}
+	}
+	if len(columns) == 0 {
+		yellow.Println("No ATT&CK techniques recorded yet")
+		return
+	}
+
+	var tactics []attack.Tactic
+	for _, t := range attack.Tactics {
+		if len(columns[t.ID]) > 0 {
+			tactics = append(tactics, t)
+		}
+	}
+	rows := 0
+	for _, cells := range columns {
+		sort.Slice(cells, func(i, j int) bool { return cells[i].count > cells[j].count })
+		if len(cells) > rows {
+			rows = len(cells)
+		}
+	}
+
+	fmt.Println()
+	cyan.Printf("  ATT&CK HEATMAP — %d sessions\n\n", len(reports))
+
+	fmt.Print("  ")
+	for _, t := range tactics {
+		bold.Printf("%-*s", heatCellWidth, truncate(t.Short, heatCellWidth-1))
+	}
+	fmt.Println()
+	fmt.Print("  ")
+	for range tactics {
+		dimmed.Print(strings.Repeat("─", heatCellWidth-1) + " ")
+	}
+	fmt.Println()
+
+	for row := 0; row < rows; row++ {
+		fmt.Print("  ")
+		for _, t := range tactics {
+			cells := columns[t.ID]
+			if row >= len(cells) {
+				fmt.Print(strings.Repeat(" ", heatCellWidth))
+				continue
+			}
+			c := cells[row]
+			text := fmt.Sprintf("%-10s %5d", c.technique, c.count)
+			heatColor(c.count, max).Printf("%-*s", heatCellWidth-1, text)
+			fmt.Print(" ")
+		}
+		fmt.Println()
+	}
+	fmt.Println()
+
+	bold.Println("  ┌─ TECHNIQUES ─────────────────────────────────────────────────────┐")
+	var all []attack.Tag
+	for tag := range index {
+		all = append(all, tag)
+	}
+	// A technique serving more than one tactic gets a row under each, with
+	// the counts seen under that tactic.
+	for _, tag := range attack.Merge(nil, all...) {
+		c := index[tag]
+		tactic, ok := attack.LookupTactic(tag.Tactic)
+		if !ok {
+			tactic.Short = tag.Tactic
+		}
+		heatColor(c.count, max).Printf("  │  %-10s", tag.Technique)
+		fmt.Printf(" %-14s", truncate(tactic.Short, 14))
+		fmt.Printf(" %-29s", truncate(attack.TechniqueName(tag.Technique), 29))
+		dimmed.Printf(" %d cmds / %d sessions\n", c.count, c.sessions)
+	}
+	bold.Println("  └──────────────────────────────────────────────────────────────────┘")
+	fmt.Println()
+}
+
+func heatColor(count, max int) *color.Color {
+	switch ratio := float64(count) / float64(max); {
+	case ratio >= 0.66:
+		return color.New(color.BgRed, color.FgWhite, color.Bold)
+	case ratio >= 0.33:
+		return color.New(color.BgYellow, color.FgBlack)
+	default:
+		return color.New(color.BgGreen, color.FgBlack)
+	}
+}
@@ -0,0 +1,170 @@
+package cli
+
+import (
+	"GradGuard/internal/config"
+	"GradGuard/internal/evidence"
+	"GradGuard/internal/export"
+	"GradGuard/internal/ipintel"
+	"GradGuard/internal/logstore"
+	"GradGuard/internal/sshserver"
+	"GradGuard/internal/store"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// ExportEvidence writes a session's evidence package to out, or to
+// evidence-<id>.tar.gz when out is empty.
+func ExportEvidence(sessionID, out string) bool {
+	if out == "" {
+		out = "evidence-" + sessionID + ".tar.gz"
+	}
+	evidence.SetKeyPath(config.Get().Evidence.SigningKey)
+	var opts export.Options
+	if pub, err := evidence.PublicKey(); err == nil {
+		opts.PublicKey = pub
+	} else {
+		yellow.Printf("  %v; the package will not be verified\n", err)
+	}
+	if data, err := store.SessionLog(logstore.Reports, sessionID); err == nil {
+		var r struct {
+			RemoteAddr string `json:"remote_addr"`
+		}
+		json.Unmarshal(data, &r)
+		if info := ipintel.Cached(r.RemoteAddr); info != nil {
+			opts.Intel = info
+		}
+	}
+
+	tmp := out + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		red.Printf("  %v\n", err)
+		return false
+	}
+	summary, err := export.Evidence(f, sessionID, opts)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, out)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		red.Printf("  %v\n", err)
+		return false
+	}
+
+	fmt.Println()
+	green.Printf("  Wrote %s", out)
+	fmt.Printf(" (%d files, %s", len(summary.Files), byteSize(summary.Bytes))
+	if summary.Artifacts > 0 {
+		fmt.Printf(", %d artifacts", summary.Artifacts)
+	}
+	fmt.Println(")")
+	switch {
+	case opts.PublicKey == nil:
+		if summary.Problem != "" {
+			yellow.Printf("  %s\n", summary.Problem)
+		}
+	case summary.Verified:
+		green.Println("  VERIFIED: logs are as captured")
+	default:
+		red.Printf("  TAMPERED: %s\n", summary.Problem)
+	}
+	if len(summary.Missing) > 0 {
+		dimmed.Printf("  Nothing kept for: %s\n", strings.Join(summary.Missing, ", "))
+	}
+	fmt.Println()
+	return true
+}
+
+// ExportIntel writes the sessions picked by --since, --until and
+// --verdict as a STIX 2.1 bundle or a MISP event (--format stix or misp)
+// marked --tlp, to --out or stdout. Unset flags fall back to the intel
+// config.
+func ExportIntel(args []string) bool {
+	cfg := config.Get().Intel
+	opts := sshserver.IntelOptions(cfg)
+	format, out := "stix", ""
+	if len(args)%2 != 0 {
+		red.Printf("  %s needs a value\n", args[len(args)-1])
+		return false
+	}
+	for i := 0; i < len(args); i += 2 {
+		flag, value := args[i], args[i+1]
+		var err error
+		switch flag {
+		case "--format":
+			if value != "stix" && value != "misp" {
+				err = fmt.Errorf("want stix or misp")
+			}
+			format = value
+		case "--since":
+			opts.Filter.Since, err = parseWhen(value)
+		case "--until":
+			opts.Filter.Until, err = parseWhen(value)
+		case "--verdict":
+			opts.Filter.Verdicts = strings.Split(value, ",")
+		case "--tlp":
+			if !slices.Contains(export.TLPLevels(), value) {
+				err = fmt.Errorf("want one of %s", strings.Join(export.TLPLevels(), ", "))
+			}
+			opts.TLP = value
+		case "--out":
+			out = value
+		default:
+			err = fmt.Errorf("unknown flag")
+		}
+		if err != nil {
+			red.Printf("  %s %s: %v\n", flag, value, err)
+			return false
+		}
+	}
+
+	var data []byte
+	var err error
+	if format == "misp" {
+		data, err = export.MISP(opts)
+	} else {
+		data, err = export.STIX(opts)
+	}
+	if err != nil {
+		red.Printf("  %v\n", err)
+		return false
+	}
+	if out == "" {
+		os.Stdout.Write(data)
+		return true
+	}
+	if err := os.WriteFile(out, data, 0644); err != nil {
+		red.Printf("  %v\n", err)
+		return false
+	}
+	green.Printf("  Wrote %s", out)
+	fmt.Printf(" (%s, %s, TLP:%s)\n", format, byteSize(int64(len(data))), strings.ToUpper(opts.TLP))
+	return true
+}
+
+// ServeTAXII serves the intel feed on addr, or on intel.taxii_listen when
+// addr is empty, without running the honeypot.
+func ServeTAXII(addr string) bool {
+	cfg := config.Get().Intel
+	if addr == "" {
+		addr = cfg.TAXIIListen
+	}
+	if addr == "" {
+		red.Println("  No address: pass --listen or set intel.taxii_listen")
+		return false
+	}
+	scheme := "http"
+	if cfg.TAXIICert != "" {
+		scheme = "https"
+	}
+	green.Printf("  TAXII 2.1 feed on %s://%s", scheme, addr)
+	dimmed.Printf(" (discovery at /taxii2/)\n")
+	red.Printf("  %v\n", export.ServeTAXII(addr, sshserver.TAXIIOptions(cfg)))
+	return false
+}
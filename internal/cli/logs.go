@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"GradGuard/internal/config"
+	"GradGuard/internal/logstore"
+	sshserver "GradGuard/internal/sshserver"
+	"fmt"
+	"time"
+)
+
+// ArchiveLogs packs finished sessions idle for olderThanHours into the
+// archive; a negative value means the configured archive_after_hours.
+func ArchiveLogs(olderThanHours int) bool {
+	policy := sshserver.LogPolicy(config.Get().Logs)
+	if olderThanHours >= 0 {
+		policy.ArchiveAfter = time.Duration(olderThanHours) * time.Hour
+	}
+
+	r, err := logstore.Archive(policy.ArchiveAfter)
+	fmt.Println()
+	if r.Sessions == 0 && err == nil {
+		dimmed.Printf("  No finished sessions idle for %s to archive\n\n", policy.ArchiveAfter)
+		return true
+	}
+	green.Printf("  Archived %d sessions", r.Sessions)
+	fmt.Printf(" (%d files, %s → %s)\n", r.Files, byteSize(r.Bytes), byteSize(r.Bytes-r.Freed))
+	dimmed.Printf("  %s, %d sessions archived in all\n", logstore.ArchiveDir, len(logstore.Index()))
+	if err != nil {
+		red.Printf("  %v\n", err)
+	}
+	fmt.Println()
+	return err == nil
+}
+
+// PruneLogs removes sessions by age and total size; a negative value
+// means the configured max_age_days or max_total_mb.
+func PruneLogs(maxAgeDays, maxTotalMB int) bool {
+	policy := sshserver.LogPolicy(config.Get().Logs)
+	if maxAgeDays >= 0 {
+		policy.MaxAge = time.Duration(maxAgeDays) * 24 * time.Hour
+	}
+	if maxTotalMB >= 0 {
+		policy.MaxTotalBytes = int64(maxTotalMB) << 20
+	}
+	fmt.Println()
+	if policy.MaxAge == 0 && policy.MaxTotalBytes == 0 {
+		yellow.Println("  No retention limit set (logs.max_age_days, logs.max_total_mb)")
+		fmt.Println()
+		return true
+	}
+
+	r, err := logstore.Prune(policy)
+	if r.Sessions == 0 && err == nil {
+		dimmed.Println("  Every session is within the retention limits")
+		fmt.Println()
+		return true
+	}
+	green.Printf("  Pruned %d sessions", r.Sessions)
+	fmt.Printf(" (%d files, %s freed)\n", r.Files, byteSize(r.Freed))
+	if err != nil {
+		red.Printf("  %v\n", err)
+	}
+	fmt.Println()
+	return err == nil
+}
+
+func byteSize(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1fMB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1fKB", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%dB", n)
+}
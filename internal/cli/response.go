@@ -0,0 +1,77 @@
+package cli
+
+import (
+	"GradGuard/internal/config"
+	"GradGuard/internal/detector"
+	sshserver "GradGuard/internal/sshserver"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// PlanResponse dry-runs the configured playbooks for a session escalating
+// up to level, showing every step each level would run or skip.
+func PlanResponse(level string) bool {
+	cfg := config.Get()
+	if err := detector.SetResponse(true, sshserver.ResponsePlaybooks(cfg.Response)); err != nil {
+		red.Printf("  response config invalid: %v\n", err)
+		return false
+	}
+
+	levels := []detector.Confidence{detector.ConfidenceWarning, detector.ConfidenceHigh, detector.ConfidenceCritical}
+	last := -1
+	for i, l := range levels {
+		if string(l) == level {
+			last = i
+		}
+	}
+	if last < 0 {
+		red.Printf("  unknown level %q (want warning, high or critical)\n", level)
+		return false
+	}
+
+	fmt.Println()
+	r := detector.NewResponder(detector.Target{Container: "honeypot-dry-run"})
+	for _, l := range levels[:last+1] {
+		taken, results := r.Respond(l)
+		bold.Printf("  ┌─ %s ", strings.ToUpper(string(l)))
+		dimmed.Printf("→ %s\n", taken)
+		for _, res := range results {
+			printActionResult(res.Action, res.Status, res.Params, res.Error)
+			if res.Status == detector.ActionDryRun {
+				for _, line := range strings.Split(res.Output, "\n") {
+					dimmed.Printf("  │        $ %s\n", line)
+				}
+			}
+		}
+		bold.Println("  └───────────────────────────────────────────────────────────┘")
+		fmt.Println()
+	}
+	return true
+}
+
+func printActionResult(action, status string, params map[string]string, errText string) {
+	c := dimmed
+	switch status {
+	case detector.ActionApplied, detector.ActionDryRun:
+		c = green
+	case detector.ActionFailed:
+		c = red
+	case detector.ActionRolledBack:
+		c = yellow
+	}
+	c.Printf("  │    %-11s", status)
+	fmt.Printf(" %s", action)
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		dimmed.Printf(" %s=%q", k, params[k])
+	}
+	fmt.Println()
+	if errText != "" {
+		red.Printf("  │        %s\n", errText)
+	}
+}
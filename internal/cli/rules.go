@@ -0,0 +1,92 @@
+package cli
+
+import (
+	"GradGuard/internal/analyzer"
+	"GradGuard/internal/config"
+	"fmt"
+	"strings"
+)
+
+func loadConfiguredRules() bool {
+	set, err := analyzer.LoadRules(config.Get().Rules.Dir)
+	if err != nil {
+		red.Printf("  rule files invalid: %v\n", err)
+		return false
+	}
+	analyzer.SetActiveRules(set)
+	return true
+}
+
+func CheckCorpus() bool {
+	if !loadConfiguredRules() {
+		return false
+	}
+	failures := analyzer.CheckCorpus(analyzer.RegressionCorpus)
+	total := len(analyzer.RegressionCorpus)
+
+	bold.Println("  ┌─ RULE REGRESSION CORPUS ───────────────┐")
+	for _, f := range failures {
+		red.Printf("  │  FAIL %s\n", f)
+	}
+	if len(failures) == 0 {
+		green.Printf("  │  %d/%d cases pass\n", total, total)
+	} else {
+		yellow.Printf("  │  %d/%d cases pass\n", total-len(failures), total)
+	}
+	bold.Println("  └────────────────────────────────────────┘")
+	fmt.Println()
+	return len(failures) == 0
+}
+
+func TestRules(command string) bool {
+	if !loadConfiguredRules() {
+		return false
+	}
+	set := analyzer.ActiveRules()
+
+	fmt.Println()
+	dimmed.Printf("  %d rules from %s\n\n", len(set.Rules), strings.Join(set.Sources, ", "))
+
+	bold.Println("  ┌─ PARSED COMMANDS ─────────────────────────────────────────┐")
+	parsed := analyzer.ParseLine(command, nil)
+	for _, pl := range parsed.Pipelines {
+		indent := strings.Repeat("  ", pl.Depth)
+		for _, c := range pl.Commands {
+			via := ""
+			if c.Via != "" {
+				via = dimmed.Sprintf("  (via %s)", c.Via)
+			}
+			white.Printf("  │  %s> %s", indent, c.String())
+			fmt.Println(via)
+		}
+	}
+	bold.Println("  └───────────────────────────────────────────────────────────┘")
+	fmt.Println()
+
+	if len(parsed.Decoded) > 0 {
+		bold.Println("  ┌─ DECODED LAYERS ──────────────────────────────────────────┐")
+		for _, d := range parsed.Decoded {
+			yellow.Printf("  │  %s%-7s", strings.Repeat("  ", d.Level-1), d.Encoding)
+			fmt.Printf(" → %s\n", truncate(strings.ReplaceAll(d.Text, "\n", "⏎"), 48))
+		}
+		bold.Println("  └───────────────────────────────────────────────────────────┘")
+		fmt.Println()
+	}
+
+	result := analyzer.Classify(command)
+	bold.Println("  ┌─ RULES FIRED ─────────────────────────────────────────────┐")
+	if len(result.Matches) == 0 {
+		dimmed.Println("  │  none")
+	}
+	for _, m := range result.Matches {
+		categoryColor(string(m.Category)).Printf("  │  [%-11s]", m.Category)
+		fmt.Printf(" %-28s +%-3d %s\n", m.RuleID, m.Weight, dimmed.Sprint(m.Technique))
+		dimmed.Printf("  │    %s — %s\n", m.Reason, m.Command)
+	}
+	bold.Println("  └───────────────────────────────────────────────────────────┘")
+	fmt.Println()
+
+	categoryColor(string(result.Category)).Printf("  RESULT: %s", strings.ToUpper(string(result.Category)))
+	fmt.Printf("  (weight: %d)\n\n", result.SuspicionWeight)
+	return true
+}
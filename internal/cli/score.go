@@ -0,0 +1,90 @@
+package cli
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+type scoreChange struct {
+	CommandIndex  int                 `json:"command_index"`
+	Command       string              `json:"command"`
+	Decay         float64             `json:"decay"`
+	Contributions []scoreContribution `json:"contributions"`
+	Score         float64             `json:"score"`
+}
+
+type scoreContribution struct {
+	RuleID   string  `json:"rule_id"`
+	Category string  `json:"category"`
+	Weight   int     `json:"weight"`
+	Points   float64 `json:"points"`
+	Note     string  `json:"note"`
+}
+
+const waterfallWidth = 40
+
+// printScoreWaterfall draws how the score got to its final value: each
+// command's bar starts where the previous one ended, less whatever had
+// decayed in between (shown as ░), followed by the rules behind it.
+func printScoreWaterfall(changes []scoreChange) {
+	if len(changes) == 0 {
+		return
+	}
+	scale := float64(waterfallWidth) / 100
+
+	bold.Println("  ┌─ SCORE WATERFALL ─────────────────────────────────────────────────────┐")
+	prev := 0.0
+	for _, c := range changes {
+		added := 0.0
+		for _, p := range c.Contributions {
+			added += p.Points
+		}
+		if added < 0.05 && c.Decay > -0.05 {
+			prev = c.Score
+			continue
+		}
+
+		start := prev + c.Decay
+		lead := int(math.Round(start * scale))
+		faded := int(math.Round(prev*scale)) - lead
+		bar := int(math.Round(c.Score*scale)) - lead
+		if added >= 0.05 && bar < 1 {
+			bar = 1
+		}
+
+		fmt.Printf("  │  #%-3d ", c.CommandIndex)
+		fmt.Print(strings.Repeat(" ", lead))
+		if bar > 0 {
+			red.Print(strings.Repeat("█", bar))
+		}
+		if rest := faded - bar; rest > 0 {
+			dimmed.Print(strings.Repeat("░", rest))
+		}
+		pad := waterfallWidth - lead - max(bar, faded)
+		fmt.Print(strings.Repeat(" ", max(pad, 0)))
+		fmt.Printf(" %5.1f", c.Score)
+		if c.Decay <= -0.05 {
+			dimmed.Printf("  (decay %.1f)", c.Decay)
+		}
+		fmt.Println()
+		white.Printf("  │        > %s\n", truncate(c.Command, 60))
+		for _, p := range c.Contributions {
+			if p.Points < 0.05 && p.Note == "" {
+				continue
+			}
+			rule := p.RuleID
+			if rule == "" {
+				rule = "(no rule)"
+			}
+			line := fmt.Sprintf("  │          %+6.1f  %-28s", p.Points, rule)
+			if p.Note != "" {
+				line += fmt.Sprintf(" of %d — %s", p.Weight, p.Note)
+			}
+			categoryColor(p.Category).Println(line)
+		}
+		prev = c.Score
+	}
+	bold.Println("  └───────────────────────────────────────────────────────────────────────┘")
+	fmt.Println()
+}
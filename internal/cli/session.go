@@ -1,11 +1,15 @@
 package cli
 
 import (
+	"GradGuard/internal/analyzer"
+	"GradGuard/internal/attack"
+	"GradGuard/internal/ipintel"
+	"GradGuard/internal/logstore"
 	"GradGuard/internal/ml"
+	"GradGuard/internal/store"
 	"encoding/json"
 	"fmt"
-	"os"
-	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/fatih/color"
@@ -19,6 +23,11 @@ type commandEvent struct {
 	Category       string `json:"category"`
 	SuspicionScore int    `json:"suspicion_score"`
 	Reason         string `json:"reason"`
+	Decoded        []struct {
+		Encoding string `json:"encoding"`
+		Text     string `json:"text"`
+		Level    int    `json:"level"`
+	} `json:"decoded"`
 }
 
 type detectionEvent struct {
@@ -29,6 +38,36 @@ type detectionEvent struct {
 	CommandIndex   int    `json:"command_index"`
 	TriggerCommand string `json:"trigger_command"`
 	ResponseTaken  string `json:"response_taken"`
+	Actions        []struct {
+		Action string            `json:"action"`
+		Params map[string]string `json:"params"`
+		Status string            `json:"status"`
+		Output string            `json:"output"`
+		Error  string            `json:"error"`
+	} `json:"actions"`
+	Verification *struct {
+		Revealed []string `json:"revealed"`
+	} `json:"verification"`
+	Techniques []attack.Tag `json:"techniques"`
+	Pattern    string       `json:"pattern"`
+	Profile    string       `json:"profile"`
+	Settings   *struct {
+		Signals []string `json:"signals"`
+		Tuning  struct {
+			ScoreWarning   int   `json:"score_warning"`
+			ScoreHigh      int   `json:"score_high"`
+			ScoreCritical  int   `json:"score_critical"`
+			SequenceLength int   `json:"sequence_length"`
+			TimingMedianMs int64 `json:"timing_median_ms"`
+			TimingMaxGapMs int64 `json:"timing_max_gap_ms"`
+			TimingWindow   int   `json:"timing_window"`
+		} `json:"tuning"`
+	} `json:"settings"`
+	ChainSteps []struct {
+		Step         string `json:"step"`
+		Command      string `json:"command"`
+		CommandIndex int    `json:"command_index"`
+	} `json:"chain_steps"`
 }
 
 func boolLabel(b bool) string {
@@ -55,31 +94,88 @@ func ShowSession(sessionID string) {
 
 	_, vc := verdictBadge(report.Verdict)
 	vc.Printf("  VERDICT: %s", strings.ToUpper(report.Verdict))
-	fmt.Printf("  (score: %d/100)\n\n", report.FinalSuspicionScore)
+	fmt.Printf("  (score: %d/100", report.FinalSuspicionScore)
+	if report.PeakSuspicionScore > report.FinalSuspicionScore {
+		fmt.Printf(", peak %d", report.PeakSuspicionScore)
+	}
+	fmt.Print(")\n\n")
+
+	printBehaviours(report.Assessment)
+	printKeystrokes(report.Keystrokes, report.Operator)
 
 	bold.Println("  ┌─ ATTACKER INTEL ──────────────────────┐")
-	ipInfo := LookupIP(report.RemoteAddr)
+	ipInfo := ipintel.Lookup(report.RemoteAddr)
 	printIPInfo(ipInfo)
 	bold.Println("  └───────────────────────────────────────┘")
 	fmt.Println()
 
-	bold.Println("  ┌─ CATEGORY BREAKDOWN ──────────┐")
-	green.Printf("  │  recon      : %d\n", report.CategoryBreakdown["recon"])
-	red.Printf("  │  fingerprint: %d\n", report.CategoryBreakdown["fingerprint"])
-	red.Printf("  │  exploit    : %d\n", report.CategoryBreakdown["exploit"])
-	dimmed.Printf("  │  unknown    : %d\n", report.CategoryBreakdown["unknown"])
-	bold.Println("  └───────────────────────────────┘")
+	if len(report.Techniques) > 0 {
+		bold.Println("  ┌─ ATT&CK TECHNIQUES ───────────────────────────────────────┐")
+		for _, t := range report.Techniques {
+			tactic := t.Tactic
+			if info, ok := attack.LookupTactic(t.Tactic); ok {
+				tactic = info.Name
+			}
+			yellow.Printf("  │  %-10s", t.Technique)
+			fmt.Printf(" %-42s ×%d\n", truncate(t.Name, 42), t.Count)
+			dimmed.Printf("  │             %s\n", tactic)
+		}
+		bold.Println("  └───────────────────────────────────────────────────────────┘")
+		fmt.Println()
+	}
+
+	bold.Println("  ┌─ CATEGORY BREAKDOWN ──────────────────┐")
+	for _, name := range orderedCategories(report.CategoryBreakdown) {
+		n := report.CategoryBreakdown[name]
+		c := categoryColor(name)
+		if n == 0 {
+			c = dimmed
+		}
+		c.Printf("  │  %-18s: %d\n", categoryLabel(name), n)
+	}
+	bold.Println("  └───────────────────────────────────────┘")
 	fmt.Println()
 
+	printScoreWaterfall(report.ScoreChanges)
+
 	if len(detections) > 0 {
 		bold.Println("  ┌─ DETECTION EVENTS ────────────────────────────────────────┐")
 		for _, d := range detections {
 			confColor := confidenceColor(d.Confidence)
 			confColor.Printf("  │  [%s]", strings.ToUpper(d.Confidence))
 			fmt.Printf(" %s\n", d.Signal)
+			if d.Pattern != "" {
+				dimmed.Printf("  │    pattern : %s\n", d.Pattern)
+				for _, st := range d.ChainSteps {
+					dimmed.Printf("  │      %-20s #%-3d %s\n", st.Step, st.CommandIndex, truncate(st.Command, 40))
+				}
+			}
 			dimmed.Printf("  │    trigger : %s\n", d.TriggerCommand)
 			dimmed.Printf("  │    response: %s\n", d.ResponseTaken)
+			for _, a := range d.Actions {
+				printActionResult(a.Action, a.Status, a.Params, a.Error)
+			}
+			if v := d.Verification; v != nil {
+				if len(v.Revealed) == 0 {
+					green.Println("  │    verified: no fingerprinting check reveals the container")
+				} else {
+					yellow.Printf("  │    verified: still revealed by %s\n", strings.Join(v.Revealed, ", "))
+				}
+			}
 			dimmed.Printf("  │    at cmd  : #%d — %s\n", d.CommandIndex, d.Timestamp)
+			if d.Settings != nil {
+				t := d.Settings.Tuning
+				dimmed.Printf("  │    profile : %s — score %d/%d/%d, sequence %d, timing <%dms over %d (gap %dms)\n",
+					d.Profile, t.ScoreWarning, t.ScoreHigh, t.ScoreCritical, t.SequenceLength,
+					t.TimingMedianMs, t.TimingWindow, t.TimingMaxGapMs)
+			}
+			if len(d.Techniques) > 0 {
+				ids := make([]string, len(d.Techniques))
+				for i, t := range d.Techniques {
+					ids[i] = t.Technique
+				}
+				dimmed.Printf("  │    att&ck  : %s\n", strings.Join(ids, ", "))
+			}
 			fmt.Println("  │")
 		}
 		bold.Println("  └───────────────────────────────────────────────────────────┘")
@@ -118,12 +214,63 @@ func ShowSession(sessionID string) {
 		)
 		white.Printf("  │         > %s\n", cmd.Command)
 		dimmed.Printf("  │           %s\n", cmd.Reason)
+		for _, d := range cmd.Decoded {
+			yellow.Printf("  │           %s%-7s", strings.Repeat("  ", d.Level-1), d.Encoding)
+			fmt.Printf(" → %s\n", truncate(strings.ReplaceAll(d.Text, "\n", "⏎"), 60))
+		}
 		fmt.Println("  │")
 	}
 	bold.Println("  └────────────────────────────────────────────────────────────────────┘")
 	fmt.Println()
 }
 
+func printBehaviours(a assessment) {
+	if len(a.Behaviours) == 0 {
+		return
+	}
+	bold.Println("  ┌─ BEHAVIOURS ──────────────────────────────────────────────┐")
+	for _, b := range a.Behaviours {
+		c := dimmed
+		switch {
+		case b.Confidence >= 0.75:
+			c = red
+		case b.Confidence >= 0.5:
+			c = yellow
+		}
+		filled := int(b.Confidence*20 + 0.5)
+		c.Printf("  │  %-17s %s", b.Label, strings.Repeat("█", filled))
+		dimmed.Print(strings.Repeat("░", 20-filled))
+		fmt.Printf(" %3.0f%%  ", b.Confidence*100)
+		dimmed.Printf("%s\n", strings.Join(b.Sources, "+"))
+		for _, e := range b.Evidence {
+			dimmed.Printf("  │      %s\n", e)
+		}
+	}
+	bold.Println("  └───────────────────────────────────────────────────────────┘")
+	fmt.Println()
+}
+
+func printKeystrokes(k keystrokes, op *operatorClass) {
+	if k.Keys+k.BurstChars == 0 {
+		return
+	}
+	bold.Println("  ┌─ KEYSTROKES ──────────────────────────────────────────────┐")
+	if op != nil {
+		c := green
+		if op.Class == analyzer.OperatorBot {
+			c = red
+		}
+		c.Printf("  │  %s", op.Class)
+		fmt.Printf(" (%.0f%%)\n", op.Confidence*100)
+		dimmed.Printf("  │      %s\n", op.Evidence)
+	}
+	fmt.Printf("  │  typed keys  : %-6d  interval %.0fms ± %.0f%%\n", k.Keys, k.MeanIntervalMs, k.IntervalCV*100)
+	fmt.Printf("  │  pasted      : %-6d  in %d burst(s)\n", k.BurstChars, k.Bursts)
+	fmt.Printf("  │  backspaces  : %-6d  lines %d (%d whole)\n", k.Backspaces, k.Lines, k.BurstLines)
+	bold.Println("  └───────────────────────────────────────────────────────────┘")
+	fmt.Println()
+}
+
 func confidenceColor(confidence string) *color.Color {
 	switch confidence {
 	case "critical":
@@ -136,21 +283,54 @@ func confidenceColor(confidence string) *color.Color {
 }
 
 func categoryColor(category string) *color.Color {
-	switch category {
-	case "fingerprint":
+	switch analyzer.Category(category) {
+	case analyzer.CategoryFingerprint, analyzer.CategoryCredentialAccess:
 		return red
-	case "exploit":
+	case analyzer.CategoryExploit, analyzer.CategoryPersistence, analyzer.CategoryCryptomining:
 		return color.New(color.FgMagenta, color.Bold)
-	case "recon":
+	case analyzer.CategoryDefenseEvasion, analyzer.CategoryLateralMovement:
+		return cyan
+	case analyzer.CategoryRecon, analyzer.CategoryDownload:
 		return yellow
-	default:
+	case analyzer.CategoryUnknown:
 		return dimmed
 	}
+	if info, ok := analyzer.LookupCategory(analyzer.Category(category)); ok && info.Flagged {
+		return red
+	}
+	return dimmed
+}
+
+func categoryLabel(category string) string {
+	if info, ok := analyzer.LookupCategory(analyzer.Category(category)); ok {
+		return info.Label
+	}
+	return category
+}
+
+// orderedCategories lists the categories in a breakdown by rank, with any
+// the current rule set no longer knows at the end.
+func orderedCategories(breakdown map[string]int) []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, c := range analyzer.Categories() {
+		if _, ok := breakdown[string(c.Name)]; ok {
+			names = append(names, string(c.Name))
+			seen[string(c.Name)] = true
+		}
+	}
+	var rest []string
+	for name := range breakdown {
+		if !seen[name] {
+			rest = append(rest, name)
+		}
+	}
+	sort.Strings(rest)
+	return append(names, rest...)
 }
 
 func loadReport(sessionID string) *reportFile {
-	path := filepath.Join("logs/reports", sessionID+"-report.json")
-	data, err := os.ReadFile(path)
+	data, err := store.SessionLog(logstore.Reports, sessionID)
 	if err != nil {
 		return nil
 	}
@@ -163,8 +343,7 @@ func loadReport(sessionID string) *reportFile {
 
 func loadSessionCommands(sessionID string) []commandEvent {
 	var commands []commandEvent
-	path := filepath.Join("logs/sessions", sessionID+".json")
-	data, err := os.ReadFile(path)
+	data, err := store.SessionLog(logstore.Commands, sessionID)
 	if err != nil {
 		return nil
 	}
@@ -184,8 +363,7 @@ func loadSessionCommands(sessionID string) []commandEvent {
 
 func loadSessionDetections(sessionID string) []detectionEvent {
 	var detections []detectionEvent
-	path := filepath.Join("logs/detections", sessionID+"-detections.json")
-	data, err := os.ReadFile(path)
+	data, err := store.SessionLog(logstore.Detections, sessionID)
 	if err != nil {
 		return nil
 	}
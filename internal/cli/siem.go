@@ -0,0 +1,129 @@
+package cli
+
+import (
+	"GradGuard/internal/config"
+	"GradGuard/internal/events"
+	"GradGuard/internal/siem"
+	"GradGuard/internal/sshserver"
+	"GradGuard/internal/store"
+	"crypto/tls"
+	"fmt"
+	"os"
+	"time"
+)
+
+// ReceiveSIEM runs a stand-in SIEM receiver that prints every message it
+// is sent. For tls and https it makes a throwaway certificate and writes
+// it to certFile for the sink's ca_file.
+func ReceiveSIEM(network, addr, certFile string) bool {
+	var cert *tls.Certificate
+	if network == "tls" || network == "https" {
+		c, pem, err := siem.SelfSigned()
+		if err != nil {
+			red.Printf("  %v\n", err)
+			return false
+		}
+		if certFile == "" {
+			certFile = "siem-receiver.pem"
+		}
+		if err := os.WriteFile(certFile, pem, 0644); err != nil {
+			red.Printf("  %v\n", err)
+			return false
+		}
+		cert = &c
+		dimmed.Printf("  Certificate written to %s; point the sink's ca_file at it\n", certFile)
+	}
+	green.Printf("  Receiving %s on %s\n\n", network, addr)
+	err := siem.Receive(network, addr, cert, func(from string, msg []byte) {
+		dimmed.Printf("%s ", time.Now().UTC().Format(time.RFC3339))
+		cyan.Printf("%s ", from)
+		fmt.Println(string(msg))
+	})
+	red.Printf("  %v\n", err)
+	return false
+}
+
+// ReplaySIEM sends the stored commands, detections and session reports
+// matching --session, --since and --until through every configured siem
+// sink, to load a SIEM with history or try a sink against a stand-in.
+func ReplaySIEM(args []string) bool {
+	q := store.Query{Kinds: []events.Kind{events.KindCommand, events.KindDetection, events.KindSessionEnd}}
+	for i := 0; i+1 < len(args); i += 2 {
+		flag, value := args[i], args[i+1]
+		var err error
+		switch flag {
+		case "--session":
+			q.SessionID = value
+		case "--since":
+			q.Since, err = parseWhen(value)
+		case "--until":
+			q.Until, err = parseWhen(value)
+		default:
+			err = fmt.Errorf("unknown filter")
+		}
+		if err != nil {
+			red.Printf("  %s %s: %v\n", flag, value, err)
+			return false
+		}
+	}
+	if len(args)%2 != 0 {
+		red.Printf("  %s needs a value\n", args[len(args)-1])
+		return false
+	}
+
+	var sinks []*siem.Sink
+	for _, c := range config.Get().Events.Sinks {
+		if c.Type != "siem" {
+			continue
+		}
+		s, err := siem.New(sshserver.SIEMOptions(c))
+		if err != nil {
+			red.Printf("  %v\n", err)
+			return false
+		}
+		defer s.Close()
+		sinks = append(sinks, s)
+	}
+	if len(sinks) == 0 {
+		yellow.Println("  No siem sinks are configured under events.sinks")
+		return false
+	}
+
+	s := store.Shared()
+	if s == nil {
+		yellow.Println("  The event store is empty; run \"honeypot store import\" to fill it from logs/")
+		return false
+	}
+	records, err := s.Find(q)
+	if err != nil {
+		red.Printf("  %v\n", err)
+		return false
+	}
+	batch := make([]events.Event, 0, len(records))
+	for _, r := range records {
+		batch = append(batch, events.Event{Kind: r.Kind, Time: r.Time, SessionID: r.SessionID, RemoteAddr: r.RemoteAddr, Data: r.Data})
+	}
+
+	fmt.Println()
+	ok := true
+	for _, sink := range sinks {
+		var failed error
+		for i := 0; i < len(batch); i += 64 {
+			if err := sink.Write(batch[i:min(i+64, len(batch))]); err != nil && failed == nil {
+				failed = err
+			}
+		}
+		bold.Printf("  %s", sink.Name())
+		if failed != nil {
+			ok = false
+			red.Printf("  %v\n", failed)
+		} else {
+			green.Printf("  %d events sent\n", len(batch))
+		}
+		if n := sink.Spooled(); n > 0 {
+			yellow.Printf("    %s spooled for retry\n", byteSize(n))
+		}
+	}
+	fmt.Println()
+	return ok
+}
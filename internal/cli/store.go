@@ -0,0 +1,145 @@
+package cli
+
+import (
+	"GradGuard/internal/events"
+	"GradGuard/internal/store"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ImportStore backfills the event store from the session log files.
+func ImportStore() bool {
+	s, err := store.OpenShared()
+	if err != nil {
+		red.Printf("  event store: %v\n", err)
+		return false
+	}
+	defer s.Close()
+
+	fmt.Println()
+	r, err := s.Import()
+	green.Printf("  Imported %d sessions", r.Sessions)
+	fmt.Printf(" (%d commands, %d detections, %d reports)\n",
+		r.Events[events.KindCommand], r.Events[events.KindDetection], r.Events[events.KindSessionEnd])
+	if r.Skipped > 0 {
+		dimmed.Printf("  %d session logs were already in the store\n", r.Skipped)
+	}
+	dimmed.Printf("  %s now holds %d events\n", store.Dir, s.Len())
+	if err != nil {
+		red.Printf("  %v\n", err)
+	}
+	fmt.Println()
+	return err == nil
+}
+
+// QueryStore prints the stored events matching the filters, given as
+// --session, --ip, --kind, --category, --rule, --signal, --verdict,
+// --since, --until and --limit pairs. Times are RFC 3339 or a duration
+// back from now, such as 24h.
+func QueryStore(args []string) bool {
+	var q store.Query
+	for i := 0; i+1 < len(args); i += 2 {
+		flag, value := args[i], args[i+1]
+		var err error
+		switch flag {
+		case "--session":
+			q.SessionID = value
+		case "--ip":
+			q.IP = value
+		case "--kind":
+			for _, k := range strings.Split(value, ",") {
+				q.Kinds = append(q.Kinds, events.Kind(k))
+			}
+		case "--category":
+			q.Category = value
+		case "--rule":
+			q.Rule = value
+		case "--signal":
+			q.Signal = value
+		case "--verdict":
+			q.Verdict = value
+		case "--since":
+			q.Since, err = parseWhen(value)
+		case "--until":
+			q.Until, err = parseWhen(value)
+		case "--limit":
+			_, err = fmt.Sscanf(value, "%d", &q.Limit)
+		default:
+			err = fmt.Errorf("unknown filter")
+		}
+		if err != nil {
+			red.Printf("  %s %s: %v\n", flag, value, err)
+			return false
+		}
+	}
+	if len(args)%2 != 0 {
+		red.Printf("  %s needs a value\n", args[len(args)-1])
+		return false
+	}
+
+	s := store.Shared()
+	if s == nil {
+		yellow.Println("  The event store is empty; run \"honeypot store import\" to fill it from logs/")
+		return true
+	}
+	records, err := s.Find(q)
+	if err != nil {
+		red.Printf("  %v\n", err)
+		return false
+	}
+	fmt.Println()
+	for _, r := range records {
+		dimmed.Printf("  %s ", r.Time.UTC().Format(time.RFC3339))
+		cyan.Printf("%-13s", r.Kind)
+		fmt.Printf(" %-15s %s\n", hostOnly(r.RemoteAddr), recordSummary(r))
+	}
+	dimmed.Printf("\n  %d events\n\n", len(records))
+	return true
+}
+
+func parseWhen(value string) (time.Time, error) {
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+func hostOnly(addr string) string {
+	if i := strings.LastIndexByte(addr, ':'); i > 0 {
+		return strings.Trim(addr[:i], "[]")
+	}
+	return addr
+}
+
+// recordSummary is the part of an event worth a glance in a listing.
+func recordSummary(r store.Record) string {
+	var d struct {
+		Command    string `json:"command"`
+		Category   string `json:"category"`
+		Signal     string `json:"signal"`
+		Confidence string `json:"confidence"`
+		Level      string `json:"level"`
+		Taken      string `json:"taken"`
+		Verdict    string `json:"verdict"`
+		User       string `json:"user"`
+		Path       string `json:"path"`
+	}
+	json.Unmarshal(r.Data, &d)
+	switch r.Kind {
+	case events.KindCommand:
+		return fmt.Sprintf("[%s] %s", d.Category, truncate(d.Command, 60))
+	case events.KindDetection:
+		return fmt.Sprintf("%s (%s) %s", d.Signal, d.Confidence, r.SessionID)
+	case events.KindResponse:
+		return fmt.Sprintf("%s → %s", d.Level, d.Taken)
+	case events.KindSessionEnd:
+		return fmt.Sprintf("%s %s", d.Verdict, r.SessionID)
+	case events.KindAuth, events.KindSessionStart:
+		return fmt.Sprintf("user=%s %s", d.User, r.SessionID)
+	case events.KindArtifact:
+		return d.Path
+	}
+	return r.SessionID
+}
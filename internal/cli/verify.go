@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"GradGuard/internal/config"
+	"GradGuard/internal/evidence"
+	"fmt"
+)
+
+// VerifySession checks a session's logs against their hash chains and
+// signed manifest and reports the first broken link.
+func VerifySession(sessionID string) bool {
+	evidence.SetKeyPath(config.Get().Evidence.SigningKey)
+	pub, err := evidence.PublicKey()
+	if err != nil {
+		red.Printf("  %v\n", err)
+		return false
+	}
+	r := evidence.Verify(sessionID, pub)
+
+	fmt.Println()
+	bold.Printf("  SESSION: %s\n\n", sessionID)
+	bold.Println("  ┌─ INTEGRITY ───────────────────────────────────────────────┐")
+	for _, f := range r.Files {
+		fmt.Printf("  │  %-11s: ", f.Log)
+		switch {
+		case f.Problem != "":
+			red.Println(f.Problem)
+		case !f.Present:
+			dimmed.Println("none")
+		case f.Chain != nil:
+			green.Printf("chain intact")
+			fmt.Printf(" (%d lines)", f.Chain.Lines)
+			if f.Unsigned > 0 {
+				yellow.Printf("  %d after signing", f.Unsigned)
+			}
+			fmt.Println()
+		default:
+			green.Println("matches manifest")
+		}
+	}
+	if m := r.Manifest; m != nil {
+		fmt.Printf("  │  manifest   : signed %s by key %s ", m.Signed, m.KeyID)
+		if r.SignatureOK {
+			green.Println("✓")
+		} else {
+			red.Println("✗")
+		}
+	}
+	bold.Println("  └───────────────────────────────────────────────────────────┘")
+	fmt.Println()
+
+	if !r.OK() {
+		red.Printf("  TAMPERED: %s\n\n", r.FirstBroken)
+		return false
+	}
+	green.Println("  VERIFIED: logs are as captured")
+	fmt.Println()
+	return true
+}
@@ -0,0 +1,153 @@
+package cli
+
+import (
+	"GradGuard/internal/config"
+	"GradGuard/internal/control"
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Watch follows a running sensor: sessions as they open, each command
+// with its category and the session's score, detections and verdicts,
+// narrowed by --verdict and --ip.
+func Watch(args []string) bool {
+	var f control.Filter
+	for i := 0; i < len(args); i += 2 {
+		if i+1 == len(args) {
+			red.Printf("  %s needs a value\n", args[i])
+			return false
+		}
+		switch args[i] {
+		case "--verdict":
+			f.Verdicts = append(f.Verdicts, strings.Split(args[i+1], ",")...)
+		case "--ip":
+			f.IPs = append(f.IPs, strings.Split(args[i+1], ",")...)
+		default:
+			red.Printf("  %s: unknown flag\n", args[i])
+			return false
+		}
+	}
+
+	socket := config.Get().Control.Socket
+	green.Printf("  Watching the sensor on %s", socket)
+	if len(f.Verdicts) > 0 {
+		dimmed.Printf("  verdict %s", strings.Join(f.Verdicts, ","))
+	}
+	if len(f.IPs) > 0 {
+		dimmed.Printf("  ip %s", strings.Join(f.IPs, ","))
+	}
+	fmt.Print("\n\n")
+	err := control.Watch(socket, f, showMessage)
+	red.Printf("  %v\n", err)
+	return false
+}
+
+func showMessage(m control.Message) {
+	if m.Missed > 0 {
+		yellow.Printf("  … %d messages missed while falling behind\n", m.Missed)
+	}
+	badge, badgeColor := verdictBadge(m.Verdict)
+	dimmed.Printf("  %s ", m.Time.Local().Format(time.TimeOnly))
+	badgeColor.Printf("%-9s", "["+badge+"]")
+	cyan.Printf(" %-15s ", hostOnly(m.RemoteAddr))
+	switch m.Type {
+	case control.TypeOpen:
+		bold.Printf("opened %s", m.SessionID)
+		dimmed.Printf("  user %s  %s\n", m.User, m.Client)
+	case control.TypeCommand:
+		fmt.Printf("$ %s ", truncate(m.Command, 60))
+		categoryColor(m.Category).Printf(" %s", categoryLabel(m.Category))
+		dimmed.Printf("  score %d\n", m.Score)
+	case control.TypeDetection:
+		confidenceColor(m.Confidence).Printf("⚠ %s %s", m.Signal, m.Confidence)
+		dimmed.Printf("  %s", m.Details)
+		if m.Response != "" && m.Response != "none" {
+			yellow.Printf("  → %s", m.Response)
+		}
+		fmt.Println()
+	case control.TypeClose:
+		bold.Printf("closed %s", m.SessionID)
+		dimmed.Printf("  %s, %d commands, score %d\n", m.Verdict, m.Commands, m.Score)
+	}
+}
+
+// WatchSessions lists the sessions open on a running sensor, numbered
+// for WatchAttach.
+func WatchSessions() bool {
+	live, err := control.List(config.Get().Control.Socket)
+	if err != nil {
+		red.Printf("  %v\n", err)
+		return false
+	}
+	showLive(live)
+	return true
+}
+
+func showLive(live []control.Live) {
+	fmt.Println()
+	if len(live) == 0 {
+		dimmed.Println("  No live sessions")
+		fmt.Println()
+		return
+	}
+	for i, l := range live {
+		badge, badgeColor := verdictBadge(l.Verdict)
+		fmt.Printf("  %2d  ", i+1)
+		badgeColor.Printf("%-9s", "["+badge+"]")
+		cyan.Printf(" %-15s ", hostOnly(l.RemoteAddr))
+		fmt.Printf("%s", l.ID)
+		dimmed.Printf("  %s, %s, %d commands, score %d", l.User, time.Since(l.Started).Round(time.Second), l.Commands, l.Score)
+		if !l.Terminal {
+			dimmed.Print(", no shell")
+		}
+		fmt.Println()
+	}
+	fmt.Println()
+}
+
+// WatchAttach shows a live session's terminal as the attacker sees it,
+// read-only, until the session ends. Without an ID, or given a number
+// from WatchSessions, it picks from the live sessions.
+func WatchAttach(id string) bool {
+	socket := config.Get().Control.Socket
+	if _, err := strconv.Atoi(id); id == "" || err == nil {
+		live, err := control.List(socket)
+		if err != nil {
+			red.Printf("  %v\n", err)
+			return false
+		}
+		if id == "" {
+			showLive(live)
+			if len(live) == 0 {
+				return false
+			}
+			fmt.Print("  Attach to which? ")
+			line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+			id = strings.TrimSpace(line)
+		}
+		if n, err := strconv.Atoi(id); err == nil {
+			if n < 1 || n > len(live) {
+				red.Printf("  No session %d\n", n)
+				return false
+			}
+			id = live[n-1].ID
+		}
+	}
+
+	err := control.Attach(socket, id, func(l control.Live) {
+		green.Printf("\n  Attached to %s", l.ID)
+		dimmed.Printf("  %s as %s, %dx%d, read-only; Ctrl-C leaves\n\n", hostOnly(l.RemoteAddr), l.User, l.Cols, l.Rows)
+	}, os.Stdout)
+	// Put back whatever colours the session left the terminal in.
+	fmt.Print("\x1b[0m\r\n")
+	if err != nil {
+		red.Printf("  %v\n", err)
+		return false
+	}
+	dimmed.Println("  Session ended")
+	return true
+}
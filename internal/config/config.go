@@ -0,0 +1,510 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+)
+
+type Config struct {
+	Rules     RulesConfig     `json:"rules"`
+	Scoring   ScoringConfig   `json:"scoring"`
+	Detector  DetectorConfig  `json:"detector"`
+	Response  ResponseConfig  `json:"response"`
+	Deception DeceptionConfig `json:"deception"`
+	Tarpit    TarpitConfig    `json:"tarpit"`
+	Events    EventsConfig    `json:"events"`
+	Logs      LogsConfig      `json:"logs"`
+	Evidence  EvidenceConfig  `json:"evidence"`
+	Intel     IntelConfig     `json:"intel"`
+	Alerts    AlertsConfig    `json:"alerts"`
+	Limits    LimitsConfig    `json:"limits"`
+	Admin     AdminConfig     `json:"admin"`
+	Control   ControlConfig   `json:"control"`
+}
+
+// RulesConfig is where rule files, *.json, are read from, checked for
+// changes every ReloadSeconds; 0 reads them once at start.
+type RulesConfig struct {
+	Dir           string `json:"dir"`
+	ReloadSeconds int    `json:"reload_seconds"`
+}
+
+// ScoringConfig tunes the session suspicion score. A half-life of 0
+// turns decay off and a repeat factor of 1 counts repeated commands in
+// full; categories missing from CategoryCaps are only bounded by the
+// overall maximum of 100.
+type ScoringConfig struct {
+	HalfLifeSeconds int            `json:"half_life_seconds"`
+	RepeatFactor    float64        `json:"repeat_factor"`
+	CategoryCaps    map[string]int `json:"category_caps"`
+}
+
+// DetectorConfig holds one profile per kind of login identity. A session
+// uses the profile listing its login user, or the one named "default".
+type DetectorConfig struct {
+	Profiles []DetectorProfile `json:"profiles"`
+}
+
+// DetectorProfile picks the signals a profile runs: all registered ones
+// when Signals is empty, minus any in Disabled.
+type DetectorProfile struct {
+	Name       string             `json:"name"`
+	Users      []string           `json:"users"`
+	Signals    []string           `json:"signals"`
+	Disabled   []string           `json:"disabled"`
+	Thresholds DetectorThresholds `json:"thresholds"`
+}
+
+// DetectorThresholds tunes the built-in signals of one profile; a value
+// left out keeps its default (50/75/100, 3, 300ms/5000ms over 5).
+type DetectorThresholds struct {
+	ScoreWarning   int `json:"score_warning"`
+	ScoreHigh      int `json:"score_high"`
+	ScoreCritical  int `json:"score_critical"`
+	SequenceLength int `json:"sequence_length"`
+	TimingMedianMs int `json:"timing_median_ms"`
+	TimingMaxGapMs int `json:"timing_max_gap_ms"`
+	TimingWindow   int `json:"timing_window"`
+}
+
+// ResponseConfig sets what is done to a container when a signal fires.
+// Playbooks are keyed by level (warning, high, critical) and replace the
+// built-in playbook of that level. DryRun records every step with the
+// script it would run instead of running it.
+type ResponseConfig struct {
+	DryRun    bool                      `json:"dry_run"`
+	Playbooks map[string][]ResponseStep `json:"playbooks"`
+}
+
+// ResponseStep runs one named action with its parameters. If a required
+// step fails, the steps applied before it in the same run are undone.
+type ResponseStep struct {
+	Action   string            `json:"action"`
+	Params   map[string]string `json:"params"`
+	Required bool              `json:"required"`
+}
+
+// DeceptionConfig sets up the containers sessions run in, so that they
+// look less like containers. Image may be the deception variant built
+// from the Dockerfile; Hostname replaces Docker's container-ID hostname;
+// ProcViews mounts the /proc views that responses rewrite from the host,
+// kept under run/proc, which must be 0700 and the sensor's own;
+// Init is the container's first process, which /proc/1/comm shows.
+type DeceptionConfig struct {
+	Image     string   `json:"image"`
+	Hostname  string   `json:"hostname"`
+	ProcViews bool     `json:"proc_views"`
+	Init      []string `json:"init"`
+}
+
+// TarpitConfig lists the tiers the tarpit response escalates through,
+// slowest last. Leaving it out keeps the built-in slow, sticky and tar.
+type TarpitConfig struct {
+	Tiers []TarpitTier `json:"tiers"`
+}
+
+// TarpitTier slows a live session at the SSH channel: output throttled to
+// BytesPerSecond, a delay before each command that grows per command up
+// to a maximum, extra delay for named binaries and random "disk busy"
+// stalls. Zero turns a knob off.
+type TarpitTier struct {
+	Name              string         `json:"name"`
+	BytesPerSecond    int            `json:"bytes_per_second"`
+	CommandDelayMs    int            `json:"command_delay_ms"`
+	CommandGrowthMs   int            `json:"command_growth_ms"`
+	MaxCommandDelayMs int            `json:"max_command_delay_ms"`
+	StallChance       float64        `json:"stall_chance"`
+	StallMs           int            `json:"stall_ms"`
+	SlowBinariesMs    map[string]int `json:"slow_binaries_ms"`
+}
+
+// EventsConfig sends every event the honeypot records to extra sinks on
+// top of the session files under logs/, which are always written. Each
+// sink has its own queue of QueueSize events (0 means 1024); a sink that
+// falls that far behind loses events rather than slow down sessions.
+type EventsConfig struct {
+	QueueSize int          `json:"queue_size"`
+	Sinks     []SinkConfig `json:"sinks"`
+}
+
+// SinkConfig is one event sink. Type picks which fields apply:
+//
+//	jsonl    Path, rotated at MaxMB keeping Keep old files
+//	stdout   nothing
+//	syslog   Network and Address (empty for the local daemon), Tag
+//	webhook  URL, Headers, TimeoutSeconds
+//	unix     Path of the listening socket
+//	siem     Format (cef, leef, ecs or hec) posted to URL, or sent as
+//	         syslog to Address over Network udp, tcp or tls; CAFile
+//	         trusts a private CA, Token and Index are Splunk HEC's. A
+//	         failed batch is spooled at once to SpoolPath (default under
+//	         logs/spool) up to SpoolMB, and retried in the background,
+//	         Retries times with backoff (0 means 3), then every 30
+//	         seconds until the receiver is back.
+type SinkConfig struct {
+	Type           string            `json:"type"`
+	Path           string            `json:"path"`
+	MaxMB          int               `json:"max_mb"`
+	Keep           int               `json:"keep"`
+	Network        string            `json:"network"`
+	Address        string            `json:"address"`
+	Tag            string            `json:"tag"`
+	URL            string            `json:"url"`
+	Headers        map[string]string `json:"headers"`
+	TimeoutSeconds int               `json:"timeout_seconds"`
+	Format         string            `json:"format"`
+	CAFile         string            `json:"ca_file"`
+	Token          string            `json:"token"`
+	Index          string            `json:"index"`
+	Retries        int               `json:"retries"`
+	SpoolPath      string            `json:"spool_path"`
+	SpoolMB        int               `json:"spool_mb"`
+}
+
+// LogsConfig keeps logs/ in check. Finished sessions idle for
+// ArchiveAfterHours are packed into gzipped archives; sessions that
+// started more than MaxAgeDays ago, then the oldest until everything fits
+// in MaxTotalMB, are removed. The janitor does both every JanitorMinutes
+// while the honeypot runs; 0 leaves it to "honeypot logs". Compression
+// must be gzip: this build has no zstd encoder.
+type LogsConfig struct {
+	ArchiveAfterHours int    `json:"archive_after_hours"`
+	MaxAgeDays        int    `json:"max_age_days"`
+	MaxTotalMB        int    `json:"max_total_mb"`
+	JanitorMinutes    int    `json:"janitor_minutes"`
+	Compression       string `json:"compression"`
+}
+
+// EvidenceConfig names the ed25519 key that signs each session's
+// manifest. It is created on first use, with its public half beside it
+// in a .pub file; keep a copy of that to verify logs elsewhere.
+type EvidenceConfig struct {
+	SigningKey string `json:"signing_key"`
+}
+
+// IntelConfig shares what the honeypot sees with peers, marked with TLP
+// (white, green, amber or red). Sessions with one of Verdicts are shared,
+// or every session but the clean ones when it is empty. TAXIIListen, when
+// set, serves them as a TAXII 2.1 feed covering the last WindowDays (0
+// means all kept), behind basic auth when TAXIIUser is set, over HTTPS
+// with TAXIICert and TAXIIKey. Anywhere but a loopback address the feed
+// needs both a certificate and a user.
+type IntelConfig struct {
+	TLP           string   `json:"tlp"`
+	Verdicts      []string `json:"verdicts"`
+	TAXIIListen   string   `json:"taxii_listen"`
+	TAXIIUser     string   `json:"taxii_user"`
+	TAXIIPassword string   `json:"taxii_password"`
+	TAXIICert     string   `json:"taxii_cert"`
+	TAXIIKey      string   `json:"taxii_key"`
+	WindowDays    int      `json:"window_days"`
+}
+
+// AlertsConfig tells people about attacks as they happen. Detections,
+// session verdicts and sessions from a new AS become alerts; the first
+// rule an alert matches sends it to the named outputs, and a rule marked
+// continue lets the ones after it have a go too. For example, to page on
+// exploits and mail a daily digest of new networks:
+//
+//	"rules": [
+//	  {"name": "page", "kinds": ["verdict"], "min_severity": "critical",
+//	   "verdicts": ["exploit_attempt"], "outputs": ["pager"]},
+//	  {"name": "networks", "kinds": ["new_asn"], "digest_minutes": 1440,
+//	   "outputs": ["mail"]}
+//	]
+type AlertsConfig struct {
+	Outputs []AlertOutput `json:"outputs"`
+	Rules   []AlertRule   `json:"rules"`
+}
+
+// AlertOutput is one place alerts go. Type picks which fields apply:
+//
+//	webhook  the alert as JSON posted to URL, with Headers
+//	slack    a Slack (or Mattermost) incoming webhook at URL
+//	teams    a Teams incoming webhook at URL
+//	smtp     mail From to To through the server at Address, logging in
+//	         as User when set
+type AlertOutput struct {
+	Name           string            `json:"name"`
+	Type           string            `json:"type"`
+	URL            string            `json:"url"`
+	Headers        map[string]string `json:"headers"`
+	Address        string            `json:"address"`
+	From           string            `json:"from"`
+	To             []string          `json:"to"`
+	User           string            `json:"user"`
+	Password       string            `json:"password"`
+	TimeoutSeconds int               `json:"timeout_seconds"`
+}
+
+// AlertRule matches alerts of one of Kinds (detection, verdict, new_asn),
+// or of any kind when it names none, at MinSeverity (info, warning, high,
+// critical) or above, with one of Signals and Verdicts when given. Per (ip or session) is what repeats
+// are counted against: an alert like one sent within DedupeMinutes is
+// dropped, and at most ThrottleCount go out per ThrottleMinutes. With
+// DigestMinutes the alerts are gathered into one message. Title and Body
+// are Go templates of the alert, replacing the default wording. new_asn
+// alerts are only raised when some rule names the kind, as telling them
+// needs every attacker's address looked up at ip-api.com.
+type AlertRule struct {
+	Name            string   `json:"name"`
+	Kinds           []string `json:"kinds"`
+	MinSeverity     string   `json:"min_severity"`
+	Signals         []string `json:"signals"`
+	Verdicts        []string `json:"verdicts"`
+	Outputs         []string `json:"outputs"`
+	Continue        bool     `json:"continue"`
+	Per             string   `json:"per"`
+	DedupeMinutes   int      `json:"dedupe_minutes"`
+	ThrottleCount   int      `json:"throttle_count"`
+	ThrottleMinutes int      `json:"throttle_minutes"`
+	DigestMinutes   int      `json:"digest_minutes"`
+	Title           string   `json:"title"`
+	Body            string   `json:"body"`
+}
+
+// LimitsConfig caps the connections open at once, in all and from one
+// address; 0 means no cap. A connection over a cap is closed before the
+// SSH handshake.
+type LimitsConfig struct {
+	MaxConnections int `json:"max_connections"`
+	MaxPerIP       int `json:"max_per_ip"`
+}
+
+// AdminConfig serves the honeypot's own health on Listen: /metrics in the
+// Prometheus text format, /healthz while the process runs and /readyz
+// while Docker answers and the log directories can be written. Empty
+// turns it off; keep it on a loopback or management address.
+type AdminConfig struct {
+	Listen string `json:"listen"`
+}
+
+// ControlConfig is the Unix socket "honeypot watch" attaches to, open
+// only to the user the sensor runs as. Empty turns it off.
+type ControlConfig struct {
+	Socket string `json:"socket"`
+}
+
+func Default() *Config {
+	return &Config{
+		Rules: RulesConfig{
+			Dir:           "rules",
+			ReloadSeconds: 5,
+		},
+		Scoring: ScoringConfig{
+			HalfLifeSeconds: 900,
+			RepeatFactor:    0.5,
+			CategoryCaps: map[string]int{
+				"unknown":  10,
+				"recon":    30,
+				"download": 40,
+			},
+		},
+		Deception: DeceptionConfig{
+			Image:     "honeypot-base",
+			ProcViews: true,
+			Init:      []string{"sleep", "infinity"},
+		},
+		Logs: LogsConfig{
+			ArchiveAfterHours: 24,
+			Compression:       "gzip",
+		},
+		Evidence: EvidenceConfig{
+			SigningKey: "config/evidence_ed25519",
+		},
+		Intel: IntelConfig{
+			TLP:        "green",
+			WindowDays: 30,
+		},
+		Control: ControlConfig{
+			Socket: "run/control.sock",
+		},
+	}
+}
+
+var (
+	once   sync.Once
+	loaded *Config
+)
+
+// Get returns the process configuration, read once from $GRADGUARD_CONFIG
+// or config/gradguard.json. A missing file means defaults; a broken one is
+// fatal so a typo never silently disables detection.
+func Get() *Config {
+	once.Do(func() {
+		path := os.Getenv("GRADGUARD_CONFIG")
+		if path == "" {
+			path = "config/gradguard.json"
+		}
+		cfg, err := Load(path)
+		if err != nil {
+			log.Fatalf("config %s: %v", path, err)
+		}
+		loaded = cfg
+	})
+	return loaded
+}
+
+func Load(path string) (*Config, error) {
+	cfg := Default()
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, err
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (c *Config) validate() error {
+	if c.Rules.ReloadSeconds < 0 {
+		return fmt.Errorf("rules.reload_seconds must not be negative")
+	}
+	if c.Scoring.HalfLifeSeconds < 0 {
+		return fmt.Errorf("scoring.half_life_seconds must not be negative")
+	}
+	if c.Scoring.RepeatFactor < 0 || c.Scoring.RepeatFactor > 1 {
+		return fmt.Errorf("scoring.repeat_factor must be between 0 and 1")
+	}
+	if c.Deception.Image == "" || len(c.Deception.Init) == 0 {
+		return fmt.Errorf("deception.image and deception.init must be set")
+	}
+	if c.Logs.ArchiveAfterHours < 0 || c.Logs.MaxAgeDays < 0 || c.Logs.MaxTotalMB < 0 || c.Logs.JanitorMinutes < 0 {
+		return fmt.Errorf("logs limits must not be negative")
+	}
+	if c.Limits.MaxConnections < 0 || c.Limits.MaxPerIP < 0 {
+		return fmt.Errorf("limits must not be negative")
+	}
+	if c.Evidence.SigningKey == "" {
+		return fmt.Errorf("evidence.signing_key must be set")
+	}
+	switch c.Intel.TLP {
+	case "white", "green", "amber", "red":
+	default:
+		return fmt.Errorf("intel.tlp: unknown %q (want white, green, amber or red)", c.Intel.TLP)
+	}
+	if c.Intel.WindowDays < 0 {
+		return fmt.Errorf("intel.window_days must not be negative")
+	}
+	if (c.Intel.TAXIICert == "") != (c.Intel.TAXIIKey == "") {
+		return fmt.Errorf("intel.taxii_cert and intel.taxii_key go together")
+	}
+	switch c.Logs.Compression {
+	case "gzip":
+	case "zstd":
+		return fmt.Errorf("logs.compression: zstd is not available in this build, use gzip")
+	default:
+		return fmt.Errorf("logs.compression: unknown %q (want gzip)", c.Logs.Compression)
+	}
+	for i, sink := range c.Events.Sinks {
+		if err := sink.validate(); err != nil {
+			return fmt.Errorf("events.sinks[%d]: %w", i, err)
+		}
+	}
+	outputs := map[string]bool{}
+	for i, o := range c.Alerts.Outputs {
+		if o.Name == "" || outputs[o.Name] {
+			return fmt.Errorf("alerts.outputs[%d]: needs a name no other output has", i)
+		}
+		outputs[o.Name] = true
+		switch o.Type {
+		case "webhook", "slack", "teams":
+			if o.URL == "" {
+				return fmt.Errorf("alerts.outputs[%d]: %s output needs a url", i, o.Type)
+			}
+		case "smtp":
+			if o.Address == "" || o.From == "" || len(o.To) == 0 {
+				return fmt.Errorf("alerts.outputs[%d]: smtp output needs an address, from and to", i)
+			}
+		default:
+			return fmt.Errorf("alerts.outputs[%d]: unknown type %q", i, o.Type)
+		}
+	}
+	for i, r := range c.Alerts.Rules {
+		if err := r.validate(outputs); err != nil {
+			return fmt.Errorf("alerts.rules[%d]: %w", i, err)
+		}
+	}
+	for name, limit := range c.Scoring.CategoryCaps {
+		if limit < 0 {
+			return fmt.Errorf("scoring.category_caps.%s must not be negative", name)
+		}
+	}
+	return nil
+}
+
+func (r AlertRule) validate(outputs map[string]bool) error {
+	if r.Name == "" || len(r.Outputs) == 0 {
+		return fmt.Errorf("a rule needs a name and outputs")
+	}
+	for _, name := range r.Outputs {
+		if !outputs[name] {
+			return fmt.Errorf("rule %s: no output named %q", r.Name, name)
+		}
+	}
+	for _, k := range r.Kinds {
+		if k != "detection" && k != "verdict" && k != "new_asn" {
+			return fmt.Errorf("rule %s: unknown kind %q (want detection, verdict or new_asn)", r.Name, k)
+		}
+	}
+	switch r.MinSeverity {
+	case "", "info", "warning", "high", "critical":
+	default:
+		return fmt.Errorf("rule %s: unknown min_severity %q", r.Name, r.MinSeverity)
+	}
+	if r.Per != "" && r.Per != "ip" && r.Per != "session" {
+		return fmt.Errorf("rule %s: per must be ip or session, not %q", r.Name, r.Per)
+	}
+	if r.DedupeMinutes < 0 || r.ThrottleCount < 0 || r.ThrottleMinutes < 0 || r.DigestMinutes < 0 {
+		return fmt.Errorf("rule %s: dedupe, throttle and digest settings must not be negative", r.Name)
+	}
+	if (r.ThrottleCount > 0) != (r.ThrottleMinutes > 0) {
+		return fmt.Errorf("rule %s: throttle_count and throttle_minutes go together", r.Name)
+	}
+	return nil
+}
+
+func (s SinkConfig) validate() error {
+	switch s.Type {
+	case "jsonl", "unix":
+		if s.Path == "" {
+			return fmt.Errorf("%s sink needs a path", s.Type)
+		}
+	case "webhook":
+		if s.URL == "" {
+			return fmt.Errorf("webhook sink needs a url")
+		}
+	case "siem":
+		switch s.Format {
+		case "cef", "leef", "ecs", "hec":
+		default:
+			return fmt.Errorf("siem sink: format must be cef, leef, ecs or hec, not %q", s.Format)
+		}
+		if s.URL == "" {
+			if s.Address == "" {
+				return fmt.Errorf("siem sink needs a url or an address")
+			}
+			if s.Network != "udp" && s.Network != "tcp" && s.Network != "tls" {
+				return fmt.Errorf("siem sink: network must be udp, tcp or tls, not %q", s.Network)
+			}
+		}
+	case "syslog", "stdout":
+	default:
+		return fmt.Errorf("unknown sink type %q", s.Type)
+	}
+	if s.MaxMB < 0 || s.Keep < 0 || s.TimeoutSeconds < 0 || s.SpoolMB < 0 {
+		return fmt.Errorf("%s sink: max_mb, keep, timeout_seconds and spool_mb must not be negative", s.Type)
+	}
+	return nil
+}
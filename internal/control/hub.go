@@ -0,0 +1,276 @@
+// Package control lets the CLI look at a running sensor through a local
+// Unix socket: the sessions open now, their commands and detections as
+// they happen, and any one session's terminal, read-only.
+package control
+
+import (
+	"GradGuard/internal/events"
+	"encoding/json"
+	"net"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// watchQueue is how many messages a watcher may fall behind by before
+	// it misses some.
+	watchQueue = 256
+	// screenQueue is how many writes a viewer may fall behind by before
+	// it is let go.
+	screenQueue = 256
+	// replaySize is how much recent output a viewer sees on attaching.
+	replaySize = 16 << 10
+)
+
+// Live is a session open now.
+type Live struct {
+	ID         string    `json:"id"`
+	RemoteAddr string    `json:"remote_addr"`
+	User       string    `json:"user"`
+	Client     string    `json:"client"`
+	Started    time.Time `json:"started"`
+	Commands   int       `json:"commands"`
+	Score      int       `json:"score"`
+	// Verdict is what the session looks like so far.
+	Verdict string `json:"verdict"`
+	// Terminal is set once the session has a shell to attach to.
+	Terminal bool   `json:"terminal"`
+	Cols     uint32 `json:"cols,omitempty"`
+	Rows     uint32 `json:"rows,omitempty"`
+}
+
+// Message is one thing a watcher is told: a session opening, a command,
+// a detection or a session closing with its verdict.
+type Message struct {
+	Type       string    `json:"type"`
+	Time       time.Time `json:"time"`
+	SessionID  string    `json:"session_id"`
+	RemoteAddr string    `json:"remote_addr"`
+	Verdict    string    `json:"verdict"`
+	User       string    `json:"user,omitempty"`
+	Client     string    `json:"client,omitempty"`
+	Command    string    `json:"command,omitempty"`
+	Category   string    `json:"category,omitempty"`
+	Score      int       `json:"score,omitempty"`
+	Signal     string    `json:"signal,omitempty"`
+	Confidence string    `json:"confidence,omitempty"`
+	Details    string    `json:"details,omitempty"`
+	Response   string    `json:"response,omitempty"`
+	Commands   int       `json:"commands,omitempty"`
+	// Missed counts the messages dropped before this one because the
+	// watcher fell behind.
+	Missed int `json:"missed,omitempty"`
+}
+
+// Message types.
+const (
+	TypeOpen      = "open"
+	TypeCommand   = "command"
+	TypeDetection = "detection"
+	TypeClose     = "close"
+)
+
+// Filter narrows what a watcher is sent: sessions whose verdict so far is
+// one of Verdicts, from one of IPs, which may be addresses or CIDR
+// ranges. Empty lists let everything through.
+type Filter struct {
+	Verdicts []string `json:"verdicts,omitempty"`
+	IPs      []string `json:"ips,omitempty"`
+}
+
+func (f Filter) match(m Message) bool {
+	if len(f.Verdicts) > 0 && !slices.Contains(f.Verdicts, m.Verdict) {
+		return false
+	}
+	if len(f.IPs) == 0 {
+		return true
+	}
+	ip := net.ParseIP(hostOf(m.RemoteAddr))
+	for _, want := range f.IPs {
+		if _, cidr, err := net.ParseCIDR(want); err == nil {
+			if ip != nil && cidr.Contains(ip) {
+				return true
+			}
+		} else if w := net.ParseIP(want); w != nil && w.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+type watcher struct {
+	filter Filter
+	ch     chan Message
+	missed int
+}
+
+// hub is what the sensor knows of its live sessions and who is looking.
+var hub = struct {
+	mu       sync.Mutex
+	sessions map[string]*session
+	watchers map[*watcher]bool
+}{
+	sessions: map[string]*session{},
+	watchers: map[*watcher]bool{},
+}
+
+type session struct {
+	Live
+	term *Terminal
+}
+
+// Opened records a session as soon as its connection is up.
+func Opened(id, remoteAddr, user, client string) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	hub.sessions[id] = &session{Live: Live{
+		ID: id, RemoteAddr: remoteAddr, User: user, Client: client,
+		Started: time.Now().UTC(), Verdict: "clean",
+	}}
+}
+
+// Closed forgets a session whose connection is gone. One whose shell is
+// still winding down stays until its end is published, so the verdict
+// its last messages are filtered by is still known.
+func Closed(id string) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	if s, ok := hub.sessions[id]; ok && (s.term == nil || s.term.ended()) {
+		delete(hub.sessions, id)
+	}
+}
+
+// SetVerdict records what a session looks like after its latest command.
+func SetVerdict(id, verdict string) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	if s, ok := hub.sessions[id]; ok {
+		s.Verdict = verdict
+	}
+}
+
+// Sessions lists the sessions open now, oldest first.
+func Sessions() []Live {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	list := make([]Live, 0, len(hub.sessions))
+	for _, s := range hub.sessions {
+		list = append(list, s.Live)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Started.Before(list[j].Started) })
+	return list
+}
+
+func subscribe(f Filter) *watcher {
+	w := &watcher{filter: f, ch: make(chan Message, watchQueue)}
+	hub.mu.Lock()
+	hub.watchers[w] = true
+	hub.mu.Unlock()
+	return w
+}
+
+func unsubscribe(w *watcher) {
+	hub.mu.Lock()
+	delete(hub.watchers, w)
+	hub.mu.Unlock()
+}
+
+// broadcast hands a message to every watcher it passes the filter of,
+// never waiting on one. Callers hold hub.mu.
+func broadcast(m Message) {
+	for w := range hub.watchers {
+		if !w.filter.match(m) {
+			continue
+		}
+		sent := m
+		sent.Missed = w.missed
+		select {
+		case w.ch <- sent:
+			w.missed = 0
+		default:
+			w.missed++
+		}
+	}
+}
+
+// Sink is an event sink that tells watchers what live sessions do.
+type Sink struct{}
+
+func (Sink) Name() string { return "control" }
+
+func (Sink) Write(batch []events.Event) error {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	for _, e := range batch {
+		m := Message{Time: e.Time, SessionID: e.SessionID, RemoteAddr: e.RemoteAddr, Verdict: "clean"}
+		s := hub.sessions[e.SessionID]
+		if s != nil {
+			m.Verdict = s.Verdict
+		}
+		switch e.Kind {
+		case events.KindSessionStart:
+			var start events.SessionStart
+			decode(e, &start)
+			m.Type, m.User, m.Client = TypeOpen, start.User, start.Client
+		case events.KindCommand:
+			var c struct {
+				Command        string `json:"command"`
+				CommandIndex   int    `json:"command_index"`
+				Category       string `json:"category"`
+				SuspicionScore int    `json:"suspicion_score"`
+			}
+			// Bracketed entries mark what the honeypot did, not commands.
+			if !decode(e, &c) || strings.HasPrefix(c.Command, "[") {
+				continue
+			}
+			m.Type, m.Command, m.Category, m.Score = TypeCommand, c.Command, c.Category, c.SuspicionScore
+			if s != nil {
+				s.Commands, s.Score = c.CommandIndex, c.SuspicionScore
+			}
+		case events.KindDetection:
+			var d struct {
+				Signal         string `json:"signal"`
+				Confidence     string `json:"confidence"`
+				Details        string `json:"details"`
+				TriggerCommand string `json:"trigger_command"`
+				ResponseTaken  string `json:"response_taken"`
+			}
+			decode(e, &d)
+			m.Type, m.Signal, m.Confidence, m.Details = TypeDetection, d.Signal, d.Confidence, d.Details
+			m.Command, m.Response = d.TriggerCommand, d.ResponseTaken
+		case events.KindSessionEnd:
+			var r struct {
+				Verdict             string `json:"verdict"`
+				TotalCommands       int    `json:"total_commands"`
+				FinalSuspicionScore int    `json:"final_suspicion_score"`
+			}
+			decode(e, &r)
+			m.Type, m.Commands, m.Score = TypeClose, r.TotalCommands, r.FinalSuspicionScore
+			if r.Verdict != "" {
+				m.Verdict = r.Verdict
+			}
+			delete(hub.sessions, e.SessionID)
+		default:
+			continue
+		}
+		broadcast(m)
+	}
+	return nil
+}
+
+func (Sink) Close() error { return nil }
+
+func decode(e events.Event, v any) bool {
+	data, err := json.Marshal(e.Data)
+	return err == nil && json.Unmarshal(data, v) == nil
+}
+
+func hostOf(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
@@ -0,0 +1,128 @@
+package control
+
+import "io"
+
+// maxCSI is the longest control sequence passed on; a longer one is
+// dropped whole.
+const maxCSI = 64
+
+// scrubber passes a session's output on to the operator's terminal
+// without the sequences an attacker could use against it: OSC, DCS, APC,
+// PM and SOS strings, which set titles and clipboards, load fonts and
+// talk to terminal extensions, and the queries that make a terminal
+// type a report back into whatever reads its keyboard. Sequences split
+// across writes are followed from one write to the next.
+type scrubber struct {
+	w     io.Writer
+	state int
+	seq   []byte
+	out   []byte
+}
+
+const (
+	ground = iota
+	escape
+	csi
+	str
+	strEscape
+)
+
+func (s *scrubber) Write(p []byte) (int, error) {
+	s.out = s.out[:0]
+	for _, b := range p {
+		s.feed(b)
+	}
+	if len(s.out) == 0 {
+		return len(p), nil
+	}
+	if _, err := s.w.Write(s.out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (s *scrubber) feed(b byte) {
+	switch s.state {
+	case ground:
+		if b == 0x1b {
+			s.state = escape
+			return
+		}
+		s.out = append(s.out, b)
+	case escape:
+		switch b {
+		case ']', 'P', '_', '^', 'X':
+			s.state = str
+		case '[':
+			s.state, s.seq = csi, append(s.seq[:0], 0x1b, '[')
+		case 'Z':
+			// DECID asks the terminal to identify itself.
+			s.state = ground
+		case 0x1b:
+		default:
+			s.out = append(s.out, 0x1b, b)
+			s.state = ground
+		}
+	case csi:
+		switch {
+		case b == 0x1b:
+			s.state = escape
+		case b == 0x18 || b == 0x1a:
+			// CAN and SUB cancel the sequence.
+			s.state = ground
+		case b < 0x20:
+			// Terminals act on C0 controls met inside a sequence.
+			s.out = append(s.out, b)
+		case b >= 0x40 && b <= 0x7e:
+			s.seq = append(s.seq, b)
+			if len(s.seq) <= maxCSI && !query(s.seq) {
+				s.out = append(s.out, s.seq...)
+			}
+			s.state = ground
+		case len(s.seq) <= maxCSI:
+			s.seq = append(s.seq, b)
+		}
+	case str:
+		switch b {
+		case 0x07, 0x18, 0x1a:
+			s.state = ground
+		case 0x1b:
+			s.state = strEscape
+		}
+	case strEscape:
+		if b == '\\' {
+			s.state = ground
+			return
+		}
+		// An ESC inside a string ends it and starts a sequence of its own.
+		s.state = escape
+		s.feed(b)
+	}
+}
+
+// query reports whether a complete CSI sequence asks the terminal for a
+// report: device status and attributes, window reports, mode and
+// parameter requests and version queries.
+func query(seq []byte) bool {
+	params := seq[2 : len(seq)-1]
+	final := seq[len(seq)-1]
+	private := len(params) > 0 && (params[0] == '?' || params[0] == '>' || params[0] == '=')
+	last := byte(0)
+	if len(params) > 0 {
+		last = params[len(params)-1]
+	}
+	switch final {
+	case 'n', 'c', 't', 'x':
+		return true
+	case 'p', 'w':
+		// DECRQM and DECRQPSR, after a $.
+		return last == '$'
+	case 'q':
+		// XTVERSION.
+		return len(params) > 0 && params[0] == '>'
+	case 'u':
+		// The kitty keyboard protocol's flags query.
+		return private
+	}
+	return false
+}
@@ -0,0 +1,279 @@
+package control
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// writeTimeout is how long a client that stopped reading is waited for.
+const writeTimeout = 30 * time.Second
+
+// request is the one line a client opens with: watch with a filter,
+// sessions, or attach to a session by ID or unique prefix.
+type request struct {
+	Op      string `json:"op"`
+	Filter  Filter `json:"filter,omitempty"`
+	Session string `json:"session,omitempty"`
+}
+
+// reply is the first line of an answer to sessions and attach.
+type reply struct {
+	Error    string `json:"error,omitempty"`
+	Sessions []Live `json:"sessions,omitempty"`
+	Session  *Live  `json:"session,omitempty"`
+}
+
+// Serve listens on the Unix socket at path until it fails. The socket
+// is only open to the user the sensor runs as; a stale one left by a
+// sensor that died is replaced, a live one is not.
+func Serve(path string) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		conn.Close()
+		return fmt.Errorf("%s: another sensor is listening", path)
+	}
+	ln, err := listen(dir, path)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(path)
+	defer ln.Close()
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		go serve(conn)
+	}
+}
+
+// listen binds the socket in a directory only the sensor's user can
+// enter, makes it 0600 and only then moves it to path, so it is never
+// reachable with the mode the umask would give it.
+func listen(dir, path string) (*net.UnixListener, error) {
+	private, err := os.MkdirTemp(dir, ".control-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(private)
+	bound := filepath.Join(private, "sock")
+	ln, err := net.ListenUnix("unix", &net.UnixAddr{Name: bound, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+	// Once moved, the socket is removed by path, not by the name it was
+	// bound at.
+	ln.SetUnlinkOnClose(false)
+	if err := os.Chmod(bound, 0600); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	if err := os.Rename(bound, path); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
+
+func serve(conn net.Conn) {
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	r := bufio.NewReader(conn)
+	line, err := r.ReadBytes('\n')
+	if err != nil {
+		return
+	}
+	var req request
+	out := &deadlineWriter{conn}
+	enc := json.NewEncoder(out)
+	if err := json.Unmarshal(line, &req); err != nil {
+		enc.Encode(reply{Error: "bad request: " + err.Error()})
+		return
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	// Nothing more is read from the client; its hanging up is what ends
+	// a watch or a view.
+	gone := make(chan struct{})
+	go func() {
+		io.Copy(io.Discard, r)
+		close(gone)
+	}()
+
+	switch req.Op {
+	case "sessions":
+		enc.Encode(reply{Sessions: Sessions()})
+	case "watch":
+		w := subscribe(req.Filter)
+		defer unsubscribe(w)
+		for {
+			select {
+			case m := <-w.ch:
+				if enc.Encode(m) != nil {
+					return
+				}
+			case <-gone:
+				return
+			}
+		}
+	case "attach":
+		id, err := find(req.Session)
+		if err != nil {
+			enc.Encode(reply{Error: err.Error()})
+			return
+		}
+		t, live, ok := terminal(id)
+		var replay []byte
+		var ch chan []byte
+		if ok {
+			replay, ch, ok = t.view()
+		}
+		if !ok {
+			enc.Encode(reply{Error: fmt.Sprintf("session %s has no terminal to attach to", id)})
+			return
+		}
+		defer t.leave(ch)
+		if enc.Encode(reply{Session: &live}) != nil {
+			return
+		}
+		if _, err := out.Write(replay); err != nil {
+			return
+		}
+		for {
+			select {
+			case p, open := <-ch:
+				if !open {
+					return
+				}
+				if _, err := out.Write(p); err != nil {
+					return
+				}
+			case <-gone:
+				return
+			}
+		}
+	default:
+		enc.Encode(reply{Error: fmt.Sprintf("unknown op %q", req.Op)})
+	}
+}
+
+type deadlineWriter struct{ conn net.Conn }
+
+func (d *deadlineWriter) Write(p []byte) (int, error) {
+	d.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	return d.conn.Write(p)
+}
+
+// find resolves a session ID or a prefix of exactly one live session's.
+func find(prefix string) (string, error) {
+	if prefix == "" {
+		return "", errors.New("no session given")
+	}
+	var ids []string
+	for _, l := range Sessions() {
+		if l.ID == prefix {
+			return l.ID, nil
+		}
+		if strings.HasPrefix(l.ID, prefix) {
+			ids = append(ids, l.ID)
+		}
+	}
+	switch len(ids) {
+	case 0:
+		return "", fmt.Errorf("no live session %s", prefix)
+	case 1:
+		return ids[0], nil
+	}
+	return "", fmt.Errorf("%s matches %d live sessions", prefix, len(ids))
+}
+
+// dial opens the socket and sends the request.
+func dial(path string, req request) (net.Conn, *bufio.Reader, error) {
+	conn, err := net.DialTimeout("unix", path, 2*time.Second)
+	if err != nil {
+		return nil, nil, fmt.Errorf("no sensor listening on %s: %w", path, err)
+	}
+	data, _ := json.Marshal(req)
+	if _, err := conn.Write(append(data, '\n')); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	return conn, bufio.NewReader(conn), nil
+}
+
+func readReply(r *bufio.Reader) (reply, error) {
+	var rep reply
+	line, err := r.ReadBytes('\n')
+	if err != nil {
+		return rep, fmt.Errorf("sensor hung up: %w", err)
+	}
+	if err := json.Unmarshal(line, &rep); err != nil {
+		return rep, err
+	}
+	if rep.Error != "" {
+		return rep, errors.New(rep.Error)
+	}
+	return rep, nil
+}
+
+// List asks the sensor on path for its live sessions.
+func List(path string) ([]Live, error) {
+	conn, r, err := dial(path, request{Op: "sessions"})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	rep, err := readReply(r)
+	return rep.Sessions, err
+}
+
+// Watch calls fn with every message the filter lets through, until the
+// sensor on path goes away.
+func Watch(path string, f Filter, fn func(Message)) error {
+	conn, r, err := dial(path, request{Op: "watch", Filter: f})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	dec := json.NewDecoder(r)
+	for {
+		var m Message
+		if err := dec.Decode(&m); err != nil {
+			if errors.Is(err, io.EOF) {
+				return errors.New("sensor closed the stream")
+			}
+			return err
+		}
+		fn(m)
+	}
+}
+
+// Attach copies a live session's terminal to w, starting with what it
+// showed lately, until the session ends. attached is called with the
+// session before the first byte. What reaches w is scrubbed of the
+// escape sequences that could reach beyond the operator's screen.
+func Attach(path, id string, attached func(Live), w io.Writer) error {
+	conn, r, err := dial(path, request{Op: "attach", Session: id})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	rep, err := readReply(r)
+	if err != nil {
+		return err
+	}
+	attached(*rep.Session)
+	_, err = io.Copy(&scrubber{w: w}, r)
+	return err
+}
@@ -0,0 +1,113 @@
+package control
+
+import (
+	"bytes"
+	"sync"
+)
+
+// Terminal passes a session's terminal output on to whoever attaches to
+// it. It never holds the session up: a viewer that falls behind is let
+// go.
+type Terminal struct {
+	id string
+
+	mu      sync.Mutex
+	recent  []byte
+	viewers map[chan []byte]bool
+	closed  bool
+}
+
+// NewTerminal makes a session attachable; call Close when its shell ends.
+func NewTerminal(id string, cols, rows uint32) *Terminal {
+	t := &Terminal{id: id, viewers: map[chan []byte]bool{}}
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	if s, ok := hub.sessions[id]; ok {
+		s.term, s.Terminal = t, true
+		s.Cols, s.Rows = cols, rows
+	}
+	return t
+}
+
+func (t *Terminal) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.recent = append(t.recent, p...)
+	if over := len(t.recent) - replaySize; over > 0 {
+		t.recent = append(t.recent[:0], t.recent[over:]...)
+	}
+	for ch := range t.viewers {
+		select {
+		case ch <- bytes.Clone(p):
+		default:
+			delete(t.viewers, ch)
+			close(ch)
+		}
+	}
+	return len(p), nil
+}
+
+// Resize records the session's new window size for viewers to come.
+func (t *Terminal) Resize(cols, rows uint32) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	if s, ok := hub.sessions[t.id]; ok {
+		s.Cols, s.Rows = cols, rows
+	}
+}
+
+// Close ends every view of the terminal.
+func (t *Terminal) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.closed = true
+	for ch := range t.viewers {
+		close(ch)
+	}
+	t.viewers = nil
+	return nil
+}
+
+func (t *Terminal) ended() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.closed
+}
+
+// view starts a viewer: what the terminal showed lately, from the first
+// full line, then everything it shows from now on.
+func (t *Terminal) view() ([]byte, chan []byte, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return nil, nil, false
+	}
+	replay := t.recent
+	if len(replay) == replaySize {
+		if i := bytes.IndexByte(replay, '\n'); i >= 0 {
+			replay = replay[i+1:]
+		}
+	}
+	ch := make(chan []byte, screenQueue)
+	t.viewers[ch] = true
+	return bytes.Clone(replay), ch, true
+}
+
+func (t *Terminal) leave(ch chan []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.viewers[ch] {
+		delete(t.viewers, ch)
+		close(ch)
+	}
+}
+
+func terminal(id string) (*Terminal, Live, bool) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+	s, ok := hub.sessions[id]
+	if !ok || s.term == nil {
+		return nil, Live{}, false
+	}
+	return s.term, s.Live, true
+}
@@ -0,0 +1,149 @@
+package deception
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+)
+
+// Dir holds the views, a directory of its own for each session. It must
+// be the sensor's alone: it is made 0700, and refused if it is anyone
+// else's or open to them, as what is in it is mounted into containers.
+var Dir = filepath.Join("run", "proc")
+
+// viewed are the /proc files a ProcView stands in for. Only files whose
+// content does not tick are safe: a frozen /proc/uptime would give the
+// game away.
+var viewed = []string{"cpuinfo", "meminfo"}
+
+// ProcView is a set of host files bind-mounted over /proc entries of one
+// container, the way LXCFS does it. They start as copies of the host's own
+// and are rewritten in place, so the container sees a new view without
+// needing any privilege inside it.
+type ProcView struct {
+	dir string
+}
+
+var (
+	viewsMu sync.Mutex
+	views   = map[string]*ProcView{}
+)
+
+// NewProcView prepares the views for a session's container, to be mounted
+// with MountArgs when it is started.
+func NewProcView(sessionID string) (*ProcView, error) {
+	base, err := privateDir()
+	if err != nil {
+		return nil, err
+	}
+	// A new directory every time, never one that was there already.
+	dir, err := os.MkdirTemp(base, sessionID+"-")
+	if err != nil {
+		return nil, err
+	}
+	v := &ProcView{dir: dir}
+	for _, name := range viewed {
+		if err := v.Restore(name); err != nil {
+			os.RemoveAll(dir)
+			return nil, err
+		}
+	}
+
+	viewsMu.Lock()
+	views[sessionID] = v
+	viewsMu.Unlock()
+	return v, nil
+}
+
+// privateDir makes Dir if need be and checks it is the sensor's alone.
+// Docker wants bind mounts by absolute path.
+func privateDir() (string, error) {
+	dir, err := filepath.Abs(Dir)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return "", err
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	switch {
+	case !info.IsDir():
+		return "", fmt.Errorf("%s is not a directory", dir)
+	case ok && int(st.Uid) != os.Getuid():
+		return "", fmt.Errorf("%s belongs to another user", dir)
+	case info.Mode().Perm()&0077 != 0:
+		return "", fmt.Errorf("%s is open to other users (%v); make it 0700", dir, info.Mode().Perm())
+	}
+	return dir, nil
+}
+
+// ViewFor returns the views of a session's container, or nil when it was
+// started without them.
+func ViewFor(sessionID string) *ProcView {
+	viewsMu.Lock()
+	defer viewsMu.Unlock()
+	return views[sessionID]
+}
+
+// Remove forgets the session's views and deletes their files; call it
+// once the container is gone.
+func Remove(sessionID string) {
+	viewsMu.Lock()
+	v := views[sessionID]
+	delete(views, sessionID)
+	viewsMu.Unlock()
+	if v != nil {
+		os.RemoveAll(v.dir)
+	}
+}
+
+// MountArgs are the docker run flags that put the views in place.
+func (v *ProcView) MountArgs() []string {
+	var args []string
+	for _, name := range viewed {
+		args = append(args, "-v", fmt.Sprintf("%s:/proc/%s:ro", filepath.Join(v.dir, name), name))
+	}
+	return args
+}
+
+// Write replaces what the container reads from /proc/<name>.
+func (v *ProcView) Write(name, content string) error {
+	if !isViewed(name) {
+		return fmt.Errorf("/proc/%s has no view", name)
+	}
+	// Truncating keeps the inode, which is what the bind mount points at;
+	// writing a new file and renaming it over would not show through.
+	f, err := os.OpenFile(filepath.Join(v.dir, name), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(content); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Restore puts the host's own /proc/<name> back.
+func (v *ProcView) Restore(name string) error {
+	data, err := os.ReadFile(filepath.Join("/proc", name))
+	if err != nil {
+		return err
+	}
+	return v.Write(name, string(data))
+}
+
+func isViewed(name string) bool {
+	for _, n := range viewed {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
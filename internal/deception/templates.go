@@ -0,0 +1,95 @@
+package deception
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CPUInfo renders a /proc/cpuinfo for a physical machine: one entry per
+// logical CPU, two threads per core, and no hypervisor flag.
+func CPUInfo(model string, cores int) string {
+	if cores < 1 {
+		cores = 1
+	}
+	var b strings.Builder
+	for i := 0; i < cores*2; i++ {
+		fmt.Fprintf(&b, "processor\t: %d\n", i)
+		b.WriteString("vendor_id\t: GenuineIntel\n")
+		b.WriteString("cpu family\t: 6\n")
+		b.WriteString("model\t\t: 158\n")
+		fmt.Fprintf(&b, "model name\t: %s\n", model)
+		b.WriteString("stepping\t: 10\n")
+		b.WriteString("microcode\t: 0xf4\n")
+		b.WriteString("cpu MHz\t\t: 2600.000\n")
+		b.WriteString("cache size\t: 12288 KB\n")
+		b.WriteString("physical id\t: 0\n")
+		fmt.Fprintf(&b, "siblings\t: %d\n", cores*2)
+		fmt.Fprintf(&b, "core id\t\t: %d\n", i%cores)
+		fmt.Fprintf(&b, "cpu cores\t: %d\n", cores)
+		fmt.Fprintf(&b, "apicid\t\t: %d\n", i)
+		b.WriteString("fpu\t\t: yes\n")
+		b.WriteString("fpu_exception\t: yes\n")
+		b.WriteString("cpuid level\t: 22\n")
+		b.WriteString("wp\t\t: yes\n")
+		b.WriteString("flags\t\t: fpu vme de pse tsc msr pae mce cx8 apic sep mtrr pge mca cmov pat pse36 " +
+			"clflush dts acpi mmx fxsr sse sse2 ss ht tm pbe syscall nx pdpe1gb rdtscp lm constant_tsc " +
+			"art arch_perfmon pebs bts rep_good nopl xtopology nonstop_tsc cpuid aperfmperf pni pclmulqdq " +
+			"dtes64 monitor ds_cpl vmx est tm2 ssse3 sdbg fma cx16 xtpr pdcm pcid sse4_1 sse4_2 x2apic " +
+			"movbe popcnt tsc_deadline_timer aes xsave avx f16c rdrand lahf_lm abm 3dnowprefetch " +
+			"cpuid_fault epb invpcid_single pti ssbd ibrs ibpb stibp tpr_shadow vnmi flexpriority ept " +
+			"vpid fsgsbase tsc_adjust bmi1 avx2 smep bmi2 erms invpcid mpx rdseed adx smap clflushopt " +
+			"intel_pt xsaveopt xsavec xgetbv1 xsaves dtherm ida arat pln pts hwp hwp_notify hwp_act_window " +
+			"hwp_epp md_clear flush_l1d\n")
+		b.WriteString("bogomips\t: 5199.98\n")
+		b.WriteString("clflush size\t: 64\n")
+		b.WriteString("cache_alignment\t: 64\n")
+		b.WriteString("address sizes\t: 39 bits physical, 48 bits virtual\n")
+		b.WriteString("power management:\n\n")
+	}
+	return b.String()
+}
+
+// MemInfo renders a /proc/meminfo for a machine with totalMB of memory,
+// a third of it in use.
+func MemInfo(totalMB int) string {
+	total := totalMB * 1024
+	free := total / 3
+	cached := total / 4
+	available := free + cached
+	lines := []struct {
+		name string
+		kb   int
+	}{
+		{"MemTotal", total},
+		{"MemFree", free},
+		{"MemAvailable", available},
+		{"Buffers", total / 40},
+		{"Cached", cached},
+		{"SwapCached", 0},
+		{"Active", total / 3},
+		{"Inactive", total / 4},
+		{"SwapTotal", 2097148},
+		{"SwapFree", 2097148},
+		{"Dirty", 212},
+		{"Writeback", 0},
+		{"AnonPages", total / 5},
+		{"Mapped", total / 20},
+		{"Shmem", total / 60},
+		{"Slab", total / 30},
+		{"PageTables", total / 200},
+		{"CommitLimit", total/2 + 2097148},
+		{"Committed_AS", total / 2},
+		{"VmallocTotal", 34359738367},
+		{"HugePages_Total", 0},
+		{"Hugepagesize", 2048},
+	}
+	var b strings.Builder
+	for _, l := range lines {
+		if strings.HasPrefix(l.name, "HugePages_") {
+			fmt.Fprintf(&b, "%-15s %8d\n", l.name+":", l.kb)
+			continue
+		}
+		fmt.Fprintf(&b, "%-15s %8d kB\n", l.name+":", l.kb)
+	}
+	return b.String()
+}
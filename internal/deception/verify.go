@@ -0,0 +1,76 @@
+package deception
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Check is one of the ways attackers tell a container from a real host:
+// a command to run inside it and what in its output gives it away.
+type Check struct {
+	Name    string
+	Command string
+	Reveals func(out string) bool
+}
+
+var (
+	containerCgroup = regexp.MustCompile(`(?i)docker|containerd|kubepods|lxc|libpod`)
+	containerID     = regexp.MustCompile(`^[0-9a-f]{12}$`)
+	overlayRoot     = regexp.MustCompile(`(?m)^\S+ \S+ \S+ \S+ / .* - overlay `)
+)
+
+// Checks are the fingerprinting checks a response is verified against,
+// the same ones the fingerprint rules see scanners run.
+var Checks = []Check{
+	{"dockerenv", "test -e /.dockerenv && echo present", func(out string) bool {
+		return strings.Contains(out, "present")
+	}},
+	{"cgroup", "cat /proc/1/cgroup", containerCgroup.MatchString},
+	{"pid1", "cat /proc/1/comm", func(out string) bool {
+		return out != "systemd" && out != "init"
+	}},
+	{"detect-virt", "systemd-detect-virt -c 2>/dev/null", func(out string) bool {
+		return out != "" && out != "none"
+	}},
+	{"cpu-hypervisor", "grep -cw hypervisor /proc/cpuinfo", func(out string) bool {
+		return out != "" && out != "0"
+	}},
+	{"hostname", "hostname", containerID.MatchString},
+	{"overlay-root", "cat /proc/self/mountinfo", overlayRoot.MatchString},
+}
+
+type CheckResult struct {
+	Check    string `json:"check"`
+	Revealed bool   `json:"revealed"`
+	Output   string `json:"output,omitempty"`
+}
+
+// Verification is what the fingerprinting checks saw after a response.
+// Revealed lists the checks that still give the container away.
+type Verification struct {
+	Revealed []string      `json:"revealed"`
+	Checks   []CheckResult `json:"checks"`
+}
+
+// Verify runs every check through run, which executes a script in the
+// container, and reports which of them still give it away.
+func Verify(run func(script string) (string, error)) Verification {
+	v := Verification{Revealed: []string{}}
+	for _, c := range Checks {
+		out, _ := run(c.Command)
+		out = strings.TrimSpace(out)
+		res := CheckResult{Check: c.Name, Revealed: c.Reveals(out), Output: firstLine(out)}
+		if res.Revealed {
+			v.Revealed = append(v.Revealed, c.Name)
+		}
+		v.Checks = append(v.Checks, res)
+	}
+	return v
+}
+
+func firstLine(s string) string {
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		return s[:i] + " …"
+	}
+	return s
+}
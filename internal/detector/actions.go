@@ -0,0 +1,277 @@
+package detector
+
+import (
+	"GradGuard/internal/deception"
+	"GradGuard/internal/tarpit"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Action is a named deception step for a session's container. Script
+// builds a shell script run inside it from the step's parameters, laid
+// over Defaults; scripts must leave the container the same if run twice.
+// Undo, when set, takes the step back out.
+//
+// Some responses cannot be done from inside an unprivileged container.
+// Such actions set Host instead, which works from the honeypot host on
+// the session's Target, such as its procfs views or its SSH channel, with
+// HostUndo to take it back and Doc to say what a dry run would have done.
+type Action struct {
+	Name     string
+	Defaults map[string]string
+	Script   func(p map[string]string) string
+	Undo     func(p map[string]string) string
+	Host     func(t Target, p map[string]string) error
+	HostUndo func(t Target, p map[string]string) error
+	Doc      func(p map[string]string) string
+}
+
+// Target is what a session's responses work on. View is nil when the
+// container was started without procfs views, Tarpit when there is no
+// live channel, as in a dry run.
+type Target struct {
+	Container string
+	View      *deception.ProcView
+	Tarpit    *tarpit.Tarpit
+}
+
+var errNoViews = errors.New("container was started without procfs views")
+
+var (
+	actionsMu sync.RWMutex
+	actions   = map[string]Action{}
+)
+
+// RegisterAction makes an action available to playbooks. It panics on a
+// duplicate name, like Register.
+func RegisterAction(a Action) {
+	actionsMu.Lock()
+	defer actionsMu.Unlock()
+	if _, dup := actions[a.Name]; dup {
+		panic(fmt.Sprintf("detector: action %s registered twice", a.Name))
+	}
+	actions[a.Name] = a
+}
+
+func lookupAction(name string) (Action, bool) {
+	actionsMu.RLock()
+	defer actionsMu.RUnlock()
+	a, ok := actions[name]
+	return a, ok
+}
+
+// params lays a step's parameters over the action's defaults.
+func (a Action) params(given map[string]string) map[string]string {
+	p := map[string]string{}
+	for k, v := range a.Defaults {
+		p[k] = v
+	}
+	for k, v := range given {
+		p[k] = v
+	}
+	return p
+}
+
+// actionKey names one action with one set of parameters, which is what a
+// session applies at most once.
+func actionKey(name string, params map[string]string) string {
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	b.WriteString(name)
+	for _, k := range keys {
+		fmt.Fprintf(&b, " %s=%s", k, params[k])
+	}
+	return b.String()
+}
+
+// shellQuote quotes s as one single-quoted shell word.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// list splits a comma-separated parameter, dropping empty entries.
+func list(s string) []string {
+	var out []string
+	for _, f := range strings.Split(s, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// appendOnce adds each line to file unless the file already has it.
+func appendOnce(file string, lines []string) string {
+	var cmds []string
+	for _, l := range lines {
+		cmds = append(cmds, fmt.Sprintf("{ grep -qxF %s %s || echo %s >> %s; }",
+			shellQuote(l), file, shellQuote(l), file))
+	}
+	return strings.Join(cmds, " && ")
+}
+
+// removeLines takes lines back out of file, writing in place so files
+// Docker bind-mounts, like /etc/hosts, keep working.
+func removeLines(file string, lines []string) string {
+	var patterns []string
+	for _, l := range lines {
+		patterns = append(patterns, "-e "+shellQuote(l))
+	}
+	return fmt.Sprintf("grep -vxF %s %s > /tmp/.gg-undo; cat /tmp/.gg-undo > %s; rm -f /tmp/.gg-undo",
+		strings.Join(patterns, " "), file, file)
+}
+
+const slowShellMarker = "# gradguard-slow"
+
+func init() {
+	// mask-cgroup and fake-dockerenv were the original warning response.
+	// Neither works: /proc/1/cgroup is read-only in an unprivileged
+	// container, and a /.dockerenv with anything in it is still there.
+	// They stay for playbooks that name them.
+	RegisterAction(Action{
+		Name:     "mask-cgroup",
+		Defaults: map[string]string{"cgroup": "0::/init.scope"},
+		Script: func(p map[string]string) string {
+			return "mkdir -p /sys/fs/cgroup && echo " + shellQuote(p["cgroup"]) + " > /proc/1/cgroup"
+		},
+	})
+	RegisterAction(Action{
+		Name:     "fake-dockerenv",
+		Defaults: map[string]string{"platform": "baremetal"},
+		Script: func(p map[string]string) string {
+			return "printf '# system environment\\nPLATFORM=%s\\n' " + shellQuote(p["platform"]) + " > /.dockerenv"
+		},
+		Undo: func(map[string]string) string { return ": > /.dockerenv" },
+	})
+	RegisterAction(Action{
+		Name: "remove-dockerenv",
+		Script: func(map[string]string) string {
+			return "rm -f /.dockerenv"
+		},
+		Undo: func(map[string]string) string { return "touch /.dockerenv" },
+	})
+	RegisterAction(Action{
+		Name: "fake-cpuinfo",
+		Defaults: map[string]string{
+			"model": "Intel(R) Core(TM) i7-9750H CPU @ 2.60GHz",
+			"cores": "6",
+		},
+		Host: func(t Target, p map[string]string) error {
+			cores, err := strconv.Atoi(p["cores"])
+			if err != nil {
+				return fmt.Errorf("cores: %w", err)
+			}
+			if t.View == nil {
+				return errNoViews
+			}
+			return t.View.Write("cpuinfo", deception.CPUInfo(p["model"], cores))
+		},
+		HostUndo: func(t Target, _ map[string]string) error { return t.View.Restore("cpuinfo") },
+		Doc: func(p map[string]string) string {
+			return fmt.Sprintf("host: /proc/cpuinfo view → %s cores of %s, no hypervisor flag", p["cores"], p["model"])
+		},
+	})
+	RegisterAction(Action{
+		Name:     "fake-meminfo",
+		Defaults: map[string]string{"total_mb": "16384"},
+		Host: func(t Target, p map[string]string) error {
+			mb, err := strconv.Atoi(p["total_mb"])
+			if err != nil {
+				return fmt.Errorf("total_mb: %w", err)
+			}
+			if t.View == nil {
+				return errNoViews
+			}
+			return t.View.Write("meminfo", deception.MemInfo(mb))
+		},
+		HostUndo: func(t Target, _ map[string]string) error { return t.View.Restore("meminfo") },
+		Doc: func(p map[string]string) string {
+			return fmt.Sprintf("host: /proc/meminfo view → %s MB total", p["total_mb"])
+		},
+	})
+	RegisterAction(Action{
+		Name: "fake-users",
+		Defaults: map[string]string{
+			"users": "deploy:1001:Deploy User,monitor:1002:Monitor User",
+		},
+		Script: func(p map[string]string) string {
+			return appendOnce("/etc/passwd", passwdLines(p["users"]))
+		},
+		Undo: func(p map[string]string) string {
+			return removeLines("/etc/passwd", passwdLines(p["users"]))
+		},
+	})
+	RegisterAction(Action{
+		Name: "fake-hosts",
+		Defaults: map[string]string{
+			"hosts": "10.0.0.1 gateway.internal,10.0.0.2 db.internal,10.0.0.3 cache.internal",
+		},
+		Script: func(p map[string]string) string {
+			return appendOnce("/etc/hosts", hostsLines(p["hosts"]))
+		},
+		Undo: func(p map[string]string) string {
+			return removeLines("/etc/hosts", hostsLines(p["hosts"]))
+		},
+	})
+	RegisterAction(Action{
+		Name:     "tarpit",
+		Defaults: map[string]string{"tier": "slow"},
+		Host: func(t Target, p map[string]string) error {
+			if t.Tarpit == nil {
+				return errors.New("session has no tarpit")
+			}
+			return t.Tarpit.Escalate(p["tier"])
+		},
+		Doc: func(p map[string]string) string {
+			return "host: slow the SSH channel to tarpit tier " + p["tier"]
+		},
+	})
+	// slow-shell only slows shells started after it; tarpit slows the
+	// live session.
+	RegisterAction(Action{
+		Name:     "slow-shell",
+		Defaults: map[string]string{"max_seconds": "3"},
+		Script: func(p map[string]string) string {
+			line := fmt.Sprintf("sleep $((RANDOM %% %s + 1)) %s", p["max_seconds"], slowShellMarker)
+			return fmt.Sprintf("sed -i '/%s$/d' /root/.bashrc && echo %s >> /root/.bashrc",
+				slowShellMarker, shellQuote(line))
+		},
+		Undo: func(map[string]string) string {
+			return fmt.Sprintf("sed -i '/%s$/d' /root/.bashrc", slowShellMarker)
+		},
+	})
+}
+
+// passwdLines turns "name:uid:gecos,..." into /etc/passwd entries.
+func passwdLines(users string) []string {
+	var lines []string
+	for _, u := range list(users) {
+		f := strings.SplitN(u, ":", 3)
+		for len(f) < 3 {
+			f = append(f, "")
+		}
+		lines = append(lines, fmt.Sprintf("%s:x:%s:%s:%s,,,:/home/%s:/bin/bash", f[0], f[1], f[1], f[2], f[0]))
+	}
+	return lines
+}
+
+// hostsLines turns "ip name,..." into /etc/hosts entries.
+func hostsLines(hosts string) []string {
+	var lines []string
+	for _, h := range list(hosts) {
+		f := strings.Fields(h)
+		if len(f) < 2 {
+			continue
+		}
+		lines = append(lines, f[0]+"    "+strings.Join(f[1:], " "))
+	}
+	return lines
+}
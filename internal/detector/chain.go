@@ -0,0 +1,348 @@
+package detector
+
+import (
+	sshsession "GradGuard/internal/Session"
+	"GradGuard/internal/attack"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const SignalKillChain SignalType = "kill_chain"
+
+const (
+	// maxPartialChains bounds how many half-finished instances of one
+	// pattern a session keeps, oldest dropped first.
+	maxPartialChains = 8
+	// maxBound bounds how many step expressions compiled with captured
+	// values are kept; the cache starts over when it is full.
+	maxBound = 1024
+)
+
+//go:embed chains/*.json
+var builtinChains embed.FS
+
+// ChainPattern is a declarative multi-step attack pattern. Steps must be
+// seen in order, with at most MaxGap unrelated commands between two steps
+// and all of them within Window of the first.
+type ChainPattern struct {
+	ID         string       `json:"id"`
+	Name       string       `json:"name"`
+	Confidence Confidence   `json:"confidence"`
+	Response   Confidence   `json:"response,omitempty"`
+	MaxGap     int          `json:"max_gap"`
+	WindowSecs int          `json:"window_seconds"`
+	Techniques []attack.Tag `json:"techniques"`
+	Steps      []ChainStep  `json:"steps"`
+}
+
+// ChainStep matches a command when any of Rules, Categories, Techniques
+// or Regex matches it, and Where, if set, matches too. Capture pulls named
+// values out of the command that later steps use as ${name} in Regex and
+// Where; its expressions are tried in order and the first to match wins.
+type ChainStep struct {
+	Name       string   `json:"name"`
+	Rules      []string `json:"rules,omitempty"`
+	Categories []string `json:"categories,omitempty"`
+	Techniques []string `json:"techniques,omitempty"`
+	Regex      string   `json:"regex,omitempty"`
+	Where      string   `json:"where,omitempty"`
+	Capture    []string `json:"capture,omitempty"`
+
+	// regex and where are Regex and Where compiled, when they use no
+	// captured values.
+	regex   *regexp.Regexp
+	where   *regexp.Regexp
+	capture []*regexp.Regexp
+}
+
+type chainFile struct {
+	Version  int            `json:"version"`
+	Patterns []ChainPattern `json:"patterns"`
+}
+
+var chainPatterns []ChainPattern
+
+func init() {
+	patterns, err := loadBuiltinChains()
+	if err != nil {
+		panic(fmt.Sprintf("detector: builtin chains: %v", err))
+	}
+	chainPatterns = patterns
+	Register(SignalKillChain, func(Tuning) Signal { return &KillChainSignal{patterns: chainPatterns} })
+}
+
+func loadBuiltinChains() ([]ChainPattern, error) {
+	entries, err := builtinChains.ReadDir("chains")
+	if err != nil {
+		return nil, err
+	}
+	var patterns []ChainPattern
+	for _, e := range entries {
+		data, err := builtinChains.ReadFile("chains/" + e.Name())
+		if err != nil {
+			return nil, err
+		}
+		var f chainFile
+		if err := json.Unmarshal(data, &f); err != nil {
+			return nil, fmt.Errorf("%s: %w", e.Name(), err)
+		}
+		if f.Version != 1 {
+			return nil, fmt.Errorf("%s: unsupported version %d", e.Name(), f.Version)
+		}
+		for i := range f.Patterns {
+			if err := f.Patterns[i].compile(); err != nil {
+				return nil, fmt.Errorf("%s: %w", e.Name(), err)
+			}
+		}
+		patterns = append(patterns, f.Patterns...)
+	}
+	return patterns, nil
+}
+
+func (p *ChainPattern) compile() error {
+	if p.ID == "" || len(p.Steps) < 2 {
+		return fmt.Errorf("pattern %q: needs an id and at least two steps", p.ID)
+	}
+	switch p.Confidence {
+	case ConfidenceWarning, ConfidenceHigh, ConfidenceCritical:
+	default:
+		return fmt.Errorf("pattern %s: bad confidence %q", p.ID, p.Confidence)
+	}
+	for i := range p.Steps {
+		s := &p.Steps[i]
+		if len(s.Rules) == 0 && len(s.Categories) == 0 && len(s.Techniques) == 0 && s.Regex == "" {
+			return fmt.Errorf("pattern %s step %d: nothing to match", p.ID, i+1)
+		}
+		for _, e := range []struct {
+			expr string
+			re   **regexp.Regexp
+		}{{s.Regex, &s.regex}, {s.Where, &s.where}} {
+			if e.expr == "" {
+				continue
+			}
+			re, err := regexp.Compile(bindVars(e.expr, nil))
+			if err != nil {
+				return fmt.Errorf("pattern %s step %d: %w", p.ID, i+1, err)
+			}
+			if !chainVar.MatchString(e.expr) {
+				*e.re = re
+			}
+		}
+		for _, expr := range s.Capture {
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				return fmt.Errorf("pattern %s step %d: capture: %w", p.ID, i+1, err)
+			}
+			s.capture = append(s.capture, re)
+		}
+	}
+	return nil
+}
+
+var chainVar = regexp.MustCompile(`\$\{(\w+)\}`)
+
+// bindVars fills ${name} in a step expression with captured values,
+// quoted, or a catch-all for values not captured.
+func bindVars(expr string, vars map[string]string) string {
+	return chainVar.ReplaceAllStringFunc(expr, func(m string) string {
+		if v, ok := vars[m[2:len(m)-1]]; ok {
+			return regexp.QuoteMeta(v)
+		}
+		return `\S+`
+	})
+}
+
+// bound caches step expressions compiled with captured values, by the
+// expression they bind to; patterns are shared by every session.
+var bound = struct {
+	mu sync.Mutex
+	re map[string]*regexp.Regexp
+}{re: map[string]*regexp.Regexp{}}
+
+// compiled is a step expression ready to match: compiled once when it
+// uses no captured values, or with vars bound into it.
+func compiled(static *regexp.Regexp, expr string, vars map[string]string) *regexp.Regexp {
+	if static != nil {
+		return static
+	}
+	src := bindVars(expr, vars)
+	bound.mu.Lock()
+	defer bound.mu.Unlock()
+	if re, ok := bound.re[src]; ok {
+		return re
+	}
+	// Bound values are quoted, so what compiled unbound compiles bound.
+	re, err := regexp.Compile(src)
+	if err != nil {
+		return nil
+	}
+	if len(bound.re) >= maxBound {
+		clear(bound.re)
+	}
+	bound.re[src] = re
+	return re
+}
+
+func (s *ChainStep) matches(o Observation, vars map[string]string) bool {
+	hit := anyString(s.Rules, o.Rules) || anyString(s.Categories, []string{o.Category})
+	for _, t := range o.Techniques {
+		hit = hit || anyString(s.Techniques, []string{t.Technique})
+	}
+	if !hit && s.Regex != "" {
+		re := compiled(s.regex, s.Regex, vars)
+		hit = re != nil && re.MatchString(o.Command)
+	}
+	if !hit {
+		return false
+	}
+	if s.Where == "" {
+		return true
+	}
+	re := compiled(s.where, s.Where, vars)
+	return re != nil && re.MatchString(o.Command)
+}
+
+func (s *ChainStep) captures(cmd string) map[string]string {
+	vars := map[string]string{}
+	for _, re := range s.capture {
+		m := re.FindStringSubmatch(cmd)
+		if m == nil {
+			continue
+		}
+		for i, name := range re.SubexpNames() {
+			if name != "" && m[i] != "" {
+				vars[name] = m[i]
+			}
+		}
+		break
+	}
+	return vars
+}
+
+func anyString(want, have []string) bool {
+	for _, w := range want {
+		for _, h := range have {
+			if w == h {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// KillChainSignal follows every pattern through a session, keeping the
+// partial matches in the session state, and fires once per pattern when
+// its last step is seen.
+type KillChainSignal struct {
+	patterns []ChainPattern
+}
+
+func (k *KillChainSignal) Name() SignalType { return SignalKillChain }
+
+func (k *KillChainSignal) Check(o Observation) *DetectionEvent {
+	session := o.Session
+	if session.Chains == nil {
+		session.Chains = map[string][]sshsession.ChainProgress{}
+	}
+	if session.ChainsCompleted == nil {
+		session.ChainsCompleted = map[string]bool{}
+	}
+	at := session.LastCommandTime
+	if at.IsZero() {
+		at = time.Now()
+	}
+	step := sshsession.ChainStepMatch{Command: o.Command, CommandIndex: session.CommandCount}
+
+	var fired *DetectionEvent
+	for i := range k.patterns {
+		p := &k.patterns[i]
+		if session.ChainsCompleted[p.ID] {
+			continue
+		}
+
+		var kept []sshsession.ChainProgress
+		for _, prog := range session.Chains[p.ID] {
+			if p.expired(prog, session.CommandCount, at) {
+				continue
+			}
+			p.advance(&prog, o, step)
+			// Only one event leaves per command; a second completed
+			// pattern waits for the next one.
+			if prog.Step == len(p.Steps) && fired == nil {
+				fired = p.event(prog, o)
+				session.ChainsCompleted[p.ID] = true
+				break
+			}
+			kept = append(kept, prog)
+		}
+		if session.ChainsCompleted[p.ID] {
+			delete(session.Chains, p.ID)
+			continue
+		}
+
+		if p.Steps[0].matches(o, nil) {
+			prog := sshsession.ChainProgress{Started: at, Vars: map[string]string{}}
+			p.advance(&prog, o, step)
+			if prog.Step == len(p.Steps) && fired == nil {
+				fired = p.event(prog, o)
+				session.ChainsCompleted[p.ID] = true
+				delete(session.Chains, p.ID)
+				continue
+			}
+			kept = append(kept, prog)
+		}
+		if len(kept) > maxPartialChains {
+			kept = kept[len(kept)-maxPartialChains:]
+		}
+		session.Chains[p.ID] = kept
+	}
+	return fired
+}
+
+func (p *ChainPattern) expired(prog sshsession.ChainProgress, index int, at time.Time) bool {
+	if p.WindowSecs > 0 && at.Sub(prog.Started) > time.Duration(p.WindowSecs)*time.Second {
+		return true
+	}
+	return p.MaxGap > 0 && index-prog.LastIndex-1 > p.MaxGap
+}
+
+// advance moves prog through as many steps as this one command satisfies,
+// so one-liners like `wget ... && chmod +x x && ./x` complete a pattern.
+func (p *ChainPattern) advance(prog *sshsession.ChainProgress, o Observation, step sshsession.ChainStepMatch) {
+	for prog.Step < len(p.Steps) {
+		s := &p.Steps[prog.Step]
+		if !s.matches(o, prog.Vars) {
+			return
+		}
+		for name, v := range s.captures(o.Command) {
+			prog.Vars[name] = v
+		}
+		step.Step = s.Name
+		prog.Steps = append(prog.Steps, step)
+		prog.LastIndex = step.CommandIndex
+		prog.Step++
+	}
+}
+
+func (p *ChainPattern) event(prog sshsession.ChainProgress, o Observation) *DetectionEvent {
+	var parts []string
+	for _, s := range prog.Steps {
+		parts = append(parts, fmt.Sprintf("%s: #%d %s", s.Step, s.CommandIndex, s.Command))
+	}
+	return &DetectionEvent{
+		Signal:         SignalKillChain,
+		Confidence:     p.Confidence,
+		Details:        fmt.Sprintf("kill chain %s (%s) — %s", p.ID, p.Name, strings.Join(parts, "; ")),
+		TriggerCommand: o.Command,
+		CommandIndex:   o.Session.CommandCount,
+		Techniques:     attack.Merge(nil, p.Techniques...),
+		Pattern:        p.ID,
+		ChainSteps:     prog.Steps,
+		Response:       p.Response,
+	}
+}
@@ -2,53 +2,100 @@ package detector
 
 import (
 	sshsession "GradGuard/internal/Session"
-	"encoding/json"
-	"os"
-	"path/filepath"
-	"sync"
+	"GradGuard/internal/deception"
+	"GradGuard/internal/events"
 	"time"
 )
 
-var mu sync.Mutex
+// responseQueue is how many detections may wait for their response.
+const responseQueue = 64
 
 type Detector struct {
 	session   *sshsession.SessionState
-	container string
-	threshold ThresholdSignal
-	sequence  SequenceSignal
-	timing    TimingSignal
+	responder *Responder
+	profile   Profile
+	settings  Settings
+	signals   []Signal
+	// pending feeds the goroutine that responds to detections, so the
+	// docker calls of a response never hold up the session's output.
+	pending chan DetectionEvent
+	done    chan struct{}
 }
 
+// New builds a detector running the signals of the profile for the
+// session's login user. Close it when the session ends.
 func New(session *sshsession.SessionState) *Detector {
-	return &Detector{
+	profile := ProfileFor(session.User)
+	d := &Detector{
 		session:   session,
-		container: containerName(session.ID),
+		responder: NewResponder(targetFor(session.ID)),
+		profile:   profile,
+		settings:  profile.Settings(),
+		signals:   profile.build(),
+		pending:   make(chan DetectionEvent, responseQueue),
+		done:      make(chan struct{}),
 	}
+	go d.respond()
+	return d
 }
 
-func (d *Detector) Check(cmd string, category string, delayMs int64) []DetectionEvent {
+// Check shows one classified command to every signal of the profile and
+// returns the events they raised. o.Session is filled in by the detector.
+// The events are responded to, then published, in the background; the
+// ones returned do not carry the response yet.
+func (d *Detector) Check(o Observation) []DetectionEvent {
 	var events []DetectionEvent
 
-	if e := d.threshold.Check(d.session, cmd); e != nil {
-		e.ResponseTaken = Execute(d.container, e.Confidence)
+	o.Session = d.session
+	for _, s := range d.signals {
+		e := s.Check(o)
+		if e == nil {
+			continue
+		}
+		e.Profile = d.profile.Name
+		e.Settings = &d.settings
 		events = append(events, d.finalize(*e))
 	}
 
-	if e := d.sequence.Check(d.session, cmd, category); e != nil {
-		e.ResponseTaken = Execute(d.container, ConfidenceCritical)
-		events = append(events, d.finalize(*e))
+	for _, event := range events {
+		d.pending <- event
 	}
 
-	if e := d.timing.Check(d.session, cmd, delayMs); e != nil {
-		e.ResponseTaken = Execute(d.container, e.Confidence)
-		events = append(events, d.finalize(*e))
-	}
+	return events
+}
 
-	for _, event := range events {
-		writeDetection(d.session.ID, event)
+// respond runs the playbook for each detection in turn, checks what still
+// gives the container away once something was applied, and publishes the
+// detection with what was done.
+func (d *Detector) respond() {
+	defer close(d.done)
+	for e := range d.pending {
+		level := e.Response
+		if level == "" {
+			level = e.Confidence
+		}
+		e.ResponseTaken, e.Actions = d.responder.Respond(level)
+		if applied(e.Actions) {
+			e.Verification = d.responder.Verify()
+		}
+		publish(e)
 	}
+}
 
-	return events
+// Close waits for the detections still being responded to, so they are
+// published before the session's end.
+func (d *Detector) Close() {
+	close(d.pending)
+	<-d.done
+}
+
+func applied(results []ActionResult) bool {
+	for _, r := range results {
+		if r.Status == ActionApplied {
+			return true
+		}
+	}
+	return false
 }
 
 func (d *Detector) finalize(e DetectionEvent) DetectionEvent {
@@ -58,19 +105,46 @@ func (d *Detector) finalize(e DetectionEvent) DetectionEvent {
 	return e
 }
 
-func writeDetection(sessionID string, event DetectionEvent) {
-	mu.Lock()
-	defer mu.Unlock()
-
-	dir := "logs/detections"
-	_ = os.MkdirAll(dir, 0755)
+// ResponseEvent is what the responder did about one detection, published
+// on its own so sinks can follow responses without reading detections.
+type ResponseEvent struct {
+	Signal       SignalType              `json:"signal"`
+	Level        Confidence              `json:"level"`
+	Taken        string                  `json:"taken"`
+	Actions      []ActionResult          `json:"actions"`
+	Verification *deception.Verification `json:"verification,omitempty"`
+}
 
-	path := filepath.Join(dir, sessionID+"-detections.json")
-	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+func publish(event DetectionEvent) {
+	at, err := time.Parse(time.RFC3339, event.Timestamp)
 	if err != nil {
+		at = time.Now().UTC()
+	}
+	events.Publish(events.Event{
+		Kind:       events.KindDetection,
+		Time:       at,
+		SessionID:  event.SessionID,
+		RemoteAddr: event.RemoteAddr,
+		Data:       event,
+	})
+	if len(event.Actions) == 0 {
 		return
 	}
-	defer file.Close()
-
-	json.NewEncoder(file).Encode(event)
+	level := event.Response
+	if level == "" {
+		level = event.Confidence
+	}
+	events.Publish(events.Event{
+		Kind:       events.KindResponse,
+		Time:       at,
+		SessionID:  event.SessionID,
+		RemoteAddr: event.RemoteAddr,
+		Data: ResponseEvent{
+			Signal:       event.Signal,
+			Level:        level,
+			Taken:        event.ResponseTaken,
+			Actions:      event.Actions,
+			Verification: event.Verification,
+		},
+	})
 }
@@ -0,0 +1,48 @@
+package detector
+
+import (
+	"GradGuard/internal/analyzer"
+	"GradGuard/internal/attack"
+	"fmt"
+)
+
+const SignalKeystrokes SignalType = "keystroke_dynamics"
+
+// KeystrokeSignal reports who is at the keyboard, from how the session's
+// input arrived, once there is enough of it and again when the answer
+// changes to one not given before; a session whose input sits near a
+// boundary is not reported on every command. Only a bot is worth
+// deceiving harder; a person typing gets no response at all.
+type KeystrokeSignal struct {
+	reported map[string]bool
+}
+
+func (k *KeystrokeSignal) Name() SignalType { return SignalKeystrokes }
+
+func (k *KeystrokeSignal) Check(o Observation) *DetectionEvent {
+	op, ok := analyzer.ClassifyOperator(o.Session.Keystrokes.Stats())
+	if !ok || k.reported[op.Class] {
+		return nil
+	}
+	if k.reported == nil {
+		k.reported = map[string]bool{}
+	}
+	k.reported[op.Class] = true
+
+	e := &DetectionEvent{
+		Signal:         SignalKeystrokes,
+		Confidence:     ConfidenceWarning,
+		Details:        fmt.Sprintf("operator looks like %s (%.2f) — %s", op.Class, op.Confidence, op.Evidence),
+		TriggerCommand: o.Command,
+		CommandIndex:   o.Session.CommandCount,
+		Operator:       op.Class,
+	}
+	switch op.Class {
+	case analyzer.OperatorBot:
+		e.Confidence = ConfidenceHigh
+		e.Techniques = []attack.Tag{scriptedShell}
+	case analyzer.OperatorTyping:
+		e.Response = NoResponse
+	}
+	return e
+}
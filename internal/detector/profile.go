@@ -0,0 +1,118 @@
+package detector
+
+import (
+	"fmt"
+	"sync"
+)
+
+const DefaultProfileName = "default"
+
+// Profile is the detector setup for one kind of login identity: the
+// users it applies to, the signals it runs and how they are tuned. No
+// Signals means every registered signal; Disabled then takes some away.
+type Profile struct {
+	Name     string
+	Users    []string
+	Signals  []SignalType
+	Disabled []SignalType
+	Tuning   Tuning
+}
+
+var (
+	profilesMu sync.RWMutex
+	profiles   = []Profile{{Name: DefaultProfileName, Tuning: DefaultTuning()}}
+)
+
+// SetProfiles replaces the detector profiles. A profile named "default"
+// is added if missing; it serves every user no other profile lists.
+func SetProfiles(list []Profile) error {
+	hasDefault := false
+	users := map[string]string{}
+	list = append([]Profile(nil), list...)
+	for i := range list {
+		p := &list[i]
+		if p.Name == "" {
+			return fmt.Errorf("detector profile without a name")
+		}
+		if p.Name == DefaultProfileName {
+			hasDefault = true
+		}
+		for _, u := range p.Users {
+			if other, dup := users[u]; dup {
+				return fmt.Errorf("user %q is in profiles %s and %s", u, other, p.Name)
+			}
+			users[u] = p.Name
+		}
+		for _, name := range append(append([]SignalType{}, p.Signals...), p.Disabled...) {
+			if _, ok := lookupSignal(name); !ok {
+				return fmt.Errorf("profile %s: unknown signal %q", p.Name, name)
+			}
+		}
+		p.Tuning = p.Tuning.withDefaults()
+		if err := p.Tuning.validate(); err != nil {
+			return fmt.Errorf("profile %s: %w", p.Name, err)
+		}
+	}
+	if !hasDefault {
+		list = append(list, Profile{Name: DefaultProfileName, Tuning: DefaultTuning()})
+	}
+
+	profilesMu.Lock()
+	profiles = list
+	profilesMu.Unlock()
+	return nil
+}
+
+// ProfileFor returns the profile for a login user.
+func ProfileFor(user string) Profile {
+	profilesMu.RLock()
+	defer profilesMu.RUnlock()
+	var fallback Profile
+	for _, p := range profiles {
+		for _, u := range p.Users {
+			if u == user {
+				return p
+			}
+		}
+		if p.Name == DefaultProfileName {
+			fallback = p
+		}
+	}
+	return fallback
+}
+
+// Enabled lists the signals the profile runs.
+func (p Profile) Enabled() []SignalType {
+	names := p.Signals
+	if len(names) == 0 {
+		names = Registered()
+	}
+	var enabled []SignalType
+	for _, name := range names {
+		off := false
+		for _, d := range p.Disabled {
+			if d == name {
+				off = true
+			}
+		}
+		if !off {
+			enabled = append(enabled, name)
+		}
+	}
+	return enabled
+}
+
+// Settings is what the profile's events record of it.
+func (p Profile) Settings() Settings {
+	return Settings{Signals: p.Enabled(), Tuning: p.Tuning}
+}
+
+func (p Profile) build() []Signal {
+	var signals []Signal
+	for _, name := range p.Enabled() {
+		if factory, ok := lookupSignal(name); ok {
+			signals = append(signals, factory(p.Tuning))
+		}
+	}
+	return signals
+}
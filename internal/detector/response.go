@@ -1,8 +1,14 @@
 package detector
 
 import (
+	"GradGuard/internal/deception"
+	"GradGuard/internal/tarpit"
+	"context"
 	"fmt"
 	"os/exec"
+	"strings"
+	"sync"
+	"time"
 )
 
 type ResponseLevel int
@@ -13,69 +19,228 @@ const (
 	ResponseCritical ResponseLevel = 3
 )
 
-func Execute(containerName string, confidence Confidence) string {
-	switch confidence {
-	case ConfidenceWarning:
-		applyWarningResponse(containerName)
-		return "applied_warning_deception"
-	case ConfidenceHigh:
-		applyHighResponse(containerName)
-		return "applied_high_deception"
-	case ConfidenceCritical:
-		applyCriticalResponse(containerName)
-		return "applied_critical_deception"
+const (
+	// actionTimeout bounds one action so a wedged container cannot hold
+	// up the session's detector.
+	actionTimeout = 10 * time.Second
+	// maxActionOutput is how much of an action's output an event keeps.
+	maxActionOutput = 1 << 10
+)
+
+const (
+	ActionApplied    = "applied"
+	ActionFailed     = "failed"
+	ActionSkipped    = "skipped"
+	ActionRolledBack = "rolled_back"
+	ActionDryRun     = "dry_run"
+)
+
+// Step is one action of a playbook. When a Required step fails, the steps
+// the same run had applied are undone, so the container is never left
+// half-dressed.
+type Step struct {
+	Action   string
+	Params   map[string]string
+	Required bool
+}
+
+// ActionResult is what one step did, recorded in the detection event.
+type ActionResult struct {
+	Action string            `json:"action"`
+	Params map[string]string `json:"params,omitempty"`
+	Status string            `json:"status"`
+	Output string            `json:"output,omitempty"`
+	Error  string            `json:"error,omitempty"`
+}
+
+// defaultPlaybooks are the built-in responses. Each level lists all the
+// steps it wants, lower levels' included; what a session already has is
+// skipped.
+func defaultPlaybooks() map[Confidence][]Step {
+	warning := []Step{{Action: "remove-dockerenv"}}
+	high := append(append([]Step{}, warning...),
+		Step{Action: "fake-cpuinfo"}, Step{Action: "fake-meminfo"},
+		Step{Action: "fake-users"}, Step{Action: "fake-hosts"},
+		Step{Action: "tarpit", Params: map[string]string{"tier": "slow"}})
+	critical := append(append([]Step{}, high...),
+		Step{Action: "slow-shell"},
+		Step{Action: "tarpit", Params: map[string]string{"tier": "sticky"}})
+	return map[Confidence][]Step{
+		ConfidenceWarning:  warning,
+		ConfidenceHigh:     high,
+		ConfidenceCritical: critical,
 	}
-	return "none"
 }
 
-func applyWarningResponse(c string) {
-	dockerExec(c, `mkdir -p /sys/fs/cgroup && `+
-		`echo '0::/init.scope' > /proc/1/cgroup || true`)
+var (
+	responseMu sync.RWMutex
+	playbooks  = defaultPlaybooks()
+	dryRun     bool
+)
 
-	dockerExec(c, `echo '# system environment' > /.dockerenv && `+
-		`echo 'PLATFORM=baremetal' >> /.dockerenv`)
+// SetResponse sets the response playbooks and dry-run mode. Levels
+// missing from given keep their built-in playbook; an empty one turns
+// that level's response off. In dry-run mode every step is recorded with
+// the script it would have run, and nothing is run.
+func SetResponse(dry bool, given map[Confidence][]Step) error {
+	merged := defaultPlaybooks()
+	for level, steps := range given {
+		switch level {
+		case ConfidenceWarning, ConfidenceHigh, ConfidenceCritical:
+		default:
+			return fmt.Errorf("playbook for unknown level %q", level)
+		}
+		for _, s := range steps {
+			if _, ok := lookupAction(s.Action); !ok {
+				return fmt.Errorf("playbook %s: unknown action %q", level, s.Action)
+			}
+		}
+		merged[level] = steps
+	}
+
+	responseMu.Lock()
+	playbooks, dryRun = merged, dry
+	responseMu.Unlock()
+	return nil
 }
 
-func applyHighResponse(c string) {
-	applyWarningResponse(c)
-
-	dockerExec(c, `cat > /tmp/fake_cpuinfo << 'EOF'
-				processor	: 0
-				vendor_id	: GenuineIntel
-				cpu family	: 6
-				model		: 158
-				model name	: Intel(R) Core(TM) i7-9750H CPU @ 2.60GHz
-				stepping	: 10
-				cpu MHz		: 2600.000
-				cache size	: 12288 KB
-				physical id	: 0
-				siblings	: 12
-				core id		: 0
-				cpu cores	: 6
-				flags		: fpu vme de pse tsc msr pae mce cx8 apic
-				EOF
-				mount --bind /tmp/fake_cpuinfo /proc/cpuinfo 2>/dev/null || true`)
-
-	dockerExec(c, `echo 'deploy:x:1001:1001:Deploy User,,,:/home/deploy:/bin/bash' >> /etc/passwd && `+
-		`echo 'monitor:x:1002:1002:Monitor User,,,:/home/monitor:/bin/bash' >> /etc/passwd`)
-
-	dockerExec(c, `echo '10.0.0.1    gateway.internal' >> /etc/hosts && `+
-		`echo '10.0.0.2    db.internal' >> /etc/hosts && `+
-		`echo '10.0.0.3    cache.internal' >> /etc/hosts`)
+// Playbook returns the steps of a response level.
+func Playbook(level Confidence) []Step {
+	responseMu.RLock()
+	defer responseMu.RUnlock()
+	return playbooks[level]
 }
 
-func applyCriticalResponse(c string) {
-	applyHighResponse(c)
+// Responder runs playbooks for one session, each action with the same
+// parameters at most once per session.
+type Responder struct {
+	target  Target
+	dryRun  bool
+	applied map[string]bool
+}
 
-	dockerExec(c, `echo 'sleep $((RANDOM % 3 + 1))' >> /root/.bashrc`)
+func NewResponder(target Target) *Responder {
+	responseMu.RLock()
+	defer responseMu.RUnlock()
+	return &Responder{target: target, dryRun: dryRun, applied: map[string]bool{}}
 }
 
-func dockerExec(containerName, command string) {
-	cmd := exec.Command("docker", "exec", containerName,
-		"bash", "-c", command)
-	cmd.Run()
+// Respond runs the playbook of a level and reports what it did, along
+// with the summary kept in DetectionEvent.ResponseTaken.
+func (r *Responder) Respond(level Confidence) (string, []ActionResult) {
+	steps := Playbook(level)
+	if len(steps) == 0 {
+		return "none", nil
+	}
+
+	var results []ActionResult
+	var done []int
+	failed := false
+	for _, s := range steps {
+		a, _ := lookupAction(s.Action)
+		params := a.params(s.Params)
+		key := actionKey(a.Name, params)
+		res := ActionResult{Action: a.Name, Params: params}
+
+		switch {
+		case r.applied[key]:
+			res.Status = ActionSkipped
+		case r.dryRun:
+			res.Status = ActionDryRun
+			if a.Host != nil {
+				res.Output = a.Doc(params)
+			} else {
+				res.Output = a.Script(params)
+			}
+			r.applied[key] = true
+		default:
+			out, err := r.apply(a, params)
+			res.Output = out
+			if err != nil {
+				res.Status = ActionFailed
+				res.Error = err.Error()
+				failed = true
+			} else {
+				res.Status = ActionApplied
+				r.applied[key] = true
+				done = append(done, len(results))
+			}
+		}
+		results = append(results, res)
+
+		if res.Status == ActionFailed && s.Required {
+			r.rollback(results, done)
+			return fmt.Sprintf("rolled_back_%s_deception", level), results
+		}
+	}
+
+	switch {
+	case r.dryRun:
+		return fmt.Sprintf("dry_run_%s_deception", level), results
+	case failed:
+		return fmt.Sprintf("partial_%s_deception", level), results
+	}
+	return fmt.Sprintf("applied_%s_deception", level), results
+}
+
+// rollback undoes the steps of this run that were applied, newest first.
+// A step whose undo fails keeps its applied status and its error.
+func (r *Responder) rollback(results []ActionResult, done []int) {
+	for i := len(done) - 1; i >= 0; i-- {
+		res := &results[done[i]]
+		a, _ := lookupAction(res.Action)
+		var err error
+		switch {
+		case a.HostUndo != nil:
+			err = a.HostUndo(r.target, res.Params)
+		case a.Undo != nil:
+			_, err = r.run(a.Undo(res.Params))
+		default:
+			continue
+		}
+		if err != nil {
+			res.Error = "rollback: " + err.Error()
+			continue
+		}
+		res.Status = ActionRolledBack
+		delete(r.applied, actionKey(res.Action, res.Params))
+	}
+}
+
+// Verify re-runs the fingerprinting checks in the container to see what
+// still gives it away after a response.
+func (r *Responder) Verify() *deception.Verification {
+	v := deception.Verify(r.run)
+	return &v
+}
+
+func (r *Responder) apply(a Action, params map[string]string) (string, error) {
+	if a.Host == nil {
+		return r.run(a.Script(params))
+	}
+	return "", a.Host(r.target, params)
+}
+
+func (r *Responder) run(script string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), actionTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "docker", "exec", r.target.Container, "bash", "-c", script).CombinedOutput()
+	text := strings.TrimSpace(string(out))
+	if len(text) > maxActionOutput {
+		text = text[:maxActionOutput] + "…"
+	}
+	return text, err
 }
 
 func containerName(sessionID string) string {
 	return fmt.Sprintf("honeypot-%s", sessionID)
 }
+
+// targetFor is what a live session's responses work on.
+func targetFor(sessionID string) Target {
+	return Target{
+		Container: containerName(sessionID),
+		View:      deception.ViewFor(sessionID),
+		Tarpit:    tarpit.For(sessionID),
+	}
+}
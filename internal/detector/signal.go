@@ -0,0 +1,69 @@
+package detector
+
+import (
+	sshsession "GradGuard/internal/Session"
+	"GradGuard/internal/attack"
+	"fmt"
+	"sync"
+)
+
+// Observation is what a signal is shown for each command.
+type Observation struct {
+	Session    *sshsession.SessionState
+	Command    string
+	Category   string
+	Techniques []attack.Tag
+	Rules      []string
+	DelayMs    int64
+}
+
+// Signal watches one session's commands and returns an event when it sees
+// what it looks for. A signal sets DetectionEvent.Response to ask for a
+// response other than the one matching its confidence.
+type Signal interface {
+	Name() SignalType
+	Check(o Observation) *DetectionEvent
+}
+
+// Factory makes a fresh signal for a new session, tuned by its profile.
+type Factory func(t Tuning) Signal
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[SignalType]Factory{}
+	order      []SignalType
+)
+
+// Register makes a signal available to profiles. It panics on a duplicate
+// name, as two signals silently sharing one would be a programming error.
+func Register(name SignalType, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, dup := registry[name]; dup {
+		panic(fmt.Sprintf("detector: signal %s registered twice", name))
+	}
+	registry[name] = factory
+	order = append(order, name)
+}
+
+// Registered lists every registered signal in registration order, which
+// is the order a detector runs them in.
+func Registered() []SignalType {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return append([]SignalType(nil), order...)
+}
+
+func lookupSignal(name SignalType) (Factory, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	f, ok := registry[name]
+	return f, ok
+}
+
+func init() {
+	Register(SignalThreshold, func(t Tuning) Signal { return &ThresholdSignal{tuning: t} })
+	Register(SignalSequence, func(t Tuning) Signal { return &SequenceSignal{tuning: t} })
+	Register(SignalTiming, func(t Tuning) Signal { return &TimingSignal{tuning: t} })
+	Register(SignalKeystrokes, func(Tuning) Signal { return &KeystrokeSignal{} })
+}
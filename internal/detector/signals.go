@@ -2,6 +2,9 @@ package detector
 
 import (
 	sshsession "GradGuard/internal/Session"
+	"GradGuard/internal/attack"
+	"GradGuard/internal/deception"
+	"fmt"
 	"time"
 )
 
@@ -16,122 +19,181 @@ const (
 	ConfidenceWarning  Confidence = "warning"
 	ConfidenceHigh     Confidence = "high"
 	ConfidenceCritical Confidence = "critical"
+
+	// NoResponse is a response level that leaves the container alone.
+	NoResponse Confidence = "none"
 )
 
 type DetectionEvent struct {
-	Timestamp      string     `json:"timestamp"`
-	SessionID      string     `json:"session_id"`
-	RemoteAddr     string     `json:"remote_addr"`
-	Signal         SignalType `json:"signal"`
-	Confidence     Confidence `json:"confidence"`
-	Details        string     `json:"details"`
-	CommandIndex   int        `json:"command_index"`
-	TriggerCommand string     `json:"trigger_command"`
-	ResponseTaken  string     `json:"response_taken"`
+	Timestamp      string       `json:"timestamp"`
+	SessionID      string       `json:"session_id"`
+	RemoteAddr     string       `json:"remote_addr"`
+	Signal         SignalType   `json:"signal"`
+	Confidence     Confidence   `json:"confidence"`
+	Details        string       `json:"details"`
+	CommandIndex   int          `json:"command_index"`
+	TriggerCommand string       `json:"trigger_command"`
+	ResponseTaken  string       `json:"response_taken"`
+	Techniques     []attack.Tag `json:"techniques"`
+	Profile        string       `json:"profile,omitempty"`
+	// Settings is the profile's setup when the event was raised.
+	Settings *Settings `json:"settings,omitempty"`
+	// Actions is what each step of the response playbook did.
+	Actions []ActionResult `json:"actions,omitempty"`
+	// Verification is what the fingerprinting checks saw once the
+	// response was applied.
+	Verification *deception.Verification `json:"verification,omitempty"`
+	// Pattern and ChainSteps name the kill chain a kill_chain event
+	// completed and the command behind each step.
+	Pattern    string                      `json:"pattern,omitempty"`
+	ChainSteps []sshsession.ChainStepMatch `json:"chain_steps,omitempty"`
+	// Operator is the keystroke_dynamics classification of who is typing.
+	Operator string `json:"operator,omitempty"`
+
+	// Response is the response level the signal asks for; empty means
+	// the event's confidence.
+	Response Confidence `json:"-"`
+}
+
+var (
+	sandboxChecks = attack.Tag{Tactic: "TA0005", Technique: "T1497.001"}
+	scriptedShell = attack.Tag{Tactic: "TA0002", Technique: "T1059.004"}
+)
+
+// sessionTechniques is every technique seen so far in the session, used
+// by signals that fire on the session as a whole rather than one command.
+func sessionTechniques(session *sshsession.SessionState, current []attack.Tag) []attack.Tag {
+	tags := attack.Merge(nil, current...)
+	for t := range session.Techniques {
+		tags = attack.Merge(tags, t)
+	}
+	return tags
 }
 
 type ThresholdSignal struct {
-	firedAt50  bool
-	firedAt75  bool
-	firedAt100 bool
+	tuning        Tuning
+	firedWarning  bool
+	firedHigh     bool
+	firedCritical bool
 }
 
-func (t *ThresholdSignal) Check(session *sshsession.SessionState, cmd string) *DetectionEvent {
+func (t *ThresholdSignal) Name() SignalType { return SignalThreshold }
+
+func (t *ThresholdSignal) Check(o Observation) *DetectionEvent {
+	session, cmd := o.Session, o.Command
 	score := session.SuspicionScore
+	tags := sessionTechniques(session, o.Techniques)
 
-	if score >= 100 && !t.firedAt100 {
-		t.firedAt100 = true
+	if score >= t.tuning.ScoreCritical && !t.firedCritical {
+		t.firedCritical = true
 		return &DetectionEvent{
 			Signal:         SignalThreshold,
 			Confidence:     ConfidenceCritical,
-			Details:        "suspicion score reached 100",
+			Details:        fmt.Sprintf("suspicion score reached %d", t.tuning.ScoreCritical),
 			TriggerCommand: cmd,
 			CommandIndex:   session.CommandCount,
+			Techniques:     tags,
 		}
 	}
-	if score >= 75 && !t.firedAt75 {
-		t.firedAt75 = true
+	if score >= t.tuning.ScoreHigh && !t.firedHigh {
+		t.firedHigh = true
 		return &DetectionEvent{
 			Signal:         SignalThreshold,
 			Confidence:     ConfidenceHigh,
-			Details:        "suspicion score crossed 75",
+			Details:        fmt.Sprintf("suspicion score crossed %d", t.tuning.ScoreHigh),
 			TriggerCommand: cmd,
 			CommandIndex:   session.CommandCount,
+			Techniques:     tags,
 		}
 	}
-	if score >= 50 && !t.firedAt50 {
-		t.firedAt50 = true
+	if score >= t.tuning.ScoreWarning && !t.firedWarning {
+		t.firedWarning = true
 		return &DetectionEvent{
 			Signal:         SignalThreshold,
 			Confidence:     ConfidenceWarning,
-			Details:        "suspicion score crossed 50",
+			Details:        fmt.Sprintf("suspicion score crossed %d", t.tuning.ScoreWarning),
 			TriggerCommand: cmd,
 			CommandIndex:   session.CommandCount,
+			Techniques:     tags,
 		}
 	}
 	return nil
 }
 
 type SequenceSignal struct {
+	tuning                  Tuning
 	consecutiveFingerprints int
 	fired                   bool
 }
 
-func (s *SequenceSignal) Check(session *sshsession.SessionState, cmd string, category string) *DetectionEvent {
-	if category == "fingerprint" {
+func (s *SequenceSignal) Name() SignalType { return SignalSequence }
+
+func (s *SequenceSignal) Check(o Observation) *DetectionEvent {
+	session, cmd := o.Session, o.Command
+	if o.Category == "fingerprint" {
 		s.consecutiveFingerprints++
 	} else {
 		s.consecutiveFingerprints = 0
 	}
 
-	if s.consecutiveFingerprints >= 3 && !s.fired {
+	if s.consecutiveFingerprints >= s.tuning.SequenceLength && !s.fired {
 		s.fired = true
 		return &DetectionEvent{
-			Signal:         SignalSequence,
-			Confidence:     ConfidenceCritical,
-			Details:        "3 or more fingerprint commands in sequence — likely automated scanner",
+			Signal:     SignalSequence,
+			Confidence: ConfidenceCritical,
+			Details: fmt.Sprintf("%d or more fingerprint commands in sequence — likely automated scanner",
+				s.tuning.SequenceLength),
 			TriggerCommand: cmd,
 			CommandIndex:   session.CommandCount,
+			Techniques:     []attack.Tag{sandboxChecks},
+			Response:       ConfidenceCritical,
 		}
 	}
 	return nil
 }
 
 type TimingSignal struct {
+	tuning       Tuning
 	recentDelays []int64
 	fired        bool
 }
 
-func (t *TimingSignal) Check(session *sshsession.SessionState, cmd string, delayMs int64) *DetectionEvent {
+func (t *TimingSignal) Name() SignalType { return SignalTiming }
+
+func (t *TimingSignal) Check(o Observation) *DetectionEvent {
+	session, cmd, delayMs := o.Session, o.Command, o.DelayMs
 	if delayMs == 0 {
 		return nil
 	}
 
 	t.recentDelays = append(t.recentDelays, delayMs)
-	if len(t.recentDelays) > 5 {
-		t.recentDelays = t.recentDelays[len(t.recentDelays)-5:]
+	window := t.tuning.TimingWindow
+	if len(t.recentDelays) > window {
+		t.recentDelays = t.recentDelays[len(t.recentDelays)-window:]
 	}
 
-	if len(t.recentDelays) < 5 {
+	if len(t.recentDelays) < window {
 		return nil
 	}
 
 	for _, d := range t.recentDelays {
-		if d > 5000 {
+		if d > t.tuning.TimingMaxGapMs {
 			t.recentDelays = nil
 			return nil
 		}
 	}
 
 	median := medianDelay(t.recentDelays)
-	if median < 300 && !t.fired {
+	if median < t.tuning.TimingMedianMs && !t.fired {
 		t.fired = true
 		return &DetectionEvent{
-			Signal:         SignalTiming,
-			Confidence:     ConfidenceHigh,
-			Details:        "median command delay under 300ms — automated tool detected",
+			Signal:     SignalTiming,
+			Confidence: ConfidenceHigh,
+			Details: fmt.Sprintf("median command delay under %dms — automated tool detected",
+				t.tuning.TimingMedianMs),
 			TriggerCommand: cmd,
 			CommandIndex:   session.CommandCount,
+			Techniques:     []attack.Tag{scriptedShell},
 		}
 	}
 	return nil
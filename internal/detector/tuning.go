@@ -0,0 +1,85 @@
+package detector
+
+import "fmt"
+
+// Tuning holds the numbers the built-in signals decide by. A zero field
+// takes its default.
+type Tuning struct {
+	// ScoreWarning, ScoreHigh and ScoreCritical are the suspicion scores
+	// at which threshold_breach fires at each confidence.
+	ScoreWarning  int `json:"score_warning"`
+	ScoreHigh     int `json:"score_high"`
+	ScoreCritical int `json:"score_critical"`
+	// SequenceLength is how many fingerprint commands in a row
+	// sequence_detection wants.
+	SequenceLength int `json:"sequence_length"`
+	// timing_analysis fires when the median of the last TimingWindow
+	// command delays is under TimingMedianMs; a delay over TimingMaxGapMs
+	// starts the window again.
+	TimingMedianMs int64 `json:"timing_median_ms"`
+	TimingMaxGapMs int64 `json:"timing_max_gap_ms"`
+	TimingWindow   int   `json:"timing_window"`
+}
+
+func DefaultTuning() Tuning {
+	return Tuning{
+		ScoreWarning:   50,
+		ScoreHigh:      75,
+		ScoreCritical:  100,
+		SequenceLength: 3,
+		TimingMedianMs: 300,
+		TimingMaxGapMs: 5000,
+		TimingWindow:   5,
+	}
+}
+
+func (t Tuning) withDefaults() Tuning {
+	d := DefaultTuning()
+	if t.ScoreWarning == 0 {
+		t.ScoreWarning = d.ScoreWarning
+	}
+	if t.ScoreHigh == 0 {
+		t.ScoreHigh = d.ScoreHigh
+	}
+	if t.ScoreCritical == 0 {
+		t.ScoreCritical = d.ScoreCritical
+	}
+	if t.SequenceLength == 0 {
+		t.SequenceLength = d.SequenceLength
+	}
+	if t.TimingMedianMs == 0 {
+		t.TimingMedianMs = d.TimingMedianMs
+	}
+	if t.TimingMaxGapMs == 0 {
+		t.TimingMaxGapMs = d.TimingMaxGapMs
+	}
+	if t.TimingWindow == 0 {
+		t.TimingWindow = d.TimingWindow
+	}
+	return t
+}
+
+func (t Tuning) validate() error {
+	if t.ScoreWarning < 0 || t.ScoreWarning > t.ScoreHigh || t.ScoreHigh > t.ScoreCritical {
+		return fmt.Errorf("score thresholds must rise from warning to critical, got %d/%d/%d",
+			t.ScoreWarning, t.ScoreHigh, t.ScoreCritical)
+	}
+	if t.SequenceLength < 1 {
+		return fmt.Errorf("sequence length must be at least 1")
+	}
+	if t.TimingMedianMs < 0 || t.TimingMaxGapMs < t.TimingMedianMs {
+		return fmt.Errorf("timing median %dms must be positive and below the max gap %dms",
+			t.TimingMedianMs, t.TimingMaxGapMs)
+	}
+	if t.TimingWindow < 1 {
+		return fmt.Errorf("timing window must be at least 1")
+	}
+	return nil
+}
+
+// Settings is the detector setup an event was raised under, kept with the
+// event so old detections still make sense after retuning.
+type Settings struct {
+	Signals []SignalType `json:"signals"`
+	Tuning  Tuning       `json:"tuning"`
+}
@@ -0,0 +1,343 @@
+package events
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultQueueSize is how many events a sink may fall behind by.
+	DefaultQueueSize = 1024
+	// backpressure is how long Publish waits on a full queue before it
+	// drops the event for that sink; the shell must never stall longer.
+	// Lossless sinks are never dropped from.
+	backpressure = 20 * time.Millisecond
+	// maxBatch is the most events a sink is handed at once.
+	maxBatch = 64
+	// flushTimeout bounds Flush so a wedged sink cannot hold up the end
+	// of a session.
+	flushTimeout = 5 * time.Second
+	// closeTimeout bounds how long Close waits for the sinks to close, as
+	// some still have something to send.
+	closeTimeout = 30 * time.Second
+)
+
+// Sink is somewhere events go. Write gets them in publish order, a batch
+// at a time, from a single goroutine.
+type Sink interface {
+	Name() string
+	Write(batch []Event) error
+	Close() error
+}
+
+// Lossless is a Sink that must be handed every event, such as the session
+// files the evidence chain is sealed over. Once its queue is full, events
+// for it wait in memory instead of being dropped.
+type Lossless interface {
+	Sink
+	Lossless()
+}
+
+// SinkStats is how one sink is keeping up.
+type SinkStats struct {
+	Name      string `json:"name"`
+	Delivered int64  `json:"delivered"`
+	Dropped   int64  `json:"dropped"`
+	Failed    int64  `json:"failed"`
+	Queued    int    `json:"queued"`
+}
+
+type item struct {
+	event   Event
+	flushed chan struct{}
+}
+
+type worker struct {
+	sink      Sink
+	queue     chan item
+	delivered atomic.Int64
+	dropped   atomic.Int64
+	failed    atomic.Int64
+	lastErr   time.Time
+
+	// lossless workers keep what a full queue will not take in backlog,
+	// in order, until the queue has drained.
+	lossless bool
+	mu       sync.Mutex
+	backlog  []item
+
+	// closing is held to send on the queue, and taken outright to close
+	// it; nothing is queued once closed is set.
+	closing sync.RWMutex
+	closed  bool
+	done    chan struct{}
+}
+
+func (w *worker) run() {
+	batch := make([]Event, 0, maxBatch)
+	var waiting []chan struct{}
+	collect := func(it item) {
+		if it.flushed != nil {
+			waiting = append(waiting, it.flushed)
+		} else {
+			batch = append(batch, it.event)
+		}
+	}
+	for it := range w.queue {
+		collect(it)
+	more:
+		for len(batch) < maxBatch {
+			select {
+			case next, ok := <-w.queue:
+				if !ok {
+					break more
+				}
+				collect(next)
+			default:
+				break more
+			}
+		}
+		for _, next := range w.takeBacklog() {
+			if len(batch) == maxBatch {
+				w.write(batch)
+				batch = batch[:0]
+			}
+			collect(next)
+		}
+
+		if len(batch) > 0 {
+			w.write(batch)
+			batch = batch[:0]
+		}
+		for _, done := range waiting {
+			close(done)
+		}
+		waiting = waiting[:0]
+	}
+	// What was kept back after the last flush still goes out.
+	for _, it := range w.takeBacklog() {
+		if it.flushed != nil {
+			close(it.flushed)
+		} else {
+			batch = append(batch, it.event)
+		}
+	}
+	if len(batch) > 0 {
+		w.write(batch)
+	}
+	w.sink.Close()
+	close(w.done)
+}
+
+// takeBacklog hands over the backlog once everything queued before it has
+// been taken, and nothing goes on the queue while it is not empty, so
+// events still come out in publish order.
+func (w *worker) takeBacklog() []item {
+	if !w.lossless {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.queue) > 0 {
+		return nil
+	}
+	backlog := w.backlog
+	w.backlog = nil
+	return backlog
+}
+
+func (w *worker) write(batch []Event) {
+	if err := w.sink.Write(batch); err != nil {
+		w.failed.Add(int64(len(batch)))
+		// One line a minute per sink is enough to notice.
+		if time.Since(w.lastErr) > time.Minute {
+			log.Printf("event sink %s: %v", w.sink.Name(), err)
+			w.lastErr = time.Now()
+		}
+		return
+	}
+	w.delivered.Add(int64(len(batch)))
+}
+
+// offer queues an item, waiting a moment on a full queue before giving
+// up on it. Flush markers wait longer, as their caller is waiting anyway.
+// A lossless worker never gives up or waits: what the queue will not
+// take goes on the backlog. Once the worker is closed items are dropped.
+func (w *worker) offer(it item) {
+	w.closing.RLock()
+	defer w.closing.RUnlock()
+	if w.closed {
+		if it.flushed != nil {
+			close(it.flushed)
+		} else {
+			w.dropped.Add(1)
+		}
+		return
+	}
+	if w.lossless {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		if len(w.backlog) == 0 {
+			select {
+			case w.queue <- it:
+				return
+			default:
+			}
+		}
+		w.backlog = append(w.backlog, it)
+		return
+	}
+	select {
+	case w.queue <- it:
+		return
+	default:
+	}
+	wait := backpressure
+	if it.flushed != nil {
+		wait = flushTimeout
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case w.queue <- it:
+	case <-timer.C:
+		if it.flushed != nil {
+			close(it.flushed)
+			return
+		}
+		w.dropped.Add(1)
+	}
+}
+
+// Bus fans events out to its sinks, each with its own queue and
+// goroutine, so a slow sink only ever holds up itself.
+type Bus struct {
+	workers []*worker
+}
+
+// NewBus starts a worker per sink. queueSize of 0 means the default.
+func NewBus(queueSize int, sinks ...Sink) *Bus {
+	if queueSize <= 0 {
+		queueSize = DefaultQueueSize
+	}
+	b := &Bus{}
+	for _, s := range sinks {
+		w := &worker{sink: s, queue: make(chan item, queueSize), done: make(chan struct{})}
+		_, w.lossless = s.(Lossless)
+		b.workers = append(b.workers, w)
+		go w.run()
+	}
+	return b
+}
+
+func (b *Bus) Publish(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	for _, w := range b.workers {
+		w.offer(item{event: e})
+	}
+}
+
+// Flush waits until every sink has written what was published before it,
+// or until flushTimeout.
+func (b *Bus) Flush() {
+	var wg sync.WaitGroup
+	for _, w := range b.workers {
+		done := make(chan struct{})
+		w.offer(item{flushed: done})
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case <-done:
+			case <-time.After(flushTimeout):
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Close flushes and stops every sink, waiting up to closeTimeout for
+// them to close. Events published after it are dropped.
+func (b *Bus) Close() {
+	b.Flush()
+	for _, w := range b.workers {
+		w.closing.Lock()
+		if !w.closed {
+			w.closed = true
+			close(w.queue)
+		}
+		w.closing.Unlock()
+	}
+	deadline := time.After(closeTimeout)
+	for _, w := range b.workers {
+		select {
+		case <-w.done:
+		case <-deadline:
+			return
+		}
+	}
+}
+
+func (b *Bus) Stats() []SinkStats {
+	var stats []SinkStats
+	for _, w := range b.workers {
+		stats = append(stats, SinkStats{
+			Name:      w.sink.Name(),
+			Delivered: w.delivered.Load(),
+			Dropped:   w.dropped.Load(),
+			Failed:    w.failed.Load(),
+			Queued:    w.queued(),
+		})
+	}
+	return stats
+}
+
+func (w *worker) queued() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.queue) + len(w.backlog)
+}
+
+var (
+	defaultOnce sync.Once
+	current     atomic.Pointer[Bus]
+)
+
+// active is the process bus. Until SetBus is called it only writes the
+// session files under logs/, which the CLI and the model read.
+func active() *Bus {
+	defaultOnce.Do(func() {
+		if current.Load() == nil {
+			current.Store(NewBus(0, NewSessionFiles()))
+		}
+	})
+	return current.Load()
+}
+
+// SetBus makes b the process bus; call it at startup. The bus it
+// replaces writes out what it still holds and is closed.
+func SetBus(b *Bus) {
+	defaultOnce.Do(func() {})
+	if old := current.Swap(b); old != nil {
+		old.Close()
+	}
+}
+
+// Close closes the process bus, writing out what its sinks still hold;
+// call it as the process stops.
+func Close() { active().Close() }
+
+// Publish sends an event to every sink of the process bus without
+// waiting on any of them for longer than a moment. Only lossy sinks lose
+// events to a full queue; lossless ones keep a backlog.
+func Publish(e Event) { active().Publish(e) }
+
+// Flush waits for the process bus to write out what was published.
+func Flush() { active().Flush() }
+
+// Stats reports how each sink of the process bus is keeping up.
+func Stats() []SinkStats { return active().Stats() }
@@ -0,0 +1,52 @@
+package events
+
+import "time"
+
+type Kind string
+
+const (
+	KindAuth         Kind = "auth"
+	KindSessionStart Kind = "session_start"
+	KindCommand      Kind = "command"
+	KindDetection    Kind = "detection"
+	KindResponse     Kind = "response"
+	KindArtifact     Kind = "artifact"
+	KindSessionEnd   Kind = "session_end"
+)
+
+// Event is one thing the honeypot saw or did. Data is the record for the
+// kind: a logger.CommandEvent, a detector.DetectionEvent or ResponseEvent,
+// the analyzer.SessionReport for a session's end, or one of the types
+// below. It is kept as the producer's own type so sinks write exactly what
+// the log files have always held.
+type Event struct {
+	Kind       Kind      `json:"kind"`
+	Time       time.Time `json:"time"`
+	SessionID  string    `json:"session_id,omitempty"`
+	RemoteAddr string    `json:"remote_addr,omitempty"`
+	Data       any       `json:"data"`
+}
+
+// Auth is one login attempt. Attempts come before a session exists, so
+// their events have no session id.
+type Auth struct {
+	User     string `json:"user"`
+	Password string `json:"password,omitempty"`
+	Method   string `json:"method"`
+	Client   string `json:"client"`
+	Accepted bool   `json:"accepted"`
+}
+
+type SessionStart struct {
+	User   string `json:"user"`
+	Client string `json:"client"`
+}
+
+// Artifact is a file an attacker brought into or left in the container.
+type Artifact struct {
+	Timestamp string `json:"timestamp"`
+	Path      string `json:"path"`
+	SHA256    string `json:"sha256"`
+	Size      int64  `json:"size"`
+	Source    string `json:"source,omitempty"`
+}
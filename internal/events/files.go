@@ -0,0 +1,297 @@
+package events
+
+import (
+	"GradGuard/internal/evidence"
+	"GradGuard/internal/logstore"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SessionFiles writes the per-session files the CLI and the model read,
+// in the day partition logstore gives them: commands, detections and
+// captured artifacts one JSON object a line, each chained by hash to the
+// line before, and the report carried by session_end indented, followed
+// by the signed manifest of those and the session's recording.
+type SessionFiles struct {
+	chains map[string]chainTail
+}
+
+// chainTail is where a log's hash chain has got to.
+type chainTail struct {
+	seq  int
+	head string
+}
+
+func NewSessionFiles() *SessionFiles {
+	return &SessionFiles{chains: map[string]chainTail{}}
+}
+
+func (s *SessionFiles) Name() string { return "files" }
+
+// Lossless marks the session files as a sink never dropped from: a gap
+// in a hash chain could not be told from tampering.
+func (s *SessionFiles) Lossless() {}
+
+func (s *SessionFiles) Write(batch []Event) error {
+	appends := map[string]*bytes.Buffer{}
+	var order []string
+	var errs []error
+	flush := func() {
+		for _, path := range order {
+			errs = append(errs, appendFile(path, appends[path].Bytes()))
+		}
+		appends, order = map[string]*bytes.Buffer{}, nil
+	}
+	for _, e := range batch {
+		if e.SessionID == "" {
+			continue
+		}
+		switch e.Kind {
+		case KindCommand, KindDetection, KindArtifact:
+			kind := logstore.Commands
+			switch e.Kind {
+			case KindDetection:
+				kind = logstore.Detections
+			case KindArtifact:
+				kind = logstore.Artifacts
+			}
+			path := logstore.Path(kind, e.SessionID, e.Time)
+			line, err := s.seal(path, kind, e)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			buf, ok := appends[path]
+			if !ok {
+				buf = &bytes.Buffer{}
+				appends[path] = buf
+				order = append(order, path)
+			}
+			buf.Write(line)
+			buf.WriteByte('\n')
+		case KindSessionEnd:
+			// The manifest pins the logs as they are, so everything
+			// before the report goes to disk first.
+			flush()
+			errs = append(errs, s.writeReport(e))
+		}
+	}
+	flush()
+	return errors.Join(errs...)
+}
+
+func (s *SessionFiles) Close() error { return nil }
+
+// seal encodes the event's record and links it into its log's chain,
+// picking the chain up from the log, live or archived, if this process
+// has not yet written to it.
+func (s *SessionFiles) seal(path string, kind logstore.Kind, e Event) ([]byte, error) {
+	content, err := json.Marshal(e.Data)
+	if err != nil {
+		return nil, err
+	}
+	tail, ok := s.chains[path]
+	if !ok {
+		data, _ := logstore.Read(kind, e.SessionID)
+		tail.seq, tail.head = evidence.Tail(e.SessionID, string(kind), data)
+	}
+	line, hash, err := evidence.Seal(content, tail.seq+1, tail.head)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", summary(e), err)
+	}
+	s.chains[path] = chainTail{seq: tail.seq + 1, head: hash}
+	return line, nil
+}
+
+func (s *SessionFiles) writeReport(e Event) error {
+	data, err := json.MarshalIndent(e.Data, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	path := logstore.Path(logstore.Reports, e.SessionID, e.Time)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return err
+	}
+	return s.writeManifest(e, data)
+}
+
+// writeManifest signs what the session's logs hold now that its report
+// is written. Lines logged after it are still chained; verify counts them
+// as unsigned.
+func (s *SessionFiles) writeManifest(e Event, report []byte) error {
+	files := map[logstore.Kind][]byte{logstore.Reports: report}
+	for _, kind := range evidence.Chained {
+		path := logstore.Path(kind, e.SessionID, e.Time)
+		if data, err := os.ReadFile(path); err == nil {
+			files[kind] = data
+		}
+		delete(s.chains, path)
+	}
+	for _, kind := range evidence.Pinned {
+		if kind == logstore.Reports {
+			continue
+		}
+		if data, err := os.ReadFile(logstore.Path(kind, e.SessionID, e.Time)); err == nil {
+			files[kind] = data
+		}
+	}
+	key, err := evidence.SigningKey()
+	if err != nil {
+		return err
+	}
+	m := evidence.NewManifest(e.SessionID, files)
+	m.Sign(key)
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := logstore.Path(logstore.Manifests, e.SessionID, e.Time)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+func appendFile(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// JSONLFile writes every event, envelope and all, to one JSON-lines file
+// that is rotated once it reaches maxBytes, keeping keep old files as
+// path.1 (newest) to path.<keep>.
+type JSONLFile struct {
+	path     string
+	maxBytes int64
+	keep     int
+	f        *os.File
+	size     int64
+}
+
+func NewJSONLFile(path string, maxBytes int64, keep int) *JSONLFile {
+	return &JSONLFile{path: path, maxBytes: maxBytes, keep: keep}
+}
+
+func (j *JSONLFile) Name() string { return "jsonl:" + j.path }
+
+func (j *JSONLFile) Write(batch []Event) error {
+	data, err := encodeLines(batch)
+	if err != nil {
+		return err
+	}
+	if j.f == nil {
+		if err := j.open(); err != nil {
+			return err
+		}
+	}
+	if j.maxBytes > 0 && j.size > 0 && j.size+int64(len(data)) > j.maxBytes {
+		if err := j.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := j.f.Write(data)
+	j.size += int64(n)
+	return err
+}
+
+func (j *JSONLFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(j.path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(j.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	j.f, j.size = f, info.Size()
+	return nil
+}
+
+func (j *JSONLFile) rotate() error {
+	j.f.Close()
+	j.f = nil
+	if j.keep < 1 {
+		if err := os.Remove(j.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return j.open()
+	}
+	os.Remove(fmt.Sprintf("%s.%d", j.path, j.keep))
+	for i := j.keep - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", j.path, i), fmt.Sprintf("%s.%d", j.path, i+1))
+	}
+	if err := os.Rename(j.path, j.path+".1"); err != nil {
+		return err
+	}
+	return j.open()
+}
+
+func (j *JSONLFile) Close() error {
+	if j.f == nil {
+		return nil
+	}
+	return j.f.Close()
+}
+
+func encodeLines(batch []Event) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, e := range batch {
+		if err := enc.Encode(e); err != nil {
+			return nil, fmt.Errorf("%s event: %w", e.Kind, err)
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// Stdout writes every event as a JSON line to standard output.
+type Stdout struct{}
+
+func (Stdout) Name() string { return "stdout" }
+
+func (Stdout) Write(batch []Event) error {
+	data, err := encodeLines(batch)
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(data)
+	return err
+}
+
+func (Stdout) Close() error { return nil }
+
+// summary is a one-line description of an event for sinks that want
+// something shorter than the whole record.
+func summary(e Event) string {
+	parts := []string{string(e.Kind)}
+	if e.SessionID != "" {
+		parts = append(parts, "session="+e.SessionID)
+	}
+	if e.RemoteAddr != "" {
+		parts = append(parts, "ip="+e.RemoteAddr)
+	}
+	return strings.Join(parts, " ")
+}
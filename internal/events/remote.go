@@ -0,0 +1,148 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/syslog"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Syslog sends each event as a JSON message. Detections are logged at
+// warning, everything else at info. An empty network means the local
+// syslog daemon.
+type Syslog struct {
+	network, addr, tag string
+	w                  *syslog.Writer
+}
+
+func NewSyslog(network, addr, tag string) *Syslog {
+	if tag == "" {
+		tag = "gradguard"
+	}
+	return &Syslog{network: network, addr: addr, tag: tag}
+}
+
+func (s *Syslog) Name() string { return "syslog" }
+
+func (s *Syslog) Write(batch []Event) error {
+	if s.w == nil {
+		w, err := syslog.Dial(s.network, s.addr, syslog.LOG_INFO|syslog.LOG_DAEMON, s.tag)
+		if err != nil {
+			return err
+		}
+		s.w = w
+	}
+	for _, e := range batch {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return fmt.Errorf("%s: %w", summary(e), err)
+		}
+		if e.Kind == KindDetection {
+			err = s.w.Warning(string(data))
+		} else {
+			err = s.w.Info(string(data))
+		}
+		if err != nil {
+			// Dial again next time; the daemon may have restarted.
+			s.w.Close()
+			s.w = nil
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Syslog) Close() error {
+	if s.w == nil {
+		return nil
+	}
+	return s.w.Close()
+}
+
+// Webhook POSTs each batch as a JSON array of events.
+type Webhook struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+func NewWebhook(url string, headers map[string]string, timeout time.Duration) *Webhook {
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	return &Webhook{url: url, headers: headers, client: &http.Client{Timeout: timeout}}
+}
+
+func (w *Webhook) Name() string { return "webhook:" + w.url }
+
+func (w *Webhook) Write(batch []Event) error {
+	data, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range w.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s", resp.Status)
+	}
+	return nil
+}
+
+func (w *Webhook) Close() error { return nil }
+
+// UnixSocket streams events as JSON lines to a listener on a Unix socket,
+// connecting on first use and again after a failed write.
+type UnixSocket struct {
+	path string
+	conn net.Conn
+}
+
+func NewUnixSocket(path string) *UnixSocket {
+	return &UnixSocket{path: path}
+}
+
+func (u *UnixSocket) Name() string { return "unix:" + u.path }
+
+func (u *UnixSocket) Write(batch []Event) error {
+	data, err := encodeLines(batch)
+	if err != nil {
+		return err
+	}
+	if u.conn == nil {
+		conn, err := net.DialTimeout("unix", u.path, 2*time.Second)
+		if err != nil {
+			return err
+		}
+		u.conn = conn
+	}
+	u.conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	if _, err := u.conn.Write(data); err != nil {
+		u.conn.Close()
+		u.conn = nil
+		return err
+	}
+	return nil
+}
+
+func (u *UnixSocket) Close() error {
+	if u.conn == nil {
+		return nil
+	}
+	return u.conn.Close()
+}
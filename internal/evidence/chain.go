@@ -0,0 +1,155 @@
+// Package evidence makes session logs tamper-evident: every line of a
+// session's command, detection and artifact logs is chained to the one
+// before it by hash, and a manifest signed with a local ed25519 key pins
+// the end of each chain, the report and the recording when the session
+// closes.
+package evidence
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Chain is the link a log line carries: its place in the log, the hash
+// of the line before and its own hash over that and its content.
+type Chain struct {
+	Seq  int    `json:"seq"`
+	Prev string `json:"prev"`
+	Hash string `json:"hash"`
+}
+
+const chainKey = `"chain":`
+
+// Genesis is the hash a session log's chain starts from. It names the
+// session and the log, so a chain cannot be passed off as another's.
+func Genesis(sessionID, log string) string {
+	sum := sha256.Sum256([]byte("gradguard/" + log + "/" + sessionID))
+	return hex.EncodeToString(sum[:])
+}
+
+// Link is the hash of a line with content following prev.
+func Link(prev string, content []byte) string {
+	h := sha256.New()
+	h.Write([]byte(prev))
+	h.Write(content)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Seal adds the chain to content, a JSON object, as its last field and
+// returns the line and its hash.
+func Seal(content []byte, seq int, prev string) ([]byte, string, error) {
+	content = bytes.TrimSpace(content)
+	if len(content) < 2 || content[0] != '{' || content[len(content)-1] != '}' {
+		return nil, "", fmt.Errorf("only JSON objects can be chained")
+	}
+	c := Chain{Seq: seq, Prev: prev, Hash: Link(prev, content)}
+	link, _ := json.Marshal(c)
+
+	var line bytes.Buffer
+	line.Write(content[:len(content)-1])
+	if len(content) > 2 {
+		line.WriteByte(',')
+	}
+	line.WriteString(chainKey)
+	line.Write(link)
+	line.WriteByte('}')
+	return line.Bytes(), c.Hash, nil
+}
+
+// Unseal splits a sealed line back into the content that was hashed and
+// its chain.
+func Unseal(line []byte) ([]byte, Chain, bool) {
+	line = bytes.TrimSpace(line)
+	i := bytes.LastIndex(line, []byte(chainKey))
+	if i < 1 || line[len(line)-1] != '}' {
+		return nil, Chain{}, false
+	}
+	var c Chain
+	if err := json.Unmarshal(line[i+len(chainKey):len(line)-1], &c); err != nil || c.Hash == "" {
+		return nil, Chain{}, false
+	}
+	// Inside a string the key's quotes would be escaped, so this is the
+	// field Seal added.
+	var content []byte
+	switch line[i-1] {
+	case ',':
+		content = append(append([]byte{}, line[:i-1]...), '}')
+	case '{':
+		content = []byte("{}")
+	default:
+		return nil, Chain{}, false
+	}
+	return content, c, true
+}
+
+// Break is where a chain stops holding.
+type Break struct {
+	Line   int    `json:"line"`
+	Reason string `json:"reason"`
+}
+
+// ChainResult is what walking one log's chain found.
+type ChainResult struct {
+	Lines  int    `json:"lines"`
+	Head   string `json:"head"`
+	Broken *Break `json:"broken,omitempty"`
+	// heads[i] is the hash after line i+1, so a manifest's head can be
+	// checked against the chain at the length it was signed at.
+	heads []string
+}
+
+// HeadAt is the chain's hash after n lines.
+func (r ChainResult) HeadAt(n int) (string, bool) {
+	if n < 1 || n > len(r.heads) {
+		return "", false
+	}
+	return r.heads[n-1], true
+}
+
+// VerifyChain walks a session log line by line from the genesis hash and
+// reports the first line that does not follow from the one before.
+func VerifyChain(sessionID, log string, data []byte) ChainResult {
+	result := ChainResult{Head: Genesis(sessionID, log)}
+	n := 0
+	for _, raw := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(raw) == "" {
+			continue
+		}
+		n++
+		content, c, ok := Unseal([]byte(raw))
+		switch {
+		case !ok:
+			result.Broken = &Break{Line: n, Reason: "line carries no chain link"}
+		case c.Seq != n:
+			result.Broken = &Break{Line: n, Reason: fmt.Sprintf("sequence %d where %d was expected; a line was removed or reordered", c.Seq, n)}
+		case c.Prev != result.Head:
+			result.Broken = &Break{Line: n, Reason: "does not follow from the line before it"}
+		case Link(c.Prev, content) != c.Hash:
+			result.Broken = &Break{Line: n, Reason: "content does not match its hash; the line was edited"}
+		}
+		if result.Broken != nil {
+			return result
+		}
+		result.Head = c.Hash
+		result.Lines = n
+		result.heads = append(result.heads, c.Hash)
+	}
+	return result
+}
+
+// Tail is where a log's chain currently ends, so appending can carry on
+// from a file written before a restart. A log without chained lines
+// starts from its genesis.
+func Tail(sessionID, log string, data []byte) (seq int, head string) {
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		if _, c, ok := Unseal([]byte(lines[i])); ok {
+			return c.Seq, c.Hash
+		}
+	}
+	return 0, Genesis(sessionID, log)
+}
@@ -0,0 +1,157 @@
+package evidence
+
+import (
+	"GradGuard/internal/logstore"
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSession = "test-1700000000000000000"
+
+// chained seals n command lines the way the session files write them.
+func chained(t *testing.T, n int) []string {
+	t.Helper()
+	seq, head := Tail(testSession, string(logstore.Commands), nil)
+	var lines []string
+	for i := 1; i <= n; i++ {
+		line, hash, err := Seal([]byte(fmt.Sprintf(`{"command":"cmd %d","command_index":%d}`, i, i)), seq+1, head)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, string(line))
+		seq, head = seq+1, hash
+	}
+	return lines
+}
+
+func joined(lines []string) []byte {
+	return []byte(strings.Join(lines, "\n") + "\n")
+}
+
+func TestSealRoundTrip(t *testing.T) {
+	for _, content := range []string{`{"command":"id","n":1}`, `{}`} {
+		line, hash, err := Seal([]byte(content), 1, "prev")
+		if err != nil {
+			t.Fatalf("%s: %v", content, err)
+		}
+		got, c, ok := Unseal(line)
+		if !ok {
+			t.Fatalf("%s: sealed line does not unseal: %s", content, line)
+		}
+		if string(got) != content {
+			t.Errorf("unsealed %s, want %s", got, content)
+		}
+		if c.Seq != 1 || c.Prev != "prev" || c.Hash != hash || hash != Link("prev", []byte(content)) {
+			t.Errorf("%s: chain %+v, hash %s", content, c, hash)
+		}
+	}
+	if _, _, err := Seal([]byte(`["not an object"]`), 1, "prev"); err == nil {
+		t.Error("sealed a JSON array")
+	}
+	if _, _, ok := Unseal([]byte(`{"command":"id"}`)); ok {
+		t.Error("unsealed a line without a chain")
+	}
+}
+
+func TestVerifyChain(t *testing.T) {
+	lines := chained(t, 4)
+	edited := append([]string{}, lines...)
+	edited[1] = strings.Replace(edited[1], "cmd 2", "cmd X", 1)
+	removed := append(append([]string{}, lines[:1]...), lines[2:]...)
+	reordered := []string{lines[0], lines[2], lines[1], lines[3]}
+
+	for _, tc := range []struct {
+		name   string
+		lines  []string
+		broken int
+		reason string
+	}{
+		{"intact", lines, 0, ""},
+		{"edited", edited, 2, "edited"},
+		{"removed", removed, 2, "removed or reordered"},
+		{"reordered", reordered, 2, "removed or reordered"},
+	} {
+		r := VerifyChain(testSession, string(logstore.Commands), joined(tc.lines))
+		switch {
+		case tc.broken == 0 && r.Broken != nil:
+			t.Errorf("%s: broken at line %d: %s", tc.name, r.Broken.Line, r.Broken.Reason)
+		case tc.broken == 0 && r.Lines != len(lines):
+			t.Errorf("%s: %d lines, want %d", tc.name, r.Lines, len(lines))
+		case tc.broken != 0 && r.Broken == nil:
+			t.Errorf("%s: chain holds", tc.name)
+		case tc.broken != 0 && (r.Broken.Line != tc.broken || !strings.Contains(r.Broken.Reason, tc.reason)):
+			t.Errorf("%s: broken at line %d: %s; want line %d, %s", tc.name, r.Broken.Line, r.Broken.Reason, tc.broken, tc.reason)
+		}
+	}
+
+	// A chain from another session's genesis does not verify as this one.
+	if r := VerifyChain("other-1700000000000000001", string(logstore.Commands), joined(lines)); r.Broken == nil {
+		t.Error("chain verified under another session")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	pub, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := chained(t, 4)
+	signedLines := joined(lines)
+	report := []byte(`{"session_id":"` + testSession + `"}` + "\n")
+
+	write := func(k logstore.Kind, data []byte) {
+		t.Helper()
+		path := logstore.Path(k, testSession, time.Time{})
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tc := range []struct {
+		name     string
+		commands []byte
+		want     string
+	}{
+		{"intact", signedLines, ""},
+		{"line appended after signing", joined(append(lines, chained(t, 5)[4])), ""},
+		{"edited", []byte(strings.Replace(string(signedLines), "cmd 3", "cmd X", 1)), "line 3"},
+		{"removed", joined(append(append([]string{}, lines[:2]...), lines[3:]...)), "line 3"},
+		{"reordered", joined([]string{lines[0], lines[1], lines[3], lines[2]}), "line 3"},
+		{"last line removed", joined(lines[:3]), "line 4: missing"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Chdir(t.TempDir())
+			m := NewManifest(testSession, map[logstore.Kind][]byte{
+				logstore.Commands: signedLines,
+				logstore.Reports:  report,
+			})
+			m.Sign(key)
+			data, err := json.Marshal(m)
+			if err != nil {
+				t.Fatal(err)
+			}
+			write(logstore.Manifests, data)
+			write(logstore.Reports, report)
+			write(logstore.Commands, tc.commands)
+
+			r := Verify(testSession, pub)
+			switch {
+			case tc.want == "" && !r.OK():
+				t.Errorf("verify failed: %s", r.FirstBroken)
+			case tc.want != "" && r.OK():
+				t.Error("verify passed a tampered log")
+			case tc.want != "" && !strings.Contains(r.FirstBroken, tc.want):
+				t.Errorf("verify found %q, want %q", r.FirstBroken, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package evidence
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// DefaultKeyPath is where the signing key is kept unless the config says
+// otherwise; its public half is written next to it with a .pub suffix.
+const DefaultKeyPath = "config/evidence_ed25519"
+
+var (
+	keyMu   sync.Mutex
+	keyPath = DefaultKeyPath
+	key     ed25519.PrivateKey
+)
+
+// SetKeyPath picks the signing key file; call it at startup.
+func SetKeyPath(path string) {
+	keyMu.Lock()
+	defer keyMu.Unlock()
+	if path != keyPath {
+		keyPath, key = path, nil
+	}
+}
+
+// SigningKey loads the signing key, generating it on first use.
+func SigningKey() (ed25519.PrivateKey, error) {
+	keyMu.Lock()
+	defer keyMu.Unlock()
+	if key != nil {
+		return key, nil
+	}
+	k, err := loadKey(keyPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		k, err = createKey(keyPath)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("evidence key %s: %w", keyPath, err)
+	}
+	key = k
+	return k, nil
+}
+
+// PublicKey is the public half of the local signing key, read from the
+// key file without creating one.
+func PublicKey() (ed25519.PublicKey, error) {
+	keyMu.Lock()
+	path := keyPath
+	keyMu.Unlock()
+	if k, err := loadKey(path); err == nil {
+		return k.Public().(ed25519.PublicKey), nil
+	}
+	data, err := os.ReadFile(path + ".pub")
+	if err != nil {
+		return nil, fmt.Errorf("evidence key %s: %w", path, err)
+	}
+	return parsePublic(data)
+}
+
+// KeyID is a short fingerprint of a public key.
+func KeyID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:8])
+}
+
+// MarshalPublic encodes a public key as the PEM the .pub file holds.
+func MarshalPublic(pub ed25519.PublicKey) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
+
+func loadKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, fmt.Errorf("not a PEM private key")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	k, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("not an ed25519 key")
+	}
+	return k, nil
+}
+
+func parsePublic(data []byte) (ed25519.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, fmt.Errorf("not a PEM public key")
+	}
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	pub, ok := parsed.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("not an ed25519 key")
+	}
+	return pub, nil
+}
+
+func createKey(path string) (ed25519.PrivateKey, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	privDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return nil, err
+	}
+	pubPEM, err := MarshalPublic(pub)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	// O_EXCL so two processes starting together cannot each write a key.
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if errors.Is(err, fs.ErrExist) {
+		return loadKey(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := pem.Encode(f, &pem.Block{Type: "PRIVATE KEY", Bytes: privDER}); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path+".pub", pubPEM, 0644); err != nil {
+		return nil, err
+	}
+	return priv, nil
+}
@@ -0,0 +1,225 @@
+package evidence
+
+import (
+	"GradGuard/internal/logstore"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"time"
+)
+
+const manifestVersion = 1
+
+// Chained are the logs whose lines carry a hash chain; Pinned are
+// written once and pinned by their digest alone.
+var (
+	Chained = []logstore.Kind{logstore.Commands, logstore.Detections, logstore.Artifacts}
+	Pinned  = []logstore.Kind{logstore.Reports, logstore.Recordings}
+)
+
+func signed() []logstore.Kind {
+	return append(append([]logstore.Kind{}, Chained...), Pinned...)
+}
+
+// FileDigest pins one session file as it was when the manifest was
+// signed. Lines and Head are where a chained log's chain ended.
+type FileDigest struct {
+	Log    logstore.Kind `json:"log"`
+	SHA256 string        `json:"sha256"`
+	Bytes  int           `json:"bytes"`
+	Lines  int           `json:"lines,omitempty"`
+	Head   string        `json:"head,omitempty"`
+}
+
+// Manifest is the signed record of a session's logs at its close.
+type Manifest struct {
+	Version   int          `json:"version"`
+	SessionID string       `json:"session_id"`
+	Signed    string       `json:"signed"`
+	Files     []FileDigest `json:"files"`
+	KeyID     string       `json:"key_id"`
+	Signature string       `json:"signature"`
+}
+
+// NewManifest digests the session files given, keyed by kind.
+func NewManifest(sessionID string, files map[logstore.Kind][]byte) Manifest {
+	m := Manifest{
+		Version:   manifestVersion,
+		SessionID: sessionID,
+		Signed:    time.Now().UTC().Format(time.RFC3339),
+	}
+	for _, k := range signed() {
+		data, ok := files[k]
+		if !ok {
+			continue
+		}
+		sum := sha256.Sum256(data)
+		d := FileDigest{Log: k, SHA256: hex.EncodeToString(sum[:]), Bytes: len(data)}
+		if isChained(k) {
+			r := VerifyChain(sessionID, string(k), data)
+			d.Lines, d.Head = r.Lines, r.Head
+		}
+		m.Files = append(m.Files, d)
+	}
+	return m
+}
+
+func isChained(k logstore.Kind) bool {
+	for _, c := range Chained {
+		if c == k {
+			return true
+		}
+	}
+	return false
+}
+
+// payload is what the signature covers: the manifest without it.
+func (m Manifest) payload() []byte {
+	m.Signature = ""
+	data, _ := json.Marshal(m)
+	return data
+}
+
+func (m *Manifest) Sign(key ed25519.PrivateKey) {
+	m.KeyID = KeyID(key.Public().(ed25519.PublicKey))
+	m.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, m.payload()))
+}
+
+// CheckSignature checks the manifest was signed by pub and not changed.
+func (m Manifest) CheckSignature(pub ed25519.PublicKey) error {
+	if m.KeyID != KeyID(pub) {
+		return fmt.Errorf("signed by key %s, not the local key %s", m.KeyID, KeyID(pub))
+	}
+	sig, err := base64.StdEncoding.DecodeString(m.Signature)
+	if err != nil || !ed25519.Verify(pub, m.payload(), sig) {
+		return fmt.Errorf("signature does not match the manifest")
+	}
+	return nil
+}
+
+// FileCheck is what was found of one session file.
+type FileCheck struct {
+	Log     logstore.Kind
+	Present bool
+	Chain   *ChainResult
+	// Unsigned counts chained lines written after the manifest.
+	Unsigned int
+	Problem  string
+}
+
+// Result is the verdict on a session's logs. FirstBroken describes the
+// first thing that does not hold, checking the chains in log order, then
+// the manifest.
+type Result struct {
+	SessionID   string
+	Files       []FileCheck
+	Manifest    *Manifest
+	SignatureOK bool
+	FirstBroken string
+}
+
+func (r Result) OK() bool { return r.FirstBroken == "" }
+
+func (r *Result) broken(format string, args ...any) {
+	if r.FirstBroken == "" {
+		r.FirstBroken = fmt.Sprintf(format, args...)
+	}
+}
+
+// Verify checks a session's logs, wherever they are kept, against their
+// hash chains and the manifest signed with pub.
+func Verify(sessionID string, pub ed25519.PublicKey) Result {
+	result := Result{SessionID: sessionID}
+	files := map[logstore.Kind][]byte{}
+	for _, k := range signed() {
+		check := FileCheck{Log: k}
+		data, err := logstore.Read(k, sessionID)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+		case err != nil:
+			check.Problem = err.Error()
+			result.broken("%s log unreadable: %v", k, err)
+		default:
+			check.Present = true
+			files[k] = data
+		}
+		if check.Present && isChained(k) {
+			chain := VerifyChain(sessionID, string(k), data)
+			check.Chain = &chain
+			if chain.Broken != nil {
+				check.Problem = fmt.Sprintf("line %d: %s", chain.Broken.Line, chain.Broken.Reason)
+				result.broken("%s log, line %d: %s", k, chain.Broken.Line, chain.Broken.Reason)
+			}
+		}
+		result.Files = append(result.Files, check)
+	}
+
+	data, err := logstore.Read(logstore.Manifests, sessionID)
+	if err != nil {
+		result.broken("no manifest: %v", err)
+		return result
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		result.broken("manifest unreadable: %v", err)
+		return result
+	}
+	result.Manifest = &m
+	if m.SessionID != sessionID {
+		result.broken("manifest is for session %s", m.SessionID)
+	}
+	if err := m.CheckSignature(pub); err != nil {
+		result.broken("manifest %v", err)
+	} else {
+		result.SignatureOK = true
+	}
+
+	signed := map[logstore.Kind]FileDigest{}
+	for _, d := range m.Files {
+		signed[d.Log] = d
+	}
+	for i := range result.Files {
+		check := &result.Files[i]
+		d, ok := signed[check.Log]
+		switch {
+		case !ok && check.Present:
+			check.Problem = "not in the manifest"
+			result.broken("%s log was created after the manifest was signed", check.Log)
+		case ok && !check.Present:
+			check.Problem = "missing"
+			result.broken("%s log is missing but the manifest lists it", check.Log)
+		case !ok:
+		case check.Chain != nil:
+			if check.Chain.Broken != nil {
+				continue
+			}
+			if check.Chain.Lines < d.Lines {
+				check.Problem = fmt.Sprintf("%d lines where the manifest signed %d", check.Chain.Lines, d.Lines)
+				result.broken("%s log, line %d: missing; the manifest signed %d lines", check.Log, check.Chain.Lines+1, d.Lines)
+				continue
+			}
+			head, ok := check.Chain.HeadAt(d.Lines)
+			if d.Lines == 0 {
+				head, ok = Genesis(sessionID, string(check.Log)), true
+			}
+			if !ok || head != d.Head {
+				check.Problem = fmt.Sprintf("chain at line %d differs from the signed head", d.Lines)
+				result.broken("%s log, line %d: chain differs from the head the manifest signed; the log was rewritten", check.Log, max(d.Lines, 1))
+				continue
+			}
+			check.Unsigned = check.Chain.Lines - d.Lines
+		default:
+			sum := sha256.Sum256(files[check.Log])
+			if hex.EncodeToString(sum[:]) != d.SHA256 {
+				check.Problem = "digest differs from the manifest"
+				result.broken("%s file changed after the manifest was signed", check.Log)
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,285 @@
+// Package export packages what the honeypot kept about sessions for use
+// outside it.
+package export
+
+import (
+	"GradGuard/internal/analyzer"
+	"GradGuard/internal/events"
+	"GradGuard/internal/evidence"
+	"GradGuard/internal/logstore"
+	"GradGuard/internal/store"
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"sort"
+	"strings"
+	"time"
+)
+
+// authWindow is how far before a session its address's login attempts
+// are taken to belong to it.
+const authWindow = time.Hour
+
+// Options is what the package needs from outside the session's logs.
+type Options struct {
+	// Intel is what was known of the attacker's address; it is written
+	// as given.
+	Intel any
+	// PublicKey checks the manifest; without it the package is built
+	// unverified.
+	PublicKey ed25519.PublicKey
+}
+
+// Summary is what went into a package.
+type Summary struct {
+	Root      string
+	Files     []string
+	Bytes     int64
+	Artifacts int
+	Verified  bool
+	// Problem is the first thing verification found broken.
+	Problem string
+	// Missing names the parts the session has nothing for.
+	Missing []string
+}
+
+// Evidence writes a gzipped tar of everything kept about the session to
+// w: its report, logs, recording, login attempts, captured artifacts,
+// intel, model output, signed manifest and a narrative with a UTC
+// timeline, all under a directory named for it. A log whose file is gone
+// but whose events the store still holds goes under from-store/, as it
+// cannot be checked against the manifest. The same stored data always
+// makes the same bytes.
+func Evidence(w io.Writer, sessionID string, opts Options) (Summary, error) {
+	p := &pkg{files: map[string][]byte{}, summary: Summary{Root: "evidence-" + sessionID}}
+
+	reportData, rebuilt, err := readLog(logstore.Reports, sessionID)
+	if err != nil {
+		return p.summary, fmt.Errorf("session %s has no report: %w", sessionID, err)
+	}
+	var report analyzer.SessionReport
+	if err := json.Unmarshal(reportData, &report); err != nil {
+		return p.summary, fmt.Errorf("report for %s: %w", sessionID, err)
+	}
+	n := &narrative{Report: report, Intel: opts.Intel}
+	p.addLog(n, "report.json", reportData, rebuilt)
+	logs := map[logstore.Kind][]byte{}
+
+	for _, part := range []struct {
+		kind logstore.Kind
+		name string
+	}{
+		{logstore.Commands, "commands.jsonl"},
+		{logstore.Detections, "detections.jsonl"},
+		{logstore.Artifacts, "artifacts.jsonl"},
+		{logstore.Recordings, "recording.cast"},
+		{logstore.Manifests, "manifest.json"},
+	} {
+		data, rebuilt, err := readLog(part.kind, sessionID)
+		if err != nil {
+			p.summary.Missing = append(p.summary.Missing, string(part.kind))
+			continue
+		}
+		p.addLog(n, part.name, data, rebuilt)
+		logs[part.kind] = data
+	}
+	n.commands(logs[logstore.Commands])
+	n.detections(logs[logstore.Detections])
+	for _, a := range n.artifacts(logs[logstore.Artifacts]) {
+		data, err := logstore.ReadBlob(sessionID, a.SHA256)
+		switch {
+		case err != nil:
+			a.Status = "content not kept"
+		case digest(data) != a.SHA256:
+			a.Status = "content does not match its hash"
+		default:
+			a.Status = "kept"
+			if _, dup := p.files["artifacts/"+a.SHA256]; !dup {
+				p.add("artifacts/"+a.SHA256, data)
+				p.summary.Artifacts++
+			}
+		}
+		n.Artifacts = append(n.Artifacts, a)
+	}
+
+	auth := authAttempts(report)
+	if auth == nil {
+		p.summary.Missing = append(p.summary.Missing, "auth")
+	} else {
+		p.add("auth.jsonl", auth)
+		n.auth(auth)
+	}
+
+	if opts.Intel != nil {
+		p.addJSON("intel.json", opts.Intel)
+	} else {
+		p.summary.Missing = append(p.summary.Missing, "intel")
+	}
+	p.addJSON("ml.json", struct {
+		Opinion    *analyzer.MLOpinion     `json:"opinion"`
+		Assessment analyzer.Assessment     `json:"assessment"`
+		Operator   *analyzer.OperatorClass `json:"operator,omitempty"`
+	}{report.ML, report.Assessment, report.Operator})
+
+	if opts.PublicKey != nil {
+		result := evidence.Verify(sessionID, opts.PublicKey)
+		p.addJSON("verification.json", result)
+		if pem, err := evidence.MarshalPublic(opts.PublicKey); err == nil {
+			p.add("signing-key.pub", pem)
+		}
+		p.summary.Verified, p.summary.Problem = result.OK(), result.FirstBroken
+		n.Integrity = &result
+	}
+	if len(n.Rebuilt) > 0 {
+		p.summary.Verified = false
+		if p.summary.Problem == "" {
+			p.summary.Problem = n.rebuilt()
+		}
+	}
+
+	n.Contents = append(p.names(), "SHA256SUMS", "narrative.html", "narrative.md")
+	sort.Strings(n.Contents)
+	p.add("narrative.md", n.markdown())
+	html, err := n.html()
+	if err != nil {
+		return p.summary, err
+	}
+	p.add("narrative.html", html)
+	p.add("SHA256SUMS", p.sums())
+
+	at, _ := time.Parse(time.RFC3339, report.EndTime)
+	return p.summary, p.write(w, at)
+}
+
+// readLog reads a session file as logged, chain and all, falling back to
+// the store for sessions whose files are gone; rebuilt says it did.
+func readLog(k logstore.Kind, id string) (data []byte, rebuilt bool, err error) {
+	data, err = logstore.Read(k, id)
+	if errors.Is(err, fs.ErrNotExist) && k != logstore.Recordings && k != logstore.Manifests {
+		data, err = store.SessionLog(k, id)
+		return data, err == nil, err
+	}
+	return data, false, err
+}
+
+// authAttempts are the stored login attempts from the session's address
+// in the hour before it started and while it ran, one event a line; nil
+// when the store is not kept.
+func authAttempts(report analyzer.SessionReport) []byte {
+	s := store.Shared()
+	if s == nil {
+		return nil
+	}
+	start, _ := time.Parse(time.RFC3339, report.StartTime)
+	end, _ := time.Parse(time.RFC3339, report.EndTime)
+	records, err := s.Find(store.Query{
+		Kinds: []events.Kind{events.KindAuth},
+		IP:    hostOf(report.RemoteAddr),
+		Since: start.Add(-authWindow),
+		// End times are to the second; attempts in its last second count.
+		Until: end.Add(time.Second),
+	})
+	if err != nil {
+		return nil
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, r := range records {
+		enc.Encode(r)
+	}
+	return buf.Bytes()
+}
+
+func hostOf(addr string) string {
+	if i := strings.LastIndexByte(addr, ':'); i > 0 {
+		return strings.Trim(addr[:i], "[]")
+	}
+	return addr
+}
+
+func digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// pkg is the package's files as they are gathered.
+type pkg struct {
+	files   map[string][]byte
+	summary Summary
+}
+
+func (p *pkg) add(name string, data []byte) {
+	p.files[name] = data
+}
+
+// addLog adds a session log under name, or under from-store/ when it was
+// rebuilt from the store rather than read as logged.
+func (p *pkg) addLog(n *narrative, name string, data []byte, rebuilt bool) {
+	if rebuilt {
+		name = "from-store/" + name
+		n.Rebuilt = append(n.Rebuilt, name)
+	}
+	p.add(name, data)
+}
+
+func (p *pkg) addJSON(name string, v any) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return
+	}
+	p.add(name, append(data, '\n'))
+}
+
+func (p *pkg) names() []string {
+	names := make([]string, 0, len(p.files))
+	for name := range p.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// sums lists every file's SHA-256 in the format sha256sum -c reads.
+func (p *pkg) sums() []byte {
+	var buf bytes.Buffer
+	for _, name := range p.names() {
+		fmt.Fprintf(&buf, "%s  %s\n", digest(p.files[name]), name)
+	}
+	return buf.Bytes()
+}
+
+// write tars the files in name order with a fixed mode and the session's
+// end as their time, and gzips them without a name or time of its own.
+func (p *pkg) write(w io.Writer, at time.Time) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	for _, name := range p.names() {
+		data := p.files[name]
+		hdr := &tar.Header{
+			Name:    p.summary.Root + "/" + name,
+			Mode:    0644,
+			Size:    int64(len(data)),
+			ModTime: at.UTC(),
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return err
+		}
+		p.summary.Files = append(p.summary.Files, name)
+		p.summary.Bytes += int64(len(data))
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
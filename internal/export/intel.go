@@ -0,0 +1,147 @@
+package export
+
+import (
+	"GradGuard/internal/analyzer"
+	"GradGuard/internal/attack"
+	"GradGuard/internal/events"
+	"GradGuard/internal/logstore"
+	"GradGuard/internal/store"
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Filter picks the sessions intel is shared from: those that started in
+// [Since, Until), either zero for no bound, with one of Verdicts. With no
+// verdicts every session but the clean ones is shared.
+type Filter struct {
+	Since    time.Time
+	Until    time.Time
+	Verdicts []string
+}
+
+func (f Filter) match(r analyzer.SessionReport, start time.Time) bool {
+	if !f.Since.IsZero() && start.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !start.Before(f.Until) {
+		return false
+	}
+	if len(f.Verdicts) == 0 {
+		return r.Verdict != analyzer.VerdictClean
+	}
+	for _, v := range f.Verdicts {
+		if v == r.Verdict {
+			return true
+		}
+	}
+	return false
+}
+
+// observed is what one session gives to share.
+type observed struct {
+	report     analyzer.SessionReport
+	start, end time.Time
+	ip         string
+	commands   []observedCommand
+	artifacts  []events.Artifact
+	techniques []attack.Tag
+}
+
+type observedCommand struct {
+	Timestamp  string       `json:"timestamp"`
+	Command    string       `json:"command"`
+	Category   string       `json:"category"`
+	Techniques []attack.Tag `json:"techniques"`
+}
+
+// collect reads the sessions the filter picks, oldest first. The
+// markers the shell logs around a session are not the attacker's
+// commands and are left out.
+func collect(f Filter) []observed {
+	var sessions []observed
+	store.WalkLogs(logstore.Reports, func(id string, data []byte) {
+		var r analyzer.SessionReport
+		if json.Unmarshal(data, &r) != nil || r.SessionID == "" {
+			return
+		}
+		start, err := time.Parse(time.RFC3339, r.StartTime)
+		if err != nil || !f.match(r, start) {
+			return
+		}
+		end, err := time.Parse(time.RFC3339, r.EndTime)
+		if err != nil {
+			end = start
+		}
+		o := observed{report: r, start: start.UTC(), end: end.UTC(), ip: hostOf(r.RemoteAddr)}
+
+		seen := map[attack.Tag]bool{}
+		tag := func(t attack.Tag) {
+			if t.Technique != "" && !seen[t] {
+				seen[t] = true
+				o.techniques = append(o.techniques, t)
+			}
+		}
+		for _, t := range r.Techniques {
+			tag(attack.Tag{Tactic: t.Tactic, Technique: t.Technique})
+		}
+		if data, err := store.SessionLog(logstore.Commands, id); err == nil {
+			each(data, func(c observedCommand) {
+				if c.Command == "" || strings.HasPrefix(c.Command, "[") {
+					return
+				}
+				o.commands = append(o.commands, c)
+				for _, t := range c.Techniques {
+					tag(t)
+				}
+			})
+		}
+		if data, err := store.SessionLog(logstore.Artifacts, id); err == nil {
+			each(data, func(a events.Artifact) { o.artifacts = append(o.artifacts, a) })
+		}
+		sessions = append(sessions, o)
+	})
+	sort.Slice(sessions, func(i, j int) bool {
+		if !sessions[i].start.Equal(sessions[j].start) {
+			return sessions[i].start.Before(sessions[j].start)
+		}
+		return sessions[i].report.SessionID < sessions[j].report.SessionID
+	})
+	return sessions
+}
+
+// byAddress groups sessions by attacker address, in the order each was
+// first seen.
+func byAddress(sessions []observed) (order []string, groups map[string][]observed) {
+	groups = map[string][]observed{}
+	for _, s := range sessions {
+		if _, ok := groups[s.ip]; !ok {
+			order = append(order, s.ip)
+		}
+		groups[s.ip] = append(groups[s.ip], s)
+	}
+	return order, groups
+}
+
+// namespace is this honeypot's UUID namespace. IDs are derived from what
+// they name, so exporting the same sessions twice gives the same IDs and
+// peers polling the feed see updates rather than duplicates.
+var namespace = [16]byte{0x6b, 0x1e, 0x52, 0x0c, 0x3f, 0x8d, 0x4a, 0x61, 0x9b, 0x27, 0xd4, 0x0e, 0x5a, 0x3c, 0x81, 0xf2}
+
+// uuid5 is an RFC 4122 name-based UUID.
+func uuid5(ns [16]byte, name string) string {
+	h := sha1.New()
+	h.Write(ns[:])
+	h.Write([]byte(name))
+	u := h.Sum(nil)[:16]
+	u[6] = u[6]&0x0f | 0x50
+	u[8] = u[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16])
+}
+
+func nameID(parts ...string) string {
+	return uuid5(namespace, strings.Join(parts, "|"))
+}
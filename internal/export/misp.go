@@ -0,0 +1,204 @@
+package export
+
+import (
+	"GradGuard/internal/attack"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strconv"
+	"time"
+)
+
+// MISP's enumerations, as its event JSON spells them.
+const (
+	mispThreatMedium    = "2"
+	mispAnalysisDone    = "2"
+	mispDistCommunity   = "1"
+	mispSightingSeen    = "0"
+	mispCategoryNetwork = "Network activity"
+)
+
+type mispEvent struct {
+	UUID          string          `json:"uuid"`
+	Info          string          `json:"info"`
+	Date          string          `json:"date"`
+	Timestamp     string          `json:"timestamp"`
+	ThreatLevelID string          `json:"threat_level_id"`
+	Analysis      string          `json:"analysis"`
+	Distribution  string          `json:"distribution"`
+	Published     bool            `json:"published"`
+	Orgc          mispOrg         `json:"Orgc"`
+	Tag           []mispTag       `json:"Tag"`
+	Attribute     []mispAttribute `json:"Attribute"`
+	Object        []mispObject    `json:"Object"`
+}
+
+type mispOrg struct {
+	Name string `json:"name"`
+}
+
+type mispTag struct {
+	Name string `json:"name"`
+}
+
+type mispAttribute struct {
+	UUID           string         `json:"uuid"`
+	Type           string         `json:"type"`
+	Category       string         `json:"category"`
+	ObjectRelation string         `json:"object_relation,omitempty"`
+	Value          string         `json:"value"`
+	ToIDS          bool           `json:"to_ids"`
+	Comment        string         `json:"comment,omitempty"`
+	Timestamp      string         `json:"timestamp"`
+	FirstSeen      string         `json:"first_seen,omitempty"`
+	LastSeen       string         `json:"last_seen,omitempty"`
+	Tag            []mispTag      `json:"Tag,omitempty"`
+	Sighting       []mispSighting `json:"Sighting,omitempty"`
+}
+
+type mispSighting struct {
+	UUID         string `json:"uuid"`
+	Type         string `json:"type"`
+	DateSighting string `json:"date_sighting"`
+	Source       string `json:"source"`
+}
+
+type mispObject struct {
+	UUID         string          `json:"uuid"`
+	Name         string          `json:"name"`
+	MetaCategory string          `json:"meta-category"`
+	Comment      string          `json:"comment"`
+	Timestamp    string          `json:"timestamp"`
+	FirstSeen    string          `json:"first_seen,omitempty"`
+	Attribute    []mispAttribute `json:"Attribute"`
+}
+
+func unix(t time.Time) string { return strconv.FormatInt(t.Unix(), 10) }
+
+// galaxyTag is the MISP galaxy cluster tag for an ATT&CK technique.
+func galaxyTag(technique string) mispTag {
+	return mispTag{Name: fmt.Sprintf(`misp-galaxy:mitre-attack-pattern="%s - %s"`, attack.TechniqueName(technique), technique)}
+}
+
+// MISP is the intel as one MISP event: an ip-src attribute per attacker
+// address with a sighting per session, a process object per command and
+// a file object per artifact, tagged with the TLP level and the ATT&CK
+// techniques seen.
+func MISP(o IntelOptions) ([]byte, error) {
+	sessions := collect(o.Filter)
+	e := mispEvent{
+		ThreatLevelID: mispThreatMedium,
+		Analysis:      mispAnalysisDone,
+		Distribution:  mispDistCommunity,
+		Orgc:          mispOrg{Name: "GradGuard"},
+		Tag:           []mispTag{{Name: "tlp:" + o.tlp()}},
+		Attribute:     []mispAttribute{},
+		Object:        []mispObject{},
+	}
+	ids := []string{"misp-event"}
+	first, last := catalogueTime, catalogueTime
+	if len(sessions) > 0 {
+		first, last = sessions[0].start, sessions[0].end
+	}
+	techniques := map[string]bool{}
+
+	order, groups := byAddress(sessions)
+	for _, ip := range order {
+		group := groups[ip]
+		attr := mispAttribute{
+			UUID:      nameID("misp-attribute", ip),
+			Type:      "ip-src",
+			Category:  mispCategoryNetwork,
+			Value:     ip,
+			ToIDS:     true,
+			Comment:   fmt.Sprintf("SSH honeypot attacker; sessions: %d", len(group)),
+			FirstSeen: group[0].start.Format(time.RFC3339),
+		}
+		seen := map[string]bool{}
+		var end time.Time
+		for _, s := range group {
+			ids = append(ids, s.report.SessionID)
+			if s.end.After(end) {
+				end = s.end
+			}
+			if s.end.After(last) {
+				last = s.end
+			}
+			attr.Sighting = append(attr.Sighting, mispSighting{
+				UUID:         nameID("misp-sighting", s.report.SessionID),
+				Type:         mispSightingSeen,
+				DateSighting: unix(s.end),
+				Source:       "gradguard",
+			})
+			for _, t := range s.techniques {
+				techniques[t.Technique] = true
+				if !seen[t.Technique] {
+					seen[t.Technique] = true
+					attr.Tag = append(attr.Tag, galaxyTag(t.Technique))
+				}
+			}
+			e.Object = append(e.Object, sessionObjects(s)...)
+		}
+		attr.LastSeen = end.Format(time.RFC3339)
+		attr.Timestamp = unix(end)
+		e.Attribute = append(e.Attribute, attr)
+	}
+	for _, t := range sortedKeys(techniques) {
+		e.Tag = append(e.Tag, galaxyTag(t))
+	}
+
+	e.UUID = nameID(ids...)
+	e.Date = first.Format("2006-01-02")
+	e.Timestamp = unix(last)
+	e.Info = fmt.Sprintf("GradGuard SSH honeypot; sessions: %d, addresses: %d", len(sessions), len(order))
+	if len(sessions) > 0 {
+		e.Info += fmt.Sprintf(", from %s to %s", first.Format("2006-01-02 15:04"), last.Format("2006-01-02 15:04 MST"))
+	}
+	data, err := json.MarshalIndent(map[string]any{"Event": e}, "", "  ")
+	return append(data, '\n'), err
+}
+
+// sessionObjects are the MISP objects of one session's commands and
+// artifacts.
+func sessionObjects(s observed) []mispObject {
+	var objects []mispObject
+	comment := fmt.Sprintf("Session %s from %s, %s", s.report.SessionID, s.ip, verdictLabel(s.report.Verdict))
+	attribute := func(id, typ, category, relation, value string, ids bool, at time.Time) mispAttribute {
+		return mispAttribute{
+			UUID: nameID("misp-attribute", id, relation), Type: typ, Category: category,
+			ObjectRelation: relation, Value: value, ToIDS: ids, Timestamp: unix(at),
+		}
+	}
+	for _, c := range s.commands {
+		at, err := time.Parse(time.RFC3339, c.Timestamp)
+		if err != nil {
+			at = s.start
+		}
+		id := nameID("process", s.report.SessionID, c.Timestamp, c.Command)
+		objects = append(objects, mispObject{
+			UUID: id, Name: "process", MetaCategory: "misc", Comment: comment,
+			Timestamp: unix(at), FirstSeen: at.UTC().Format(time.RFC3339),
+			Attribute: []mispAttribute{
+				attribute(id, "text", "Other", "command-line", c.Command, false, at),
+			},
+		})
+	}
+	for _, a := range s.artifacts {
+		at, err := time.Parse(time.RFC3339, a.Timestamp)
+		if err != nil {
+			at = s.end
+		}
+		id := nameID("file", s.report.SessionID, a.SHA256, a.Path)
+		attrs := []mispAttribute{
+			attribute(id, "sha256", "Payload delivery", "sha256", a.SHA256, true, at),
+			attribute(id, "filename", "Payload delivery", "filename", path.Base(a.Path), false, at),
+			attribute(id, "text", "Other", "path", path.Dir(a.Path), false, at),
+			attribute(id, "size-in-bytes", "Other", "size-in-bytes", strconv.FormatInt(a.Size, 10), false, at),
+		}
+		objects = append(objects, mispObject{
+			UUID: id, Name: "file", MetaCategory: "file", Comment: comment,
+			Timestamp: unix(at), Attribute: attrs,
+		})
+	}
+	return objects
+}
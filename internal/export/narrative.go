@@ -0,0 +1,318 @@
+package export
+
+import (
+	"GradGuard/internal/analyzer"
+	"GradGuard/internal/events"
+	"GradGuard/internal/evidence"
+	"GradGuard/internal/store"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"sort"
+	"strings"
+	"time"
+)
+
+// narrative is the human-readable account of a session, written as
+// Markdown and HTML from the same facts.
+type narrative struct {
+	Report    analyzer.SessionReport
+	Intel     any
+	Integrity *evidence.Result
+	// Rebuilt are the files put together from the event store because
+	// the logged ones are gone.
+	Rebuilt   []string
+	Timeline  []moment
+	Artifacts []artifact
+	Contents  []string
+}
+
+// moment is one line of the timeline.
+type moment struct {
+	At     time.Time
+	What   string
+	Detail string
+}
+
+type artifact struct {
+	events.Artifact
+	Status string
+}
+
+func (n *narrative) at(stamp, what, detail string) {
+	t, err := time.Parse(time.RFC3339, stamp)
+	if err != nil {
+		return
+	}
+	n.Timeline = append(n.Timeline, moment{At: t.UTC(), What: what, Detail: detail})
+}
+
+// each decodes every line of a JSON-lines log into a fresh v.
+func each[T any](data []byte, fn func(T)) {
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		var v T
+		if json.Unmarshal([]byte(line), &v) == nil {
+			fn(v)
+		}
+	}
+}
+
+func (n *narrative) commands(data []byte) {
+	each(data, func(c struct {
+		Timestamp      string `json:"timestamp"`
+		Command        string `json:"command"`
+		Category       string `json:"category"`
+		SuspicionScore int    `json:"suspicion_score"`
+	}) {
+		n.at(c.Timestamp, "command", fmt.Sprintf("%s [%s, score %d]", c.Command, c.Category, c.SuspicionScore))
+	})
+}
+
+func (n *narrative) detections(data []byte) {
+	each(data, func(d struct {
+		Timestamp     string `json:"timestamp"`
+		Signal        string `json:"signal"`
+		Confidence    string `json:"confidence"`
+		Details       string `json:"details"`
+		ResponseTaken string `json:"response_taken"`
+	}) {
+		detail := fmt.Sprintf("%s (%s): %s", d.Signal, d.Confidence, d.Details)
+		if d.ResponseTaken != "" {
+			detail += "; response: " + d.ResponseTaken
+		}
+		n.at(d.Timestamp, "detection", detail)
+	})
+}
+
+func (n *narrative) artifacts(data []byte) []artifact {
+	var list []artifact
+	each(data, func(a events.Artifact) {
+		n.at(a.Timestamp, "artifact", fmt.Sprintf("%s %s (%d bytes, sha256 %s)", a.Source, a.Path, a.Size, a.SHA256))
+		list = append(list, artifact{Artifact: a})
+	})
+	return list
+}
+
+func (n *narrative) auth(data []byte) {
+	each(data, func(r store.Record) {
+		var a events.Auth
+		json.Unmarshal(r.Data, &a)
+		outcome := "rejected"
+		if a.Accepted {
+			outcome = "accepted"
+		}
+		n.Timeline = append(n.Timeline, moment{
+			At:     r.Time.UTC(),
+			What:   "login",
+			Detail: fmt.Sprintf("%s %s login as %q from %s", outcome, a.Method, a.User, a.Client),
+		})
+	})
+}
+
+// sorted is the timeline in time order, opened and closed by the
+// session's start and end.
+func (n *narrative) sorted() []moment {
+	r := n.Report
+	all := append([]moment(nil), n.Timeline...)
+	if t, err := time.Parse(time.RFC3339, r.StartTime); err == nil {
+		all = append(all, moment{At: t.UTC(), What: "session", Detail: "started from " + r.RemoteAddr})
+	}
+	if t, err := time.Parse(time.RFC3339, r.EndTime); err == nil {
+		all = append(all, moment{At: t.UTC(), What: "session", Detail: fmt.Sprintf("ended after %d commands, verdict %s", r.TotalCommands, r.Verdict)})
+	}
+	sort.SliceStable(all, func(i, j int) bool { return all[i].At.Before(all[j].At) })
+	return all
+}
+
+func (n *narrative) integrity() string {
+	switch {
+	case n.Integrity == nil:
+		return "not checked: no public key"
+	case n.Integrity.OK():
+		return "VERIFIED: logs are as captured"
+	default:
+		return "TAMPERED: " + n.Integrity.FirstBroken
+	}
+}
+
+func (n *narrative) rebuilt() string {
+	return fmt.Sprintf("%s from the event store, unverifiable: the logged files are gone", strings.Join(n.Rebuilt, ", "))
+}
+
+// facts are the summary rows both forms open with.
+func (n *narrative) facts() [][2]string {
+	r := n.Report
+	facts := [][2]string{
+		{"Session", r.SessionID},
+		{"Remote address", r.RemoteAddr},
+		{"Started (UTC)", r.StartTime},
+		{"Ended (UTC)", r.EndTime},
+		{"Duration", (time.Duration(r.DurationSeconds) * time.Second).String()},
+		{"Commands", fmt.Sprint(r.TotalCommands)},
+		{"Verdict", r.Verdict},
+		{"Peak suspicion score", fmt.Sprint(r.PeakSuspicionScore)},
+		{"Integrity", n.integrity()},
+	}
+	if len(n.Rebuilt) > 0 {
+		facts = append(facts, [2]string{"Rebuilt", n.rebuilt()})
+	}
+	return facts
+}
+
+// intelRows lists the intel's fields by name, skipping empty ones.
+func (n *narrative) intelRows() [][2]string {
+	data, err := json.Marshal(n.Intel)
+	if err != nil {
+		return nil
+	}
+	var fields map[string]any
+	if json.Unmarshal(data, &fields) != nil {
+		return nil
+	}
+	var rows [][2]string
+	for k, v := range fields {
+		if v == nil || v == "" || v == false || v == 0.0 {
+			continue
+		}
+		rows = append(rows, [2]string{k, fmt.Sprint(v)})
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i][0] < rows[j][0] })
+	return rows
+}
+
+func (n *narrative) model() []string {
+	var lines []string
+	if ml := n.Report.ML; ml != nil {
+		lines = append(lines,
+			fmt.Sprintf("Fingerprinting probability %.2f", ml.FingerprintingProb),
+			"Intent "+ml.Intent,
+			fmt.Sprintf("Anomaly %v (score %.3f)", ml.Anomaly, ml.AnomalyScore))
+	}
+	if op := n.Report.Operator; op != nil {
+		lines = append(lines, fmt.Sprintf("Operator %s (%.0f%%): %s", op.Class, op.Confidence*100, op.Evidence))
+	}
+	return lines
+}
+
+func mdCell(s string) string {
+	s = strings.NewReplacer("|", `\|`, "\r", "", "\n", " ").Replace(s)
+	return strings.TrimSpace(s)
+}
+
+func (n *narrative) markdown() []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "# Evidence: session %s\n\n", n.Report.SessionID)
+	b.WriteString("| | |\n|---|---|\n")
+	for _, f := range n.facts() {
+		fmt.Fprintf(&b, "| %s | %s |\n", f[0], mdCell(f[1]))
+	}
+
+	if bs := n.Report.Assessment.Behaviours; len(bs) > 0 {
+		b.WriteString("\n## Assessment\n\n")
+		for _, bh := range bs {
+			fmt.Fprintf(&b, "- **%s** (%.0f%%, from %s): %s\n", bh.Label, bh.Confidence*100,
+				strings.Join(bh.Sources, ", "), mdCell(strings.Join(bh.Evidence, "; ")))
+		}
+	}
+	if lines := n.model(); len(lines) > 0 {
+		b.WriteString("\n## Model output\n\n")
+		for _, l := range lines {
+			fmt.Fprintf(&b, "- %s\n", mdCell(l))
+		}
+	}
+	if rows := n.intelRows(); len(rows) > 0 {
+		b.WriteString("\n## Source address\n\n| | |\n|---|---|\n")
+		for _, r := range rows {
+			fmt.Fprintf(&b, "| %s | %s |\n", r[0], mdCell(r[1]))
+		}
+	}
+
+	b.WriteString("\n## Timeline (UTC)\n\n| Time | Event | Detail |\n|---|---|---|\n")
+	for _, m := range n.sorted() {
+		fmt.Fprintf(&b, "| %s | %s | %s |\n", m.At.Format(time.RFC3339), m.What, mdCell(m.Detail))
+	}
+
+	if len(n.Artifacts) > 0 {
+		b.WriteString("\n## Artifacts\n\n| Path | Bytes | SHA-256 | |\n|---|---|---|---|\n")
+		for _, a := range n.Artifacts {
+			fmt.Fprintf(&b, "| %s | %d | `%s` | %s |\n", mdCell(a.Path), a.Size, a.SHA256, a.Status)
+		}
+	}
+
+	b.WriteString("\n## Contents\n\n")
+	for _, name := range n.Contents {
+		fmt.Fprintf(&b, "- `%s`\n", name)
+	}
+	b.WriteString("\nSHA256SUMS lists every file's digest; check it with `sha256sum -c SHA256SUMS`.\n")
+	return b.Bytes()
+}
+
+var page = template.Must(template.New("narrative").Funcs(template.FuncMap{
+	"mul100":       func(f float64) float64 { return f * 100 },
+	"join":         func(s []string) string { return strings.Join(s, ", ") },
+	"joinEvidence": func(s []string) string { return strings.Join(s, "; ") },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Evidence: session {{.Report.SessionID}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 1.5em; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; vertical-align: top; }
+td.mono, code { font-family: monospace; }
+</style>
+</head>
+<body>
+<h1>Evidence: session {{.Report.SessionID}}</h1>
+<table>
+{{range .Facts}}<tr><th>{{index . 0}}</th><td>{{index . 1}}</td></tr>
+{{end}}</table>
+{{with .Report.Assessment.Behaviours}}<h2>Assessment</h2>
+<ul>
+{{range .}}<li><strong>{{.Label}}</strong> ({{printf "%.0f" (mul100 .Confidence)}}%, from {{join .Sources}}): {{joinEvidence .Evidence}}</li>
+{{end}}</ul>
+{{end}}{{with .Model}}<h2>Model output</h2>
+<ul>
+{{range .}}<li>{{.}}</li>
+{{end}}</ul>
+{{end}}{{with .Intel}}<h2>Source address</h2>
+<table>
+{{range .}}<tr><th>{{index . 0}}</th><td>{{index . 1}}</td></tr>
+{{end}}</table>
+{{end}}<h2>Timeline (UTC)</h2>
+<table>
+<tr><th>Time</th><th>Event</th><th>Detail</th></tr>
+{{range .Timeline}}<tr><td class="mono">{{.At.Format "2006-01-02T15:04:05Z07:00"}}</td><td>{{.What}}</td><td class="mono">{{.Detail}}</td></tr>
+{{end}}</table>
+{{with .Artifacts}}<h2>Artifacts</h2>
+<table>
+<tr><th>Path</th><th>Bytes</th><th>SHA-256</th><th></th></tr>
+{{range .}}<tr><td class="mono">{{.Path}}</td><td>{{.Size}}</td><td class="mono">{{.SHA256}}</td><td>{{.Status}}</td></tr>
+{{end}}</table>
+{{end}}<h2>Contents</h2>
+<ul>
+{{range .Contents}}<li><code>{{.}}</code></li>
+{{end}}</ul>
+<p>SHA256SUMS lists every file's digest; check it with <code>sha256sum -c SHA256SUMS</code>.</p>
+</body>
+</html>
+`))
+
+func (n *narrative) html() ([]byte, error) {
+	var b bytes.Buffer
+	err := page.Execute(&b, map[string]any{
+		"Report":    n.Report,
+		"Facts":     n.facts(),
+		"Model":     n.model(),
+		"Intel":     n.intelRows(),
+		"Timeline":  n.sorted(),
+		"Artifacts": n.Artifacts,
+		"Contents":  n.Contents,
+	})
+	return b.Bytes(), err
+}
@@ -0,0 +1,293 @@
+package export
+
+import (
+	"GradGuard/internal/analyzer"
+	"GradGuard/internal/attack"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// stixNamespace is the one STIX 2.1 gives for cyber-observable IDs, so
+// an address or file has the same ID whoever exports it.
+var stixNamespace = [16]byte{0x00, 0xab, 0xed, 0xb4, 0xaa, 0x42, 0x46, 0x6c, 0x9c, 0x01, 0xfe, 0xd2, 0x33, 0x15, 0xa9, 0xb7}
+
+// tlpMarkings are the TLP 1.0 marking definitions STIX 2.1 defines.
+var tlpMarkings = map[string]string{
+	"white": "marking-definition--613f2e26-407d-48c7-9eca-b8e91df99dc9",
+	"green": "marking-definition--34098fce-860f-48ae-8e50-ebd3cc5e41da",
+	"amber": "marking-definition--f88d31f6-486f-44da-b317-01333bde0b82",
+	"red":   "marking-definition--5e57c739-391a-4eb3-b6be-7d15ca92d5ed",
+}
+
+// TLPLevels are the TLP levels intel can be marked with.
+func TLPLevels() []string { return []string{"white", "green", "amber", "red"} }
+
+// catalogueTime stamps the objects that describe the honeypot and
+// ATT&CK rather than anything it saw, so they never change version.
+var catalogueTime = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// IntelOptions shapes shared intel.
+type IntelOptions struct {
+	Filter Filter
+	// TLP marks everything shared; it defaults to green.
+	TLP string
+}
+
+func (o IntelOptions) tlp() string {
+	if _, ok := tlpMarkings[o.TLP]; ok {
+		return o.TLP
+	}
+	return "green"
+}
+
+type stixObject = map[string]any
+
+func stixTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000Z")
+}
+
+// canonical is the JSON STIX hashes for an observable's ID: sorted keys,
+// no HTML escaping.
+func canonical(v any) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(v)
+	return strings.TrimSpace(buf.String())
+}
+
+func observableID(typ string, contributing map[string]any) string {
+	return typ + "--" + uuid5(stixNamespace, canonical(contributing))
+}
+
+func addressType(ip string) string {
+	if parsed := net.ParseIP(ip); parsed != nil && parsed.To4() == nil {
+		return "ipv6-addr"
+	}
+	return "ipv4-addr"
+}
+
+// stixBundle holds the objects of a bundle as they are made.
+type stixBundle struct {
+	identity string
+	marking  string
+	objects  []stixObject
+	seen     map[string]bool
+}
+
+func (b *stixBundle) add(o stixObject) {
+	id := o["id"].(string)
+	if b.seen[id] {
+		return
+	}
+	b.seen[id] = true
+	b.objects = append(b.objects, o)
+}
+
+// domain starts an SDO or SRO with the properties every one carries.
+func (b *stixBundle) domain(typ, id string, created, modified time.Time) stixObject {
+	return stixObject{
+		"type":                typ,
+		"spec_version":        "2.1",
+		"id":                  typ + "--" + id,
+		"created":             stixTime(created),
+		"modified":            stixTime(modified),
+		"created_by_ref":      b.identity,
+		"object_marking_refs": []string{b.marking},
+	}
+}
+
+// STIXObjects is the intel the options pick as STIX 2.1 objects:
+// attacker addresses as indicators, each session as observed data of its
+// commands and artifacts and a sighting of its address's indicator, and
+// the ATT&CK techniques seen as attack patterns the indicators indicate.
+func STIXObjects(o IntelOptions) []stixObject {
+	tlp := o.tlp()
+	b := &stixBundle{
+		identity: "identity--" + nameID("identity", "gradguard"),
+		marking:  tlpMarkings[tlp],
+		seen:     map[string]bool{},
+	}
+	identity := b.domain("identity", nameID("identity", "gradguard"), catalogueTime, catalogueTime)
+	delete(identity, "created_by_ref")
+	identity["name"] = "GradGuard honeypot"
+	identity["identity_class"] = "system"
+	b.add(identity)
+	b.add(stixObject{
+		"type":            "marking-definition",
+		"spec_version":    "2.1",
+		"id":              b.marking,
+		"created":         "2017-01-20T00:00:00.000Z",
+		"definition_type": "tlp",
+		"name":            "TLP:" + strings.ToUpper(tlp),
+		"definition":      map[string]string{"tlp": tlp},
+	})
+
+	sessions := collect(o.Filter)
+	order, groups := byAddress(sessions)
+	for _, ip := range order {
+		group := groups[ip]
+		first, last := group[0].start, group[0].end
+		verdicts := map[string]bool{}
+		for _, s := range group {
+			if s.end.After(last) {
+				last = s.end
+			}
+			verdicts[s.report.Verdict] = true
+		}
+
+		addrType := addressType(ip)
+		addrID := observableID(addrType, map[string]any{"value": ip})
+		b.add(stixObject{"type": addrType, "spec_version": "2.1", "id": addrID, "value": ip})
+
+		indicator := b.domain("indicator", nameID("indicator", ip), first, last)
+		indicator["name"] = "Honeypot attacker " + ip
+		indicator["description"] = fmt.Sprintf("Logged in to the GradGuard SSH honeypot; sessions: %d, verdicts: %s.",
+			len(group), strings.Join(sortedKeys(verdicts), ", "))
+		indicator["indicator_types"] = []string{"malicious-activity"}
+		indicator["pattern"] = fmt.Sprintf("[%s:value = '%s']", addrType, ip)
+		indicator["pattern_type"] = "stix"
+		indicator["valid_from"] = stixTime(first)
+		indicator["labels"] = sortedKeys(verdicts)
+		b.add(indicator)
+
+		indicates := map[string]time.Time{}
+		for _, s := range group {
+			b.session(s, addrID, indicator["id"].(string))
+			for _, t := range s.techniques {
+				if _, ok := indicates[t.Technique]; !ok {
+					indicates[t.Technique] = s.start
+				}
+			}
+		}
+		for _, technique := range sortedKeys(indicates) {
+			pattern := b.attackPattern(technique)
+			rel := b.domain("relationship", nameID("indicates", ip, technique), indicates[technique], last)
+			rel["relationship_type"] = "indicates"
+			rel["source_ref"] = indicator["id"]
+			rel["target_ref"] = pattern
+			b.add(rel)
+		}
+	}
+	return b.objects
+}
+
+// session adds a session's observed data and its sighting.
+func (b *stixBundle) session(s observed, addrID, indicatorID string) {
+	refs := []string{addrID}
+	for _, c := range s.commands {
+		id := "process--" + nameID("process", s.report.SessionID, c.Timestamp, c.Command)
+		if !b.seen[id] {
+			b.add(stixObject{"type": "process", "spec_version": "2.1", "id": id, "command_line": c.Command})
+			refs = append(refs, id)
+		}
+	}
+	for _, a := range s.artifacts {
+		file := map[string]any{"hashes": map[string]string{"SHA-256": a.SHA256}, "name": path.Base(a.Path)}
+		id := observableID("file", file)
+		b.add(stixObject{
+			"type": "file", "spec_version": "2.1", "id": id,
+			"hashes": file["hashes"], "name": file["name"], "size": a.Size,
+		})
+		refs = append(refs, id)
+	}
+	od := b.domain("observed-data", nameID("observed-data", s.report.SessionID), s.end, s.end)
+	od["first_observed"] = stixTime(s.start)
+	od["last_observed"] = stixTime(s.end)
+	od["number_observed"] = 1
+	od["object_refs"] = dedupe(refs)
+	b.add(od)
+
+	sighting := b.domain("sighting", nameID("sighting", s.report.SessionID), s.end, s.end)
+	sighting["description"] = fmt.Sprintf("Session %s: %d commands, %s", s.report.SessionID, s.report.TotalCommands, verdictLabel(s.report.Verdict))
+	sighting["first_seen"] = stixTime(s.start)
+	sighting["last_seen"] = stixTime(s.end)
+	sighting["count"] = 1
+	sighting["sighting_of_ref"] = indicatorID
+	sighting["observed_data_refs"] = []string{od["id"].(string)}
+	sighting["where_sighted_refs"] = []string{b.identity}
+	b.add(sighting)
+}
+
+func (b *stixBundle) attackPattern(technique string) string {
+	id := "attack-pattern--" + nameID("attack-pattern", technique)
+	if b.seen[id] {
+		return id
+	}
+	p := b.domain("attack-pattern", nameID("attack-pattern", technique), catalogueTime, catalogueTime)
+	p["name"] = attack.TechniqueName(technique)
+	base, sub, _ := strings.Cut(technique, ".")
+	url := "https://attack.mitre.org/techniques/" + base + "/"
+	if sub != "" {
+		url += sub + "/"
+	}
+	p["external_references"] = []map[string]string{{"source_name": "mitre-attack", "external_id": technique, "url": url}}
+	var phases []map[string]string
+	if t, ok := attack.LookupTechnique(technique); ok {
+		for _, tactic := range t.Tactics {
+			if tc, ok := attack.LookupTactic(tactic); ok {
+				phases = append(phases, map[string]string{
+					"kill_chain_name": "mitre-attack",
+					"phase_name":      strings.ToLower(strings.ReplaceAll(tc.Name, " ", "-")),
+				})
+			}
+		}
+	}
+	if len(phases) > 0 {
+		p["kill_chain_phases"] = phases
+	}
+	b.add(p)
+	return id
+}
+
+// STIX is the intel as a STIX 2.1 bundle. Its ID follows from what it
+// holds.
+func STIX(o IntelOptions) ([]byte, error) {
+	objects := STIXObjects(o)
+	ids := make([]string, 0, len(objects))
+	for _, obj := range objects {
+		ids = append(ids, obj["id"].(string)+"@"+fmt.Sprint(obj["modified"]))
+	}
+	sort.Strings(ids)
+	data, err := json.MarshalIndent(map[string]any{
+		"type":    "bundle",
+		"id":      "bundle--" + nameID(append([]string{"bundle"}, ids...)...),
+		"objects": objects,
+	}, "", "  ")
+	return append(data, '\n'), err
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func dedupe(ids []string) []string {
+	seen := map[string]bool{}
+	out := ids[:0]
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+// verdictLabel is how a verdict reads in a sentence.
+func verdictLabel(v string) string {
+	if v == analyzer.VerdictLikelyFingerprinting {
+		return "likely fingerprinting"
+	}
+	return strings.ReplaceAll(v, "_", " ")
+}
@@ -0,0 +1,314 @@
+package export
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	taxiiMedia = "application/taxii+json;version=2.1"
+	stixMedia  = "application/stix+json;version=2.1"
+	// taxiiPageSize is how many objects a page holds when the client asks
+	// for no limit, and the most it may ask for.
+	taxiiPageSize = 500
+)
+
+// TAXIIOptions shapes the feed peers poll.
+type TAXIIOptions struct {
+	Intel IntelOptions
+	// Window, when set, limits the feed to sessions started that long
+	// before each request, on top of Intel.Filter.
+	Window time.Duration
+	// User and Password, when set, are required as basic auth.
+	User     string
+	Password string
+	// CertFile and KeyFile, when set, serve the feed over HTTPS.
+	CertFile string
+	KeyFile  string
+}
+
+// collectionID names the one collection the feed has.
+var collectionID = nameID("taxii-collection", "sessions")
+
+// TAXII serves the intel as a read-only TAXII 2.1 server: discovery at
+// /taxii2/ and one API root at /gradguard/ with a single collection of
+// the STIX objects STIXObjects gives, built afresh for every request.
+func TAXII(o TAXIIOptions) http.Handler {
+	mux := http.NewServeMux()
+	root := "/gradguard/"
+	collection := root + "collections/" + collectionID + "/"
+
+	mux.HandleFunc("GET /taxii2/", func(w http.ResponseWriter, r *http.Request) {
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+		url := scheme + "://" + r.Host + root
+		taxiiJSON(w, map[string]any{
+			"title":       "GradGuard honeypot",
+			"description": "Attacker addresses, sessions and ATT&CK techniques seen by a GradGuard SSH honeypot.",
+			"default":     url,
+			"api_roots":   []string{url},
+		})
+	})
+	mux.HandleFunc("GET "+root, func(w http.ResponseWriter, r *http.Request) {
+		taxiiJSON(w, map[string]any{
+			"title":              "GradGuard sessions",
+			"versions":           []string{taxiiMedia},
+			"max_content_length": 0,
+		})
+	})
+	info := map[string]any{
+		"id":          collectionID,
+		"title":       "Honeypot sessions",
+		"description": "One indicator per attacker address, with a sighting and observed data per session.",
+		"can_read":    true,
+		"can_write":   false,
+		"media_types": []string{stixMedia},
+	}
+	mux.HandleFunc("GET "+root+"collections/", func(w http.ResponseWriter, r *http.Request) {
+		taxiiJSON(w, map[string]any{"collections": []any{info}})
+	})
+	mux.HandleFunc("GET "+collection, func(w http.ResponseWriter, r *http.Request) {
+		taxiiJSON(w, info)
+	})
+	mux.HandleFunc("GET "+collection+"objects/", func(w http.ResponseWriter, r *http.Request) {
+		page, ok := o.query(w, r, "")
+		if !ok {
+			return
+		}
+		taxiiJSON(w, envelope(page))
+	})
+	mux.HandleFunc("GET "+collection+"objects/{id}/", func(w http.ResponseWriter, r *http.Request) {
+		page, ok := o.query(w, r, r.PathValue("id"))
+		if !ok {
+			return
+		}
+		if len(page.objects) == 0 {
+			taxiiError(w, http.StatusNotFound, "no object "+r.PathValue("id"))
+			return
+		}
+		taxiiJSON(w, envelope(page))
+	})
+	mux.HandleFunc("GET "+collection+"manifest/", func(w http.ResponseWriter, r *http.Request) {
+		page, ok := o.query(w, r, "")
+		if !ok {
+			return
+		}
+		records := make([]map[string]string, 0, len(page.objects))
+		for _, obj := range page.objects {
+			records = append(records, map[string]string{
+				"id":         obj["id"].(string),
+				"date_added": added(obj),
+				"version":    added(obj),
+				"media_type": stixMedia,
+			})
+		}
+		m := envelope(page)
+		if len(records) > 0 {
+			m["objects"] = records
+		}
+		taxiiJSON(w, m)
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if o.User != "" || o.Password != "" {
+			user, pass, ok := r.BasicAuth()
+			if !ok || subtle.ConstantTimeCompare([]byte(user), []byte(o.User)) != 1 ||
+				subtle.ConstantTimeCompare([]byte(pass), []byte(o.Password)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Basic realm="gradguard"`)
+				taxiiError(w, http.StatusUnauthorized, "authentication required")
+				return
+			}
+		}
+		if accept := r.Header.Get("Accept"); accept != "" && !acceptsTAXII(accept) {
+			taxiiError(w, http.StatusNotAcceptable, "the feed is served as "+taxiiMedia)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+func acceptsTAXII(accept string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		media, _, _ := strings.Cut(strings.TrimSpace(part), ";")
+		switch media {
+		case "application/taxii+json", "application/json", "*/*", "application/*":
+			return true
+		}
+	}
+	return false
+}
+
+// feedPage is the part of the collection one request asked for.
+type feedPage struct {
+	objects     []stixObject
+	more        bool
+	next        string
+	first, last string
+}
+
+// added is when an object was added to the collection: when its current
+// version was made, as the feed keeps no other history.
+func added(obj stixObject) string {
+	if m, ok := obj["modified"].(string); ok {
+		return m
+	}
+	if c, ok := obj["created"].(string); ok {
+		return c
+	}
+	return stixTime(catalogueTime)
+}
+
+// query filters the collection by the request's added_after, match[id],
+// match[type], limit and next, oldest first. Next is the offset to carry
+// on from.
+func (o TAXIIOptions) query(w http.ResponseWriter, r *http.Request, id string) (feedPage, bool) {
+	q := r.URL.Query()
+	intel := o.Intel
+	if o.Window > 0 {
+		if since := time.Now().Add(-o.Window); since.After(intel.Filter.Since) {
+			intel.Filter.Since = since
+		}
+	}
+	var after time.Time
+	if s := q.Get("added_after"); s != "" {
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			taxiiError(w, http.StatusBadRequest, "added_after is not an RFC 3339 timestamp")
+			return feedPage{}, false
+		}
+		after = t
+	}
+	ids := listParam(q.Get("match[id]"))
+	if id != "" {
+		ids = map[string]bool{id: true}
+	}
+	types := listParam(q.Get("match[type]"))
+	limit, offset := taxiiPageSize, 0
+	if s := q.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n <= 0 {
+			taxiiError(w, http.StatusBadRequest, "limit must be a positive number")
+			return feedPage{}, false
+		}
+		limit = min(n, taxiiPageSize)
+	}
+	if s := q.Get("next"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			taxiiError(w, http.StatusBadRequest, "next is not one this server gave")
+			return feedPage{}, false
+		}
+		offset = n
+	}
+
+	var matched []stixObject
+	for _, obj := range STIXObjects(intel) {
+		if ids != nil && !ids[obj["id"].(string)] {
+			continue
+		}
+		if types != nil && !types[obj["type"].(string)] {
+			continue
+		}
+		if !after.IsZero() {
+			if t, err := time.Parse(time.RFC3339, added(obj)); err != nil || !t.After(after) {
+				continue
+			}
+		}
+		matched = append(matched, obj)
+	}
+	sort.SliceStable(matched, func(i, j int) bool { return added(matched[i]) < added(matched[j]) })
+
+	var p feedPage
+	if offset < len(matched) {
+		end := min(offset+limit, len(matched))
+		p.objects = matched[offset:end]
+		if end < len(matched) {
+			p.more, p.next = true, strconv.Itoa(end)
+		}
+	}
+	if len(p.objects) > 0 {
+		p.first, p.last = added(p.objects[0]), added(p.objects[len(p.objects)-1])
+		w.Header().Set("X-TAXII-Date-Added-First", p.first)
+		w.Header().Set("X-TAXII-Date-Added-Last", p.last)
+	}
+	return p, true
+}
+
+func listParam(s string) map[string]bool {
+	if s == "" {
+		return nil
+	}
+	set := map[string]bool{}
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			set[v] = true
+		}
+	}
+	return set
+}
+
+func envelope(p feedPage) map[string]any {
+	e := map[string]any{"more": p.more}
+	if p.next != "" {
+		e["next"] = p.next
+	}
+	if len(p.objects) > 0 {
+		e["objects"] = p.objects
+	}
+	return e
+}
+
+func taxiiJSON(w http.ResponseWriter, v any) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		taxiiError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", taxiiMedia)
+	w.Write(append(data, '\n'))
+}
+
+func taxiiError(w http.ResponseWriter, status int, title string) {
+	data, _ := json.Marshal(map[string]any{"title": title, "http_status": strconv.Itoa(status)})
+	w.Header().Set("Content-Type", taxiiMedia)
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+// ServeTAXII serves the feed on addr until the listener fails, over
+// HTTPS when o has a certificate, as TAXII 2.1 requires. Over plain HTTP
+// or without basic auth it is only served on a loopback address.
+func ServeTAXII(addr string, o TAXIIOptions) error {
+	secure := o.CertFile != "" && o.KeyFile != ""
+	if !loopback(addr) && (!secure || o.User == "") {
+		return fmt.Errorf("%s is not a loopback address; serving the feed there needs a certificate and key, and a user", addr)
+	}
+	srv := &http.Server{Addr: addr, Handler: TAXII(o), ReadHeaderTimeout: 10 * time.Second}
+	if secure {
+		return srv.ListenAndServeTLS(o.CertFile, o.KeyFile)
+	}
+	return srv.ListenAndServe()
+}
+
+// loopback reports whether addr only listens on this host; an empty host
+// listens everywhere.
+func loopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,142 @@
+// Package ipintel looks up where an attacker's address is: country,
+// network operator and AS, and whether it is a proxy or hosting range.
+package ipintel
+
+import (
+	"GradGuard/internal/logstore"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type Info struct {
+	IP          string  `json:"query"`
+	Status      string  `json:"status"`
+	Country     string  `json:"country"`
+	CountryCode string  `json:"countryCode"`
+	Region      string  `json:"regionName"`
+	City        string  `json:"city"`
+	ISP         string  `json:"isp"`
+	Org         string  `json:"org"`
+	AS          string  `json:"as"`
+	Lat         float64 `json:"lat"`
+	Lon         float64 `json:"lon"`
+	Timezone    string  `json:"timezone"`
+	Mobile      bool    `json:"mobile"`
+	Proxy       bool    `json:"proxy"`
+	Hosting     bool    `json:"hosting"`
+	// Retrieved is when the lookup was made, for lookups kept in
+	// Dir.
+	Retrieved string `json:"retrieved,omitempty"`
+}
+
+// Dir keeps the first lookup made of each address, so evidence
+// exported later shows what was known then rather than what the lookup
+// service says today.
+var Dir = filepath.Join(logstore.Root, "intel")
+
+// Cached returns the kept lookup of the address, making and keeping
+// one if there is none yet.
+func Cached(rawAddr string) *Info {
+	ip := host(rawAddr)
+	if ip == "" {
+		return nil
+	}
+	if info := Kept(ip); info != nil {
+		return info
+	}
+	path := keptPath(ip)
+	info := Lookup(ip)
+	if info == nil {
+		return nil
+	}
+	info.Retrieved = time.Now().UTC().Format(time.RFC3339)
+	if data, err := json.MarshalIndent(info, "", "  "); err == nil {
+		if os.MkdirAll(Dir, 0755) == nil {
+			os.WriteFile(path, append(data, '\n'), 0644)
+		}
+	}
+	return info
+}
+
+// Kept returns the kept lookup of the address without making one; nil
+// if there is none.
+func Kept(rawAddr string) *Info {
+	ip := host(rawAddr)
+	if ip == "" {
+		return nil
+	}
+	data, err := os.ReadFile(keptPath(ip))
+	if err != nil {
+		return nil
+	}
+	var info Info
+	if json.Unmarshal(data, &info) != nil {
+		return nil
+	}
+	return &info
+}
+
+func keptPath(ip string) string {
+	return filepath.Join(Dir, strings.NewReplacer(":", "_", "/", "_").Replace(ip)+".json")
+}
+
+// Lookup asks ip-api.com about an address; loopback addresses are
+// answered locally.
+func Lookup(rawAddr string) *Info {
+	ip := host(rawAddr)
+	if ip == "" {
+		return nil
+	}
+
+	if isLoopback(ip) {
+		return &Info{
+			IP:      ip,
+			Status:  "local",
+			Country: "Loopback",
+			ISP:     "localhost",
+			City:    "local machine",
+		}
+	}
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	url := fmt.Sprintf("http://ip-api.com/json/%s?fields=status,message,country,countryCode,regionName,city,isp,org,as,lat,lon,timezone,mobile,proxy,hosting,query", ip)
+
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil
+	}
+	defer resp.Body.Close()
+
+	var info Info
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return nil
+	}
+
+	if info.Status != "success" {
+		return nil
+	}
+
+	return &info
+}
+
+func host(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
+
+func isLoopback(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	return parsed.IsLoopback()
+}
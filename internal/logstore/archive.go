@@ -0,0 +1,347 @@
+package logstore
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ArchiveDir holds one gzipped tar per archived session under the day it
+// started, and index.json listing them.
+var ArchiveDir = filepath.Join(Root, "archive")
+
+// Entry is one archived session in the index.
+type Entry struct {
+	SessionID  string `json:"session_id"`
+	Date       string `json:"date"`
+	RemoteAddr string `json:"remote_addr,omitempty"`
+	Verdict    string `json:"verdict,omitempty"`
+	// Archive is relative to ArchiveDir.
+	Archive    string   `json:"archive"`
+	Files      []string `json:"files"`
+	Bytes      int64    `json:"bytes"`
+	Compressed int64    `json:"compressed"`
+	ArchivedAt string   `json:"archived_at"`
+}
+
+// Result counts what an archive or prune run did.
+type Result struct {
+	Sessions int
+	Files    int
+	// Bytes is how much the sessions took up before; Freed is how much
+	// less is on disk now.
+	Bytes int64
+	Freed int64
+}
+
+// mu serialises archive and prune runs in this process. The live files
+// they move are only ever appended to by the event bus, and only sessions
+// idle for a while are touched.
+var mu sync.Mutex
+
+// Archive compresses every session that has a report and has not been
+// written to for olderThan, then removes its live files. A session that
+// is archived again, because a line arrived after it was archived, has
+// the new lines added to what was already there.
+func Archive(olderThan time.Duration) (Result, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	var result Result
+	index := Index()
+	byID := map[string]int{}
+	for i, e := range index {
+		byID[e.SessionID] = i
+	}
+
+	sessions := liveSessions()
+	ids := make([]string, 0, len(sessions))
+	for id := range sessions {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	cutoff := time.Now().Add(-olderThan)
+	var errs []error
+	for _, id := range ids {
+		s := sessions[id]
+		_, hasReport := s.paths[Reports]
+		if i, ok := byID[id]; !hasReport && ok {
+			// The report is already archived; this is a late line.
+			hasReport = index[i].has(Reports)
+		}
+		if !hasReport || s.modified.After(cutoff) {
+			continue
+		}
+		var previous *Entry
+		if i, ok := byID[id]; ok {
+			e := index[i]
+			previous = &e
+		}
+		entry, err := archiveSession(s, previous)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", id, err))
+			continue
+		}
+		if i, ok := byID[id]; ok {
+			index[i] = entry
+		} else {
+			byID[id] = len(index)
+			index = append(index, entry)
+		}
+		if err := writeIndex(index); err != nil {
+			return result, err
+		}
+		s.remove()
+		result.Files += len(s.paths) + len(s.blobs)
+		result.Sessions++
+		result.Bytes += s.bytes
+		result.Freed += s.bytes
+		if previous != nil {
+			result.Freed -= entry.Compressed - previous.Compressed
+		} else {
+			result.Freed -= entry.Compressed
+		}
+	}
+	return result, errors.Join(errs...)
+}
+
+func archiveSession(s *session, previous *Entry) (Entry, error) {
+	members := map[string][]byte{}
+	if previous != nil {
+		old, err := previous.members()
+		if err != nil {
+			return Entry{}, err
+		}
+		members = old
+	}
+	for _, k := range kinds {
+		path, ok := s.paths[k]
+		if !ok {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return Entry{}, err
+		}
+		name := string(k) + "/" + k.File(s.id)
+		if k == Reports || k == Manifests {
+			members[name] = data
+		} else {
+			members[name] = append(members[name], data...)
+		}
+	}
+	for _, path := range s.blobs {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return Entry{}, err
+		}
+		members[blobMember(s.id, filepath.Base(path))] = data
+	}
+
+	entry := Entry{
+		SessionID:  s.id,
+		Date:       s.date(),
+		Archive:    filepath.Join(s.date(), s.id+".tar.gz"),
+		ArchivedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+	if previous != nil {
+		entry.Date, entry.Archive = previous.Date, previous.Archive
+	}
+	if report, ok := members[string(Reports)+"/"+Reports.File(s.id)]; ok {
+		var r struct {
+			RemoteAddr string `json:"remote_addr"`
+			Verdict    string `json:"verdict"`
+		}
+		json.Unmarshal(report, &r)
+		entry.RemoteAddr, entry.Verdict = r.RemoteAddr, r.Verdict
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	var names []string
+	for _, k := range kinds {
+		name := string(k) + "/" + k.File(s.id)
+		if _, ok := members[name]; ok {
+			names = append(names, name)
+		}
+	}
+	var blobs []string
+	for name := range members {
+		if strings.HasPrefix(name, blobMember(s.id, "")) {
+			blobs = append(blobs, name)
+		}
+	}
+	sort.Strings(blobs)
+	for _, name := range append(names, blobs...) {
+		data := members[name]
+		// A fixed mode and time keep the archive of the same files the
+		// same bytes.
+		hdr := &tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), ModTime: time.Unix(0, 0)}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return Entry{}, err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return Entry{}, err
+		}
+		entry.Files = append(entry.Files, name)
+		entry.Bytes += int64(len(data))
+	}
+	if err := tw.Close(); err != nil {
+		return Entry{}, err
+	}
+	if err := gz.Close(); err != nil {
+		return Entry{}, err
+	}
+	entry.Compressed = int64(buf.Len())
+
+	if err := writeAtomic(filepath.Join(ArchiveDir, entry.Archive), buf.Bytes()); err != nil {
+		return Entry{}, err
+	}
+	return entry, nil
+}
+
+func (e Entry) has(k Kind) bool {
+	name := string(k) + "/" + k.File(e.SessionID)
+	for _, f := range e.Files {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// members reads every file out of the session's archive.
+func (e Entry) members() (map[string][]byte, error) {
+	f, err := os.Open(filepath.Join(ArchiveDir, e.Archive))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	members := map[string][]byte{}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return members, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		members[hdr.Name] = data
+	}
+}
+
+func (e Entry) read(k Kind) ([]byte, error) {
+	if !e.has(k) {
+		return nil, fs.ErrNotExist
+	}
+	members, err := e.members()
+	if err != nil {
+		return nil, err
+	}
+	data, ok := members[string(k)+"/"+k.File(e.SessionID)]
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+	return data, nil
+}
+
+func readArchived(k Kind, id string) ([]byte, error) {
+	for _, e := range loadIndex() {
+		if e.SessionID == id {
+			return e.read(k)
+		}
+	}
+	return nil, fs.ErrNotExist
+}
+
+// Index lists the archived sessions.
+func Index() []Entry {
+	return append([]Entry(nil), loadIndex()...)
+}
+
+var (
+	indexMu     sync.Mutex
+	indexStamp  string
+	indexCached []Entry
+)
+
+func indexPath() string { return filepath.Join(ArchiveDir, "index.json") }
+
+// loadIndex reads the index, one entry a line, reusing the last read
+// while the file is unchanged.
+func loadIndex() []Entry {
+	indexMu.Lock()
+	defer indexMu.Unlock()
+
+	info, err := os.Stat(indexPath())
+	if err != nil {
+		indexStamp, indexCached = "", nil
+		return nil
+	}
+	stamp := fmt.Sprintf("%d/%d", info.Size(), info.ModTime().UnixNano())
+	if stamp == indexStamp {
+		return indexCached
+	}
+	f, err := os.Open(indexPath())
+	if err != nil {
+		return indexCached
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil || e.SessionID == "" {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	indexStamp, indexCached = stamp, entries
+	return entries
+}
+
+func writeIndex(entries []Entry) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+	return writeAtomic(indexPath(), buf.Bytes())
+}
+
+func writeAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
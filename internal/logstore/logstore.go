@@ -0,0 +1,347 @@
+// Package logstore lays out the per-session log files and finds them
+// again wherever they have gone: a date partition, the flat directories
+// older versions wrote, or a compressed archive.
+package logstore
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Root is the directory every session file lives under.
+const Root = "logs"
+
+// Kind is one of the files a session leaves behind, named after the
+// directory it is kept in.
+type Kind string
+
+const (
+	Commands   Kind = "sessions"
+	Detections Kind = "detections"
+	Reports    Kind = "reports"
+	// Recordings are the session's terminal, in asciicast v2.
+	Recordings Kind = "recordings"
+	// Artifacts list the files captured from the container; their
+	// contents are kept by hash beside the list, see BlobPath.
+	Artifacts Kind = "artifacts"
+	// Manifests hold the signed digest of the others at the session's end.
+	Manifests Kind = "manifests"
+)
+
+var kinds = []Kind{Commands, Detections, Reports, Recordings, Artifacts, Manifests}
+
+func (k Kind) suffix() string {
+	switch k {
+	case Detections:
+		return "-detections.json"
+	case Reports:
+		return "-report.json"
+	case Recordings:
+		return ".cast"
+	case Artifacts:
+		return "-artifacts.json"
+	case Manifests:
+		return "-manifest.json"
+	}
+	return ".json"
+}
+
+// File is the name of a session's file of this kind.
+func (k Kind) File(id string) string { return id + k.suffix() }
+
+func (k Kind) idOf(name string) (string, bool) {
+	if !strings.HasSuffix(name, k.suffix()) {
+		return "", false
+	}
+	return strings.TrimSuffix(name, k.suffix()), true
+}
+
+// Started is when a session began, read from the nanosecond timestamp
+// its ID ends with.
+func Started(id string) (time.Time, bool) {
+	i := strings.LastIndexByte(id, '-')
+	ns, err := strconv.ParseInt(id[i+1:], 10, 64)
+	if err != nil || ns <= 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(0, ns).UTC(), true
+}
+
+// Partition is the directory a session's files go in: the UTC day it
+// started, or the day of at when the ID carries no time.
+func Partition(id string, at time.Time) string {
+	if t, ok := Started(id); ok {
+		at = t
+	}
+	return at.UTC().Format(time.DateOnly)
+}
+
+// Path is where a live session file of kind k is written.
+func Path(k Kind, id string, at time.Time) string {
+	return filepath.Join(Root, string(k), Partition(id, at), k.File(id))
+}
+
+// BlobPath is where the content of a captured artifact is kept while the
+// session is live: in a directory named for the session beside its
+// artifact list.
+func BlobPath(id, sha256 string, at time.Time) string {
+	return filepath.Join(Root, string(Artifacts), Partition(id, at), id, sha256)
+}
+
+// blobMember is the name a blob has inside a session's archive.
+func blobMember(id, sha256 string) string {
+	return string(Artifacts) + "/" + id + "/" + sha256
+}
+
+// ReadBlob returns a captured artifact's content from wherever it is
+// kept.
+func ReadBlob(id, sha256 string) ([]byte, error) {
+	live := []string{BlobPath(id, sha256, time.Time{})}
+	more, _ := filepath.Glob(filepath.Join(Root, string(Artifacts), "*", id, sha256))
+	for _, path := range append(live, more...) {
+		data, err := os.ReadFile(path)
+		if err == nil {
+			return data, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	for _, e := range loadIndex() {
+		if e.SessionID != id {
+			continue
+		}
+		members, err := e.members()
+		if err != nil {
+			return nil, err
+		}
+		if data, ok := members[blobMember(id, sha256)]; ok {
+			return data, nil
+		}
+	}
+	return nil, fs.ErrNotExist
+}
+
+// Read returns a session's file of kind k from wherever it is kept.
+func Read(k Kind, id string) ([]byte, error) {
+	for _, path := range livePaths(k, id) {
+		data, err := os.ReadFile(path)
+		if err == nil {
+			return data, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	return readArchived(k, id)
+}
+
+func livePaths(k Kind, id string) []string {
+	var paths []string
+	if _, ok := Started(id); ok {
+		paths = append(paths, Path(k, id, time.Time{}))
+	}
+	paths = append(paths, filepath.Join(Root, string(k), k.File(id)))
+	more, _ := filepath.Glob(filepath.Join(Root, string(k), "*", k.File(id)))
+	for _, p := range more {
+		if p != paths[0] {
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+// Walk calls fn with every session file of kind k: the live ones, then
+// those only in the archive.
+func Walk(k Kind, fn func(id string, data []byte)) {
+	seen := map[string]bool{}
+	for _, path := range liveFiles(k) {
+		id, _ := k.idOf(filepath.Base(path))
+		data, err := os.ReadFile(path)
+		if err != nil || seen[id] {
+			continue
+		}
+		seen[id] = true
+		fn(id, data)
+	}
+	for _, e := range loadIndex() {
+		if seen[e.SessionID] {
+			continue
+		}
+		data, err := e.read(k)
+		if err != nil {
+			continue
+		}
+		fn(e.SessionID, data)
+	}
+}
+
+// Kept lists the sessions that still have files, live or archived.
+func Kept() map[string]bool {
+	kept := map[string]bool{}
+	for id := range liveSessions() {
+		kept[id] = true
+	}
+	for _, e := range loadIndex() {
+		kept[e.SessionID] = true
+	}
+	return kept
+}
+
+// Has reports whether a session still has any file, live or archived.
+func Has(id string) bool {
+	for _, k := range kinds {
+		for _, path := range livePaths(k, id) {
+			if _, err := os.Stat(path); err == nil {
+				return true
+			}
+		}
+	}
+	for _, e := range loadIndex() {
+		if e.SessionID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// liveFiles lists the files of kind k outside the archive, partitioned
+// ones first.
+func liveFiles(k Kind) []string {
+	dir := filepath.Join(Root, string(k))
+	partitioned, _ := filepath.Glob(filepath.Join(dir, "*", "*"+k.suffix()))
+	flat, _ := filepath.Glob(filepath.Join(dir, "*"+k.suffix()))
+	sort.Strings(partitioned)
+	var files []string
+	for _, p := range append(partitioned, flat...) {
+		if _, ok := k.idOf(filepath.Base(p)); ok {
+			files = append(files, p)
+		}
+	}
+	return files
+}
+
+// session is what is known of one live session's files.
+type session struct {
+	id       string
+	paths    map[Kind]string
+	blobs    []string
+	bytes    int64
+	modified time.Time
+}
+
+func (s *session) date() string {
+	if t, ok := Started(s.id); ok {
+		return t.Format(time.DateOnly)
+	}
+	return s.modified.UTC().Format(time.DateOnly)
+}
+
+func liveSessions() map[string]*session {
+	sessions := map[string]*session{}
+	for _, k := range kinds {
+		for _, path := range liveFiles(k) {
+			info, err := os.Stat(path)
+			if err != nil {
+				continue
+			}
+			id, _ := k.idOf(filepath.Base(path))
+			s, ok := sessions[id]
+			if !ok {
+				s = &session{id: id, paths: map[Kind]string{}}
+				sessions[id] = s
+			}
+			if _, dup := s.paths[k]; dup {
+				continue
+			}
+			s.paths[k] = path
+			s.note(info)
+		}
+	}
+	blobs, _ := filepath.Glob(filepath.Join(Root, string(Artifacts), "*", "*", "*"))
+	for _, path := range blobs {
+		info, err := os.Stat(path)
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		id := filepath.Base(filepath.Dir(path))
+		s, ok := sessions[id]
+		if !ok {
+			s = &session{id: id, paths: map[Kind]string{}}
+			sessions[id] = s
+		}
+		s.blobs = append(s.blobs, path)
+		s.note(info)
+	}
+	return sessions
+}
+
+func (s *session) note(info fs.FileInfo) {
+	s.bytes += info.Size()
+	if info.ModTime().After(s.modified) {
+		s.modified = info.ModTime()
+	}
+}
+
+// remove deletes the session's live files and whatever directories that
+// leaves empty.
+func (s *session) remove() []error {
+	var errs []error
+	for _, path := range append(s.files(), s.blobs...) {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			errs = append(errs, err)
+			continue
+		}
+		removeEmpty(filepath.Dir(path))
+	}
+	for _, path := range s.blobs {
+		removeEmpty(filepath.Dir(filepath.Dir(path)))
+	}
+	return errs
+}
+
+func (s *session) files() []string {
+	var paths []string
+	for _, path := range s.paths {
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+// Writable checks that a file can be created in Root and in the
+// directory of every kind, making any that are missing.
+func Writable() error {
+	dirs := []string{Root}
+	for _, k := range kinds {
+		dirs = append(dirs, filepath.Join(Root, string(k)))
+	}
+	for _, dir := range dirs {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		f, err := os.CreateTemp(dir, ".writable-*")
+		if err != nil {
+			return err
+		}
+		f.Close()
+		os.Remove(f.Name())
+	}
+	return nil
+}
+
+// removeEmpty removes a day partition once nothing is left in it.
+func removeEmpty(dir string) {
+	if filepath.Dir(dir) == Root {
+		return
+	}
+	entries, err := os.ReadDir(dir)
+	if err == nil && len(entries) == 0 {
+		os.Remove(dir)
+	}
+}
@@ -0,0 +1,136 @@
+package logstore
+
+import (
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// activeWindow keeps pruning away from sessions still being written: a
+// live session touched this recently is never removed.
+const activeWindow = time.Hour
+
+// Policy is how long session logs are kept and how much room they may
+// take. Zero turns a limit off.
+type Policy struct {
+	// ArchiveAfter is how long a finished session stays uncompressed.
+	ArchiveAfter time.Duration
+	// MaxAge removes sessions that started longer ago than this.
+	MaxAge time.Duration
+	// MaxTotalBytes removes the oldest sessions until live and archived
+	// ones together fit.
+	MaxTotalBytes int64
+}
+
+// unit is one session as pruning sees it, live or archived.
+type unit struct {
+	id    string
+	date  string
+	bytes int64
+	files int
+	live  *session
+	entry *Entry
+}
+
+// Prune removes sessions past the policy's age, then the oldest ones
+// until the rest fit in its size.
+func Prune(p Policy) (Result, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	var units []unit
+	var total int64
+	for _, s := range liveSessions() {
+		total += s.bytes
+		if time.Since(s.modified) < activeWindow {
+			continue
+		}
+		units = append(units, unit{id: s.id, date: s.date(), bytes: s.bytes, files: len(s.paths) + len(s.blobs), live: s})
+	}
+	index := loadIndex()
+	for i := range index {
+		e := &index[i]
+		total += e.Compressed
+		units = append(units, unit{id: e.SessionID, date: e.Date, bytes: e.Compressed, files: len(e.Files), entry: e})
+	}
+	sort.Slice(units, func(i, j int) bool {
+		if units[i].date != units[j].date {
+			return units[i].date < units[j].date
+		}
+		return units[i].id < units[j].id
+	})
+
+	var doomed []unit
+	oldest := ""
+	if p.MaxAge > 0 {
+		oldest = time.Now().UTC().Add(-p.MaxAge).Format(time.DateOnly)
+	}
+	for _, u := range units {
+		tooOld := oldest != "" && u.date < oldest
+		tooBig := p.MaxTotalBytes > 0 && total > p.MaxTotalBytes
+		if !tooOld && !tooBig {
+			break
+		}
+		doomed = append(doomed, u)
+		total -= u.bytes
+	}
+
+	var result Result
+	var errs []error
+	removed := map[string]bool{}
+	for _, u := range doomed {
+		if u.live != nil {
+			errs = append(errs, u.live.remove()...)
+		} else {
+			path := filepath.Join(ArchiveDir, u.entry.Archive)
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				errs = append(errs, err)
+				continue
+			}
+			removeEmpty(filepath.Dir(path))
+			removed[u.id] = true
+		}
+		result.Sessions++
+		result.Files += u.files
+		result.Bytes += u.bytes
+		result.Freed += u.bytes
+	}
+	if len(removed) > 0 {
+		var kept []Entry
+		for _, e := range index {
+			if !removed[e.SessionID] {
+				kept = append(kept, e)
+			}
+		}
+		errs = append(errs, writeIndex(kept))
+	}
+	return result, errors.Join(errs...)
+}
+
+// Janitor archives and prunes by p every interval, for as long as the
+// process runs.
+func Janitor(p Policy, interval time.Duration) {
+	for range time.Tick(interval) {
+		if p.ArchiveAfter > 0 {
+			r, err := Archive(p.ArchiveAfter)
+			if err != nil {
+				log.Printf("logs: archive: %v", err)
+			}
+			if r.Sessions > 0 {
+				log.Printf("logs: archived %d sessions, %d bytes saved", r.Sessions, r.Freed)
+			}
+		}
+		if p.MaxAge > 0 || p.MaxTotalBytes > 0 {
+			r, err := Prune(p)
+			if err != nil {
+				log.Printf("logs: prune: %v", err)
+			}
+			if r.Sessions > 0 {
+				log.Printf("logs: pruned %d sessions, %d bytes freed", r.Sessions, r.Freed)
+			}
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Check is one thing /readyz needs to be true before traffic is sent our
+// way; Run returns why not.
+type Check struct {
+	Name string
+	Run  func() error
+}
+
+// Handler serves /metrics, /healthz, which answers while the process
+// does, and /readyz, which answers 200 only when every check passes and
+// lists each check either way.
+func Handler(checks []Check) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		Write(w)
+	})
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, r *http.Request) {
+		errs := make([]error, len(checks))
+		var wg sync.WaitGroup
+		for i, c := range checks {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs[i] = c.Run()
+			}()
+		}
+		wg.Wait()
+
+		status, lines := http.StatusOK, ""
+		for i, c := range checks {
+			if errs[i] != nil {
+				status = http.StatusServiceUnavailable
+				lines += fmt.Sprintf("[-] %s: %v\n", c.Name, errs[i])
+			} else {
+				lines += fmt.Sprintf("[+] %s ok\n", c.Name)
+			}
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(status)
+		fmt.Fprint(w, lines)
+		if status == http.StatusOK {
+			fmt.Fprintln(w, "ready")
+		} else {
+			fmt.Fprintln(w, "not ready")
+		}
+	})
+	return mux
+}
+
+// Serve runs the admin listener on addr until it fails.
+func Serve(addr string, checks []Check) error {
+	srv := &http.Server{Addr: addr, Handler: Handler(checks), ReadHeaderTimeout: 10 * time.Second}
+	return srv.ListenAndServe()
+}
@@ -0,0 +1,144 @@
+package metrics
+
+import (
+	"GradGuard/internal/events"
+	"encoding/json"
+)
+
+// latencyBuckets, in seconds, span a warm container start to a cold
+// image pull.
+var latencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+var (
+	SessionsActive = NewGauge("gradguard_sessions_active",
+		"SSH sessions open now.")
+	Connections = NewCounter("gradguard_connections_total",
+		"TCP connections by whether they were accepted or turned away by the connection limits.", "result")
+	ContainerStart = NewHistogram("gradguard_container_start_seconds",
+		"How long docker run took to start a session's container, by result.", latencyBuckets, "result")
+	Containers = NewGauge("gradguard_container_pool_size",
+		"Session containers running now.")
+	MLInference = NewHistogram("gradguard_ml_inference_seconds",
+		"How long the model took to give its opinion of a finished session.",
+		[]float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 5})
+
+	authAttempts = NewCounter("gradguard_auth_attempts_total",
+		"Login attempts by method and result.", "method", "result")
+	sessions = NewCounter("gradguard_sessions_total",
+		"SSH sessions started.")
+	commands = NewCounter("gradguard_commands_total",
+		"Commands run in sessions by category.", "category")
+	detections = NewCounter("gradguard_detections_total",
+		"Detections by signal and confidence.", "signal", "confidence")
+	responses = NewCounter("gradguard_response_actions_total",
+		"Response actions by action and result: success, failure, skipped or dry_run.", "action", "result")
+	verdicts = NewCounter("gradguard_session_verdicts_total",
+		"Finished sessions by verdict.", "verdict")
+)
+
+func init() {
+	Connections.Add(0, "accepted")
+	Connections.Add(0, "limited")
+
+	sinkStat := func(name, help, typ string, value func(events.SinkStats) float64) {
+		collected(name, help, typ, []string{"sink"}, func() []sample {
+			var out []sample
+			for _, s := range events.Stats() {
+				out = append(out, sample{[]string{s.Name}, value(s)})
+			}
+			return out
+		})
+	}
+	sinkStat("gradguard_event_sink_delivered_total", "Events each sink has written.", "counter",
+		func(s events.SinkStats) float64 { return float64(s.Delivered) })
+	sinkStat("gradguard_event_sink_dropped_total", "Events each sink lost to a full queue.", "counter",
+		func(s events.SinkStats) float64 { return float64(s.Dropped) })
+	sinkStat("gradguard_event_sink_failed_total", "Events each sink failed to write.", "counter",
+		func(s events.SinkStats) float64 { return float64(s.Failed) })
+	sinkStat("gradguard_event_sink_queued", "Events waiting in each sink's queue.", "gauge",
+		func(s events.SinkStats) float64 { return float64(s.Queued) })
+}
+
+// Sink is an event sink that counts what the honeypot records: logins,
+// sessions, commands, detections, responses and verdicts.
+type Sink struct{}
+
+func (Sink) Name() string { return "metrics" }
+
+func (Sink) Write(batch []events.Event) error {
+	for _, e := range batch {
+		switch e.Kind {
+		case events.KindAuth:
+			var a struct {
+				Method   string `json:"method"`
+				Accepted bool   `json:"accepted"`
+			}
+			if decode(e, &a) {
+				outcome := "rejected"
+				if a.Accepted {
+					outcome = "accepted"
+				}
+				authAttempts.Inc(a.Method, outcome)
+			}
+		case events.KindSessionStart:
+			sessions.Inc()
+		case events.KindCommand:
+			var c struct {
+				Command  string `json:"command"`
+				Category string `json:"category"`
+			}
+			// Bracketed entries mark what the honeypot did, such as
+			// starting the container, not commands.
+			if decode(e, &c) && (c.Command == "" || c.Command[0] != '[') {
+				commands.Inc(c.Category)
+			}
+		case events.KindDetection:
+			var d struct {
+				Signal     string `json:"signal"`
+				Confidence string `json:"confidence"`
+			}
+			if decode(e, &d) {
+				detections.Inc(d.Signal, d.Confidence)
+			}
+		case events.KindResponse:
+			var r struct {
+				Actions []struct {
+					Action string `json:"action"`
+					Status string `json:"status"`
+				} `json:"actions"`
+			}
+			if decode(e, &r) {
+				for _, a := range r.Actions {
+					responses.Inc(a.Action, result(a.Status))
+				}
+			}
+		case events.KindSessionEnd:
+			var s struct {
+				Verdict string `json:"verdict"`
+			}
+			if decode(e, &s) && s.Verdict != "" {
+				verdicts.Inc(s.Verdict)
+			}
+		}
+	}
+	return nil
+}
+
+func (Sink) Close() error { return nil }
+
+// result folds an action's status into success or failure; skipped and
+// dry-run actions keep their own.
+func result(status string) string {
+	switch status {
+	case "applied":
+		return "success"
+	case "failed", "rolled_back":
+		return "failure"
+	}
+	return status
+}
+
+func decode(e events.Event, v any) bool {
+	data, err := json.Marshal(e.Data)
+	return err == nil && json.Unmarshal(data, v) == nil
+}
@@ -0,0 +1,228 @@
+// Package metrics keeps the honeypot's runtime counters, gauges and
+// histograms and writes them in the Prometheus text exposition format.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// family is one metric name with its help, type and a series per set of
+// label values.
+type family struct {
+	name, help, typ string
+	labels          []string
+	buckets         []float64
+	// collect, when set, reads the family's series at scrape time.
+	collect func() []sample
+
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+type series struct {
+	values []string
+	value  float64
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// sample is one series a collect func reports.
+type sample struct {
+	values []string
+	value  float64
+}
+
+var (
+	mu       sync.Mutex
+	families []*family
+)
+
+func register(f *family) *family {
+	f.series = map[string]*series{}
+	if len(f.labels) == 0 && f.collect == nil {
+		// A metric without labels is there, at zero, from the start.
+		f.get(nil)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	families = append(families, f)
+	return f
+}
+
+func (f *family) get(values []string) *series {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s takes %d labels, got %d", f.name, len(f.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{values: append([]string(nil), values...)}
+		if f.buckets != nil {
+			s.counts = make([]uint64, len(f.buckets))
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+// Counter only goes up.
+type Counter struct{ f *family }
+
+func NewCounter(name, help string, labels ...string) *Counter {
+	return &Counter{register(&family{name: name, help: help, typ: "counter", labels: labels})}
+}
+
+func (c *Counter) Inc(values ...string) { c.Add(1, values...) }
+
+func (c *Counter) Add(v float64, values ...string) {
+	c.f.mu.Lock()
+	c.f.get(values).value += v
+	c.f.mu.Unlock()
+}
+
+// Gauge goes up and down.
+type Gauge struct{ f *family }
+
+func NewGauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{register(&family{name: name, help: help, typ: "gauge", labels: labels})}
+}
+
+func (g *Gauge) Set(v float64, values ...string) {
+	g.f.mu.Lock()
+	g.f.get(values).value = v
+	g.f.mu.Unlock()
+}
+
+func (g *Gauge) Add(v float64, values ...string) {
+	g.f.mu.Lock()
+	g.f.get(values).value += v
+	g.f.mu.Unlock()
+}
+
+func (g *Gauge) Inc(values ...string) { g.Add(1, values...) }
+func (g *Gauge) Dec(values ...string) { g.Add(-1, values...) }
+
+// Histogram counts observations into cumulative buckets.
+type Histogram struct{ f *family }
+
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	b := append([]float64(nil), buckets...)
+	sort.Float64s(b)
+	return &Histogram{register(&family{name: name, help: help, typ: "histogram", labels: labels, buckets: b})}
+}
+
+func (h *Histogram) Observe(v float64, values ...string) {
+	h.f.mu.Lock()
+	defer h.f.mu.Unlock()
+	s := h.f.get(values)
+	for i, le := range h.f.buckets {
+		if v <= le {
+			s.counts[i]++
+		}
+	}
+	s.sum += v
+	s.count++
+}
+
+// collected registers a family whose series are read at scrape time.
+func collected(name, help, typ string, labels []string, fn func() []sample) {
+	register(&family{name: name, help: help, typ: typ, labels: labels, collect: fn})
+}
+
+// Write writes every metric in the Prometheus text format, families in
+// name order and series in label order.
+func Write(w io.Writer) error {
+	mu.Lock()
+	fams := append([]*family(nil), families...)
+	mu.Unlock()
+	sort.Slice(fams, func(i, j int) bool { return fams[i].name < fams[j].name })
+
+	bw := bufio.NewWriter(w)
+	for _, f := range fams {
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s %s\n", f.name, escapeHelp(f.help), f.name, f.typ)
+		for _, s := range f.snapshot() {
+			if f.buckets == nil {
+				fmt.Fprintf(bw, "%s%s %s\n", f.name, labels(f.labels, s.values, "", ""), number(s.value))
+				continue
+			}
+			for i, le := range f.buckets {
+				fmt.Fprintf(bw, "%s_bucket%s %d\n", f.name, labels(f.labels, s.values, "le", number(le)), s.counts[i])
+			}
+			fmt.Fprintf(bw, "%s_bucket%s %d\n", f.name, labels(f.labels, s.values, "le", "+Inf"), s.count)
+			fmt.Fprintf(bw, "%s_sum%s %s\n", f.name, labels(f.labels, s.values, "", ""), number(s.sum))
+			fmt.Fprintf(bw, "%s_count%s %d\n", f.name, labels(f.labels, s.values, "", ""), s.count)
+		}
+	}
+	return bw.Flush()
+}
+
+// snapshot copies the family's series, sorted by label values.
+func (f *family) snapshot() []series {
+	var out []series
+	if f.collect != nil {
+		for _, s := range f.collect() {
+			out = append(out, series{values: s.values, value: s.value})
+		}
+	} else {
+		f.mu.Lock()
+		for _, s := range f.series {
+			c := *s
+			c.counts = append([]uint64(nil), s.counts...)
+			out = append(out, c)
+		}
+		f.mu.Unlock()
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return strings.Join(out[i].values, "\xff") < strings.Join(out[j].values, "\xff")
+	})
+	return out
+}
+
+var (
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string { return helpEscaper.Replace(s) }
+
+func labels(names, values []string, extraName, extraValue string) string {
+	if len(names) == 0 && extraName == "" {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, n := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `%s="%s"`, n, labelEscaper.Replace(values[i]))
+	}
+	if extraName != "" {
+		if len(names) > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `%s="%s"`, extraName, extraValue)
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func number(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -9,7 +9,7 @@ type AnomalyDetector struct {
 
 func NewAnomalyDetector() *AnomalyDetector {
 	return &AnomalyDetector{
-		Centroid:  make([]float64, 12),
+		Centroid:  make([]float64, NumFeatures),
 		Threshold: 0,
 	}
 }
@@ -25,7 +25,7 @@ func (a *AnomalyDetector) Train(samples []LabeledSample) {
 		return
 	}
 
-	centroid := make([]float64, 12)
+	centroid := make([]float64, NumFeatures)
 	for _, s := range legitimate {
 		v := s.Features.ToSlice()
 		for i, val := range v {
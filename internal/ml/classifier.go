@@ -10,7 +10,7 @@ type LogisticClassifier struct {
 
 func NewLogisticClassifier() *LogisticClassifier {
 	return &LogisticClassifier{
-		Weights: make([]float64, 12),
+		Weights: make([]float64, NumFeatures),
 		Bias:    0,
 		LR:      0.01,
 	}
@@ -1,10 +1,11 @@
 package ml
 
 import (
+	"GradGuard/internal/logstore"
+	"GradGuard/internal/store"
 	"encoding/json"
 	"math/rand"
 	"os"
-	"path/filepath"
 	"strings"
 )
 
@@ -84,6 +85,11 @@ func GenerateSyntheticData(rng *rand.Rand) []LabeledSample {
 				DetectionCount:   0,
 				SequenceDetected: 0,
 				TimingDetected:   0,
+
+				MeanKeyIntervalMs: 120 + rng.Float64()*250,
+				KeyIntervalCV:     0.4 + rng.Float64()*0.6,
+				PasteRatio:        rng.Float64() * 0.3,
+				BackspaceRate:     0.02 + rng.Float64()*0.08,
 			},
 		})
 	}
@@ -104,6 +110,11 @@ func GenerateSyntheticData(rng *rand.Rand) []LabeledSample {
 				DetectionCount:   0,
 				SequenceDetected: 0,
 				TimingDetected:   0,
+
+				MeanKeyIntervalMs: 0,
+				KeyIntervalCV:     0,
+				PasteRatio:        0.9 + rng.Float64()*0.1,
+				BackspaceRate:     0,
 			},
 		})
 	}
@@ -132,6 +143,11 @@ func GenerateSyntheticData(rng *rand.Rand) []LabeledSample {
 				DetectionCount:   1 + rng.Float64()*4,
 				SequenceDetected: seqDetected,
 				TimingDetected:   timDetected,
+
+				MeanKeyIntervalMs: rng.Float64() * 40,
+				KeyIntervalCV:     rng.Float64() * 0.2,
+				PasteRatio:        0.7 + rng.Float64()*0.3,
+				BackspaceRate:     rng.Float64() * 0.01,
 			},
 		})
 	}
@@ -143,6 +159,10 @@ func GenerateSyntheticData(rng *rand.Rand) []LabeledSample {
 				FingerprintRatio: rng.Float64() * 0.2,
 				ReconRatio:       0.1 + rng.Float64()*0.4,
 				ExploitRatio:     0.3 + rng.Float64()*0.6,
+				PersistenceRatio: rng.Float64() * 0.2,
+				CredentialRatio:  rng.Float64() * 0.15,
+				EvasionRatio:     rng.Float64() * 0.15,
+				DownloadRatio:    rng.Float64() * 0.3,
 				SuspicionScore:   0.7 + rng.Float64()*0.3,
 				AvgDelayMs:       100 + rng.Float64()*1000,
 				MinDelayMs:       50 + rng.Float64()*300,
@@ -152,6 +172,11 @@ func GenerateSyntheticData(rng *rand.Rand) []LabeledSample {
 				DetectionCount:   2 + rng.Float64()*5,
 				SequenceDetected: 0,
 				TimingDetected:   0,
+
+				MeanKeyIntervalMs: rng.Float64() * 300,
+				KeyIntervalCV:     rng.Float64() * 0.8,
+				PasteRatio:        0.4 + rng.Float64()*0.6,
+				BackspaceRate:     rng.Float64() * 0.05,
 			},
 		})
 	}
@@ -161,22 +186,17 @@ func GenerateSyntheticData(rng *rand.Rand) []LabeledSample {
 
 func LoadRealSamples() []LabeledSample {
 	var samples []LabeledSample
-	files, _ := filepath.Glob("logs/reports/*.json")
-	for _, f := range files {
-		data, err := os.ReadFile(f)
-		if err != nil {
-			continue
-		}
+	store.WalkLogs(logstore.Reports, func(_ string, data []byte) {
 		var r reportEntry
 		if err := json.Unmarshal(data, &r); err != nil {
-			continue
+			return
 		}
 		sample, err := ExtractFromLogs(r.SessionID)
 		if err != nil {
-			continue
+			return
 		}
 		samples = append(samples, *sample)
-	}
+	})
 	return samples
 }
 
@@ -185,7 +205,7 @@ func NormalizeSamples(samples []LabeledSample) ([]LabeledSample, []float64, []fl
 		return samples, nil, nil
 	}
 
-	numFeatures := 12
+	numFeatures := NumFeatures
 	mins := make([]float64, numFeatures)
 	maxs := make([]float64, numFeatures)
 
@@ -231,15 +251,26 @@ func sliceToFeatures(v []float64) SessionFeatures {
 		FingerprintRatio: v[0],
 		ReconRatio:       v[1],
 		ExploitRatio:     v[2],
-		SuspicionScore:   v[3],
-		AvgDelayMs:       v[4],
-		MinDelayMs:       v[5],
-		SessionDurationS: v[6],
-		UniqueCommands:   v[7],
-		CommandCount:     v[8],
-		DetectionCount:   v[9],
-		SequenceDetected: v[10],
-		TimingDetected:   v[11],
+		PersistenceRatio: v[3],
+		CredentialRatio:  v[4],
+		EvasionRatio:     v[5],
+		LateralRatio:     v[6],
+		MiningRatio:      v[7],
+		DownloadRatio:    v[8],
+		SuspicionScore:   v[9],
+		AvgDelayMs:       v[10],
+		MinDelayMs:       v[11],
+		SessionDurationS: v[12],
+		UniqueCommands:   v[13],
+		CommandCount:     v[14],
+		DetectionCount:   v[15],
+		SequenceDetected: v[16],
+		TimingDetected:   v[17],
+
+		MeanKeyIntervalMs: v[18],
+		KeyIntervalCV:     v[19],
+		PasteRatio:        v[20],
+		BackspaceRate:     v[21],
 	}
 }
 
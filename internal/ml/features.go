@@ -3,17 +3,24 @@ package ml
 import (
 	sshsession "GradGuard/internal/Session"
 	"GradGuard/internal/analyzer"
+	"GradGuard/internal/logstore"
+	"GradGuard/internal/store"
 	"encoding/json"
 	"math"
-	"os"
-	"path/filepath"
 	"strings"
+	"time"
 )
 
 type SessionFeatures struct {
 	FingerprintRatio float64
 	ReconRatio       float64
 	ExploitRatio     float64
+	PersistenceRatio float64
+	CredentialRatio  float64
+	EvasionRatio     float64
+	LateralRatio     float64
+	MiningRatio      float64
+	DownloadRatio    float64
 	SuspicionScore   float64
 	AvgDelayMs       float64
 	MinDelayMs       float64
@@ -23,8 +30,15 @@ type SessionFeatures struct {
 	DetectionCount   float64
 	SequenceDetected float64
 	TimingDetected   float64
+	// Keystroke dynamics of the session's input.
+	MeanKeyIntervalMs float64
+	KeyIntervalCV     float64
+	PasteRatio        float64
+	BackspaceRate     float64
 }
 
+const NumFeatures = 22
+
 const (
 	LabelLegitimate     = 0
 	LabelBruteForce     = 1
@@ -42,6 +56,12 @@ func (f SessionFeatures) ToSlice() []float64 {
 		f.FingerprintRatio,
 		f.ReconRatio,
 		f.ExploitRatio,
+		f.PersistenceRatio,
+		f.CredentialRatio,
+		f.EvasionRatio,
+		f.LateralRatio,
+		f.MiningRatio,
+		f.DownloadRatio,
 		f.SuspicionScore,
 		f.AvgDelayMs,
 		f.MinDelayMs,
@@ -51,6 +71,10 @@ func (f SessionFeatures) ToSlice() []float64 {
 		f.DetectionCount,
 		f.SequenceDetected,
 		f.TimingDetected,
+		f.MeanKeyIntervalMs,
+		f.KeyIntervalCV,
+		f.PasteRatio,
+		f.BackspaceRate,
 	}
 }
 
@@ -60,10 +84,6 @@ func ExtractFromSession(session *sshsession.SessionState, detectionCount int, se
 		return SessionFeatures{}
 	}
 
-	fp := float64(session.CategoryCounts[string(analyzer.CategoryFingerprint)])
-	rc := float64(session.CategoryCounts[string(analyzer.CategoryRecon)])
-	ex := float64(session.CategoryCounts[string(analyzer.CategoryExploit)])
-
 	seq := 0.0
 	if sequenceDetected {
 		seq = 1.0
@@ -73,16 +93,38 @@ func ExtractFromSession(session *sshsession.SessionState, detectionCount int, se
 		tim = 1.0
 	}
 
-	return SessionFeatures{
-		FingerprintRatio: fp / total,
-		ReconRatio:       rc / total,
-		ExploitRatio:     ex / total,
+	f := SessionFeatures{
 		SuspicionScore:   float64(session.SuspicionScore) / 100.0,
 		CommandCount:     total,
 		DetectionCount:   float64(detectionCount),
 		SequenceDetected: seq,
 		TimingDetected:   tim,
 	}
+	f.setCategoryRatios(session.CategoryCounts, total)
+	f.setKeystrokes(session.Keystrokes.Stats())
+	return f
+}
+
+func (f *SessionFeatures) setCategoryRatios(counts map[string]int, total float64) {
+	ratio := func(c analyzer.Category) float64 {
+		return float64(counts[string(c)]) / total
+	}
+	f.FingerprintRatio = ratio(analyzer.CategoryFingerprint)
+	f.ReconRatio = ratio(analyzer.CategoryRecon)
+	f.ExploitRatio = ratio(analyzer.CategoryExploit)
+	f.PersistenceRatio = ratio(analyzer.CategoryPersistence)
+	f.CredentialRatio = ratio(analyzer.CategoryCredentialAccess)
+	f.EvasionRatio = ratio(analyzer.CategoryDefenseEvasion)
+	f.LateralRatio = ratio(analyzer.CategoryLateralMovement)
+	f.MiningRatio = ratio(analyzer.CategoryCryptomining)
+	f.DownloadRatio = ratio(analyzer.CategoryDownload)
+}
+
+func (f *SessionFeatures) setKeystrokes(k sshsession.KeystrokeStats) {
+	f.MeanKeyIntervalMs = k.MeanIntervalMs
+	f.KeyIntervalCV = k.IntervalCV
+	f.PasteRatio = k.PasteRatio()
+	f.BackspaceRate = k.BackspaceRate()
 }
 
 type commandLogEntry struct {
@@ -100,6 +142,8 @@ type reportEntry struct {
 	CategoryBreakdown   map[string]int `json:"category_breakdown"`
 	FlaggedCommands     []string       `json:"flagged_commands"`
 	SessionID           string         `json:"session_id"`
+
+	Keystrokes sshsession.KeystrokeStats `json:"keystrokes"`
 }
 
 type detectionEntry struct {
@@ -107,8 +151,7 @@ type detectionEntry struct {
 }
 
 func ExtractFromLogs(sessionID string) (*LabeledSample, error) {
-	reportPath := filepath.Join("logs/reports", sessionID+"-report.json")
-	reportData, err := os.ReadFile(reportPath)
+	reportData, err := store.SessionLog(logstore.Reports, sessionID)
 	if err != nil {
 		return nil, err
 	}
@@ -117,11 +160,53 @@ func ExtractFromLogs(sessionID string) (*LabeledSample, error) {
 		return nil, err
 	}
 
-	cmdPath := filepath.Join("logs/sessions", sessionID+".json")
-	cmdData, err := os.ReadFile(cmdPath)
-	if err != nil {
+	total := float64(report.TotalCommands)
+	if total == 0 {
+		total = 1
+	}
+	features := SessionFeatures{
+		SuspicionScore:   float64(report.FinalSuspicionScore) / 100.0,
+		SessionDurationS: report.DurationSeconds,
+		CommandCount:     total,
+	}
+	if err := features.addLogFeatures(sessionID); err != nil {
 		return nil, err
 	}
+	features.setCategoryRatios(report.CategoryBreakdown, total)
+	features.setKeystrokes(report.Keystrokes)
+
+	label := verdictToLabel(report.Verdict)
+
+	return &LabeledSample{Features: features, Label: label}, nil
+}
+
+// ExtractLive builds features for a session that has just ended and has
+// no report yet, taking from the session state what the report would hold.
+func ExtractLive(session *sshsession.SessionState) (SessionFeatures, error) {
+	total := float64(session.CommandCount)
+	if total == 0 {
+		total = 1
+	}
+	features := SessionFeatures{
+		SuspicionScore:   float64(session.SuspicionScore) / 100.0,
+		SessionDurationS: time.Since(session.StartTime).Seconds(),
+		CommandCount:     total,
+	}
+	if err := features.addLogFeatures(session.ID); err != nil {
+		return SessionFeatures{}, err
+	}
+	features.setCategoryRatios(session.CategoryCounts, total)
+	features.setKeystrokes(session.Keystrokes.Stats())
+	return features, nil
+}
+
+// addLogFeatures fills in the timing, variety and detection features
+// from a session's command and detection logs.
+func (f *SessionFeatures) addLogFeatures(sessionID string) error {
+	cmdData, err := store.SessionLog(logstore.Commands, sessionID)
+	if err != nil {
+		return err
+	}
 
 	var delays []float64
 	var uniqueCmds = map[string]bool{}
@@ -142,8 +227,7 @@ func ExtractFromLogs(sessionID string) (*LabeledSample, error) {
 		}
 	}
 
-	detectionPath := filepath.Join("logs/detections", sessionID+"-detections.json")
-	detectionData, _ := os.ReadFile(detectionPath)
+	detectionData, _ := store.SessionLog(logstore.Detections, sessionID)
 	var detectionCount int
 	var sequenceDetected, timingDetected float64
 	for _, line := range strings.Split(string(detectionData), "\n") {
@@ -164,11 +248,6 @@ func ExtractFromLogs(sessionID string) (*LabeledSample, error) {
 		}
 	}
 
-	total := float64(report.TotalCommands)
-	if total == 0 {
-		total = 1
-	}
-
 	avgDelay := 0.0
 	minDelay := math.MaxFloat64
 	for _, d := range delays {
@@ -184,31 +263,21 @@ func ExtractFromLogs(sessionID string) (*LabeledSample, error) {
 		minDelay = 0
 	}
 
-	features := SessionFeatures{
-		FingerprintRatio: float64(report.CategoryBreakdown["fingerprint"]) / total,
-		ReconRatio:       float64(report.CategoryBreakdown["recon"]) / total,
-		ExploitRatio:     float64(report.CategoryBreakdown["exploit"]) / total,
-		SuspicionScore:   float64(report.FinalSuspicionScore) / 100.0,
-		AvgDelayMs:       avgDelay,
-		MinDelayMs:       minDelay,
-		SessionDurationS: report.DurationSeconds,
-		UniqueCommands:   float64(len(uniqueCmds)),
-		CommandCount:     total,
-		DetectionCount:   float64(detectionCount),
-		SequenceDetected: sequenceDetected,
-		TimingDetected:   timingDetected,
-	}
-
-	label := verdictToLabel(report.Verdict)
-
-	return &LabeledSample{Features: features, Label: label}, nil
+	f.AvgDelayMs = avgDelay
+	f.MinDelayMs = minDelay
+	f.UniqueCommands = float64(len(uniqueCmds))
+	f.DetectionCount = float64(detectionCount)
+	f.SequenceDetected = sequenceDetected
+	f.TimingDetected = timingDetected
+	return nil
 }
 
 func verdictToLabel(verdict string) int {
 	switch verdict {
 	case "likely_fingerprinting":
 		return LabelFingerprinting
-	case "exploit_attempt":
+	case analyzer.VerdictExploitAttempt, analyzer.VerdictPersistence, analyzer.VerdictCredentialTheft,
+		analyzer.VerdictLateralMovement, analyzer.VerdictCryptomining:
 		return LabelExploit
 	case "suspicious":
 		return LabelBruteForce
@@ -14,7 +14,7 @@ func NewNaiveBayesClassifier() *NaiveBayesClassifier {
 		ClassPriors:    map[int]float64{},
 		FeatureMeans:   map[int][]float64{},
 		FeatureStdDevs: map[int][]float64{},
-		NumFeatures:    12,
+		NumFeatures:    NumFeatures,
 	}
 }
 
@@ -0,0 +1,38 @@
+package ml
+
+import (
+	sshsession "GradGuard/internal/Session"
+	"GradGuard/internal/analyzer"
+	"sync"
+)
+
+var (
+	sharedOnce  sync.Once
+	sharedModel *Model
+)
+
+// SharedModel is trained once per process, the first time a session ends.
+func SharedModel() *Model {
+	sharedOnce.Do(func() {
+		sharedModel = NewModel()
+		sharedModel.Train()
+	})
+	return sharedModel
+}
+
+// Opinion is the model's view of a session that has just ended, for
+// analyzer.WriteReport to weigh against the rules. It is nil when the
+// session's logs cannot be read.
+func Opinion(session *sshsession.SessionState) *analyzer.MLOpinion {
+	features, err := ExtractLive(session)
+	if err != nil {
+		return nil
+	}
+	pred := SharedModel().Predict(features)
+	return &analyzer.MLOpinion{
+		FingerprintingProb: pred.IsFingerprintingProb,
+		Intent:             pred.Intent,
+		Anomaly:            pred.IsAnomaly,
+		AnomalyScore:       pred.AnomalyScore,
+	}
+}
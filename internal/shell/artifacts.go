@@ -0,0 +1,127 @@
+package shell
+
+import (
+	sshsession "GradGuard/internal/Session"
+	"GradGuard/internal/events"
+	"GradGuard/internal/logstore"
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// maxArtifacts and maxArtifactBytes bound what one session can make
+	// the host keep.
+	maxArtifacts     = 64
+	maxArtifactBytes = 10 << 20
+)
+
+// Kernel and device filesystems change under every container; nothing in
+// them was left by the attacker.
+var artifactSkip = []string{"/proc/", "/sys/", "/dev/", "/run/"}
+
+// captureArtifacts keeps every regular file the session added to or
+// changed in the container, by content hash, and publishes an artifact
+// event for each. It runs after the shell exits and before the container
+// is removed.
+func captureArtifacts(containerName string, session *sshsession.SessionState) {
+	out, err := exec.Command("docker", "diff", containerName).Output()
+	if err != nil {
+		return
+	}
+	captured := 0
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() && captured < maxArtifacts {
+		change, path, ok := strings.Cut(scanner.Text(), " ")
+		if !ok || (change != "A" && change != "C") || skipArtifact(path) {
+			continue
+		}
+		data, ok := copyOut(containerName, path)
+		if !ok {
+			continue
+		}
+		sum := sha256.Sum256(data)
+		digest := hex.EncodeToString(sum[:])
+		if err := keepBlob(session.ID, digest, session.StartTime, data); err != nil {
+			continue
+		}
+		source := "added"
+		if change == "C" {
+			source = "changed"
+		}
+		now := time.Now().UTC()
+		events.Publish(events.Event{
+			Kind:       events.KindArtifact,
+			Time:       now,
+			SessionID:  session.ID,
+			RemoteAddr: session.RemoteAddr,
+			Data: events.Artifact{
+				Timestamp: now.Format(time.RFC3339),
+				Path:      path,
+				SHA256:    digest,
+				Size:      int64(len(data)),
+				Source:    source,
+			},
+		})
+		captured++
+	}
+}
+
+func skipArtifact(path string) bool {
+	for _, prefix := range artifactSkip {
+		if strings.HasPrefix(path+"/", prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// copyOut reads one regular file out of the container. Directories,
+// links and files over the size limit are passed over.
+func copyOut(containerName, path string) ([]byte, bool) {
+	cmd := exec.Command("docker", "cp", containerName+":"+path, "-")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, false
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, false
+	}
+	// Killed rather than drained, so a large file is never read in full.
+	defer cmd.Wait()
+	defer cmd.Process.Kill()
+
+	tr := tar.NewReader(stdout)
+	hdr, err := tr.Next()
+	if err != nil || hdr.Typeflag != tar.TypeReg || hdr.Size > maxArtifactBytes {
+		return nil, false
+	}
+	data, err := io.ReadAll(tr)
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+func keepBlob(sessionID, digest string, start time.Time, data []byte) error {
+	path := logstore.BlobPath(sessionID, digest, start)
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
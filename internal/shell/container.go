@@ -0,0 +1,79 @@
+package shell
+
+import (
+	"GradGuard/internal/deception"
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"time"
+)
+
+// readyTimeout bounds each docker call Ready makes.
+const readyTimeout = 5 * time.Second
+
+// ContainerSpec is how session containers are started. Image may be the
+// deception variant from the Dockerfile; Hostname, when set, replaces the
+// container ID Docker would use; ProcViews mounts the procfs views that
+// host-side responses rewrite; Init is the container's first process,
+// which fingerprinting reads from /proc/1/comm.
+type ContainerSpec struct {
+	Image     string
+	Hostname  string
+	ProcViews bool
+	Init      []string
+}
+
+var containerSpec = ContainerSpec{
+	Image:     "honeypot-base",
+	ProcViews: true,
+	Init:      []string{"sleep", "infinity"},
+}
+
+// SetContainer sets how containers are started; call it before the first
+// session.
+func SetContainer(spec ContainerSpec) {
+	containerSpec = spec
+}
+
+func runArgs(name string, view *deception.ProcView) []string {
+	args := []string{"run", "-d",
+		"--name", name,
+		"--network", "none",
+		"--memory", "128m",
+		"--pids-limit", "64",
+	}
+	if containerSpec.Hostname != "" {
+		args = append(args, "--hostname", containerSpec.Hostname)
+	}
+	if view != nil {
+		args = append(args, view.MountArgs()...)
+	}
+	args = append(args, containerSpec.Image)
+	return append(args, containerSpec.Init...)
+}
+
+// Ready checks that sessions can get a container: the Docker daemon
+// answers and the image they start from is there.
+func Ready() error {
+	if _, err := docker("version", "--format", "{{.Server.Version}}"); err != nil {
+		return err
+	}
+	if _, err := docker("image", "inspect", "--format", "{{.Id}}", containerSpec.Image); err != nil {
+		return fmt.Errorf("image %s: %w", containerSpec.Image, err)
+	}
+	return nil
+}
+
+func docker(args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), readyTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "docker", args...).CombinedOutput()
+	if err != nil {
+		if msg := bytes.TrimSpace(out); len(msg) > 0 {
+			return nil, fmt.Errorf("%s", msg)
+		}
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,22 @@
+package shell
+
+import (
+	sshsession "GradGuard/internal/Session"
+	"io"
+	"time"
+)
+
+// keystrokeReader passes the client's input through to the shell,
+// timing every read for the session's keystroke record.
+type keystrokeReader struct {
+	r    io.Reader
+	keys *sshsession.Keystrokes
+}
+
+func (k *keystrokeReader) Read(p []byte) (int, error) {
+	n, err := k.r.Read(p)
+	if n > 0 {
+		k.keys.Record(p[:n], time.Now())
+	}
+	return n, err
+}
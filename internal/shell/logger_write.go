@@ -4,6 +4,7 @@ import (
 	"GradGuard/JSON/logger"
 	sshsession "GradGuard/internal/Session"
 	"GradGuard/internal/analyzer"
+	"GradGuard/internal/control"
 	"GradGuard/internal/detector"
 	"bytes"
 	"regexp"
@@ -70,18 +71,32 @@ func (l *sessionLogger) Write(p []byte) (n int, err error) {
 		l.session.CommandCount++
 
 		result := analyzer.Analyze(l.session, cmd)
+		control.SetVerdict(l.session.ID, analyzer.Verdict(l.session))
+		rules := make([]string, len(result.Matches))
+		for i, m := range result.Matches {
+			rules[i] = m.RuleID
+		}
 
-		logger.LogCommand(
-			l.session.ID,
-			l.session.RemoteAddr,
-			cmd,
-			delay.Milliseconds(),
-			l.session.CommandCount,
-			string(result.Category),
-			l.session.SuspicionScore,
-			result.Reason,
-		)
-		l.detector.Check(cmd, string(result.Category), delay.Milliseconds())
+		logger.LogCommand(logger.CommandEntry{
+			SessionID:      l.session.ID,
+			RemoteAddr:     l.session.RemoteAddr,
+			Command:        cmd,
+			DelayMs:        delay.Milliseconds(),
+			Index:          l.session.CommandCount,
+			Category:       string(result.Category),
+			SuspicionScore: l.session.SuspicionScore,
+			Reason:         result.Reason,
+			Techniques:     result.Techniques,
+			Rules:          rules,
+			Decoded:        result.Decoded,
+		})
+		l.detector.Check(detector.Observation{
+			Command:    cmd,
+			Category:   string(result.Category),
+			Techniques: result.Techniques,
+			Rules:      rules,
+			DelayMs:    delay.Milliseconds(),
+		})
 
 	}
 
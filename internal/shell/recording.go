@@ -0,0 +1,135 @@
+package shell
+
+import (
+	"GradGuard/internal/logstore"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// recorder writes the session's terminal as an asciicast v2 file: a
+// header line, then one [seconds, "o"|"i"|"r", data] line for every
+// output, input and resize, so the session can be replayed as the
+// attacker saw it.
+type recorder struct {
+	mu    sync.Mutex
+	f     *os.File
+	start time.Time
+	// Reads and writes can end inside a UTF-8 sequence; the rest of it
+	// is held until the next one so the cast keeps the text intact.
+	partial map[string][]byte
+}
+
+type castHeader struct {
+	Version   int               `json:"version"`
+	Width     uint32            `json:"width"`
+	Height    uint32            `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Env       map[string]string `json:"env"`
+}
+
+// newRecorder starts the session's recording. A nil recorder records
+// nothing, so a session whose file cannot be created still runs.
+func newRecorder(sessionID string, start time.Time, pty ptyInfo) (*recorder, error) {
+	path := logstore.Path(logstore.Recordings, sessionID, start)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return nil, err
+	}
+	header, _ := json.Marshal(castHeader{
+		Version:   2,
+		Width:     pty.cols,
+		Height:    pty.rows,
+		Timestamp: start.Unix(),
+		Env:       map[string]string{"TERM": "xterm", "SHELL": "/bin/bash"},
+	})
+	if _, err := f.Write(append(header, '\n')); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &recorder{f: f, start: start, partial: map[string][]byte{}}, nil
+}
+
+func (r *recorder) event(code string, p []byte) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		return
+	}
+	data := append(r.partial[code], p...)
+	cut := len(data)
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				cut = i
+			}
+			break
+		}
+	}
+	r.partial[code] = append([]byte(nil), data[cut:]...)
+	if cut == 0 {
+		return
+	}
+	line, _ := json.Marshal([]any{
+		float64(time.Since(r.start).Microseconds()) / 1e6,
+		code,
+		string(data[:cut]),
+	})
+	r.f.Write(append(line, '\n'))
+}
+
+func (r *recorder) resize(cols, rows uint32) {
+	r.event("r", []byte(fmt.Sprintf("%dx%d", cols, rows)))
+}
+
+// Write records output from the container.
+func (r *recorder) Write(p []byte) (int, error) {
+	r.event("o", p)
+	return len(p), nil
+}
+
+// input records what is read from in.
+func (r *recorder) input(in io.Reader) io.Reader {
+	if r == nil {
+		return in
+	}
+	return &recordedReader{r: in, rec: r}
+}
+
+func (r *recorder) Close() error {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.f == nil {
+		return nil
+	}
+	err := r.f.Close()
+	r.f = nil
+	return err
+}
+
+type recordedReader struct {
+	r   io.Reader
+	rec *recorder
+}
+
+func (rr *recordedReader) Read(p []byte) (int, error) {
+	n, err := rr.r.Read(p)
+	if n > 0 {
+		rr.rec.event("i", p[:n])
+	}
+	return n, err
+}
@@ -4,13 +4,19 @@ import (
 	"GradGuard/JSON/logger"
 	sshsession "GradGuard/internal/Session"
 	"GradGuard/internal/analyzer"
+	"GradGuard/internal/control"
+	"GradGuard/internal/deception"
 	"GradGuard/internal/detector"
+	"GradGuard/internal/events"
+	"GradGuard/internal/metrics"
 	"GradGuard/internal/ml"
+	"GradGuard/internal/tarpit"
 	"encoding/binary"
 	"fmt"
 	"io"
 	"log"
 	"os/exec"
+	"time"
 
 	"golang.org/x/crypto/ssh"
 )
@@ -25,23 +31,45 @@ func RunRealShell(
 
 	containerName := "honeypot-" + session.ID
 
-	prep := exec.Command("docker", "run", "-d",
-		"--name", containerName,
-		"--network", "none",
-		"--memory", "128m",
-		"--pids-limit", "64",
-		"honeypot-base",
-		"sleep", "infinity",
-	)
-	if out, err := prep.CombinedOutput(); err != nil {
-		logger.LogCommand(session.ID, session.RemoteAddr, "[container-start-failed] "+string(out), 0, 0, "unknown", 0, "container failed to start")
+	var view *deception.ProcView
+	if containerSpec.ProcViews {
+		v, err := deception.NewProcView(session.ID)
+		if err != nil {
+			log.Printf("procfs views for %s: %v", session.ID, err)
+		} else {
+			view = v
+			defer deception.Remove(session.ID)
+		}
+	}
+
+	prep := exec.Command("docker", runArgs(containerName, view)...)
+	started := time.Now()
+	out, err := prep.CombinedOutput()
+	if err != nil {
+		metrics.ContainerStart.Observe(time.Since(started).Seconds(), "failed")
+		logger.LogCommand(logger.CommandEntry{
+			SessionID:  session.ID,
+			RemoteAddr: session.RemoteAddr,
+			Command:    "[container-start-failed] " + string(out),
+			Category:   "unknown",
+			Reason:     "container failed to start",
+		})
 		channel.Write([]byte("System error\r\n"))
 		return
 	}
 
+	metrics.ContainerStart.Observe(time.Since(started).Seconds(), "ok")
+	metrics.Containers.Inc()
+	defer metrics.Containers.Dec()
 	defer exec.Command("docker", "rm", "-f", containerName).Run()
 
-	logger.LogCommand(session.ID, session.RemoteAddr, "[container-started]", 0, 0, "unknown", 0, "container started successfully")
+	logger.LogCommand(logger.CommandEntry{
+		SessionID:  session.ID,
+		RemoteAddr: session.RemoteAddr,
+		Command:    "[container-started]",
+		Category:   "unknown",
+		Reason:     "container started successfully",
+	})
 
 	cmd := exec.Command("docker", "exec", "-i",
 		fmt.Sprintf("--env=COLUMNS=%d", pty.cols),
@@ -60,11 +88,24 @@ func RunRealShell(
 		detector:   detector.New(session),
 	}
 
-	cmd.Stdin = channel
-	cmd.Stdout = io.MultiWriter(channel, logWriter)
-	cmd.Stderr = channel
+	pit := tarpit.For(session.ID)
+	defer tarpit.Remove(session.ID)
+
+	rec, err := newRecorder(session.ID, session.StartTime, pty)
+	if err != nil {
+		log.Printf("recording %s: %v", session.ID, err)
+	}
+
+	term := control.NewTerminal(session.ID, pty.cols, pty.rows)
+	defer term.Close()
+
+	screen := pit.Output(channel)
+	cmd.Stdin = pit.Input(rec.input(&keystrokeReader{r: channel, keys: session.Keystrokes}))
+	cmd.Stdout = io.MultiWriter(screen, logWriter, rec, term)
+	cmd.Stderr = io.MultiWriter(screen, rec, term)
 
 	if err := cmd.Start(); err != nil {
+		logWriter.detector.Close()
 		channel.Write([]byte("System error\r\n"))
 		return
 	}
@@ -74,6 +115,8 @@ func RunRealShell(
 			if req.Type == "window-change" && len(req.Payload) >= 8 {
 				cols := binary.BigEndian.Uint32(req.Payload[:4])
 				rows := binary.BigEndian.Uint32(req.Payload[4:])
+				rec.resize(cols, rows)
+				term.Resize(cols, rows)
 				exec.Command("docker", "exec", containerName,
 					"stty", fmt.Sprintf("cols %d rows %d", cols, rows),
 				).Run()
@@ -83,9 +126,36 @@ func RunRealShell(
 	}()
 
 	cmd.Wait()
-	analyzer.WriteReport(session)
+	// Responses still running need the container, and their events belong
+	// in the logs the session's end seals.
+	logWriter.detector.Close()
+	rec.Close()
+	captureArtifacts(containerName, session)
+	reason := "session ended"
+	if wasted := pit.Wasted(); wasted > 0 {
+		reason = fmt.Sprintf("session ended, %s of it in the %s tarpit", wasted.Round(time.Second), pit.Tier())
+	}
+	// Logged before the report so the manifest signed with it covers the
+	// whole session.
+	logger.LogCommand(logger.CommandEntry{
+		SessionID:      session.ID,
+		RemoteAddr:     session.RemoteAddr,
+		Command:        "[session-ended]",
+		Index:          session.CommandCount,
+		Category:       "unknown",
+		SuspicionScore: session.SuspicionScore,
+		Reason:         reason,
+	})
+
+	// The model reads the session's files, so they must be written out
+	// before it is asked, and again before the report is learned from.
+	events.Flush()
+	inference := time.Now()
+	opinion := ml.Opinion(session)
+	metrics.MLInference.Observe(time.Since(inference).Seconds())
+	analyzer.WriteReport(session, opinion)
+	events.Flush()
 	if err := ml.Ingest(session.ID); err != nil {
 		log.Printf("feedback ingest failed for %s: %v", session.ID, err)
 	}
-	logger.LogCommand(session.ID, session.RemoteAddr, "[session-ended]", 0, session.CommandCount, "unknown", session.SuspicionScore, "session ended")
 }
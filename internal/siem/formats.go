@@ -0,0 +1,251 @@
+package siem
+
+import (
+	"GradGuard/internal/attack"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Format turns a record into one message for a SIEM.
+type Format struct {
+	Name string
+	// ContentType is what the message is when posted over HTTP.
+	ContentType string
+	encode      func(r record, o *Options) ([]byte, error)
+}
+
+var formats = map[string]Format{
+	"cef":  {Name: "cef", ContentType: "text/plain", encode: cef},
+	"leef": {Name: "leef", ContentType: "text/plain", encode: leef},
+	"ecs":  {Name: "ecs", ContentType: "application/x-ndjson", encode: ecs},
+	"hec":  {Name: "hec", ContentType: "application/json", encode: hec},
+}
+
+// FormatNames lists the formats by the names the config uses.
+func FormatNames() []string { return []string{"cef", "leef", "ecs", "hec"} }
+
+// field is one key and value of a CEF or LEEF extension, in the order
+// they are written.
+type field struct{ key, value string }
+
+// fields are the extension keys both CEF and LEEF carry, named for CEF.
+// Custom fields are written with their label, and neither when empty.
+func (r record) fields() []field {
+	var f []field
+	add := func(key, value string) {
+		if value != "" {
+			f = append(f, field{key, value})
+		}
+	}
+	custom := func(key, label, value string) {
+		if value != "" {
+			f = append(f, field{key + "Label", label}, field{key, value})
+		}
+	}
+	add("rt", strconv.FormatInt(r.time.UnixMilli(), 10))
+	add("src", r.ip)
+	if r.port > 0 {
+		add("spt", strconv.Itoa(r.port))
+	}
+	custom("cs1", "sessionId", r.sessionID)
+	switch {
+	case r.command != nil:
+		c := r.command
+		custom("cs2", "category", c.Category)
+		custom("cn1", "suspicionScore", strconv.Itoa(c.SuspicionScore))
+		custom("cn2", "commandIndex", strconv.Itoa(c.CommandIndex))
+		custom("cs4", "rules", strings.Join(c.Rules, ","))
+	case r.detection != nil:
+		d := r.detection
+		custom("cs2", "signal", string(d.Signal))
+		custom("cs4", "triggerCommand", d.TriggerCommand)
+		add("act", d.ResponseTaken)
+	case r.report != nil:
+		rep := r.report
+		custom("cs2", "verdict", rep.Verdict)
+		custom("cn1", "peakSuspicionScore", strconv.Itoa(rep.PeakSuspicionScore))
+		custom("cn2", "totalCommands", strconv.Itoa(rep.TotalCommands))
+		add("start", stamp(rep.StartTime))
+		add("end", stamp(rep.EndTime))
+	}
+	custom("cs3", "techniques", techniqueIDs(r.tags()))
+	add("msg", r.message())
+	return f
+}
+
+// stamp is an RFC 3339 time as epoch milliseconds, or empty.
+func stamp(s string) string {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return ""
+	}
+	return strconv.FormatInt(t.UnixMilli(), 10)
+}
+
+var (
+	cefHeader    = strings.NewReplacer(`\`, `\\`, "|", `\|`, "\r", " ", "\n", " ")
+	cefExtension = strings.NewReplacer(`\`, `\\`, "=", `\=`, "\r", `\r`, "\n", `\n`)
+)
+
+// cef is ArcSight Common Event Format:
+// CEF:0|vendor|product|version|signature|name|severity|extension.
+func cef(r record, _ *Options) ([]byte, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "CEF:0|%s|%s|%s|%s|%s|%d|",
+		vendor, product, version, cefHeader.Replace(r.signature()), cefHeader.Replace(r.name()), r.severity())
+	sep := ""
+	for _, f := range r.fields() {
+		b.WriteString(sep + f.key + "=" + cefExtension.Replace(f.value))
+		sep = " "
+	}
+	return []byte(b.String()), nil
+}
+
+// leefKeys renames the CEF keys LEEF has its own names for.
+var leefKeys = map[string]string{
+	"rt":  "devTime",
+	"spt": "srcPort",
+	"act": "action",
+}
+
+var leefValue = strings.NewReplacer("\t", " ", "\r", " ", "\n", " ")
+
+// leef is QRadar Log Event Extended Format 2.0 with tab-separated
+// attributes.
+func leef(r record, _ *Options) ([]byte, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "LEEF:2.0|%s|%s|%s|%s|x09|", vendor, product, version, cefHeader.Replace(r.signature()))
+	attrs := append([]field{
+		{"cat", string(r.kind)},
+		{"sev", strconv.Itoa(r.severity())},
+		{"devTimeFormat", "epoch"},
+	}, r.fields()...)
+	sep := ""
+	for _, f := range attrs {
+		key := f.key
+		if k, ok := leefKeys[key]; ok {
+			key = k
+		}
+		b.WriteString(sep + key + "=" + leefValue.Replace(f.value))
+		sep = "\t"
+	}
+	return []byte(b.String()), nil
+}
+
+const ecsVersion = "8.11.0"
+
+// ecs is one Elastic Common Schema document. What ECS has no field for
+// is kept under gradguard.
+func ecs(r record, _ *Options) ([]byte, error) {
+	doc := map[string]any{
+		"@timestamp": r.time.Format(time.RFC3339Nano),
+		"ecs":        map[string]any{"version": ecsVersion},
+		"message":    r.message(),
+		"observer":   map[string]any{"vendor": vendor, "product": product, "type": "honeypot"},
+		"source":     ecsSource(r),
+	}
+	event := map[string]any{
+		"module":   "gradguard",
+		"dataset":  "gradguard." + string(r.kind),
+		"severity": r.severity(),
+		"category": []string{"intrusion_detection"},
+	}
+	own := map[string]any{"session_id": r.sessionID}
+	switch {
+	case r.command != nil:
+		c := r.command
+		event["kind"], event["type"], event["action"] = "event", []string{"info"}, "command"
+		event["risk_score"] = c.SuspicionScore
+		doc["process"] = map[string]any{"command_line": c.Command}
+		if len(c.Rules) > 0 {
+			doc["rule"] = map[string]any{"id": strings.Join(c.Rules, ",")}
+		}
+		own["category"], own["command_index"] = c.Category, c.CommandIndex
+	case r.detection != nil:
+		d := r.detection
+		event["kind"], event["type"], event["action"] = "alert", []string{"indicator"}, string(d.Signal)
+		doc["rule"] = map[string]any{"name": string(d.Signal), "description": d.Details}
+		own["confidence"], own["response_taken"], own["trigger_command"] = string(d.Confidence), d.ResponseTaken, d.TriggerCommand
+	case r.report != nil:
+		rep := r.report
+		event["kind"], event["type"], event["action"] = "event", []string{"end"}, "session_end"
+		event["risk_score"] = rep.PeakSuspicionScore
+		event["start"], event["end"] = rep.StartTime, rep.EndTime
+		event["duration"] = int64(rep.DurationSeconds * 1e9)
+		own["verdict"], own["total_commands"] = rep.Verdict, rep.TotalCommands
+	}
+	doc["event"] = event
+	doc["gradguard"] = own
+	if threat := ecsThreat(r.tags()); threat != nil {
+		doc["threat"] = threat
+	}
+	return json.Marshal(doc)
+}
+
+func ecsSource(r record) map[string]any {
+	source := map[string]any{"ip": r.ip}
+	if r.port > 0 {
+		source["port"] = r.port
+	}
+	return source
+}
+
+func ecsThreat(tags []attack.Tag) map[string]any {
+	if len(tags) == 0 {
+		return nil
+	}
+	var ids, names, tacticIDs, tacticNames []string
+	seen := map[string]bool{}
+	for _, t := range tags {
+		if !seen[t.Technique] {
+			seen[t.Technique] = true
+			ids = append(ids, t.Technique)
+			names = append(names, attack.TechniqueName(t.Technique))
+		}
+		if tactic, ok := attack.LookupTactic(t.Tactic); ok && !seen[tactic.ID] {
+			seen[tactic.ID] = true
+			tacticIDs = append(tacticIDs, tactic.ID)
+			tacticNames = append(tacticNames, tactic.Name)
+		}
+	}
+	return map[string]any{
+		"framework": "MITRE ATT&CK",
+		"technique": map[string]any{"id": ids, "name": names},
+		"tactic":    map[string]any{"id": tacticIDs, "name": tacticNames},
+	}
+}
+
+// hec is a Splunk HTTP Event Collector event carrying the record as the
+// honeypot logged it.
+func hec(r record, o *Options) ([]byte, error) {
+	var data any
+	switch {
+	case r.command != nil:
+		data = r.command
+	case r.detection != nil:
+		data = r.detection
+	case r.report != nil:
+		data = r.report
+	}
+	host, _ := os.Hostname()
+	e := map[string]any{
+		"time":       float64(r.time.UnixMicro()) / 1e6,
+		"host":       host,
+		"source":     "gradguard",
+		"sourcetype": "gradguard:" + string(r.kind),
+		"event":      data,
+		"fields": map[string]any{
+			"session_id": r.sessionID,
+			"src":        r.ip,
+			"severity":   r.severity(),
+		},
+	}
+	if o != nil && o.Index != "" {
+		e["index"] = o.Index
+	}
+	return json.Marshal(e)
+}
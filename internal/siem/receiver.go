@@ -0,0 +1,175 @@
+package siem
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Receive stands in for a SIEM: it listens on addr and calls fn with
+// every message that arrives, until the listener fails. Network is udp,
+// tcp or tls for syslog, or http or https for posted batches; cert is
+// used for tls and https.
+func Receive(network, addr string, cert *tls.Certificate, fn func(from string, msg []byte)) error {
+	switch network {
+	case "udp":
+		conn, err := net.ListenPacket("udp", addr)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		buf := make([]byte, 64<<10)
+		for {
+			n, from, err := conn.ReadFrom(buf)
+			if err != nil {
+				return err
+			}
+			fn(from.String(), append([]byte(nil), buf[:n]...))
+		}
+	case "tcp", "tls":
+		ln, err := listen(network, addr, cert)
+		if err != nil {
+			return err
+		}
+		defer ln.Close()
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return err
+			}
+			go readStream(conn, fn)
+		}
+	case "http", "https":
+		ln, err := listen(map[string]string{"http": "tcp", "https": "tls"}[network], addr, cert)
+		if err != nil {
+			return err
+		}
+		return http.Serve(ln, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			for _, msg := range splitBody(body, r.Header.Get("Content-Type")) {
+				fn(r.RemoteAddr, msg)
+			}
+			// What Splunk's collector answers, so HEC clients are content.
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"text":"Success","code":0}`))
+		}))
+	}
+	return fmt.Errorf("unknown network %q (want udp, tcp, tls, http or https)", network)
+}
+
+func listen(network, addr string, cert *tls.Certificate) (net.Listener, error) {
+	if network != "tls" {
+		return net.Listen("tcp", addr)
+	}
+	if cert == nil {
+		return nil, fmt.Errorf("a TLS receiver needs a certificate")
+	}
+	return tls.Listen("tcp", addr, &tls.Config{Certificates: []tls.Certificate{*cert}})
+}
+
+// readStream reads syslog off a stream connection, octet-counted or one
+// message a line.
+func readStream(conn net.Conn, fn func(from string, msg []byte)) {
+	defer conn.Close()
+	from := conn.RemoteAddr().String()
+	r := bufio.NewReader(conn)
+	for {
+		first, err := r.Peek(1)
+		if err != nil {
+			return
+		}
+		if first[0] >= '0' && first[0] <= '9' {
+			count, err := r.ReadString(' ')
+			if err != nil {
+				return
+			}
+			n, err := strconv.Atoi(strings.TrimSpace(count))
+			if err != nil || n <= 0 {
+				return
+			}
+			msg := make([]byte, n)
+			if _, err := io.ReadFull(r, msg); err != nil {
+				return
+			}
+			fn(from, msg)
+			continue
+		}
+		line, err := r.ReadBytes('\n')
+		if msg := bytes.TrimRight(line, "\r\n"); len(msg) > 0 {
+			fn(from, msg)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// splitBody splits a posted batch into its messages: JSON values one
+// after another for JSON, lines for anything else.
+func splitBody(body []byte, contentType string) [][]byte {
+	var msgs [][]byte
+	if strings.HasPrefix(contentType, "application/json") {
+		dec := json.NewDecoder(bytes.NewReader(body))
+		for {
+			var v json.RawMessage
+			if err := dec.Decode(&v); err != nil {
+				break
+			}
+			msgs = append(msgs, v)
+		}
+		return msgs
+	}
+	for _, line := range bytes.Split(body, []byte("\n")) {
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			msgs = append(msgs, line)
+		}
+	}
+	return msgs
+}
+
+// SelfSigned makes a throwaway certificate for a stand-in receiver on
+// this machine, and its PEM for the sender's CAFile.
+func SelfSigned() (tls.Certificate, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "gradguard stand-in receiver"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(7 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	cert := tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+	return cert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), nil
+}
@@ -0,0 +1,161 @@
+// Package siem ships honeypot events to security information and event
+// management systems: commands, detections and session reports mapped to
+// ArcSight CEF, QRadar LEEF, Elastic Common Schema or Splunk HEC, sent
+// over syslog or HTTP, retried, and spooled to disk while the receiver
+// is away.
+package siem
+
+import (
+	"GradGuard/JSON/logger"
+	"GradGuard/internal/analyzer"
+	"GradGuard/internal/attack"
+	"GradGuard/internal/detector"
+	"GradGuard/internal/events"
+	"encoding/json"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	vendor  = "GradGuard"
+	product = "Honeypot"
+	version = "1.0"
+)
+
+// record is an event the adapters map: exactly one of command, detection
+// and report is set.
+type record struct {
+	kind      events.Kind
+	time      time.Time
+	sessionID string
+	ip        string
+	port      int
+
+	command   *logger.CommandEvent
+	detection *detector.DetectionEvent
+	report    *analyzer.SessionReport
+}
+
+// decode reads the event's record back into its producer's type, which
+// works the same for live events and ones replayed from the store. Kinds
+// the adapters do not map are skipped.
+func decode(e events.Event) (record, bool) {
+	r := record{kind: e.Kind, time: e.Time.UTC(), sessionID: e.SessionID}
+	r.ip, r.port = splitAddr(e.RemoteAddr)
+	data, err := json.Marshal(e.Data)
+	if err != nil {
+		return r, false
+	}
+	switch e.Kind {
+	case events.KindCommand:
+		r.command = &logger.CommandEvent{}
+		err = json.Unmarshal(data, r.command)
+	case events.KindDetection:
+		r.detection = &detector.DetectionEvent{}
+		err = json.Unmarshal(data, r.detection)
+	case events.KindSessionEnd:
+		r.report = &analyzer.SessionReport{}
+		err = json.Unmarshal(data, r.report)
+	default:
+		return r, false
+	}
+	return r, err == nil
+}
+
+func splitAddr(addr string) (string, int) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr, 0
+	}
+	n, _ := strconv.Atoi(port)
+	return host, n
+}
+
+// severity is the event's weight from 0 to 10, as CEF and LEEF have it.
+func (r record) severity() int {
+	switch {
+	case r.command != nil:
+		return min(r.command.SuspicionScore/10, 10)
+	case r.detection != nil:
+		switch r.detection.Confidence {
+		case detector.ConfidenceCritical:
+			return 10
+		case detector.ConfidenceHigh:
+			return 8
+		}
+		return 5
+	case r.report != nil:
+		switch r.report.Verdict {
+		case analyzer.VerdictClean:
+			return 1
+		case analyzer.VerdictSuspicious, analyzer.VerdictLikelyFingerprinting:
+			return 5
+		}
+		return 8
+	}
+	return 0
+}
+
+// signature is the event's class: what CEF calls its signature ID and
+// LEEF its event ID.
+func (r record) signature() string {
+	switch {
+	case r.command != nil:
+		return "command:" + r.command.Category
+	case r.detection != nil:
+		return "detection:" + string(r.detection.Signal)
+	case r.report != nil:
+		return "session:" + r.report.Verdict
+	}
+	return string(r.kind)
+}
+
+func (r record) name() string {
+	switch {
+	case r.command != nil:
+		return "Command executed"
+	case r.detection != nil:
+		return strings.ReplaceAll(string(r.detection.Signal), "_", " ") + " detected"
+	case r.report != nil:
+		return "Session ended"
+	}
+	return string(r.kind)
+}
+
+func (r record) message() string {
+	switch {
+	case r.command != nil:
+		return r.command.Command
+	case r.detection != nil:
+		return r.detection.Details
+	case r.report != nil:
+		return "verdict " + r.report.Verdict + " after " + strconv.Itoa(r.report.TotalCommands) + " commands"
+	}
+	return ""
+}
+
+func (r record) tags() []attack.Tag {
+	switch {
+	case r.command != nil:
+		return r.command.Techniques
+	case r.detection != nil:
+		return r.detection.Techniques
+	case r.report != nil:
+		var tags []attack.Tag
+		for _, t := range r.report.Techniques {
+			tags = append(tags, attack.Tag{Tactic: t.Tactic, Technique: t.Technique})
+		}
+		return tags
+	}
+	return nil
+}
+
+func techniqueIDs(tags []attack.Tag) string {
+	ids := make([]string, 0, len(tags))
+	for _, t := range tags {
+		ids = append(ids, t.Technique)
+	}
+	return strings.Join(ids, ",")
+}
@@ -0,0 +1,266 @@
+package siem
+
+import (
+	"GradGuard/internal/events"
+	"GradGuard/internal/logstore"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRetries = 3
+	defaultTimeout = 10 * time.Second
+	// firstBackoff is how soon a spooled batch is first retried; it
+	// doubles after each failed try.
+	firstBackoff = 500 * time.Millisecond
+	// retryInterval is how often a spool is retried when no new events
+	// come along to carry it.
+	retryInterval = 30 * time.Second
+	// drainBatch is how many spooled messages go out at a time.
+	drainBatch = 64
+)
+
+// Options is one SIEM output: a format and where it goes. With URL set
+// messages are posted over HTTP; otherwise they go as syslog to Address
+// over Network, which is udp, tcp or tls.
+type Options struct {
+	Format  string
+	URL     string
+	Headers map[string]string
+	Network string
+	Address string
+	// Tag is the syslog app name.
+	Tag string
+	// CAFile is a PEM bundle to trust instead of the system roots, for
+	// TLS syslog and HTTPS.
+	CAFile string
+	// Token is a Splunk HEC token and Index the index events go to.
+	Token string
+	Index string
+	// Timeout bounds each connection and request.
+	Timeout time.Duration
+	// Retries is how many times a spooled batch is retried with backoff
+	// before the spool waits for retryInterval; 0 means 3 and a negative
+	// number none.
+	Retries int
+	// SpoolPath holds undelivered messages, up to SpoolBytes (0 for no
+	// limit). It defaults to a file under logs/spool named for the sink.
+	SpoolPath  string
+	SpoolBytes int64
+}
+
+// Sink is an event sink that maps events to a SIEM format and ships
+// them, spooling to disk what cannot be delivered.
+type Sink struct {
+	name   string
+	format Format
+	opts   Options
+	t      transport
+	spool  *spool
+
+	// mu guards the spool; draining is set while retry sends what it
+	// loaded from it.
+	mu       sync.Mutex
+	draining bool
+	kick     chan struct{}
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+func New(o Options) (*Sink, error) {
+	f, ok := formats[o.Format]
+	if !ok {
+		return nil, fmt.Errorf("unknown SIEM format %q (want one of %s)", o.Format, strings.Join(FormatNames(), ", "))
+	}
+	if o.Timeout <= 0 {
+		o.Timeout = defaultTimeout
+	}
+	if o.Retries == 0 {
+		o.Retries = defaultRetries
+	}
+
+	s := &Sink{format: f, opts: o, kick: make(chan struct{}, 1), stop: make(chan struct{}), done: make(chan struct{})}
+	var err error
+	switch {
+	case o.URL != "":
+		s.name = "siem:" + f.Name + ":" + o.URL
+		s.t, err = newHTTP(o)
+	case o.Network == "udp" || o.Network == "tcp" || o.Network == "tls":
+		s.name = "siem:" + f.Name + ":" + o.Network + "://" + o.Address
+		s.t, err = newSyslog(o)
+	default:
+		return nil, fmt.Errorf("SIEM output needs a url, or an address with network udp, tcp or tls")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	path := o.SpoolPath
+	if path == "" {
+		clean := strings.NewReplacer(":", "_", "/", "_", "?", "_", "&", "_").Replace(strings.TrimPrefix(s.name, "siem:"))
+		path = filepath.Join(logstore.Root, "spool", clean+".jsonl")
+	}
+	s.spool = &spool{path: path, maxBytes: o.SpoolBytes}
+	go s.retry()
+	return s, nil
+}
+
+func (s *Sink) Name() string { return s.name }
+
+// Write sends the events the format maps. A batch the receiver fails is
+// spooled at once rather than retried here, so an outage never holds up
+// the bus; while anything is spooled new messages join the spool, and
+// retry delivers it in order.
+func (s *Sink) Write(batch []events.Event) error {
+	var msgs []message
+	for _, e := range batch {
+		r, ok := decode(e)
+		if !ok {
+			continue
+		}
+		data, err := s.format.encode(r, &s.opts)
+		if err != nil {
+			log.Printf("%s: %s: %v", s.name, r.signature(), err)
+			continue
+		}
+		msgs = append(msgs, message{Data: data, Severity: r.severity()})
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var err error
+	if !s.draining && s.spool.size() == 0 {
+		if err = s.t.Send(msgs, s.format.ContentType); err == nil {
+			return nil
+		}
+		defer s.wake()
+	}
+	dropped, serr := s.spool.add(msgs)
+	switch {
+	case serr != nil && err != nil:
+		return fmt.Errorf("%w; spool: %v", err, serr)
+	case serr != nil:
+		return fmt.Errorf("spool: %w", serr)
+	case dropped > 0 && err != nil:
+		return fmt.Errorf("%w; spool full, %d events dropped", err, dropped)
+	case dropped > 0:
+		return fmt.Errorf("spool full, %d events dropped", dropped)
+	case err != nil:
+		return fmt.Errorf("%w; %d events spooled", err, len(msgs))
+	}
+	return nil
+}
+
+// wake has retry start on the spool now rather than at its next tick.
+func (s *Sink) wake() {
+	select {
+	case s.kick <- struct{}{}:
+	default:
+	}
+}
+
+// drain sends what is spooled, keeping whatever is left if the receiver
+// fails part way through. It sends without holding s.mu, so Write can
+// spool behind it meanwhile.
+func (s *Sink) drain() error {
+	s.mu.Lock()
+	if s.spool.size() == 0 {
+		s.mu.Unlock()
+		return nil
+	}
+	msgs, err := s.spool.load()
+	if err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	s.draining = true
+	s.mu.Unlock()
+
+	sent := 0
+	for sent < len(msgs) {
+		n := min(len(msgs)-sent, drainBatch)
+		if err = s.t.Send(msgs[sent:sent+n], s.format.ContentType); err != nil {
+			break
+		}
+		sent += n
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.draining = false
+	// Write only ever appends, so what it spooled meanwhile follows the
+	// messages loaded above.
+	all, lerr := s.spool.load()
+	if lerr == nil {
+		lerr = s.spool.keep(all[min(sent, len(all)):])
+	}
+	switch {
+	case err != nil && lerr != nil:
+		return fmt.Errorf("%w; spool: %v", err, lerr)
+	case lerr != nil:
+		return lerr
+	}
+	return err
+}
+
+// Spooled is how many bytes of messages are waiting to be delivered.
+func (s *Sink) Spooled() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.spool.size()
+}
+
+// retry drains the spool: soon after a failure, then backing off for
+// Retries tries, then every retryInterval, so a receiver that comes back
+// gets the backlog even while the honeypot is quiet.
+func (s *Sink) retry() {
+	defer close(s.done)
+	timer := time.NewTimer(retryInterval)
+	defer timer.Stop()
+	backoff, tries := firstBackoff, 0
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-s.kick:
+			backoff, tries = firstBackoff, 0
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(backoff)
+			continue
+		case <-timer.C:
+		}
+		wait := retryInterval
+		if err := s.drain(); err != nil {
+			if tries < s.opts.Retries {
+				wait = backoff
+				backoff *= 2
+				tries++
+			} else {
+				log.Printf("%s: spool: %v", s.name, err)
+			}
+		} else {
+			backoff, tries = firstBackoff, 0
+		}
+		timer.Reset(wait)
+	}
+}
+
+func (s *Sink) Close() error {
+	close(s.stop)
+	<-s.done
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.t.Close()
+}
@@ -0,0 +1,100 @@
+package siem
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// message is one formatted event and the severity syslog files it at.
+type message struct {
+	Data     []byte `json:"data"`
+	Severity int    `json:"severity"`
+}
+
+// spool keeps messages the receiver could not take, one JSON object a
+// line, until it can. Once it holds maxBytes new messages are dropped:
+// the oldest are the ones a SIEM most needs to see in order.
+type spool struct {
+	path     string
+	maxBytes int64
+}
+
+func (s *spool) size() int64 {
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// add appends msgs and returns how many did not fit.
+func (s *spool) add(msgs []message) (int, error) {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return len(msgs), err
+	}
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return len(msgs), err
+	}
+	defer f.Close()
+	size := s.size()
+	for i, msg := range msgs {
+		line, _ := json.Marshal(msg)
+		line = append(line, '\n')
+		if s.maxBytes > 0 && size+int64(len(line)) > s.maxBytes {
+			return len(msgs) - i, nil
+		}
+		if _, err := f.Write(line); err != nil {
+			return len(msgs) - i, err
+		}
+		size += int64(len(line))
+	}
+	return 0, nil
+}
+
+// load reads every spooled message.
+func (s *spool) load() ([]message, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var msgs []message
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 16<<20)
+	for scanner.Scan() {
+		var msg message
+		if json.Unmarshal(scanner.Bytes(), &msg) == nil && len(msg.Data) > 0 {
+			msgs = append(msgs, msg)
+		}
+	}
+	return msgs, nil
+}
+
+// keep replaces the spool with msgs, the ones still undelivered.
+func (s *spool) keep(msgs []message) error {
+	if len(msgs) == 0 {
+		if err := os.Remove(s.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	var buf bytes.Buffer
+	for _, msg := range msgs {
+		line, _ := json.Marshal(msg)
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, buf.Bytes(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,186 @@
+package siem
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// transport delivers formatted messages. Send either delivers them all
+// or returns an error; what was sent before the error may arrive twice
+// when the batch is retried.
+type transport interface {
+	Send(msgs []message, contentType string) error
+	Close() error
+}
+
+// syslogTransport frames each message as RFC 5424 syslog: one datagram a
+// message over UDP, octet-counted (RFC 6587) over TCP and TLS.
+type syslogTransport struct {
+	network, addr, tag string
+	tls                *tls.Config
+	timeout            time.Duration
+	conn               net.Conn
+	host               string
+}
+
+// Facility local0; detections and alerts go out at warning.
+const (
+	facility = 16
+	warning  = 4
+	info     = 6
+)
+
+func newSyslog(o Options) (*syslogTransport, error) {
+	t := &syslogTransport{network: o.Network, addr: o.Address, tag: o.Tag, timeout: o.Timeout}
+	if t.tag == "" {
+		t.tag = "gradguard"
+	}
+	t.host, _ = os.Hostname()
+	if t.host == "" {
+		t.host = "-"
+	}
+	if o.Network == "tls" {
+		cfg, err := tlsConfig(o.Address, o.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		t.tls = cfg
+	}
+	return t, nil
+}
+
+func tlsConfig(addr, caFile string) (*tls.Config, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	cfg := &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}
+	if caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%s holds no certificates", caFile)
+		}
+		cfg.RootCAs = pool
+	}
+	return cfg, nil
+}
+
+func (t *syslogTransport) dial() error {
+	dialer := &net.Dialer{Timeout: t.timeout}
+	var err error
+	switch t.network {
+	case "tls":
+		t.conn, err = tls.DialWithDialer(dialer, "tcp", t.addr, t.tls)
+	default:
+		t.conn, err = dialer.Dial(t.network, t.addr)
+	}
+	return err
+}
+
+// frame is the RFC 5424 line for a message. The priority comes from the
+// message's severity so receivers can filter without parsing it.
+func (t *syslogTransport) frame(msg []byte, severity int) []byte {
+	level := info
+	if severity >= 5 {
+		level = warning
+	}
+	header := fmt.Sprintf("<%d>1 %s %s %s %d - - ",
+		facility*8+level, time.Now().UTC().Format(time.RFC3339Nano), t.host, t.tag, os.Getpid())
+	return append([]byte(header), msg...)
+}
+
+func (t *syslogTransport) Send(msgs []message, _ string) error {
+	if t.conn == nil {
+		if err := t.dial(); err != nil {
+			return err
+		}
+	}
+	t.conn.SetWriteDeadline(time.Now().Add(t.timeout))
+	for _, msg := range msgs {
+		line := t.frame(msg.Data, msg.Severity)
+		if t.network != "udp" {
+			line = append([]byte(strconv.Itoa(len(line))+" "), line...)
+		}
+		if _, err := t.conn.Write(line); err != nil {
+			// Dial again next time; the receiver may have restarted.
+			t.conn.Close()
+			t.conn = nil
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *syslogTransport) Close() error {
+	if t.conn == nil {
+		return nil
+	}
+	return t.conn.Close()
+}
+
+// httpTransport posts each batch, one message a line.
+type httpTransport struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+func newHTTP(o Options) (*httpTransport, error) {
+	client := &http.Client{Timeout: o.Timeout}
+	if o.CAFile != "" {
+		cfg, err := tlsConfig("", o.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.ServerName = ""
+		client.Transport = &http.Transport{TLSClientConfig: cfg}
+	}
+	headers := map[string]string{}
+	for k, v := range o.Headers {
+		headers[k] = v
+	}
+	if o.Token != "" {
+		headers["Authorization"] = "Splunk " + o.Token
+	}
+	return &httpTransport{url: o.URL, headers: headers, client: client}, nil
+}
+
+func (h *httpTransport) Send(msgs []message, contentType string) error {
+	var body bytes.Buffer
+	for _, msg := range msgs {
+		body.Write(msg.Data)
+		body.WriteByte('\n')
+	}
+	req, err := http.NewRequest(http.MethodPost, h.url, &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range h.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("%s", resp.Status)
+	}
+	return nil
+}
+
+func (h *httpTransport) Close() error { return nil }
@@ -0,0 +1,49 @@
+package sshserver
+
+import (
+	"GradGuard/internal/alert"
+	"GradGuard/internal/config"
+	"time"
+)
+
+// AlertOptions turns the alerts config into the outputs and rules the
+// alert sink routes by.
+func AlertOptions(c config.AlertsConfig) alert.Options {
+	var o alert.Options
+	for _, out := range c.Outputs {
+		o.Outputs = append(o.Outputs, alert.OutputOptions{
+			Name:     out.Name,
+			Type:     out.Type,
+			URL:      out.URL,
+			Headers:  out.Headers,
+			Address:  out.Address,
+			From:     out.From,
+			To:       out.To,
+			User:     out.User,
+			Password: out.Password,
+			Timeout:  time.Duration(out.TimeoutSeconds) * time.Second,
+		})
+	}
+	for _, r := range c.Rules {
+		rule := alert.Rule{
+			Name:          r.Name,
+			MinSeverity:   r.MinSeverity,
+			Signals:       r.Signals,
+			Verdicts:      r.Verdicts,
+			Outputs:       r.Outputs,
+			Continue:      r.Continue,
+			Per:           r.Per,
+			Dedupe:        time.Duration(r.DedupeMinutes) * time.Minute,
+			ThrottleCount: r.ThrottleCount,
+			Throttle:      time.Duration(r.ThrottleMinutes) * time.Minute,
+			Digest:        time.Duration(r.DigestMinutes) * time.Minute,
+			Title:         r.Title,
+			Body:          r.Body,
+		}
+		for _, k := range r.Kinds {
+			rule.Kinds = append(rule.Kinds, alert.Kind(k))
+		}
+		o.Rules = append(o.Rules, rule)
+	}
+	return o
+}
@@ -1,6 +1,7 @@
 package sshserver
 
 import (
+	"GradGuard/internal/events"
 	"log"
 
 	"golang.org/x/crypto/ssh"
@@ -8,5 +9,16 @@ import (
 
 func passwordCallback(conn ssh.ConnMetadata, pass []byte) (*ssh.Permissions, error) {
 	log.Printf("Login attempted by user = %s, pass = %s, ip = %s", conn.User(), string(pass), conn.RemoteAddr())
+	events.Publish(events.Event{
+		Kind:       events.KindAuth,
+		RemoteAddr: conn.RemoteAddr().String(),
+		Data: events.Auth{
+			User:     conn.User(),
+			Password: string(pass),
+			Method:   "password",
+			Client:   string(conn.ClientVersion()),
+			Accepted: true,
+		},
+	})
 	return nil, nil
 }
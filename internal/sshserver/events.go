@@ -0,0 +1,75 @@
+package sshserver
+
+import (
+	"GradGuard/internal/alert"
+	"GradGuard/internal/config"
+	"GradGuard/internal/control"
+	"GradGuard/internal/events"
+	"GradGuard/internal/metrics"
+	"GradGuard/internal/siem"
+	"GradGuard/internal/store"
+	"log"
+	"time"
+)
+
+// eventBus builds the process event bus: the session files and the
+// event store the CLI and the model read, the metrics counters, the
+// control socket's watchers, each configured sink, then alerting when it
+// has rules.
+func eventBus(c config.EventsConfig, a config.AlertsConfig) *events.Bus {
+	sinks := []events.Sink{events.NewSessionFiles()}
+	if s, err := store.OpenShared(); err != nil {
+		log.Printf("event store: %v — sessions are only logged to files", err)
+	} else {
+		sinks = append(sinks, s)
+	}
+	sinks = append(sinks, metrics.Sink{}, control.Sink{})
+	for _, s := range c.Sinks {
+		switch s.Type {
+		case "jsonl":
+			sinks = append(sinks, events.NewJSONLFile(s.Path, int64(s.MaxMB)<<20, s.Keep))
+		case "stdout":
+			sinks = append(sinks, events.Stdout{})
+		case "syslog":
+			sinks = append(sinks, events.NewSyslog(s.Network, s.Address, s.Tag))
+		case "webhook":
+			sinks = append(sinks, events.NewWebhook(s.URL, s.Headers, time.Duration(s.TimeoutSeconds)*time.Second))
+		case "unix":
+			sinks = append(sinks, events.NewUnixSocket(s.Path))
+		case "siem":
+			sink, err := siem.New(SIEMOptions(s))
+			if err != nil {
+				log.Printf("siem sink: %v", err)
+				continue
+			}
+			sinks = append(sinks, sink)
+		}
+	}
+	if len(a.Rules) > 0 {
+		if sink, err := alert.New(AlertOptions(a)); err != nil {
+			log.Printf("alerts: %v", err)
+		} else {
+			sinks = append(sinks, sink)
+		}
+	}
+	return events.NewBus(c.QueueSize, sinks...)
+}
+
+// SIEMOptions turns a siem sink's config into the output it describes.
+func SIEMOptions(c config.SinkConfig) siem.Options {
+	return siem.Options{
+		Format:     c.Format,
+		URL:        c.URL,
+		Headers:    c.Headers,
+		Network:    c.Network,
+		Address:    c.Address,
+		Tag:        c.Tag,
+		CAFile:     c.CAFile,
+		Token:      c.Token,
+		Index:      c.Index,
+		Timeout:    time.Duration(c.TimeoutSeconds) * time.Second,
+		Retries:    c.Retries,
+		SpoolPath:  c.SpoolPath,
+		SpoolBytes: int64(c.SpoolMB) << 20,
+	}
+}
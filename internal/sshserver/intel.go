@@ -0,0 +1,29 @@
+package sshserver
+
+import (
+	"GradGuard/internal/config"
+	"GradGuard/internal/export"
+	"time"
+)
+
+// IntelOptions turns the intel config into what shared intel is built
+// from.
+func IntelOptions(c config.IntelConfig) export.IntelOptions {
+	return export.IntelOptions{
+		Filter: export.Filter{Verdicts: c.Verdicts},
+		TLP:    c.TLP,
+	}
+}
+
+// TAXIIOptions turns the intel config into the feed "honeypot taxii" and
+// the honeypot serve.
+func TAXIIOptions(c config.IntelConfig) export.TAXIIOptions {
+	return export.TAXIIOptions{
+		Intel:    IntelOptions(c),
+		Window:   time.Duration(c.WindowDays) * 24 * time.Hour,
+		User:     c.TAXIIUser,
+		Password: c.TAXIIPassword,
+		CertFile: c.TAXIICert,
+		KeyFile:  c.TAXIIKey,
+	}
+}
@@ -0,0 +1,51 @@
+package sshserver
+
+import (
+	"GradGuard/internal/config"
+	"net"
+	"sync"
+)
+
+// limiter counts open connections against the configured caps.
+type limiter struct {
+	max, perIP int
+
+	mu    sync.Mutex
+	total int
+	byIP  map[string]int
+}
+
+func newLimiter(c config.LimitsConfig) *limiter {
+	return &limiter{max: c.MaxConnections, perIP: c.MaxPerIP, byIP: map[string]int{}}
+}
+
+// admit takes a slot for a connection from addr, or reports that a cap
+// is reached. Each admitted connection is released once.
+func (l *limiter) admit(addr net.Addr) bool {
+	ip := hostOf(addr)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if (l.max > 0 && l.total >= l.max) || (l.perIP > 0 && l.byIP[ip] >= l.perIP) {
+		return false
+	}
+	l.total++
+	l.byIP[ip]++
+	return true
+}
+
+func (l *limiter) release(addr net.Addr) {
+	ip := hostOf(addr)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.total--
+	if l.byIP[ip]--; l.byIP[ip] <= 0 {
+		delete(l.byIP, ip)
+	}
+}
+
+func hostOf(addr net.Addr) string {
+	if host, _, err := net.SplitHostPort(addr.String()); err == nil {
+		return host
+	}
+	return addr.String()
+}
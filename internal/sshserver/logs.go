@@ -0,0 +1,17 @@
+package sshserver
+
+import (
+	"GradGuard/internal/config"
+	"GradGuard/internal/logstore"
+	"time"
+)
+
+// LogPolicy turns the logs config into the retention policy the janitor
+// and "honeypot logs" apply.
+func LogPolicy(c config.LogsConfig) logstore.Policy {
+	return logstore.Policy{
+		ArchiveAfter:  time.Duration(c.ArchiveAfterHours) * time.Hour,
+		MaxAge:        time.Duration(c.MaxAgeDays) * 24 * time.Hour,
+		MaxTotalBytes: int64(c.MaxTotalMB) << 20,
+	}
+}
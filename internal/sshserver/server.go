@@ -1,13 +1,75 @@
 package sshserver
 
 import (
+	"GradGuard/internal/analyzer"
+	"GradGuard/internal/config"
+	"GradGuard/internal/control"
+	"GradGuard/internal/detector"
+	"GradGuard/internal/events"
+	"GradGuard/internal/evidence"
+	"GradGuard/internal/export"
+	"GradGuard/internal/logstore"
+	"GradGuard/internal/metrics"
+	"GradGuard/internal/shell"
+	"GradGuard/internal/tarpit"
 	"log"
 	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"golang.org/x/crypto/ssh"
 )
 
 func Start(addr string) {
+	cfg := config.Get()
+	evidence.SetKeyPath(cfg.Evidence.SigningKey)
+	if _, err := evidence.SigningKey(); err != nil {
+		log.Fatalf("evidence config: %v", err)
+	}
+	events.SetBus(eventBus(cfg.Events, cfg.Alerts))
+	go closeOnSignal()
+	if cfg.Logs.JanitorMinutes > 0 {
+		go logstore.Janitor(LogPolicy(cfg.Logs), time.Duration(cfg.Logs.JanitorMinutes)*time.Minute)
+	}
+	if cfg.Intel.TAXIIListen != "" {
+		go func() {
+			log.Printf("TAXII feed listening on %s", cfg.Intel.TAXIIListen)
+			log.Printf("taxii: %v", export.ServeTAXII(cfg.Intel.TAXIIListen, TAXIIOptions(cfg.Intel)))
+		}()
+	}
+	if cfg.Admin.Listen != "" {
+		go func() {
+			log.Printf("Admin listener on %s", cfg.Admin.Listen)
+			log.Printf("admin: %v", metrics.Serve(cfg.Admin.Listen, readiness()))
+		}()
+	}
+	if cfg.Control.Socket != "" {
+		go func() {
+			log.Printf("control: %v", control.Serve(cfg.Control.Socket))
+		}()
+	}
+	go analyzer.WatchRules(cfg.Rules.Dir, time.Duration(cfg.Rules.ReloadSeconds)*time.Second)
+	analyzer.SetScoring(scoringModel(cfg.Scoring))
+	if err := detector.SetProfiles(detectorProfiles(cfg.Detector)); err != nil {
+		log.Fatalf("detector config: %v", err)
+	}
+	if len(cfg.Tarpit.Tiers) > 0 {
+		if err := tarpit.SetTiers(tarpitTiers(cfg.Tarpit)); err != nil {
+			log.Fatalf("tarpit config: %v", err)
+		}
+	}
+	if err := detector.SetResponse(cfg.Response.DryRun, ResponsePlaybooks(cfg.Response)); err != nil {
+		log.Fatalf("response config: %v", err)
+	}
+	shell.SetContainer(shell.ContainerSpec{
+		Image:     cfg.Deception.Image,
+		Hostname:  cfg.Deception.Hostname,
+		ProcViews: cfg.Deception.ProcViews,
+		Init:      cfg.Deception.Init,
+	})
+
 	config := &ssh.ServerConfig{
 		PasswordCallback: passwordCallback,
 	}
@@ -22,11 +84,122 @@ func Start(addr string) {
 
 	log.Printf("SSH Honeypot listening on %s", addr)
 
+	limits := newLimiter(cfg.Limits)
 	for {
 		conn, err := listener.Accept()
 		if err != nil {
 			continue
 		}
-		go handleConn(conn, config)
+		if !limits.admit(conn.RemoteAddr()) {
+			metrics.Connections.Inc("limited")
+			conn.Close()
+			continue
+		}
+		metrics.Connections.Inc("accepted")
+		go func() {
+			defer limits.release(conn.RemoteAddr())
+			handleConn(conn, config)
+		}()
+	}
+}
+
+// closeOnSignal closes the event bus when the sensor is told to stop, so
+// its sinks write out what they hold, digests still gathering included.
+func closeOnSignal() {
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	sig := <-stop
+	log.Printf("%s: closing event sinks", sig)
+	events.Close()
+	os.Exit(0)
+}
+
+// readiness is what /readyz checks: that sessions can get a container
+// and that their logs can be written.
+func readiness() []metrics.Check {
+	return []metrics.Check{
+		{Name: "docker", Run: shell.Ready},
+		{Name: "logs", Run: logstore.Writable},
+	}
+}
+
+func scoringModel(c config.ScoringConfig) analyzer.ScoringModel {
+	m := analyzer.ScoringModel{
+		HalfLife:     time.Duration(c.HalfLifeSeconds) * time.Second,
+		RepeatFactor: c.RepeatFactor,
+		CategoryCaps: map[analyzer.Category]float64{},
+	}
+	for name, limit := range c.CategoryCaps {
+		m.CategoryCaps[analyzer.Category(name)] = float64(limit)
+	}
+	return m
+}
+
+func detectorProfiles(c config.DetectorConfig) []detector.Profile {
+	var list []detector.Profile
+	for _, p := range c.Profiles {
+		list = append(list, detector.Profile{
+			Name:     p.Name,
+			Users:    p.Users,
+			Signals:  signalTypes(p.Signals),
+			Disabled: signalTypes(p.Disabled),
+			Tuning:   detectorTuning(p.Thresholds),
+		})
+	}
+	return list
+}
+
+func detectorTuning(t config.DetectorThresholds) detector.Tuning {
+	return detector.Tuning{
+		ScoreWarning:   t.ScoreWarning,
+		ScoreHigh:      t.ScoreHigh,
+		ScoreCritical:  t.ScoreCritical,
+		SequenceLength: t.SequenceLength,
+		TimingMedianMs: int64(t.TimingMedianMs),
+		TimingMaxGapMs: int64(t.TimingMaxGapMs),
+		TimingWindow:   t.TimingWindow,
+	}
+}
+
+func tarpitTiers(c config.TarpitConfig) []tarpit.Tier {
+	ms := func(n int) time.Duration { return time.Duration(n) * time.Millisecond }
+	var tiers []tarpit.Tier
+	for _, t := range c.Tiers {
+		slow := map[string]time.Duration{}
+		for bin, n := range t.SlowBinariesMs {
+			slow[bin] = ms(n)
+		}
+		tiers = append(tiers, tarpit.Tier{
+			Name:            t.Name,
+			BytesPerSecond:  t.BytesPerSecond,
+			CommandDelay:    ms(t.CommandDelayMs),
+			CommandGrowth:   ms(t.CommandGrowthMs),
+			MaxCommandDelay: ms(t.MaxCommandDelayMs),
+			StallChance:     t.StallChance,
+			StallDuration:   ms(t.StallMs),
+			SlowBinaries:    slow,
+		})
+	}
+	return tiers
+}
+
+// ResponsePlaybooks converts the configured playbooks for detector.SetResponse.
+func ResponsePlaybooks(c config.ResponseConfig) map[detector.Confidence][]detector.Step {
+	playbooks := map[detector.Confidence][]detector.Step{}
+	for level, steps := range c.Playbooks {
+		list := []detector.Step{}
+		for _, s := range steps {
+			list = append(list, detector.Step{Action: s.Action, Params: s.Params, Required: s.Required})
+		}
+		playbooks[detector.Confidence(level)] = list
+	}
+	return playbooks
+}
+
+func signalTypes(names []string) []detector.SignalType {
+	var types []detector.SignalType
+	for _, n := range names {
+		types = append(types, detector.SignalType(n))
 	}
+	return types
 }
@@ -2,6 +2,9 @@ package sshserver
 
 import (
 	"GradGuard/internal/Session"
+	"GradGuard/internal/control"
+	"GradGuard/internal/events"
+	"GradGuard/internal/metrics"
 	"GradGuard/internal/shell"
 	"log"
 	"net"
@@ -15,11 +18,25 @@ func handleConn(nConn net.Conn, config *ssh.ServerConfig) {
 		return
 	}
 	defer sshConn.Close()
+	metrics.SessionsActive.Inc()
+	defer metrics.SessionsActive.Dec()
 
 	sessionID := generateSessionId(sshConn)
 	session := Session.NewSession(sessionID, sshConn.RemoteAddr().String())
+	session.User = sshConn.User()
 
 	log.Printf("New Session %s from %s", sessionID, sshConn.RemoteAddr())
+	control.Opened(sessionID, session.RemoteAddr, session.User, string(sshConn.ClientVersion()))
+	defer control.Closed(sessionID)
+	events.Publish(events.Event{
+		Kind:       events.KindSessionStart,
+		SessionID:  sessionID,
+		RemoteAddr: session.RemoteAddr,
+		Data: events.SessionStart{
+			User:   session.User,
+			Client: string(sshConn.ClientVersion()),
+		},
+	})
 
 	go ssh.DiscardRequests(reqs)
 
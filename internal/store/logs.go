@@ -0,0 +1,178 @@
+package store
+
+import (
+	"GradGuard/internal/events"
+	"GradGuard/internal/evidence"
+	"GradGuard/internal/logstore"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// kindOf is the event kind each session log file is made of.
+func kindOf(k logstore.Kind) events.Kind {
+	switch k {
+	case logstore.Commands:
+		return events.KindCommand
+	case logstore.Detections:
+		return events.KindDetection
+	case logstore.Artifacts:
+		return events.KindArtifact
+	}
+	return events.KindSessionEnd
+}
+
+// join rebuilds a log file's contents from its records: one JSON object
+// a line, or only the last report.
+func join(k logstore.Kind, records []Record) []byte {
+	if k == logstore.Reports {
+		return records[len(records)-1].Data
+	}
+	var buf bytes.Buffer
+	for _, r := range records {
+		buf.Write(r.Data)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+// SessionLog returns a session's log of kind k as the file would hold
+// it, from the store when it has the session and from the files if not.
+// A session pruned from the logs is gone from both.
+func SessionLog(k logstore.Kind, id string) ([]byte, error) {
+	if s := Shared(); s != nil && logstore.Has(id) {
+		records, err := s.Find(Query{Kinds: []events.Kind{kindOf(k)}, SessionID: id})
+		if err == nil && len(records) > 0 {
+			return join(k, records), nil
+		}
+	}
+	return logstore.Read(k, id)
+}
+
+// WalkLogs calls fn with every session log of kind k. Once anything of
+// that kind is in the store only the store is read; "honeypot store
+// import" brings in what was logged before it. Sessions log retention
+// has pruned are skipped, as the files would not have them either.
+func WalkLogs(k logstore.Kind, fn func(id string, data []byte)) {
+	s := Shared()
+	if s == nil || s.Count(Query{Kinds: []events.Kind{kindOf(k)}}) == 0 {
+		logstore.Walk(k, fn)
+		return
+	}
+	records, err := s.Find(Query{Kinds: []events.Kind{kindOf(k)}})
+	if err != nil {
+		logstore.Walk(k, fn)
+		return
+	}
+	kept := logstore.Kept()
+	bySession := map[string][]Record{}
+	var order []string
+	for _, r := range records {
+		if r.SessionID == "" || !kept[r.SessionID] {
+			continue
+		}
+		if _, ok := bySession[r.SessionID]; !ok {
+			order = append(order, r.SessionID)
+		}
+		bySession[r.SessionID] = append(bySession[r.SessionID], r)
+	}
+	for _, id := range order {
+		fn(id, join(k, bySession[id]))
+	}
+}
+
+// ImportResult counts what a backfill brought in.
+type ImportResult struct {
+	Sessions int
+	Events   map[events.Kind]int
+	Skipped  int
+}
+
+// Import backfills the store from the session log files, live and
+// archived. A session's file is skipped when the store already holds
+// events of its kind for that session, so importing twice is harmless.
+func (s *Store) Import() (ImportResult, error) {
+	result := ImportResult{Events: map[events.Kind]int{}}
+	sessions := map[string]bool{}
+	var firstErr error
+	for _, k := range []logstore.Kind{logstore.Commands, logstore.Detections, logstore.Artifacts, logstore.Reports} {
+		kind := kindOf(k)
+		logstore.Walk(k, func(id string, data []byte) {
+			if firstErr != nil {
+				return
+			}
+			if s.Count(Query{Kinds: []events.Kind{kind}, SessionID: id}) > 0 {
+				result.Skipped++
+				return
+			}
+			batch := fileEvents(kind, id, data)
+			if len(batch) == 0 {
+				return
+			}
+			if err := s.Append(batch); err != nil {
+				firstErr = err
+				return
+			}
+			sessions[id] = true
+			result.Events[kind] += len(batch)
+		})
+	}
+	result.Sessions = len(sessions)
+	return result, firstErr
+}
+
+// fileEvents turns one session log file back into the events that wrote
+// it, taking each event's time from the record it carries. The hash
+// chain a line was sealed with is the file's, not the event's, so it is
+// left off, as it is from what the bus stores.
+func fileEvents(kind events.Kind, id string, data []byte) []events.Event {
+	var lines [][]byte
+	if kind == events.KindSessionEnd {
+		lines = [][]byte{data}
+	} else {
+		for _, line := range strings.Split(string(data), "\n") {
+			if line = strings.TrimSpace(line); line == "" {
+				continue
+			}
+			content, _, sealed := evidence.Unseal([]byte(line))
+			if !sealed {
+				content = []byte(line)
+			}
+			lines = append(lines, content)
+		}
+	}
+
+	var batch []events.Event
+	for _, line := range lines {
+		var head struct {
+			Timestamp  string `json:"timestamp"`
+			EndTime    string `json:"end_time"`
+			RemoteAddr string `json:"remote_addr"`
+		}
+		if err := json.Unmarshal(line, &head); err != nil {
+			continue
+		}
+		stamp := head.Timestamp
+		if kind == events.KindSessionEnd {
+			stamp = head.EndTime
+		}
+		at, _ := time.Parse(time.RFC3339, stamp)
+		batch = append(batch, events.Event{
+			Kind:       kind,
+			Time:       at.UTC(),
+			SessionID:  id,
+			RemoteAddr: head.RemoteAddr,
+			Data:       json.RawMessage(compact(line)),
+		})
+	}
+	return batch
+}
+
+func compact(data []byte) []byte {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, data); err != nil {
+		return data
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,206 @@
+package store
+
+import (
+	"GradGuard/internal/events"
+	"sort"
+	"time"
+)
+
+// Query picks events by any mix of indexed fields; empty fields match
+// everything. Verdict matches a session_end by its own verdict and any
+// other event by the verdict of its session.
+type Query struct {
+	Kinds     []events.Kind
+	SessionID string
+	IP        string
+	Category  string
+	Rule      string
+	Signal    string
+	Verdict   string
+	Since     time.Time
+	Until     time.Time
+	// Limit keeps the most recent matches; 0 keeps all.
+	Limit int
+}
+
+// Find returns the events matching q, oldest first.
+func (s *Store) Find(q Query) ([]Record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.refresh(); err != nil {
+		return nil, err
+	}
+
+	positions := s.match(q)
+	if q.Limit > 0 && len(positions) > q.Limit {
+		positions = positions[len(positions)-q.Limit:]
+	}
+	records := make([]Record, 0, len(positions))
+	for _, i := range positions {
+		rec, err := s.read(s.entries[i])
+		if err != nil {
+			return records, err
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+// Count is how many events match q, without reading any of them.
+func (s *Store) Count(q Query) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refresh()
+	return len(s.match(q))
+}
+
+// Sessions lists the sessions with events matching q, in the order their
+// first match happened.
+func (s *Store) Sessions(q Query) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refresh()
+	var ids []string
+	seen := map[string]bool{}
+	for _, i := range s.match(q) {
+		id := s.entries[i].Session
+		if id != "" && !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func (s *Store) match(q Query) []int {
+	var verdictSessions map[string]bool
+	if q.Verdict != "" {
+		verdictSessions = map[string]bool{}
+		for _, i := range s.byVerdict[q.Verdict] {
+			verdictSessions[s.entries[i].Session] = true
+		}
+	}
+
+	candidates, indexed := s.candidates(q, verdictSessions)
+	if !indexed {
+		candidates = s.timeRange(q.Since, q.Until)
+	}
+
+	kinds := map[events.Kind]bool{}
+	for _, k := range q.Kinds {
+		kinds[k] = true
+	}
+	since, until := int64(0), int64(0)
+	if !q.Since.IsZero() {
+		since = q.Since.UnixNano()
+	}
+	if !q.Until.IsZero() {
+		until = q.Until.UnixNano()
+	}
+
+	var out []int
+	for _, i := range candidates {
+		e := &s.entries[i]
+		switch {
+		case len(kinds) > 0 && !kinds[e.Kind],
+			q.SessionID != "" && e.Session != q.SessionID,
+			q.IP != "" && e.IP != q.IP,
+			q.Category != "" && e.Category != q.Category,
+			q.Rule != "" && !contains(e.Rules, q.Rule),
+			q.Signal != "" && e.Signal != q.Signal,
+			since != 0 && e.Time < since,
+			until != 0 && e.Time >= until:
+			continue
+		}
+		if verdictSessions != nil {
+			if e.Kind == events.KindSessionEnd && e.Verdict != q.Verdict {
+				continue
+			}
+			if !verdictSessions[e.Session] {
+				continue
+			}
+		}
+		out = append(out, i)
+	}
+	s.sortByTime(out)
+	return out
+}
+
+// candidates is the shortest index list the query can start from; the
+// second result is false when no indexed field was given.
+func (s *Store) candidates(q Query, verdictSessions map[string]bool) ([]int, bool) {
+	var lists [][]int
+	if q.SessionID != "" {
+		lists = append(lists, s.bySession[q.SessionID])
+	}
+	if q.IP != "" {
+		lists = append(lists, s.byIP[q.IP])
+	}
+	if q.Category != "" {
+		lists = append(lists, s.byCategory[q.Category])
+	}
+	if q.Rule != "" {
+		lists = append(lists, s.byRule[q.Rule])
+	}
+	if q.Signal != "" {
+		lists = append(lists, s.bySignal[q.Signal])
+	}
+	if len(q.Kinds) > 0 {
+		var byKind []int
+		for _, k := range q.Kinds {
+			byKind = append(byKind, s.byKind[k]...)
+		}
+		lists = append(lists, byKind)
+	}
+	if verdictSessions != nil {
+		var bySession []int
+		for id := range verdictSessions {
+			bySession = append(bySession, s.bySession[id]...)
+		}
+		lists = append(lists, bySession)
+	}
+	if len(lists) == 0 {
+		return nil, false
+	}
+	best := lists[0]
+	for _, l := range lists[1:] {
+		if len(l) < len(best) {
+			best = l
+		}
+	}
+	return best, true
+}
+
+// timeRange is every position in [since, until), found by binary search
+// on the time index.
+func (s *Store) timeRange(since, until time.Time) []int {
+	if s.byTime == nil {
+		s.byTime = make([]int, len(s.entries))
+		for i := range s.byTime {
+			s.byTime[i] = i
+		}
+		s.sortByTime(s.byTime)
+	}
+	lo, hi := 0, len(s.byTime)
+	if !since.IsZero() {
+		t := since.UnixNano()
+		lo = sort.Search(len(s.byTime), func(i int) bool { return s.entries[s.byTime[i]].Time >= t })
+	}
+	if !until.IsZero() {
+		t := until.UnixNano()
+		hi = sort.Search(len(s.byTime), func(i int) bool { return s.entries[s.byTime[i]].Time >= t })
+	}
+	if lo > hi {
+		return nil
+	}
+	return append([]int(nil), s.byTime[lo:hi]...)
+}
+
+func contains(list []string, v string) bool {
+	for _, x := range list {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
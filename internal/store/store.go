@@ -0,0 +1,368 @@
+// Package store keeps every event in one append-only file with indexes
+// on session, IP, time, category, rule, signal and verdict, so questions
+// about many sessions are answered without opening a file per session.
+//
+// events.jsonl holds one event a line, exactly as the bus published it.
+// index.gob is a snapshot of the index up to some offset; whatever was
+// appended after it, by this process or another, is indexed on the next
+// read.
+package store
+
+import (
+	"GradGuard/internal/events"
+	"GradGuard/internal/logstore"
+	"bufio"
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Dir is where the process store lives, beside the session logs. Log
+// retention leaves its file alone, but the records of a session whose
+// files it has removed are no longer read back; see SessionLog and
+// WalkLogs.
+var Dir = filepath.Join(logstore.Root, "store")
+
+const (
+	dataFile  = "events.jsonl"
+	indexFile = "index.gob"
+	// snapshotEvery is how many records may be indexed past the snapshot
+	// before it is written again.
+	snapshotEvery = 4096
+	indexVersion  = 1
+)
+
+// Record is one stored event with its payload left as JSON.
+type Record struct {
+	Kind       events.Kind     `json:"kind"`
+	Time       time.Time       `json:"time"`
+	SessionID  string          `json:"session_id,omitempty"`
+	RemoteAddr string          `json:"remote_addr,omitempty"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// entry is what the index knows of a record without reading it.
+type entry struct {
+	Offset   int64
+	Length   int32
+	Kind     events.Kind
+	Time     int64
+	Session  string
+	IP       string
+	Category string
+	Rules    []string
+	Signal   string
+	Verdict  string
+}
+
+type snapshot struct {
+	Version int
+	Covered int64
+	Entries []entry
+}
+
+type Store struct {
+	mu      sync.Mutex
+	dir     string
+	w       *os.File
+	r       *os.File
+	covered int64
+	entries []entry
+	unsaved int
+
+	bySession  map[string][]int
+	byIP       map[string][]int
+	byKind     map[events.Kind][]int
+	byCategory map[string][]int
+	byRule     map[string][]int
+	bySignal   map[string][]int
+	byVerdict  map[string][]int
+	byTime     []int
+}
+
+// Open opens the store in dir, creating it if need be, and indexes
+// anything appended since the last snapshot.
+func Open(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(dir, dataFile)
+	w, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	r, err := os.Open(path)
+	if err != nil {
+		w.Close()
+		return nil, err
+	}
+	s := &Store{dir: dir, w: w, r: r}
+	s.reset()
+	s.loadSnapshot()
+	if err := s.refresh(); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Store) reset() {
+	s.covered, s.entries = 0, nil
+	s.bySession = map[string][]int{}
+	s.byIP = map[string][]int{}
+	s.byKind = map[events.Kind][]int{}
+	s.byCategory = map[string][]int{}
+	s.byRule = map[string][]int{}
+	s.bySignal = map[string][]int{}
+	s.byVerdict = map[string][]int{}
+	s.byTime = nil
+}
+
+func (s *Store) loadSnapshot() {
+	f, err := os.Open(filepath.Join(s.dir, indexFile))
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	var snap snapshot
+	if err := gob.NewDecoder(bufio.NewReader(f)).Decode(&snap); err != nil || snap.Version != indexVersion {
+		return
+	}
+	info, err := s.r.Stat()
+	if err != nil || info.Size() < snap.Covered {
+		// The data file is shorter than the index says; start over.
+		return
+	}
+	for _, e := range snap.Entries {
+		s.add(e)
+	}
+	s.covered = snap.Covered
+}
+
+func (s *Store) saveSnapshot() error {
+	var buf bytes.Buffer
+	snap := snapshot{Version: indexVersion, Covered: s.covered, Entries: s.entries}
+	if err := gob.NewEncoder(&buf).Encode(snap); err != nil {
+		return err
+	}
+	path := filepath.Join(s.dir, indexFile)
+	tmp := fmt.Sprintf("%s.%d.tmp", path, os.Getpid())
+	if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	s.unsaved = 0
+	return nil
+}
+
+// refresh indexes the complete lines appended past what is covered.
+func (s *Store) refresh() error {
+	info, err := s.r.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() < s.covered {
+		s.reset()
+	}
+	if info.Size() == s.covered {
+		return nil
+	}
+
+	reader := bufio.NewReaderSize(io.NewSectionReader(s.r, s.covered, info.Size()-s.covered), 1<<16)
+	offset := s.covered
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			// A line without its newline is still being written.
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if e, ok := indexLine(line); ok {
+			e.Offset, e.Length = offset, int32(len(line))
+			s.add(e)
+			s.unsaved++
+		}
+		offset += int64(len(line))
+	}
+	s.covered = offset
+	if s.unsaved >= snapshotEvery {
+		return s.saveSnapshot()
+	}
+	return nil
+}
+
+// indexLine pulls the indexed fields out of one stored line.
+func indexLine(line []byte) (entry, bool) {
+	var rec struct {
+		Kind       events.Kind `json:"kind"`
+		Time       time.Time   `json:"time"`
+		SessionID  string      `json:"session_id"`
+		RemoteAddr string      `json:"remote_addr"`
+		Data       struct {
+			Category string   `json:"category"`
+			Rules    []string `json:"rules"`
+			Signal   string   `json:"signal"`
+			Verdict  string   `json:"verdict"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(line, &rec); err != nil || rec.Kind == "" {
+		return entry{}, false
+	}
+	return entry{
+		Kind:     rec.Kind,
+		Time:     rec.Time.UnixNano(),
+		Session:  rec.SessionID,
+		IP:       hostOf(rec.RemoteAddr),
+		Category: rec.Data.Category,
+		Rules:    rec.Data.Rules,
+		Signal:   rec.Data.Signal,
+		Verdict:  rec.Data.Verdict,
+	}, true
+}
+
+func hostOf(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+func (s *Store) add(e entry) {
+	i := len(s.entries)
+	s.entries = append(s.entries, e)
+	if e.Session != "" {
+		s.bySession[e.Session] = append(s.bySession[e.Session], i)
+	}
+	if e.IP != "" {
+		s.byIP[e.IP] = append(s.byIP[e.IP], i)
+	}
+	s.byKind[e.Kind] = append(s.byKind[e.Kind], i)
+	if e.Category != "" {
+		s.byCategory[e.Category] = append(s.byCategory[e.Category], i)
+	}
+	for _, r := range e.Rules {
+		s.byRule[r] = append(s.byRule[r], i)
+	}
+	if e.Signal != "" {
+		s.bySignal[e.Signal] = append(s.bySignal[e.Signal], i)
+	}
+	if e.Verdict != "" {
+		s.byVerdict[e.Verdict] = append(s.byVerdict[e.Verdict], i)
+	}
+	s.byTime = nil
+}
+
+// Append stores a batch of events.
+func (s *Store) Append(batch []events.Event) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, e := range batch {
+		if err := enc.Encode(e); err != nil {
+			return fmt.Errorf("%s event: %w", e.Kind, err)
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// One write, so lines from another process appending at the same
+	// time never interleave with these.
+	if _, err := s.w.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	return s.refresh()
+}
+
+// Len is how many events the store holds.
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refresh()
+	return len(s.entries)
+}
+
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var errs []error
+	if s.unsaved > 0 {
+		errs = append(errs, s.saveSnapshot())
+	}
+	errs = append(errs, s.w.Close(), s.r.Close())
+	return errors.Join(errs...)
+}
+
+// Name, Write and Close make the store a sink of the event bus.
+func (s *Store) Name() string { return "store:" + s.dir }
+
+func (s *Store) Write(batch []events.Event) error { return s.Append(batch) }
+
+func (s *Store) read(e entry) (Record, error) {
+	buf := make([]byte, e.Length)
+	if _, err := s.r.ReadAt(buf, e.Offset); err != nil {
+		return Record{}, err
+	}
+	var rec Record
+	err := json.Unmarshal(buf, &rec)
+	return rec, err
+}
+
+var (
+	sharedMu sync.Mutex
+	shared   *Store
+)
+
+// Shared is the process store, opened on first use. It is nil if the
+// store has never been written, so callers fall back to the log files.
+func Shared() *Store {
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+	if shared != nil {
+		return shared
+	}
+	if _, err := os.Stat(filepath.Join(Dir, dataFile)); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	s, err := Open(Dir)
+	if err != nil {
+		return nil
+	}
+	shared = s
+	return s
+}
+
+// OpenShared opens the process store for writing, creating it if need
+// be, and makes it the one Shared returns.
+func OpenShared() (*Store, error) {
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+	if shared != nil {
+		return shared, nil
+	}
+	s, err := Open(Dir)
+	if err != nil {
+		return nil, err
+	}
+	shared = s
+	return s, nil
+}
+
+// sortByTime orders positions by event time, keeping store order for
+// equal times.
+func (s *Store) sortByTime(positions []int) {
+	sort.SliceStable(positions, func(i, j int) bool {
+		return s.entries[positions[i]].Time < s.entries[positions[j]].Time
+	})
+}
@@ -0,0 +1,115 @@
+package tarpit
+
+import (
+	"io"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// throttleTick is how often throttled output is let through.
+const throttleTick = 50 * time.Millisecond
+
+// Output wraps what the session sees, throttling it and stalling it as
+// the current tier says. Make one for the session and share it between
+// stdout and stderr: writes take turns, so both together get the tier's
+// rate.
+func (t *Tarpit) Output(w io.Writer) io.Writer {
+	return &output{w: w, t: t}
+}
+
+type output struct {
+	w  io.Writer
+	t  *Tarpit
+	mu sync.Mutex
+}
+
+func (o *output) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	tier := o.t.current()
+	if tier == nil {
+		return o.w.Write(p)
+	}
+	o.t.sleep(o.t.stall())
+	if tier.BytesPerSecond == 0 {
+		return o.w.Write(p)
+	}
+
+	chunk := tier.BytesPerSecond * int(throttleTick) / int(time.Second)
+	if chunk < 1 {
+		chunk = 1
+	}
+	pause := time.Duration(chunk) * time.Second / time.Duration(tier.BytesPerSecond)
+	written := 0
+	for written < len(p) {
+		end := min(written+chunk, len(p))
+		n, err := o.w.Write(p[written:end])
+		written += n
+		if err != nil {
+			return written, err
+		}
+		if written < len(p) {
+			o.t.sleep(pause)
+		}
+	}
+	return written, nil
+}
+
+// Input wraps what the session types, holding each command back before
+// the shell sees its Enter.
+func (t *Tarpit) Input(r io.Reader) io.Reader {
+	return &input{r: r, t: t}
+}
+
+type input struct {
+	r      io.Reader
+	t      *Tarpit
+	line   []byte
+	escape bool
+}
+
+func (in *input) Read(p []byte) (int, error) {
+	n, err := in.r.Read(p)
+	var delay time.Duration
+	for _, b := range p[:n] {
+		// Arrow and function keys edit the line in ways not followed
+		// here; skip them rather than take their bytes as typed.
+		if in.escape {
+			in.escape = b == '[' || b == 'O' || (b >= '0' && b <= '9') || b == ';'
+			continue
+		}
+		switch b {
+		case 0x1b:
+			in.escape = true
+		case '\r', '\n':
+			if cmd := strings.TrimSpace(string(in.line)); cmd != "" {
+				delay += in.t.commandDelay(binary(cmd))
+			}
+			in.line = in.line[:0]
+		case 0x7f, 0x08:
+			if len(in.line) > 0 {
+				in.line = in.line[:len(in.line)-1]
+			}
+		case 0x03, 0x15:
+			in.line = in.line[:0]
+		default:
+			if b >= 0x20 {
+				in.line = append(in.line, b)
+			}
+		}
+	}
+	in.t.sleep(delay)
+	return n, err
+}
+
+// binary is the program a command line runs, looking past sudo and
+// env-style wrappers and any directory.
+func binary(cmd string) string {
+	fields := strings.Fields(cmd)
+	for len(fields) > 1 && (fields[0] == "sudo" || fields[0] == "env" || fields[0] == "nohup" || strings.Contains(fields[0], "=")) {
+		fields = fields[1:]
+	}
+	return path.Base(fields[0])
+}
@@ -0,0 +1,211 @@
+package tarpit
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Tier is one step of slowing a session down at the SSH channel. Output
+// is held to BytesPerSecond; each command waits CommandDelay plus
+// CommandGrowth for every command since the tier began, up to
+// MaxCommandDelay, and longer still for SlowBinaries; and any write may
+// stall for StallDuration, as if the disk were busy. Zero turns a knob off.
+type Tier struct {
+	Name            string
+	BytesPerSecond  int
+	CommandDelay    time.Duration
+	CommandGrowth   time.Duration
+	MaxCommandDelay time.Duration
+	StallChance     float64
+	StallDuration   time.Duration
+	SlowBinaries    map[string]time.Duration
+}
+
+// DefaultTiers go from barely noticeable to barely usable.
+func DefaultTiers() []Tier {
+	return []Tier{
+		{
+			Name:            "slow",
+			BytesPerSecond:  4800,
+			CommandDelay:    300 * time.Millisecond,
+			CommandGrowth:   200 * time.Millisecond,
+			MaxCommandDelay: 3 * time.Second,
+		},
+		{
+			Name:            "sticky",
+			BytesPerSecond:  960,
+			CommandDelay:    time.Second,
+			CommandGrowth:   500 * time.Millisecond,
+			MaxCommandDelay: 10 * time.Second,
+			StallChance:     0.05,
+			StallDuration:   3 * time.Second,
+			SlowBinaries: map[string]time.Duration{
+				"wget": 5 * time.Second, "curl": 5 * time.Second,
+				"nmap": 10 * time.Second, "masscan": 10 * time.Second,
+				"gcc": 8 * time.Second, "make": 8 * time.Second,
+			},
+		},
+		{
+			Name:            "tar",
+			BytesPerSecond:  120,
+			CommandDelay:    3 * time.Second,
+			CommandGrowth:   2 * time.Second,
+			MaxCommandDelay: 30 * time.Second,
+			StallChance:     0.2,
+			StallDuration:   8 * time.Second,
+			SlowBinaries: map[string]time.Duration{
+				"wget": 20 * time.Second, "curl": 20 * time.Second,
+				"nmap": 30 * time.Second, "masscan": 30 * time.Second,
+				"gcc": 20 * time.Second, "make": 20 * time.Second,
+				"tar": 15 * time.Second, "find": 15 * time.Second,
+			},
+		},
+	}
+}
+
+var (
+	tiersMu sync.RWMutex
+	tiers   = DefaultTiers()
+)
+
+// SetTiers replaces the escalation tiers, in escalation order.
+func SetTiers(list []Tier) error {
+	seen := map[string]bool{}
+	for _, t := range list {
+		if t.Name == "" {
+			return fmt.Errorf("tarpit tier without a name")
+		}
+		if seen[t.Name] {
+			return fmt.Errorf("tarpit tier %s listed twice", t.Name)
+		}
+		seen[t.Name] = true
+		if t.BytesPerSecond < 0 || t.StallChance < 0 || t.StallChance > 1 {
+			return fmt.Errorf("tarpit tier %s: bytes per second must not be negative and stall chance must be between 0 and 1", t.Name)
+		}
+	}
+	tiersMu.Lock()
+	tiers = list
+	tiersMu.Unlock()
+	return nil
+}
+
+// lookup returns a tier and its place in the escalation order.
+func lookup(name string) (Tier, int, bool) {
+	tiersMu.RLock()
+	defer tiersMu.RUnlock()
+	for i, t := range tiers {
+		if t.Name == name {
+			return t, i, true
+		}
+	}
+	return Tier{}, -1, false
+}
+
+// Tarpit is one session's slowdown. It starts off; Escalate turns it on.
+type Tarpit struct {
+	mu       sync.Mutex
+	tier     *Tier
+	rank     int
+	commands int
+	wasted   time.Duration
+	rng      *rand.Rand
+}
+
+var (
+	sessionsMu sync.Mutex
+	sessions   = map[string]*Tarpit{}
+)
+
+// For returns the session's tarpit, making it on first use.
+func For(sessionID string) *Tarpit {
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+	t, ok := sessions[sessionID]
+	if !ok {
+		t = &Tarpit{rank: -1, rng: rand.New(rand.NewSource(time.Now().UnixNano()))}
+		sessions[sessionID] = t
+	}
+	return t
+}
+
+// Remove forgets a session's tarpit once the session is over.
+func Remove(sessionID string) {
+	sessionsMu.Lock()
+	delete(sessions, sessionID)
+	sessionsMu.Unlock()
+}
+
+// Escalate moves the session to the named tier. A tarpit only gets
+// slower: a tier below the current one changes nothing.
+func (t *Tarpit) Escalate(name string) error {
+	tier, rank, ok := lookup(name)
+	if !ok {
+		return fmt.Errorf("no tarpit tier %q", name)
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if rank > t.rank {
+		t.tier, t.rank, t.commands = &tier, rank, 0
+	}
+	return nil
+}
+
+// Tier names the current tier, empty while the tarpit is off.
+func (t *Tarpit) Tier() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.tier == nil {
+		return ""
+	}
+	return t.tier.Name
+}
+
+// Wasted is how long the tarpit has held the session up so far.
+func (t *Tarpit) Wasted() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.wasted
+}
+
+func (t *Tarpit) current() *Tier {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.tier
+}
+
+// commandDelay is how long the command starting with binary waits, and
+// counts it toward the growth.
+func (t *Tarpit) commandDelay(binary string) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.tier == nil {
+		return 0
+	}
+	d := t.tier.CommandDelay + time.Duration(t.commands)*t.tier.CommandGrowth
+	if t.tier.MaxCommandDelay > 0 && d > t.tier.MaxCommandDelay {
+		d = t.tier.MaxCommandDelay
+	}
+	t.commands++
+	return d + t.tier.SlowBinaries[binary]
+}
+
+func (t *Tarpit) stall() time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.tier == nil || t.tier.StallChance == 0 || t.rng.Float64() >= t.tier.StallChance {
+		return 0
+	}
+	return t.tier.StallDuration
+}
+
+func (t *Tarpit) sleep(d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	time.Sleep(d)
+	t.mu.Lock()
+	t.wasted += d
+	t.mu.Unlock()
+}